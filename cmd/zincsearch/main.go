@@ -66,6 +66,8 @@ func main() {
 	sentries()
 	// Continuous profiling
 	profiling()
+	// Index lifecycle management
+	core.StartILM()
 
 	// HTTP init
 	app := gin.New()
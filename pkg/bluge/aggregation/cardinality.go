@@ -0,0 +1,96 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+const (
+	// DefaultCardinalityPrecisionThreshold is the default count below which cardinality aggregation counts exactly
+	DefaultCardinalityPrecisionThreshold = 3000
+	// MaxCardinalityPrecisionThreshold is the maximum precision threshold of cardinality aggregation
+	MaxCardinalityPrecisionThreshold = 40000
+)
+
+// CardinalityAggregation counts the distinct values of a field by a HyperLogLogPlusPlus sketch,
+// the numeric values are indexed with the shifted terms so they are counted by the decoded numbers.
+type CardinalityAggregation struct {
+	src                search.FieldSource
+	numeric            bool
+	precisionThreshold int
+}
+
+func NewCardinalityAggregation(field search.FieldSource, numeric bool) *CardinalityAggregation {
+	return &CardinalityAggregation{src: field, numeric: numeric, precisionThreshold: DefaultCardinalityPrecisionThreshold}
+}
+
+// SetPrecisionThreshold sets the count below which the values are counted exactly,
+// the memory of the sketch is about 8 bytes of each value below the threshold.
+func (t *CardinalityAggregation) SetPrecisionThreshold(precisionThreshold int) *CardinalityAggregation {
+	if precisionThreshold > MaxCardinalityPrecisionThreshold {
+		precisionThreshold = MaxCardinalityPrecisionThreshold
+	}
+	t.precisionThreshold = precisionThreshold
+	return t
+}
+
+func (t *CardinalityAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *CardinalityAggregation) Calculator() search.Calculator {
+	return &CardinalityCalculator{
+		src:     t.src,
+		numeric: t.numeric,
+		sketch:  NewHyperLogLogPlusPlus(t.precisionThreshold),
+	}
+}
+
+type CardinalityCalculator struct {
+	src     search.FieldSource
+	numeric bool
+	sketch  *HyperLogLogPlusPlus
+	buf     [8]byte
+}
+
+func (a *CardinalityCalculator) Consume(d *search.DocumentMatch) {
+	if a.numeric {
+		for _, v := range a.src.Numbers(d) {
+			binary.BigEndian.PutUint64(a.buf[:], math.Float64bits(v))
+			a.sketch.AddBytes(a.buf[:])
+		}
+		return
+	}
+	for _, v := range a.src.Values(d) {
+		a.sketch.AddBytes(v)
+	}
+}
+
+func (a *CardinalityCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*CardinalityCalculator); ok {
+		a.sketch.Merge(other.sketch)
+	}
+}
+
+func (a *CardinalityCalculator) Finish() {}
+
+func (a *CardinalityCalculator) Value() float64 {
+	return float64(a.sketch.Cardinality())
+}
@@ -0,0 +1,368 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// CompositeSource is a value source of composite aggregation,
+// the values of a terms source are strings, float64 or bool by valueType,
+// the values of a histogram source are float64 rounded down to Interval,
+// the values of a date_histogram source are epoch milliseconds rounded down to the Date interval.
+type CompositeSource struct {
+	Name          string
+	Field         search.FieldSource
+	ValueType     int
+	Interval      float64
+	Date          *CompositeDateInterval
+	Desc          bool
+	MissingBucket bool // the documents which have no value are in the buckets with a nil value
+}
+
+// CompositeDateInterval is the interval of a date_histogram source,
+// FixedInterval is used if CalendarInterval is empty, unit: time.Nanosecond
+type CompositeDateInterval struct {
+	CalendarInterval string
+	FixedInterval    int64
+	TimeZone         *time.Location
+}
+
+type CompositeAggregation struct {
+	sources []*CompositeSource
+	size    int
+	after   []interface{}
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewCompositeAggregation returns a composite aggregation,
+// the buckets are the combinations of the values of the sources ordered by the sources.
+func NewCompositeAggregation(sources []*CompositeSource, size int) *CompositeAggregation {
+	rv := &CompositeAggregation{
+		sources:      sources,
+		size:         size,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// SetAfter sets the key after which the buckets are returned, the values are in the order of the sources
+func (t *CompositeAggregation) SetAfter(after []interface{}) *CompositeAggregation {
+	t.after = after
+	return t
+}
+
+func (t *CompositeAggregation) Fields() []string {
+	var rv []string
+	for _, src := range t.sources {
+		rv = append(rv, src.Field.Fields()...)
+	}
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *CompositeAggregation) Calculator() search.Calculator {
+	return &CompositeCalculator{
+		sources:      t.sources,
+		size:         t.size,
+		after:        t.after,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*compositeBucket),
+	}
+}
+
+func (t *CompositeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type compositeBucket struct {
+	key    []interface{}
+	bucket *search.Bucket
+}
+
+// CompositeCalculator keeps only the first size buckets in the order of the keys,
+// so the result of merged shards is the same as the result of a single shard.
+type CompositeCalculator struct {
+	sources []*CompositeSource
+	size    int
+	after   []interface{}
+	upper   []interface{} // the keys after upper can't be in the first size buckets
+
+	aggregations map[string]search.Aggregation
+
+	bucketsList []*compositeBucket
+	bucketsMap  map[string]*compositeBucket
+}
+
+func (a *CompositeCalculator) Consume(d *search.DocumentMatch) {
+	keys := [][]interface{}{nil}
+	for _, src := range a.sources {
+		values := compositeValues(src, d)
+		if len(values) == 0 {
+			if !src.MissingBucket {
+				return
+			}
+			values = []interface{}{nil}
+		}
+		combined := make([][]interface{}, 0, len(keys)*len(values))
+		for _, key := range keys {
+			for _, value := range values {
+				combined = append(combined, append(key[:len(key):len(key)], value))
+			}
+		}
+		keys = combined
+	}
+
+	for _, key := range keys {
+		if a.after != nil && a.compare(key, a.after) <= 0 {
+			continue
+		}
+		if a.upper != nil && a.compare(key, a.upper) > 0 {
+			continue
+		}
+		name := compositeKeyName(key)
+		b, ok := a.bucketsMap[name]
+		if !ok {
+			b = &compositeBucket{key: key, bucket: search.NewBucket(name, a.aggregations)}
+			a.bucketsMap[name] = b
+			a.bucketsList = append(a.bucketsList, b)
+		}
+		b.bucket.Consume(d)
+	}
+
+	// bound the buckets kept by the calculator
+	if len(a.bucketsList) > 2*a.size {
+		a.trim()
+	}
+}
+
+// compositeValues returns the values of the source of the document
+func compositeValues(src *CompositeSource, d *search.DocumentMatch) []interface{} {
+	var values []interface{}
+	switch {
+	case src.Date != nil:
+		for _, t := range src.Field.Dates(d) {
+			nsec := roundDate(t.UnixNano(), src.Date.CalendarInterval, src.Date.FixedInterval, src.Date.TimeZone)
+			values = append(values, time.Unix(0, nsec).UnixMilli())
+		}
+	case src.Interval > 0:
+		for _, n := range src.Field.Numbers(d) {
+			values = append(values, math.Floor(n/src.Interval)*src.Interval)
+		}
+	default:
+		switch src.ValueType {
+		case TextValueSource, TextValuesSource:
+			for _, term := range src.Field.Values(d) {
+				values = append(values, string(term))
+			}
+		case NumericValueSource, NumericValuesSource:
+			for _, n := range src.Field.Numbers(d) {
+				values = append(values, n)
+			}
+		case BooleanValueSource, BooleanValuesSource:
+			for _, n := range src.Field.Numbers(d) {
+				values = append(values, n != 0)
+			}
+		}
+	}
+
+	// dedupe the values, a document is counted once in a bucket
+	rv := values[:0]
+	for _, v := range values {
+		exists := false
+		for _, e := range rv {
+			if e == v {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			rv = append(rv, v)
+		}
+	}
+	return rv
+}
+
+// compositeKeyName encodes the key to the name of the bucket
+func compositeKeyName(key []interface{}) string {
+	var sb strings.Builder
+	for i, v := range key {
+		if i > 0 {
+			sb.WriteString(MultiTermsSeparator)
+		}
+		switch v := v.(type) {
+		case nil:
+			sb.WriteString("n")
+		case string:
+			sb.WriteString("s")
+			sb.WriteString(v)
+		case float64:
+			sb.WriteString("f")
+			sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case int64:
+			sb.WriteString("i")
+			sb.WriteString(strconv.FormatInt(v, 10))
+		case bool:
+			sb.WriteString("b")
+			sb.WriteString(strconv.FormatBool(v))
+		}
+	}
+	return sb.String()
+}
+
+// compare compares the keys in the orders of the sources
+func (a *CompositeCalculator) compare(x, y []interface{}) int {
+	for i, src := range a.sources {
+		c := CompareCompositeValue(x[i], y[i])
+		if src.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// CompareCompositeValue compares the values of a composite source in ascending order, nil is the smallest
+func CompareCompositeValue(x, y interface{}) int {
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return -1
+	case y == nil:
+		return 1
+	}
+	switch x := x.(type) {
+	case string:
+		return strings.Compare(x, y.(string))
+	case float64:
+		return compareFloat(x, y.(float64))
+	case int64:
+		return compareFloat(float64(x), float64(y.(int64)))
+	case bool:
+		y := y.(bool)
+		switch {
+		case x == y:
+			return 0
+		case y:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// trim sorts the buckets and keeps the first size buckets
+func (a *CompositeCalculator) trim() {
+	sort.Sort(a)
+	if len(a.bucketsList) <= a.size {
+		return
+	}
+	for _, b := range a.bucketsList[a.size:] {
+		delete(a.bucketsMap, b.bucket.Name())
+	}
+	a.bucketsList = a.bucketsList[:a.size]
+	if a.size > 0 {
+		a.upper = a.bucketsList[a.size-1].key
+	}
+}
+
+func (a *CompositeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*CompositeCalculator); ok {
+		for _, b := range other.bucketsList {
+			if local, ok := a.bucketsMap[b.bucket.Name()]; ok {
+				local.bucket.Merge(b.bucket)
+			} else {
+				a.bucketsMap[b.bucket.Name()] = b
+				a.bucketsList = append(a.bucketsList, b)
+			}
+		}
+		a.trim()
+	}
+}
+
+func (a *CompositeCalculator) Finish() {
+	a.trim()
+	for _, b := range a.bucketsList {
+		b.bucket.Finish()
+	}
+}
+
+func (a *CompositeCalculator) Buckets() []*search.Bucket {
+	rv := make([]*search.Bucket, 0, len(a.bucketsList))
+	for _, b := range a.bucketsList {
+		rv = append(rv, b.bucket)
+	}
+	return rv
+}
+
+// Keys returns the keys of the buckets, the values are in the order of the sources
+func (a *CompositeCalculator) Keys() [][]interface{} {
+	rv := make([][]interface{}, 0, len(a.bucketsList))
+	for _, b := range a.bucketsList {
+		rv = append(rv, b.key)
+	}
+	return rv
+}
+
+// AfterKey returns the key of the last bucket, it is nil if there are no buckets
+func (a *CompositeCalculator) AfterKey() []interface{} {
+	if len(a.bucketsList) == 0 {
+		return nil
+	}
+	return a.bucketsList[len(a.bucketsList)-1].key
+}
+
+func (a *CompositeCalculator) Sources() []*CompositeSource {
+	return a.sources
+}
+
+func (a *CompositeCalculator) Len() int {
+	return len(a.bucketsList)
+}
+
+func (a *CompositeCalculator) Less(i, j int) bool {
+	return a.compare(a.bucketsList[i].key, a.bucketsList[j].key) < 0
+}
+
+func (a *CompositeCalculator) Swap(i, j int) {
+	a.bucketsList[i], a.bucketsList[j] = a.bucketsList[j], a.bucketsList[i]
+}
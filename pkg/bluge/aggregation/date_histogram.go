@@ -164,6 +164,12 @@ func (a *DateHistogramCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*DateHistogramCalculator); ok {
 		// first sum to the totals and others
 		a.total += other.total
+		if other.minValue < a.minValue {
+			a.minValue = other.minValue
+		}
+		if other.maxValue > a.maxValue {
+			a.maxValue = other.maxValue
+		}
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
 		for i := range other.bucketsList {
@@ -175,6 +181,7 @@ func (a *DateHistogramCalculator) Merge(other search.Calculator) {
 				}
 			}
 			if !foundLocal {
+				a.bucketsMap[other.bucketsList[i].Name()] = other.bucketsList[i]
 				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
 			}
 		}
@@ -206,7 +213,9 @@ func (a *DateHistogramCalculator) Finish() {
 			for value := a.minValue; value < a.maxValue; {
 				termStr := a.bucketKey(value)
 				if _, ok := a.bucketsMap[termStr]; !ok {
-					a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+					newBucket := search.NewBucket(termStr, a.aggregations)
+					a.bucketsMap[termStr] = newBucket
+					a.bucketsList = append(a.bucketsList, newBucket)
 				}
 				t := time.Unix(0, value).In(a.timeZone)
 				switch a.calendarInterval {
@@ -227,7 +236,9 @@ func (a *DateHistogramCalculator) Finish() {
 			for value := a.minValue; value < a.maxValue; value += a.fixedInterval {
 				termStr := a.bucketKey(value)
 				if _, ok := a.bucketsMap[termStr]; !ok {
-					a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+					newBucket := search.NewBucket(termStr, a.aggregations)
+					a.bucketsMap[termStr] = newBucket
+					a.bucketsList = append(a.bucketsList, newBucket)
 				}
 			}
 		}
@@ -286,38 +297,41 @@ func (a *DateHistogramCalculator) Swap(i, j int) {
 }
 
 func (a *DateHistogramCalculator) bucketKey(value int64) string {
-	var nsec int64
-	if a.calendarInterval != "" {
-		t := time.Unix(0, value).In(a.timeZone)
-		switch a.calendarInterval {
-		case "week", "1w":
-			t = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
-		case "month", "1M":
-			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
-		case "quarter", "1q":
-			switch t.Month() {
-			case 1, 2, 3:
-				t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
-			case 4, 5, 6:
-				t = time.Date(t.Year(), 4, 1, 0, 0, 0, 0, t.Location())
-			case 7, 8, 9:
-				t = time.Date(t.Year(), 7, 1, 0, 0, 0, 0, t.Location())
-			case 10, 11, 12:
-				t = time.Date(t.Year(), 10, 1, 0, 0, 0, 0, t.Location())
-			}
-		case "year", "1y":
-			t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
-		default:
-			// noop
-		}
-		nsec = t.UnixNano()
-	} else {
-		nsec = (value / a.fixedInterval) * a.fixedInterval
-	}
-
+	nsec := roundDate(value, a.calendarInterval, a.fixedInterval, a.timeZone)
 	if a.format == "epoch_millis" {
 		return strconv.FormatInt(time.Unix(0, nsec).In(a.timeZone).UnixMilli(), 10)
 	}
 
 	return time.Unix(0, nsec).In(a.timeZone).Format(a.format)
 }
+
+// roundDate rounds down the value in nanoseconds to the calendar interval in the time zone,
+// or to the fixed interval if calendar interval is empty
+func roundDate(value int64, calendarInterval string, fixedInterval int64, timeZone *time.Location) int64 {
+	if calendarInterval == "" {
+		return (value / fixedInterval) * fixedInterval
+	}
+	t := time.Unix(0, value).In(timeZone)
+	switch calendarInterval {
+	case "week", "1w":
+		t = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case "month", "1M":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case "quarter", "1q":
+		switch t.Month() {
+		case 1, 2, 3:
+			t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		case 4, 5, 6:
+			t = time.Date(t.Year(), 4, 1, 0, 0, 0, 0, t.Location())
+		case 7, 8, 9:
+			t = time.Date(t.Year(), 7, 1, 0, 0, 0, 0, t.Location())
+		case 10, 11, 12:
+			t = time.Date(t.Year(), 10, 1, 0, 0, 0, 0, t.Location())
+		}
+	case "year", "1y":
+		t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		// noop
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,427 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/blugelabs/bluge/search/similarity"
+)
+
+// DefaultOtherBucketKey is the key of the bucket of the documents not matching any filter of filters aggregation
+const DefaultOtherBucketKey = "_other_"
+
+// searcherOptions are the options of the searchers of the aggregations, the scores are not used
+var searcherOptions = search.SearcherOptions{
+	SimilarityForField: func(field string) search.Similarity {
+		return similarity.NewBM25Similarity()
+	},
+	Score: "none",
+}
+
+// queryDocuments finds the documents matching a query on the reader,
+// the documents are searched once and shared by all the calculators of an aggregation.
+type queryDocuments struct {
+	query  bluge.Query
+	reader search.Reader
+	once   sync.Once
+	docs   map[uint64]struct{}
+}
+
+func newQueryDocuments(query bluge.Query, reader search.Reader) *queryDocuments {
+	return &queryDocuments{query: query, reader: reader}
+}
+
+func (q *queryDocuments) Contains(number uint64) bool {
+	q.once.Do(q.search)
+	_, ok := q.docs[number]
+	return ok
+}
+
+func (q *queryDocuments) search() {
+	q.docs = make(map[uint64]struct{})
+	if q.reader == nil {
+		return
+	}
+	searcher, err := q.query.Searcher(q.reader, searcherOptions)
+	if err != nil {
+		return
+	}
+	defer searcher.Close()
+	ctx := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	d, err := searcher.Next(ctx)
+	for err == nil && d != nil {
+		q.docs[d.Number] = struct{}{}
+		ctx.DocumentMatchPool.Put(d)
+		d, err = searcher.Next(ctx)
+	}
+}
+
+// FilterAggregation collects the documents matching a query into one bucket,
+// the query is searched by the reader of the index which must be set by SetReader before searching.
+type FilterAggregation struct {
+	query bluge.Query
+	docs  *queryDocuments
+
+	aggregations map[string]search.Aggregation
+}
+
+func NewFilterAggregation(query bluge.Query) *FilterAggregation {
+	rv := &FilterAggregation{
+		query:        query,
+		docs:         newQueryDocuments(query, nil),
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *FilterAggregation) SetReader(reader search.Reader) {
+	t.docs = newQueryDocuments(t.query, reader)
+}
+
+func (t *FilterAggregation) Fields() []string {
+	var rv []string
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *FilterAggregation) Calculator() search.Calculator {
+	return &FilterCalculator{
+		docs:   t.docs,
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *FilterAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type FilterCalculator struct {
+	docs   *queryDocuments
+	bucket *search.Bucket
+}
+
+func (a *FilterCalculator) Consume(d *search.DocumentMatch) {
+	if a.docs.Contains(d.Number) {
+		a.bucket.Consume(d)
+	}
+}
+
+func (a *FilterCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FilterCalculator); ok {
+		a.bucket.Merge(other.bucket)
+	}
+}
+
+func (a *FilterCalculator) Finish() {
+	a.bucket.Finish()
+}
+
+func (a *FilterCalculator) Bucket() *search.Bucket {
+	return a.bucket
+}
+
+// FiltersAggregation collects the documents into one bucket for each query,
+// a document can be in multiple buckets, the other bucket collects the documents not matching any query.
+type FiltersAggregation struct {
+	keys     []string
+	queries  []bluge.Query
+	docs     []*queryDocuments
+	keyed    bool
+	otherKey string // empty if the other bucket is disabled
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewFiltersAggregation returns a FiltersAggregation,
+// keyed is true if the filters are named, otherwise the keys are the positions of the filters.
+func NewFiltersAggregation(keys []string, queries []bluge.Query, keyed bool) *FiltersAggregation {
+	rv := &FiltersAggregation{
+		keys:         keys,
+		queries:      queries,
+		keyed:        keyed,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	rv.SetReader(nil)
+	return rv
+}
+
+// SetOtherBucket enables the other bucket with the key
+func (t *FiltersAggregation) SetOtherBucket(key string) *FiltersAggregation {
+	if key == "" {
+		key = DefaultOtherBucketKey
+	}
+	t.otherKey = key
+	return t
+}
+
+func (t *FiltersAggregation) SetReader(reader search.Reader) {
+	t.docs = make([]*queryDocuments, len(t.queries))
+	for i, query := range t.queries {
+		t.docs[i] = newQueryDocuments(query, reader)
+	}
+}
+
+func (t *FiltersAggregation) Fields() []string {
+	var rv []string
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *FiltersAggregation) Calculator() search.Calculator {
+	rv := &FiltersCalculator{
+		docs:    t.docs,
+		keyed:   t.keyed,
+		buckets: make([]*search.Bucket, len(t.keys)),
+	}
+	for i, key := range t.keys {
+		rv.buckets[i] = search.NewBucket(key, t.aggregations)
+	}
+	if t.otherKey != "" {
+		rv.other = search.NewBucket(t.otherKey, t.aggregations)
+	}
+	return rv
+}
+
+func (t *FiltersAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type FiltersCalculator struct {
+	docs    []*queryDocuments
+	keyed   bool
+	buckets []*search.Bucket
+	other   *search.Bucket
+}
+
+func (a *FiltersCalculator) Consume(d *search.DocumentMatch) {
+	matched := false
+	for i, docs := range a.docs {
+		if docs.Contains(d.Number) {
+			a.buckets[i].Consume(d)
+			matched = true
+		}
+	}
+	if !matched && a.other != nil {
+		a.other.Consume(d)
+	}
+}
+
+func (a *FiltersCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FiltersCalculator); ok {
+		for i := range a.buckets {
+			a.buckets[i].Merge(other.buckets[i])
+		}
+		if a.other != nil {
+			a.other.Merge(other.other)
+		}
+	}
+}
+
+func (a *FiltersCalculator) Finish() {
+	for _, bucket := range a.buckets {
+		bucket.Finish()
+	}
+	if a.other != nil {
+		a.other.Finish()
+	}
+}
+
+// Buckets returns the buckets in the order of the filters, the other bucket is the last one
+func (a *FiltersCalculator) Buckets() []*search.Bucket {
+	if a.other == nil {
+		return a.buckets
+	}
+	return append(a.buckets[:len(a.buckets):len(a.buckets)], a.other)
+}
+
+// Keyed returns true if the filters are named
+func (a *FiltersCalculator) Keyed() bool {
+	return a.keyed
+}
+
+// FiltersKeys returns the keys of the anonymous filters, they are the positions of the filters
+func FiltersKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	return keys
+}
+
+// MissingAggregation collects the documents which have no value of the field into one bucket
+type MissingAggregation struct {
+	field string
+
+	aggregations map[string]search.Aggregation
+}
+
+func NewMissingAggregation(field string) *MissingAggregation {
+	rv := &MissingAggregation{
+		field:        field,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *MissingAggregation) Fields() []string {
+	rv := []string{t.field}
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *MissingAggregation) Calculator() search.Calculator {
+	return &MissingCalculator{
+		field:  t.field,
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *MissingAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type MissingCalculator struct {
+	field  string
+	bucket *search.Bucket
+}
+
+func (a *MissingCalculator) Consume(d *search.DocumentMatch) {
+	if len(d.DocValues(a.field)) == 0 {
+		a.bucket.Consume(d)
+	}
+}
+
+func (a *MissingCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*MissingCalculator); ok {
+		a.bucket.Merge(other.bucket)
+	}
+}
+
+func (a *MissingCalculator) Finish() {
+	a.bucket.Finish()
+}
+
+func (a *MissingCalculator) Bucket() *search.Bucket {
+	return a.bucket
+}
+
+// GlobalAggregation collects all the documents matching the query into one bucket regardless of the search query,
+// the documents are searched by the reader of the index which must be set by SetReader before searching.
+type GlobalAggregation struct {
+	query  bluge.Query
+	reader search.Reader
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewGlobalAggregation returns a GlobalAggregation, query should match all the documents of the index
+func NewGlobalAggregation(query bluge.Query) *GlobalAggregation {
+	rv := &GlobalAggregation{
+		query:        query,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *GlobalAggregation) SetReader(reader search.Reader) {
+	t.reader = reader
+}
+
+func (t *GlobalAggregation) Fields() []string {
+	return nil
+}
+
+func (t *GlobalAggregation) Calculator() search.Calculator {
+	var fields []string
+	for _, agg := range t.aggregations {
+		fields = append(fields, agg.Fields()...)
+	}
+	return &GlobalCalculator{
+		query:  t.query,
+		reader: t.reader,
+		fields: fields,
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *GlobalAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type GlobalCalculator struct {
+	query    bluge.Query
+	reader   search.Reader
+	fields   []string
+	bucket   *search.Bucket
+	consumed bool
+}
+
+// Consume ignores the documents matching the search query, all the documents are consumed by Finish
+func (a *GlobalCalculator) Consume(d *search.DocumentMatch) {}
+
+func (a *GlobalCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GlobalCalculator); ok {
+		a.bucket.Merge(other.bucket)
+	}
+}
+
+func (a *GlobalCalculator) Finish() {
+	if !a.consumed {
+		a.consumed = true
+		a.consumeAll()
+	}
+	a.bucket.Finish()
+}
+
+func (a *GlobalCalculator) consumeAll() {
+	if a.reader == nil {
+		return
+	}
+	searcher, err := a.query.Searcher(a.reader, searcherOptions)
+	if err != nil {
+		return
+	}
+	defer searcher.Close()
+	ctx := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	d, err := searcher.Next(ctx)
+	for err == nil && d != nil {
+		if err = d.LoadDocumentValues(ctx, a.fields); err != nil {
+			return
+		}
+		a.bucket.Consume(d)
+		ctx.DocumentMatchPool.Put(d)
+		d, err = searcher.Next(ctx)
+	}
+}
+
+func (a *GlobalCalculator) Bucket() *search.Bucket {
+	return a.bucket
+}
@@ -0,0 +1,127 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// GeoDistanceRange is a ring of geo_distance aggregation, from is inclusive and to is exclusive
+type GeoDistanceRange struct {
+	Key  string
+	From float64 // -Inf if not set
+	To   float64 // +Inf if not set
+}
+
+type GeoDistanceAggregation struct {
+	src    search.FieldSource
+	origin geo.Point
+	unit   float64 // meters of unit
+	ranges []*GeoDistanceRange
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewGeoDistanceAggregation returns a geoDistanceAggregation
+// unit is the meters of the distance unit used by ranges
+func NewGeoDistanceAggregation(field search.FieldSource, origin geo.Point, unit float64, ranges []*GeoDistanceRange) *GeoDistanceAggregation {
+	rv := &GeoDistanceAggregation{
+		src:          field,
+		origin:       origin,
+		unit:         unit,
+		ranges:       ranges,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *GeoDistanceAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *GeoDistanceAggregation) Calculator() search.Calculator {
+	rv := &GeoDistanceCalculator{
+		src:     t.src,
+		origin:  t.origin,
+		unit:    t.unit,
+		ranges:  t.ranges,
+		buckets: make([]*search.Bucket, 0, len(t.ranges)),
+	}
+	for _, r := range t.ranges {
+		rv.buckets = append(rv.buckets, search.NewBucket(r.Key, t.aggregations))
+	}
+	return rv
+}
+
+func (t *GeoDistanceAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type GeoDistanceCalculator struct {
+	src     search.FieldSource
+	origin  geo.Point
+	unit    float64
+	ranges  []*GeoDistanceRange
+	buckets []*search.Bucket
+}
+
+func (a *GeoDistanceCalculator) Consume(d *search.DocumentMatch) {
+	points := a.src.GeoPoints(d)
+	if len(points) == 0 {
+		return
+	}
+	distances := make([]float64, 0, len(points))
+	for _, p := range points {
+		dist := geo.Haversin(a.origin.Lon, a.origin.Lat, p.Lon, p.Lat) * 1000 / a.unit
+		distances = append(distances, dist)
+	}
+	for i, r := range a.ranges {
+		for _, dist := range distances {
+			if dist >= r.From && dist < r.To {
+				a.buckets[i].Consume(d)
+				break
+			}
+		}
+	}
+}
+
+func (a *GeoDistanceCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoDistanceCalculator); ok {
+		for i := range a.buckets {
+			if i < len(other.buckets) {
+				a.buckets[i].Merge(other.buckets[i])
+			}
+		}
+	}
+}
+
+func (a *GeoDistanceCalculator) Finish() {}
+
+func (a *GeoDistanceCalculator) Buckets() []*search.Bucket {
+	return a.buckets
+}
+
+// Ranges returns the ranges of buckets with the same order
+func (a *GeoDistanceCalculator) Ranges() []*GeoDistanceRange {
+	return a.ranges
+}
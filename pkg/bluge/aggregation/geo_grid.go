@@ -0,0 +1,205 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+const (
+	GeoHashGrid = iota
+	GeoTileGrid
+)
+
+const maxTileLat = 85.05112878
+
+// GeoBoundingBox limits the points used by grid aggregations
+type GeoBoundingBox struct {
+	Top    float64
+	Left   float64
+	Bottom float64
+	Right  float64
+}
+
+func (b *GeoBoundingBox) Contains(p *geo.Point) bool {
+	if p.Lat > b.Top || p.Lat < b.Bottom {
+		return false
+	}
+	if b.Left <= b.Right {
+		return p.Lon >= b.Left && p.Lon <= b.Right
+	}
+	// crosses the dateline
+	return p.Lon >= b.Left || p.Lon <= b.Right
+}
+
+type GeoGridAggregation struct {
+	src       search.FieldSource
+	gridType  int
+	precision int
+	size      int
+	bounds    *GeoBoundingBox
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewGeoGridAggregation returns a geoGridAggregation
+// gridType can be GeoHashGrid or GeoTileGrid,
+// precision is the length of geohash for GeoHashGrid, or the zoom level for GeoTileGrid.
+func NewGeoGridAggregation(field search.FieldSource, gridType, precision, size int, bounds *GeoBoundingBox) *GeoGridAggregation {
+	rv := &GeoGridAggregation{
+		src:          field,
+		gridType:     gridType,
+		precision:    precision,
+		size:         size,
+		bounds:       bounds,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *GeoGridAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *GeoGridAggregation) Calculator() search.Calculator {
+	return &GeoGridCalculator{
+		src:          t.src,
+		gridType:     t.gridType,
+		precision:    t.precision,
+		size:         t.size,
+		bounds:       t.bounds,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*search.Bucket),
+	}
+}
+
+func (t *GeoGridAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type GeoGridCalculator struct {
+	src       search.FieldSource
+	gridType  int
+	precision int
+	size      int
+	bounds    *GeoBoundingBox
+
+	aggregations map[string]search.Aggregation
+
+	bucketsList []*search.Bucket
+	bucketsMap  map[string]*search.Bucket
+}
+
+func (a *GeoGridCalculator) Consume(d *search.DocumentMatch) {
+	points := a.src.GeoPoints(d)
+	keys := make(map[string]struct{}, len(points))
+	for _, p := range points {
+		if a.bounds != nil && !a.bounds.Contains(p) {
+			continue
+		}
+		key := a.bucketKey(p)
+		if _, ok := keys[key]; ok {
+			continue // a document is counted once in a cell
+		}
+		keys[key] = struct{}{}
+		bucket, ok := a.bucketsMap[key]
+		if ok {
+			bucket.Consume(d)
+		} else {
+			newBucket := search.NewBucket(key, a.aggregations)
+			newBucket.Consume(d)
+			a.bucketsMap[key] = newBucket
+			a.bucketsList = append(a.bucketsList, newBucket)
+		}
+	}
+}
+
+func (a *GeoGridCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoGridCalculator); ok {
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+			} else {
+				a.bucketsMap[bucket.Name()] = bucket
+				a.bucketsList = append(a.bucketsList, bucket)
+			}
+		}
+		a.Finish()
+	}
+}
+
+func (a *GeoGridCalculator) Finish() {
+	// sort by doc_count desc, then key asc
+	sort.Slice(a.bucketsList, func(i, j int) bool {
+		ci, cj := a.bucketsList[i].Count(), a.bucketsList[j].Count()
+		if ci != cj {
+			return ci > cj
+		}
+		return a.bucketsList[i].Name() < a.bucketsList[j].Name()
+	})
+
+	if a.size < len(a.bucketsList) {
+		for _, bucket := range a.bucketsList[a.size:] {
+			delete(a.bucketsMap, bucket.Name())
+		}
+		a.bucketsList = a.bucketsList[:a.size]
+	}
+}
+
+func (a *GeoGridCalculator) Buckets() []*search.Bucket {
+	return a.bucketsList
+}
+
+func (a *GeoGridCalculator) bucketKey(p *geo.Point) string {
+	if a.gridType == GeoTileGrid {
+		return GeoTileKey(p.Lon, p.Lat, a.precision)
+	}
+	return geo.EncodeGeoHash(p.Lat, p.Lon)[:a.precision]
+}
+
+// GeoTileKey returns the key of the map tile contains the point, in format "{zoom}/{x}/{y}"
+func GeoTileKey(lon, lat float64, zoom int) string {
+	tiles := 1 << uint(zoom)
+	x := int(math.Floor((lon + 180) / 360 * float64(tiles)))
+	// the web mercator projection only covers latitude in [-85.05112878, 85.05112878]
+	lat = math.Max(math.Min(lat, maxTileLat), -maxTileLat)
+	latRad := lat * math.Pi / 180
+	y := int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * float64(tiles)))
+	x = clampTile(x, tiles)
+	y = clampTile(y, tiles)
+	return strconv.Itoa(zoom) + "/" + strconv.Itoa(x) + "/" + strconv.Itoa(y)
+}
+
+func clampTile(v, tiles int) int {
+	if v < 0 {
+		return 0
+	}
+	if v >= tiles {
+		return tiles - 1
+	}
+	return v
+}
@@ -0,0 +1,133 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// GeoBoundsAggregation computes the bounding box contains all points of a field
+type GeoBoundsAggregation struct {
+	src search.FieldSource
+}
+
+func NewGeoBoundsAggregation(field search.FieldSource) *GeoBoundsAggregation {
+	return &GeoBoundsAggregation{src: field}
+}
+
+func (t *GeoBoundsAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *GeoBoundsAggregation) Calculator() search.Calculator {
+	return &GeoBoundsCalculator{
+		src: t.src,
+		bounds: GeoBoundingBox{
+			Top:    math.Inf(-1),
+			Left:   math.Inf(1),
+			Bottom: math.Inf(1),
+			Right:  math.Inf(-1),
+		},
+	}
+}
+
+type GeoBoundsCalculator struct {
+	src    search.FieldSource
+	bounds GeoBoundingBox
+	count  int64
+}
+
+func (a *GeoBoundsCalculator) Consume(d *search.DocumentMatch) {
+	for _, p := range a.src.GeoPoints(d) {
+		a.bounds.Top = math.Max(a.bounds.Top, p.Lat)
+		a.bounds.Bottom = math.Min(a.bounds.Bottom, p.Lat)
+		a.bounds.Left = math.Min(a.bounds.Left, p.Lon)
+		a.bounds.Right = math.Max(a.bounds.Right, p.Lon)
+		a.count++
+	}
+}
+
+func (a *GeoBoundsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoBoundsCalculator); ok {
+		if other.count == 0 {
+			return
+		}
+		a.bounds.Top = math.Max(a.bounds.Top, other.bounds.Top)
+		a.bounds.Bottom = math.Min(a.bounds.Bottom, other.bounds.Bottom)
+		a.bounds.Left = math.Min(a.bounds.Left, other.bounds.Left)
+		a.bounds.Right = math.Max(a.bounds.Right, other.bounds.Right)
+		a.count += other.count
+	}
+}
+
+func (a *GeoBoundsCalculator) Finish() {}
+
+// Bounds returns the bounding box, it returns false if no points
+func (a *GeoBoundsCalculator) Bounds() (GeoBoundingBox, bool) {
+	return a.bounds, a.count > 0
+}
+
+// GeoCentroidAggregation computes the centroid of all points of a field
+type GeoCentroidAggregation struct {
+	src search.FieldSource
+}
+
+func NewGeoCentroidAggregation(field search.FieldSource) *GeoCentroidAggregation {
+	return &GeoCentroidAggregation{src: field}
+}
+
+func (t *GeoCentroidAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *GeoCentroidAggregation) Calculator() search.Calculator {
+	return &GeoCentroidCalculator{src: t.src}
+}
+
+type GeoCentroidCalculator struct {
+	src    search.FieldSource
+	sumLat float64
+	sumLon float64
+	count  int64
+}
+
+func (a *GeoCentroidCalculator) Consume(d *search.DocumentMatch) {
+	for _, p := range a.src.GeoPoints(d) {
+		a.sumLat += p.Lat
+		a.sumLon += p.Lon
+		a.count++
+	}
+}
+
+func (a *GeoCentroidCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoCentroidCalculator); ok {
+		a.sumLat += other.sumLat
+		a.sumLon += other.sumLon
+		a.count += other.count
+	}
+}
+
+func (a *GeoCentroidCalculator) Finish() {}
+
+// Centroid returns the centroid and the number of points
+func (a *GeoCentroidCalculator) Centroid() (lon, lat float64, count int64) {
+	if a.count == 0 {
+		return 0, 0, 0
+	}
+	return a.sumLon / float64(a.count), a.sumLat / float64(a.count), a.count
+}
@@ -158,6 +158,12 @@ func (a *HistogramCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*HistogramCalculator); ok {
 		// first sum to the totals and others
 		a.total += other.total
+		if other.minValue < a.minValue {
+			a.minValue = other.minValue
+		}
+		if other.maxValue > a.maxValue {
+			a.maxValue = other.maxValue
+		}
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
 		for i := range other.bucketsList {
@@ -169,6 +175,7 @@ func (a *HistogramCalculator) Merge(other search.Calculator) {
 				}
 			}
 			if !foundLocal {
+				a.bucketsMap[other.bucketsList[i].Name()] = other.bucketsList[i]
 				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
 			}
 		}
@@ -197,7 +204,9 @@ func (a *HistogramCalculator) Finish() {
 		for value := a.minValue; value < a.maxValue; value += a.interval {
 			termStr := a.bucketKey(value)
 			if _, ok := a.bucketsMap[termStr]; !ok {
-				a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+				newBucket := search.NewBucket(termStr, a.aggregations)
+				a.bucketsMap[termStr] = newBucket
+				a.bucketsList = append(a.bucketsList, newBucket)
 			}
 		}
 	} else {
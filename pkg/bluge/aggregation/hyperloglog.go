@@ -0,0 +1,185 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	hyperLogLogMinPrecision = 4
+	hyperLogLogMaxPrecision = 18
+)
+
+// HyperLogLogPlusPlus is a cardinality sketch of 64 bits hashes,
+// it counts the hashes exactly up to the precision threshold,
+// then it estimates the cardinality by the registers of HyperLogLog with the improved estimator of Otmar Ertl.
+type HyperLogLogPlusPlus struct {
+	precision uint8
+	threshold int
+	hashes    map[uint64]struct{} // nil after the sketch is converted to the registers
+	registers []uint8
+}
+
+// NewHyperLogLogPlusPlus returns a sketch which counts exactly up to threshold values,
+// the precision of the registers uses about the same memory as the exact counting of threshold values.
+func NewHyperLogLogPlusPlus(threshold int) *HyperLogLogPlusPlus {
+	if threshold < 0 {
+		threshold = 0
+	}
+	return &HyperLogLogPlusPlus{
+		precision: hyperLogLogPrecision(threshold),
+		threshold: threshold,
+		hashes:    make(map[uint64]struct{}),
+	}
+}
+
+// hyperLogLogPrecision returns the precision of which the registers use the memory of the hash table of threshold hashes
+func hyperLogLogPrecision(threshold int) uint8 {
+	entries := uint64(math.Ceil(float64(threshold) / 0.75))
+	p := bits.Len64(entries * 4)
+	if p < hyperLogLogMinPrecision {
+		p = hyperLogLogMinPrecision
+	}
+	if p > hyperLogLogMaxPrecision {
+		p = hyperLogLogMaxPrecision
+	}
+	return uint8(p)
+}
+
+// Add adds a hash of a value
+func (s *HyperLogLogPlusPlus) Add(hash uint64) {
+	if s.hashes == nil {
+		s.addRegister(hash)
+		return
+	}
+	s.hashes[hash] = struct{}{}
+	if len(s.hashes) > s.threshold {
+		s.toRegisters()
+	}
+}
+
+// AddBytes adds a value
+func (s *HyperLogLogPlusPlus) AddBytes(value []byte) {
+	s.Add(hashBytes(value))
+}
+
+func (s *HyperLogLogPlusPlus) toRegisters() {
+	s.registers = make([]uint8, 1<<s.precision)
+	for hash := range s.hashes {
+		s.addRegister(hash)
+	}
+	s.hashes = nil
+}
+
+func (s *HyperLogLogPlusPlus) addRegister(hash uint64) {
+	index := hash >> (64 - s.precision)
+	w := hash << s.precision
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if maxRho := 64 - s.precision + 1; rho > maxRho {
+		rho = maxRho
+	}
+	if rho > s.registers[index] {
+		s.registers[index] = rho
+	}
+}
+
+// Merge merges the other sketch which has the same threshold
+func (s *HyperLogLogPlusPlus) Merge(other *HyperLogLogPlusPlus) {
+	if other.hashes != nil {
+		for hash := range other.hashes {
+			s.Add(hash)
+		}
+		return
+	}
+	if s.hashes != nil {
+		s.toRegisters()
+	}
+	for i, v := range other.registers {
+		if v > s.registers[i] {
+			s.registers[i] = v
+		}
+	}
+}
+
+// Cardinality returns the exact count if it is at most the threshold, otherwise the estimated count
+func (s *HyperLogLogPlusPlus) Cardinality() uint64 {
+	if s.hashes != nil {
+		return uint64(len(s.hashes))
+	}
+
+	m := float64(len(s.registers))
+	q := 64 - int(s.precision)
+	counts := make([]float64, q+2)
+	for _, v := range s.registers {
+		counts[v]++
+	}
+	z := m * hyperLogLogTau((m-counts[q+1])/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + counts[k])
+	}
+	z += m * hyperLogLogSigma(counts[0]/m)
+	return uint64(math.Round(m * m / (2 * math.Ln2 * z)))
+}
+
+func hyperLogLogSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hyperLogLogTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// hashBytes returns the 64 bits hash of the value, the FNV-1a hash is mixed by the finalizer of MurmurHash3
+func hashBytes(value []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(value)
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb3fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
@@ -0,0 +1,133 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// IPRange is a range of ip_range aggregation, from is inclusive and to is exclusive,
+// the addresses are in the 16-byte form.
+type IPRange struct {
+	Key  string
+	From net.IP // nil if not set
+	To   net.IP // nil if not set
+}
+
+func (r *IPRange) contains(ip []byte) bool {
+	if r.From != nil && bytes.Compare(ip, r.From) < 0 {
+		return false
+	}
+	if r.To != nil && bytes.Compare(ip, r.To) >= 0 {
+		return false
+	}
+	return true
+}
+
+type IPRangeAggregation struct {
+	src    search.FieldSource
+	ranges []*IPRange
+
+	aggregations map[string]search.Aggregation
+}
+
+func NewIPRangeAggregation(field search.FieldSource, ranges []*IPRange) *IPRangeAggregation {
+	rv := &IPRangeAggregation{
+		src:          field,
+		ranges:       ranges,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *IPRangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) Calculator() search.Calculator {
+	rv := &IPRangeCalculator{
+		src:     t.src,
+		ranges:  t.ranges,
+		buckets: make([]*search.Bucket, 0, len(t.ranges)),
+	}
+	for _, r := range t.ranges {
+		rv.buckets = append(rv.buckets, search.NewBucket(r.Key, t.aggregations))
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type IPRangeCalculator struct {
+	src     search.FieldSource
+	ranges  []*IPRange
+	buckets []*search.Bucket
+}
+
+func (a *IPRangeCalculator) Consume(d *search.DocumentMatch) {
+	values := a.src.Values(d)
+	if len(values) == 0 {
+		return
+	}
+	ips := make([]net.IP, 0, len(values))
+	for _, v := range values {
+		if ip, err := zutils.DecodeIP(v); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+	for i, r := range a.ranges {
+		for _, ip := range ips {
+			if r.contains(ip) {
+				a.buckets[i].Consume(d)
+				break
+			}
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*IPRangeCalculator); ok {
+		for i := range a.buckets {
+			if i < len(other.buckets) {
+				a.buckets[i].Merge(other.buckets[i])
+			}
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Finish() {}
+
+func (a *IPRangeCalculator) Buckets() []*search.Bucket {
+	return a.buckets
+}
+
+// Ranges returns the ranges of buckets with the same order
+func (a *IPRangeCalculator) Ranges() []*IPRange {
+	return a.ranges
+}
@@ -0,0 +1,126 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// NestedAggregation aggregates the hidden nested documents of a path of the matched documents,
+// the nested documents are loaded by the reader of the index which must be set by SetReader before searching.
+type NestedAggregation struct {
+	path        string
+	pathField   string
+	parentField string
+	reader      search.Reader
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewNestedAggregation returns a NestedAggregation,
+// pathField and parentField are the fields of the nested documents which store the path and the _id of the parent document.
+func NewNestedAggregation(path, pathField, parentField string) *NestedAggregation {
+	rv := &NestedAggregation{
+		path:         path,
+		pathField:    pathField,
+		parentField:  parentField,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *NestedAggregation) SetReader(reader search.Reader) {
+	t.reader = reader
+}
+
+func (t *NestedAggregation) Fields() []string {
+	return []string{"_id"}
+}
+
+func (t *NestedAggregation) Calculator() search.Calculator {
+	fields := []string{t.pathField}
+	for _, agg := range t.aggregations {
+		fields = append(fields, agg.Fields()...)
+	}
+	return &NestedCalculator{
+		path:        t.path,
+		pathField:   t.pathField,
+		parentField: t.parentField,
+		reader:      t.reader,
+		fields:      fields,
+		ctx:         search.NewSearchContext(0, 0),
+		bucket:      search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *NestedAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+// SingleBucketCalculator is implemented by the calculators which collect documents into one bucket
+type SingleBucketCalculator interface {
+	search.Calculator
+	Bucket() *search.Bucket
+}
+
+type NestedCalculator struct {
+	path        string
+	pathField   string
+	parentField string
+	reader      search.Reader
+	fields      []string
+	ctx         *search.Context
+	bucket      *search.Bucket
+}
+
+func (a *NestedCalculator) Consume(d *search.DocumentMatch) {
+	ids := d.DocValues("_id")
+	if a.reader == nil || len(ids) == 0 {
+		return
+	}
+	postings, err := a.reader.PostingsIterator(ids[0], a.parentField, false, false, false)
+	if err != nil {
+		return
+	}
+	p, err := postings.Next()
+	for err == nil && p != nil {
+		child := &search.DocumentMatch{Number: p.Number()}
+		child.SetReader(a.reader)
+		if err = child.LoadDocumentValues(a.ctx, a.fields); err != nil {
+			return
+		}
+		if paths := child.DocValues(a.pathField); len(paths) > 0 && string(paths[0]) == a.path {
+			a.bucket.Consume(child)
+		}
+		p, err = postings.Next()
+	}
+}
+
+func (a *NestedCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*NestedCalculator); ok {
+		a.bucket.Merge(other.bucket)
+	}
+}
+
+func (a *NestedCalculator) Finish() {
+	a.bucket.Finish()
+}
+
+func (a *NestedCalculator) Bucket() *search.Bucket {
+	return a.bucket
+}
@@ -0,0 +1,128 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+	"github.com/caio/go-tdigest"
+)
+
+// DefaultPercentilesCompression is the default compression of the t-digest
+const DefaultPercentilesCompression = 100
+
+// PercentilesAggregation estimates the percentiles of the values of a field by t-digest,
+// it is used by both percentiles and percentile_ranks aggregations.
+type PercentilesAggregation struct {
+	src         search.NumericValuesSource
+	compression float64
+	percents    []float64
+	values      []float64
+	keyed       bool
+}
+
+// NewPercentilesAggregation returns a PercentilesAggregation computes the values at the percents,
+// keyed decides the results are returned as a map or a list.
+func NewPercentilesAggregation(field search.NumericValuesSource, compression float64, percents []float64, keyed bool) *PercentilesAggregation {
+	return &PercentilesAggregation{
+		src:         field,
+		compression: compression,
+		percents:    percents,
+		keyed:       keyed,
+	}
+}
+
+// NewPercentileRanksAggregation returns a PercentilesAggregation computes the percents of the values
+func NewPercentileRanksAggregation(field search.NumericValuesSource, compression float64, values []float64, keyed bool) *PercentilesAggregation {
+	return &PercentilesAggregation{
+		src:         field,
+		compression: compression,
+		values:      values,
+		keyed:       keyed,
+	}
+}
+
+func (t *PercentilesAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *PercentilesAggregation) Calculator() search.Calculator {
+	rv := &PercentilesCalculator{
+		src:      t.src,
+		percents: t.percents,
+		values:   t.values,
+		keyed:    t.keyed,
+	}
+	rv.digest, _ = tdigest.New(tdigest.Compression(t.compression))
+	return rv
+}
+
+type PercentilesCalculator struct {
+	src      search.NumericValuesSource
+	digest   *tdigest.TDigest
+	percents []float64
+	values   []float64
+	keyed    bool
+}
+
+func (a *PercentilesCalculator) Consume(d *search.DocumentMatch) {
+	for _, v := range a.src.Numbers(d) {
+		_ = a.digest.Add(v)
+	}
+}
+
+func (a *PercentilesCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*PercentilesCalculator); ok {
+		_ = a.digest.Merge(other.digest)
+	}
+}
+
+func (a *PercentilesCalculator) Finish() {}
+
+// Ranks returns true if the calculator computes percentile ranks
+func (a *PercentilesCalculator) Ranks() bool {
+	return a.values != nil
+}
+
+func (a *PercentilesCalculator) Keyed() bool {
+	return a.keyed
+}
+
+// Keys returns the requested percents of percentiles or the requested values of percentile ranks
+func (a *PercentilesCalculator) Keys() []float64 {
+	if a.Ranks() {
+		return a.values
+	}
+	return a.percents
+}
+
+// Results returns the values at the percents for percentiles, or the percents of the values for percentile ranks,
+// in the order of Keys, it returns false if there is no value.
+func (a *PercentilesCalculator) Results() ([]float64, bool) {
+	if a.digest.Count() == 0 {
+		return nil, false
+	}
+	results := make([]float64, 0, len(a.Keys()))
+	if a.Ranks() {
+		for _, v := range a.values {
+			results = append(results, a.digest.CDF(v)*100)
+		}
+	} else {
+		for _, p := range a.percents {
+			results = append(results, a.digest.Quantile(p/100))
+		}
+	}
+	return results, true
+}
@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// NumericRange is a range of range and date_range aggregations, from is inclusive and to is exclusive
+type NumericRange struct {
+	Key  string
+	From float64 // -Inf if not set
+	To   float64 // +Inf if not set
+}
+
+func (r *NumericRange) contains(v float64) bool {
+	return v >= r.From && v < r.To
+}
+
+type RangeAggregation struct {
+	src     search.FieldSource
+	date    bool
+	ranges  []*NumericRange
+	missing *float64
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewRangeAggregation returns a range aggregation of the numeric values of the field
+func NewRangeAggregation(field search.FieldSource, ranges []*NumericRange) *RangeAggregation {
+	rv := &RangeAggregation{
+		src:          field,
+		ranges:       ranges,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// NewDateRangeAggregation returns a range aggregation of the date values of the field,
+// the values and the ranges are epoch milliseconds.
+func NewDateRangeAggregation(field search.FieldSource, ranges []*NumericRange) *RangeAggregation {
+	rv := NewRangeAggregation(field, ranges)
+	rv.date = true
+	return rv
+}
+
+// SetMissing sets the value of the documents which have no value of the field
+func (t *RangeAggregation) SetMissing(value float64) *RangeAggregation {
+	t.missing = &value
+	return t
+}
+
+func (t *RangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *RangeAggregation) Calculator() search.Calculator {
+	rv := &RangeCalculator{
+		src:     t.src,
+		date:    t.date,
+		ranges:  t.ranges,
+		missing: t.missing,
+		buckets: make([]*search.Bucket, 0, len(t.ranges)),
+	}
+	for _, r := range t.ranges {
+		rv.buckets = append(rv.buckets, search.NewBucket(r.Key, t.aggregations))
+	}
+	return rv
+}
+
+func (t *RangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type RangeCalculator struct {
+	src     search.FieldSource
+	date    bool
+	ranges  []*NumericRange
+	missing *float64
+	buckets []*search.Bucket
+}
+
+func (a *RangeCalculator) Consume(d *search.DocumentMatch) {
+	var values []float64
+	if a.date {
+		for _, t := range a.src.Dates(d) {
+			values = append(values, float64(t.UnixMilli()))
+		}
+	} else {
+		values = a.src.Numbers(d)
+	}
+	if len(values) == 0 && a.missing != nil {
+		values = []float64{*a.missing}
+	}
+	for i, r := range a.ranges {
+		for _, v := range values {
+			if r.contains(v) {
+				a.buckets[i].Consume(d)
+				break
+			}
+		}
+	}
+}
+
+func (a *RangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*RangeCalculator); ok {
+		for i := range a.buckets {
+			if i < len(other.buckets) {
+				a.buckets[i].Merge(other.buckets[i])
+			}
+		}
+	}
+}
+
+func (a *RangeCalculator) Finish() {
+	for _, bucket := range a.buckets {
+		bucket.Finish()
+	}
+}
+
+func (a *RangeCalculator) Buckets() []*search.Bucket {
+	return a.buckets
+}
+
+// Ranges returns the ranges of buckets with the same order
+func (a *RangeCalculator) Ranges() []*NumericRange {
+	return a.ranges
+}
+
+// Date returns true if the calculator is created by NewDateRangeAggregation
+func (a *RangeCalculator) Date() bool {
+	return a.date
+}
@@ -0,0 +1,149 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"sort"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+type RareTermsAggregation struct {
+	src         *TermsSource
+	maxDocCount int
+	include     func(term string) bool
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewRareTermsAggregation returns a rare_terms aggregation which returns the terms
+// of at most maxDocCount matched documents, ordered by doc count ascending.
+func NewRareTermsAggregation(src *TermsSource, maxDocCount int) *RareTermsAggregation {
+	rv := &RareTermsAggregation{
+		src:          src,
+		maxDocCount:  maxDocCount,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// SetInclude sets the filter of the terms, the terms not included have no buckets
+func (t *RareTermsAggregation) SetInclude(include func(term string) bool) *RareTermsAggregation {
+	t.include = include
+	return t
+}
+
+func (t *RareTermsAggregation) Fields() []string {
+	rv := t.src.Field.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *RareTermsAggregation) Calculator() search.Calculator {
+	rv := &RareTermsCalculator{
+		src:          t.src,
+		maxDocCount:  t.maxDocCount,
+		include:      t.include,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*search.Bucket),
+		compareKey:   compareBucketKey,
+	}
+	switch t.src.ValueType {
+	case NumericValueSource, NumericValuesSource:
+		rv.compareKey = compareBucketNumericKey
+	}
+	return rv
+}
+
+func (t *RareTermsAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+// RareTermsCalculator keeps all the terms of the matched documents,
+// a term is rare only if its doc count of all the shards is at most maxDocCount.
+type RareTermsCalculator struct {
+	src         *TermsSource
+	maxDocCount int
+	include     func(term string) bool
+	compareKey  func(a, b *search.Bucket) int
+
+	aggregations map[string]search.Aggregation
+
+	bucketsList []*search.Bucket
+	bucketsMap  map[string]*search.Bucket
+}
+
+func (a *RareTermsCalculator) Consume(d *search.DocumentMatch) {
+	terms := sourceTerms(a.src, d)
+	if len(terms) == 0 && a.src.Missing != nil {
+		terms = []string{*a.src.Missing}
+	}
+	for i, term := range terms {
+		if a.include != nil && !a.include(term) {
+			continue
+		}
+		if duplicated(terms[:i], term) {
+			continue
+		}
+		bucket, ok := a.bucketsMap[term]
+		if !ok {
+			bucket = search.NewBucket(term, a.aggregations)
+			a.bucketsMap[term] = bucket
+			a.bucketsList = append(a.bucketsList, bucket)
+		}
+		bucket.Consume(d)
+	}
+}
+
+func (a *RareTermsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*RareTermsCalculator); ok {
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+			} else {
+				a.bucketsMap[bucket.Name()] = bucket
+				a.bucketsList = append(a.bucketsList, bucket)
+			}
+		}
+	}
+}
+
+func (a *RareTermsCalculator) Finish() {
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
+}
+
+// Buckets returns the rare terms ordered by doc count ascending and then by key ascending
+func (a *RareTermsCalculator) Buckets() []*search.Bucket {
+	rv := make([]*search.Bucket, 0)
+	for _, bucket := range a.bucketsList {
+		if int(bucket.Count()) <= a.maxDocCount {
+			rv = append(rv, bucket)
+		}
+	}
+	sort.SliceStable(rv, func(i, j int) bool {
+		if c := CompareBucketCount(rv[i], rv[j]); c != 0 {
+			return c < 0
+		}
+		return a.compareKey(rv[i], rv[j]) < 0
+	})
+	return rv
+}
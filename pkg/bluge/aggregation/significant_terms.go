@@ -0,0 +1,384 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// SignificanceHeuristic scores a term by the doc frequency of the term in the subset (foreground)
+// and the superset (background), the terms without a positive score are not significant.
+type SignificanceHeuristic func(subsetFreq, subsetSize, supersetFreq, supersetSize float64) float64
+
+// JLH returns the default significance heuristic of significant_terms aggregation,
+// it is the absolute change of the probability multiplied by the relative change.
+func JLH() SignificanceHeuristic {
+	return func(subsetFreq, subsetSize, supersetFreq, supersetSize float64) float64 {
+		if subsetSize == 0 || supersetSize == 0 || supersetFreq == 0 {
+			return 0
+		}
+		subsetProbability := subsetFreq / subsetSize
+		supersetProbability := supersetFreq / supersetSize
+		absoluteProbabilityChange := subsetProbability - supersetProbability
+		if absoluteProbabilityChange <= 0 {
+			return 0
+		}
+		return absoluteProbabilityChange * (subsetProbability / supersetProbability)
+	}
+}
+
+// ChiSquare returns the chi-square significance heuristic,
+// includeNegatives keeps the terms less frequent in the subset than in the rest of the superset,
+// backgroundIsSuperset tells whether the subset is contained in the superset.
+func ChiSquare(includeNegatives, backgroundIsSuperset bool) SignificanceHeuristic {
+	return func(subsetFreq, subsetSize, supersetFreq, supersetSize float64) float64 {
+		f := newFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize, backgroundIsSuperset)
+		if !includeNegatives && f.negative() {
+			return math.Inf(-1)
+		}
+		score := f.n * math.Pow(f.n11*f.n00-f.n01*f.n10, 2) / (f.nx1 * f.n1x * f.n0x * f.nx0)
+		if math.IsNaN(score) {
+			return math.Inf(-1)
+		}
+		return score
+	}
+}
+
+// MutualInformation returns the mutual information significance heuristic,
+// the options are the same as ChiSquare.
+func MutualInformation(includeNegatives, backgroundIsSuperset bool) SignificanceHeuristic {
+	return func(subsetFreq, subsetSize, supersetFreq, supersetSize float64) float64 {
+		f := newFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize, backgroundIsSuperset)
+		if !includeNegatives && f.negative() {
+			return math.Inf(-1)
+		}
+		score := (mutualInformationTerm(f.n00, f.n0x, f.nx0, f.n) +
+			mutualInformationTerm(f.n01, f.n0x, f.nx1, f.n) +
+			mutualInformationTerm(f.n10, f.n1x, f.nx0, f.n) +
+			mutualInformationTerm(f.n11, f.n1x, f.nx1, f.n)) / math.Ln2
+		if math.IsNaN(score) {
+			return math.Inf(-1)
+		}
+		return score
+	}
+}
+
+// mutualInformationTerm is a term of mutual information,
+// nxy is a cell of the contingency table, nx and ny are the sums of the row and the column of the cell.
+func mutualInformationTerm(nxy, nx, ny, n float64) float64 {
+	numerator := math.Abs(n * nxy)
+	denominator := math.Abs(nx * ny)
+	factor := math.Abs(nxy / n)
+	if numerator < 1e-7 && factor < 1e-7 {
+		return 0
+	}
+	return factor * math.Log(numerator/denominator)
+}
+
+// frequencies is the contingency table of a term,
+// the first digit is whether the documents are in the subset, the second digit is whether the documents have the term,
+// x is the sum of both values.
+type frequencies struct {
+	n00, n01, n10, n11 float64
+	n0x, n1x, nx0, nx1 float64
+	n                  float64
+}
+
+func newFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize float64, backgroundIsSuperset bool) *frequencies {
+	if backgroundIsSuperset {
+		// the rest of the superset which is not in the subset
+		supersetFreq -= subsetFreq
+		supersetSize -= subsetSize
+	}
+	f := &frequencies{
+		n00: supersetSize - supersetFreq,
+		n01: supersetFreq,
+		n10: subsetSize - subsetFreq,
+		n11: subsetFreq,
+	}
+	f.n0x = f.n00 + f.n01
+	f.n1x = f.n10 + f.n11
+	f.nx0 = f.n00 + f.n10
+	f.nx1 = f.n01 + f.n11
+	f.n = f.n00 + f.n01 + f.n10 + f.n11
+	return f
+}
+
+// negative tells whether the term is less frequent in the subset than in the rest of the superset
+func (f *frequencies) negative() bool {
+	return f.n11/f.nx1 < f.n10/f.nx0
+}
+
+type SignificantTermsAggregation struct {
+	src          *TermsSource
+	size         int
+	minDocCount  int
+	include      func(term string) bool
+	heuristic    SignificanceHeuristic
+	background   map[string]int
+	supersetSize int
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewSignificantTermsAggregation returns a significant_terms aggregation which returns the terms
+// more frequent in the matched documents than in the documents of the index, it requires SetReader.
+func NewSignificantTermsAggregation(src *TermsSource, size int) *SignificantTermsAggregation {
+	rv := &SignificantTermsAggregation{
+		src:          src,
+		size:         size,
+		minDocCount:  3,
+		heuristic:    JLH(),
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// SetMinDocCount sets the minimum doc count in the matched documents of the returned terms, default 3
+func (t *SignificantTermsAggregation) SetMinDocCount(minDocCount int) *SignificantTermsAggregation {
+	t.minDocCount = minDocCount
+	return t
+}
+
+// SetInclude sets the filter of the terms, the terms not included have no buckets
+func (t *SignificantTermsAggregation) SetInclude(include func(term string) bool) *SignificantTermsAggregation {
+	t.include = include
+	return t
+}
+
+// SetHeuristic sets the significance heuristic, default JLH
+func (t *SignificantTermsAggregation) SetHeuristic(heuristic SignificanceHeuristic) *SignificantTermsAggregation {
+	if heuristic != nil {
+		t.heuristic = heuristic
+	}
+	return t
+}
+
+// SetReader loads the doc frequencies of the terms of the field from the reader as the background
+func (t *SignificantTermsAggregation) SetReader(reader search.Reader) {
+	t.background = make(map[string]int)
+	t.supersetSize = 0
+	fields := t.src.Field.Fields()
+	if len(fields) == 0 {
+		return
+	}
+	if stats, err := reader.CollectionStats(fields[0]); err == nil {
+		t.supersetSize = int(stats.TotalDocumentCount())
+	}
+	dict, err := reader.DictionaryIterator(fields[0], nil, nil, nil)
+	if err != nil {
+		return
+	}
+	defer dict.Close()
+	entry, err := dict.Next()
+	for err == nil && entry != nil {
+		if term, ok := backgroundTerm(t.src, entry.Term()); ok {
+			t.background[term] += int(entry.Count())
+		}
+		entry, err = dict.Next()
+	}
+}
+
+// backgroundTerm returns the term of the dictionary as the term returned by sourceTerms,
+// only the full precision terms of numeric fields are used.
+func backgroundTerm(src *TermsSource, term string) (string, bool) {
+	switch src.ValueType {
+	case NumericValueSource, NumericValuesSource:
+		if valid, shift := numeric.ValidPrefixCodedTerm(term); !valid || shift != 0 {
+			return "", false
+		}
+		i, err := numeric.PrefixCoded(term).Int64()
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatFloat(numeric.Int64ToFloat64(i), 'f', -1, 64), true
+	default:
+		return term, true
+	}
+}
+
+func (t *SignificantTermsAggregation) Fields() []string {
+	rv := t.src.Field.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *SignificantTermsAggregation) Calculator() search.Calculator {
+	return &SignificantTermsCalculator{
+		src:          t.src,
+		size:         t.size,
+		minDocCount:  t.minDocCount,
+		include:      t.include,
+		heuristic:    t.heuristic,
+		background:   t.background,
+		supersetSize: t.supersetSize,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*search.Bucket),
+	}
+}
+
+func (t *SignificantTermsAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+// SignificantTermsCalculator keeps all the terms of the matched documents,
+// the terms are scored after the calculators of the shards are merged.
+type SignificantTermsCalculator struct {
+	src          *TermsSource
+	size         int
+	minDocCount  int
+	include      func(term string) bool
+	heuristic    SignificanceHeuristic
+	background   map[string]int
+	supersetSize int
+
+	aggregations map[string]search.Aggregation
+
+	bucketsList []*search.Bucket
+	bucketsMap  map[string]*search.Bucket
+	total       int
+
+	scored []*SignificantBucket
+}
+
+// SignificantBucket is a bucket with the score and the background doc count of the term
+type SignificantBucket struct {
+	*search.Bucket
+	Score   float64
+	BgCount int
+}
+
+func (a *SignificantTermsCalculator) Consume(d *search.DocumentMatch) {
+	a.total++
+	terms := sourceTerms(a.src, d)
+	if len(terms) == 0 && a.src.Missing != nil {
+		terms = []string{*a.src.Missing}
+	}
+	for i, term := range terms {
+		if a.include != nil && !a.include(term) {
+			continue
+		}
+		if duplicated(terms[:i], term) {
+			continue
+		}
+		bucket, ok := a.bucketsMap[term]
+		if !ok {
+			bucket = search.NewBucket(term, a.aggregations)
+			a.bucketsMap[term] = bucket
+			a.bucketsList = append(a.bucketsList, bucket)
+		}
+		bucket.Consume(d)
+	}
+}
+
+func (a *SignificantTermsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*SignificantTermsCalculator); ok {
+		a.total += other.total
+		a.supersetSize += other.supersetSize
+		background := make(map[string]int, len(a.background)+len(other.background))
+		for term, n := range a.background {
+			background[term] += n
+		}
+		for term, n := range other.background {
+			background[term] += n
+		}
+		a.background = background
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+			} else {
+				a.bucketsMap[bucket.Name()] = bucket
+				a.bucketsList = append(a.bucketsList, bucket)
+			}
+		}
+		a.scored = nil
+	}
+}
+
+func (a *SignificantTermsCalculator) Finish() {
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
+	a.scored = nil
+}
+
+// SignificantBuckets returns the significant terms ordered by score descending
+func (a *SignificantTermsCalculator) SignificantBuckets() []*SignificantBucket {
+	if a.scored != nil {
+		return a.scored
+	}
+	a.scored = make([]*SignificantBucket, 0)
+	for _, bucket := range a.bucketsList {
+		count := int(bucket.Count())
+		if count < a.minDocCount {
+			continue
+		}
+		bgCount := a.background[bucket.Name()]
+		if bgCount < count {
+			// the term of missing or not indexed values
+			bgCount = count
+		}
+		score := a.heuristic(float64(count), float64(a.total), float64(bgCount), float64(a.supersetSize))
+		if !(score > 0) {
+			// not significant
+			continue
+		}
+		a.scored = append(a.scored, &SignificantBucket{Bucket: bucket, Score: score, BgCount: bgCount})
+	}
+	sort.SliceStable(a.scored, func(i, j int) bool {
+		if a.scored[i].Score != a.scored[j].Score {
+			return a.scored[i].Score > a.scored[j].Score
+		}
+		return strings.Compare(a.scored[i].Name(), a.scored[j].Name()) < 0
+	})
+	if len(a.scored) > a.size {
+		a.scored = a.scored[:a.size]
+	}
+	return a.scored
+}
+
+func (a *SignificantTermsCalculator) Buckets() []*search.Bucket {
+	scored := a.SignificantBuckets()
+	rv := make([]*search.Bucket, 0, len(scored))
+	for _, bucket := range scored {
+		rv = append(rv, bucket.Bucket)
+	}
+	return rv
+}
+
+func (a *SignificantTermsCalculator) Source() *TermsSource {
+	return a.src
+}
+
+// DocCount returns the number of the matched documents
+func (a *SignificantTermsCalculator) DocCount() int {
+	return a.total
+}
+
+// BgCount returns the number of the documents of the index
+func (a *SignificantTermsCalculator) BgCount() int {
+	return a.supersetSize
+}
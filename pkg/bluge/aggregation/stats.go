@@ -0,0 +1,178 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// StatsAggregation computes the count, min, max, sum and sum of squares of the values of a field
+type StatsAggregation struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+}
+
+func NewStatsAggregation(field search.NumericValuesSource) *StatsAggregation {
+	return &StatsAggregation{src: field}
+}
+
+// NewExtendedStatsAggregation returns a StatsAggregation which also reports the variance and the standard deviation,
+// sigma is the number of standard deviations of the bounds from the average.
+func NewExtendedStatsAggregation(field search.NumericValuesSource, sigma float64) *StatsAggregation {
+	return &StatsAggregation{src: field, extended: true, sigma: sigma}
+}
+
+func (t *StatsAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *StatsAggregation) Calculator() search.Calculator {
+	return &StatsCalculator{
+		src:      t.src,
+		extended: t.extended,
+		sigma:    t.sigma,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+type StatsCalculator struct {
+	src          search.NumericValuesSource
+	extended     bool
+	sigma        float64
+	count        int64
+	min          float64
+	max          float64
+	sum          float64
+	sumOfSquares float64
+}
+
+func (a *StatsCalculator) Consume(d *search.DocumentMatch) {
+	for _, v := range a.src.Numbers(d) {
+		a.count++
+		a.min = math.Min(a.min, v)
+		a.max = math.Max(a.max, v)
+		a.sum += v
+		a.sumOfSquares += v * v
+	}
+}
+
+func (a *StatsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*StatsCalculator); ok {
+		a.count += other.count
+		a.min = math.Min(a.min, other.min)
+		a.max = math.Max(a.max, other.max)
+		a.sum += other.sum
+		a.sumOfSquares += other.sumOfSquares
+	}
+}
+
+func (a *StatsCalculator) Finish() {}
+
+func (a *StatsCalculator) Count() int64 {
+	return a.count
+}
+
+func (a *StatsCalculator) Min() float64 {
+	return a.min
+}
+
+func (a *StatsCalculator) Max() float64 {
+	return a.max
+}
+
+func (a *StatsCalculator) Sum() float64 {
+	return a.sum
+}
+
+func (a *StatsCalculator) Avg() float64 {
+	return a.sum / float64(a.count)
+}
+
+func (a *StatsCalculator) SumOfSquares() float64 {
+	return a.sumOfSquares
+}
+
+// Extended returns true if the calculator is created by NewExtendedStatsAggregation
+func (a *StatsCalculator) Extended() bool {
+	return a.extended
+}
+
+func (a *StatsCalculator) Sigma() float64 {
+	return a.sigma
+}
+
+// Variance returns the population variance
+func (a *StatsCalculator) Variance() float64 {
+	avg := a.Avg()
+	return math.Max(a.sumOfSquares/float64(a.count)-avg*avg, 0)
+}
+
+// VarianceSampling returns the sample variance
+func (a *StatsCalculator) VarianceSampling() float64 {
+	if a.count < 2 {
+		return math.NaN()
+	}
+	return a.Variance() * float64(a.count) / float64(a.count-1)
+}
+
+// ValueCountAggregation counts the values of a field,
+// the numeric values are indexed with the shifted terms so they are counted by the decoded numbers.
+type ValueCountAggregation struct {
+	src     search.FieldSource
+	numeric bool
+}
+
+func NewValueCountAggregation(field search.FieldSource, numeric bool) *ValueCountAggregation {
+	return &ValueCountAggregation{src: field, numeric: numeric}
+}
+
+func (t *ValueCountAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *ValueCountAggregation) Calculator() search.Calculator {
+	return &ValueCountCalculator{src: t.src, numeric: t.numeric}
+}
+
+type ValueCountCalculator struct {
+	src     search.FieldSource
+	numeric bool
+	count   int64
+}
+
+func (a *ValueCountCalculator) Consume(d *search.DocumentMatch) {
+	if a.numeric {
+		a.count += int64(len(a.src.Numbers(d)))
+	} else {
+		a.count += int64(len(a.src.Values(d)))
+	}
+}
+
+func (a *ValueCountCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ValueCountCalculator); ok {
+		a.count += other.count
+	}
+}
+
+func (a *ValueCountCalculator) Finish() {}
+
+func (a *ValueCountCalculator) Value() float64 {
+	return float64(a.count)
+}
@@ -16,46 +16,135 @@
 package aggregation
 
 import (
+	"math"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 )
 
+// MultiTermsSeparator separates the terms of the fields in the bucket keys of multi_terms aggregation
+const MultiTermsSeparator = "\x00"
+
+// TermsSource is a field of terms aggregation,
+// valueType is the value type of the field, missing is the term of the documents which have no value.
+type TermsSource struct {
+	Field     search.FieldSource
+	ValueType int
+	Missing   *string
+}
+
+// TermsOrder is an order of the buckets of terms aggregation
+type TermsOrder struct {
+	Compare func(a, b *search.Bucket) int // compares the buckets in ascending order
+	Desc    bool
+	Count   bool // orders by doc count, the error of doc count is computed only for descending doc count order
+}
+
 type TermsAggregation struct {
-	src     search.FieldSource
-	srcType int
-	size    int
+	sources     []*TermsSource
+	size        int
+	shardSize   int
+	minDocCount int
+	include     func(term string) bool
+	orders      []*TermsOrder
+	emptyTerms  []string
 
 	aggregations map[string]search.Aggregation
-
-	lessFunc func(a, b *search.Bucket) bool
-	desc     bool
-	sortFunc func(p sort.Interface)
 }
 
 // NewTermsAggregation returns a termsAggregation
 // field use to set the field use to terms aggregation
 // valueType use to set the value type, can be diy.TextValueSource / diy.TextValuesSource / diy.NumericValueSource / diy.NumericValuesSource
 func NewTermsAggregation(field search.FieldSource, valueType int, size int) *TermsAggregation {
+	return NewMultiTermsAggregation([]*TermsSource{{Field: field, ValueType: valueType}}, size)
+}
+
+// NewMultiTermsAggregation returns a terms aggregation on the combinations of the terms of the fields,
+// the terms of a bucket key are separated by MultiTermsSeparator.
+func NewMultiTermsAggregation(sources []*TermsSource, size int) *TermsAggregation {
 	rv := &TermsAggregation{
-		src:     field,
-		srcType: valueType,
-		size:    size,
-		desc:    true,
-		lessFunc: func(a, b *search.Bucket) bool {
-			return a.Aggregations()["count"].(search.MetricCalculator).Value() < b.Aggregations()["count"].(search.MetricCalculator).Value()
-		},
+		sources:      sources,
+		size:         size,
+		shardSize:    defaultShardSize(size),
+		minDocCount:  1,
 		aggregations: make(map[string]search.Aggregation),
-		sortFunc:     sort.Sort,
 	}
+	rv.orders = []*TermsOrder{{Compare: CompareBucketCount, Desc: true, Count: true}}
 	rv.aggregations["count"] = aggregations.CountMatches()
 	return rv
 }
 
+// defaultShardSize is the number of buckets kept by each shard, more than size for better accuracy
+func defaultShardSize(size int) int {
+	return size*3/2 + 10
+}
+
+// SetMissing sets the term of the documents which have no value of the field
+func (t *TermsAggregation) SetMissing(term string) *TermsAggregation {
+	t.sources[0].Missing = &term
+	return t
+}
+
+// SetShardSize sets the number of buckets kept by each shard, it is at least size
+func (t *TermsAggregation) SetShardSize(shardSize int) *TermsAggregation {
+	if shardSize < t.size {
+		shardSize = t.size
+	}
+	t.shardSize = shardSize
+	return t
+}
+
+// SetMinDocCount sets the minimum doc count of the returned buckets,
+// the buckets of the terms not in the matched documents are returned if it is 0, which requires SetReader.
+func (t *TermsAggregation) SetMinDocCount(minDocCount int) *TermsAggregation {
+	t.minDocCount = minDocCount
+	return t
+}
+
+// SetInclude sets the filter of the terms, the terms not included have no buckets
+func (t *TermsAggregation) SetInclude(include func(term string) bool) *TermsAggregation {
+	t.include = include
+	return t
+}
+
+// SetOrder sets the orders of the buckets, the buckets are finally ordered by key ascending
+func (t *TermsAggregation) SetOrder(orders ...*TermsOrder) *TermsAggregation {
+	if len(orders) > 0 {
+		t.orders = orders
+	}
+	return t
+}
+
+// SetReader loads the terms of the field from the reader for min_doc_count 0
+func (t *TermsAggregation) SetReader(reader search.Reader) {
+	t.emptyTerms = nil
+	if t.minDocCount > 0 || len(t.sources) != 1 {
+		return
+	}
+	fields := t.sources[0].Field.Fields()
+	if len(fields) == 0 {
+		return
+	}
+	dict, err := reader.DictionaryIterator(fields[0], nil, nil, nil)
+	if err != nil {
+		return
+	}
+	defer dict.Close()
+	entry, err := dict.Next()
+	for err == nil && entry != nil {
+		t.emptyTerms = append(t.emptyTerms, entry.Term())
+		entry, err = dict.Next()
+	}
+}
+
 func (t *TermsAggregation) Fields() []string {
-	rv := t.src.Fields()
+	var rv []string
+	for _, src := range t.sources {
+		rv = append(rv, src.Field.Fields()...)
+	}
 	for _, agg := range t.aggregations {
 		rv = append(rv, agg.Fields()...)
 	}
@@ -63,16 +152,25 @@ func (t *TermsAggregation) Fields() []string {
 }
 
 func (t *TermsAggregation) Calculator() search.Calculator {
-	return &TermsCalculator{
-		src:          t.src,
-		srcType:      t.srcType,
+	rv := &TermsCalculator{
+		sources:      t.sources,
 		size:         t.size,
+		shardSize:    t.shardSize,
+		minDocCount:  t.minDocCount,
+		include:      t.include,
+		orders:       t.orders,
+		emptyTerms:   t.emptyTerms,
 		aggregations: t.aggregations,
-		desc:         t.desc,
-		lessFunc:     t.lessFunc,
-		sortFunc:     t.sortFunc,
 		bucketsMap:   make(map[string]*search.Bucket),
 	}
+	rv.compareKey = compareBucketKey
+	if len(t.sources) == 1 {
+		switch t.sources[0].ValueType {
+		case NumericValueSource, NumericValuesSource:
+			rv.compareKey = compareBucketNumericKey
+		}
+	}
+	return rv
 }
 
 func (t *TermsAggregation) AddAggregation(name string, aggregation search.Aggregation) {
@@ -80,199 +178,201 @@ func (t *TermsAggregation) AddAggregation(name string, aggregation search.Aggreg
 }
 
 type TermsCalculator struct {
-	src     interface{}
-	srcType int
-	size    int
+	sources     []*TermsSource
+	size        int
+	shardSize   int
+	minDocCount int
+	include     func(term string) bool
+	orders      []*TermsOrder
+	compareKey  func(a, b *search.Bucket) int
+	emptyTerms  []string
 
 	aggregations map[string]search.Aggregation
 
-	bucketsList []*search.Bucket
-	bucketsMap  map[string]*search.Bucket
-	total       int
-	other       int
-
-	desc     bool
-	lessFunc func(a, b *search.Bucket) bool
-	sortFunc func(p sort.Interface)
+	bucketsList   []*search.Bucket
+	bucketsMap    map[string]*search.Bucket
+	total         int
+	docCountError int
+	finished      bool
 }
 
 func (a *TermsCalculator) Consume(d *search.DocumentMatch) {
-	switch a.srcType {
-	case TextValueSource:
-		a.consumeTextValueSource(d)
-	case TextValuesSource:
-		a.consumeTextValuesSource(d)
-	case NumericValueSource:
-		a.consumeNumericValueSource(d)
-	case NumericValuesSource:
-		a.consumeNumericValuesSource(d)
-	case BooleanValueSource:
-		a.consumeBooleanValueSource(d)
-	case BooleanValuesSource:
-		a.consumeBooleanValuesSource(d)
-	default:
-		// not support
-	}
-}
-
-func (a *TermsCalculator) consumeTextValueSource(d *search.DocumentMatch) {
 	a.total++
-	src := a.src.(search.TextValueSource)
-	term := src.Value(d)
-	termStr := string(term)
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
-	}
-}
-
-func (a *TermsCalculator) consumeTextValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.TextValuesSource)
-	for _, term := range src.Values(d) {
-		termStr := string(term)
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
+	var keys []string
+	for i, src := range a.sources {
+		terms := sourceTerms(src, d)
+		if len(terms) == 0 && src.Missing != nil {
+			terms = []string{*src.Missing}
+		}
+		if len(terms) == 0 {
+			return
+		}
+		if i == 0 {
+			keys = terms
+			continue
 		}
+		combined := make([]string, 0, len(keys)*len(terms))
+		for _, key := range keys {
+			for _, term := range terms {
+				combined = append(combined, key+MultiTermsSeparator+term)
+			}
+		}
+		keys = combined
 	}
-}
 
-func (a *TermsCalculator) consumeNumericValueSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValueSource)
-	term := src.Number(d)
-	termStr := strconv.FormatFloat(term, 'f', -1, 64)
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
+	for i, key := range keys {
+		if a.include != nil && !a.include(key) {
+			continue
+		}
+		if duplicated(keys[:i], key) {
+			continue
+		}
+		bucket, ok := a.bucketsMap[key]
+		if !ok {
+			bucket = search.NewBucket(key, a.aggregations)
+			a.bucketsMap[key] = bucket
+			a.bucketsList = append(a.bucketsList, bucket)
+		}
 		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
 	}
 }
 
-func (a *TermsCalculator) consumeNumericValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
-		termStr := strconv.FormatFloat(term, 'f', -1, 64)
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
+// sourceTerms returns the terms of the field of the document as strings
+func sourceTerms(src *TermsSource, d *search.DocumentMatch) []string {
+	var numbers []float64
+	switch src.ValueType {
+	case TextValueSource:
+		if term := src.Field.Value(d); term != nil {
+			return []string{string(term)}
 		}
-	}
-}
-
-func (a *TermsCalculator) consumeBooleanValueSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValueSource)
-	term := src.Number(d)
-	termStr := "false"
-	if term != 0 {
-		termStr = "true"
+		return nil
+	case TextValuesSource:
+		values := src.Field.Values(d)
+		terms := make([]string, 0, len(values))
+		for _, term := range values {
+			terms = append(terms, string(term))
+		}
+		return terms
+	case NumericValueSource, BooleanValueSource:
+		if n := src.Field.Number(d); !math.IsNaN(n) {
+			numbers = []float64{n}
+		}
+	case NumericValuesSource, BooleanValuesSource:
+		numbers = src.Field.Numbers(d)
+	default:
+		// not support
+		return nil
 	}
 
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
+	terms := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		switch src.ValueType {
+		case BooleanValueSource, BooleanValuesSource:
+			terms = append(terms, strconv.FormatBool(n != 0))
+		default:
+			terms = append(terms, strconv.FormatFloat(n, 'f', -1, 64))
+		}
 	}
+	return terms
 }
 
-func (a *TermsCalculator) consumeBooleanValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
-		termStr := "false"
-		if term != 0 {
-			termStr = "true"
-		}
-
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
+func duplicated(terms []string, term string) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
 		}
 	}
+	return false
 }
 
 func (a *TermsCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*TermsCalculator); ok {
-		// first sum to the totals and others
+		// first sum to the totals and errors
 		a.total += other.total
+		a.docCountError += other.docCountError
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
-		for i := range other.bucketsList {
-			var foundLocal bool
-			for j := range a.bucketsList {
-				if other.bucketsList[i].Name() == a.bucketsList[j].Name() {
-					a.bucketsList[j].Merge(other.bucketsList[i])
-					foundLocal = true
-				}
-			}
-			if !foundLocal {
-				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+			} else {
+				a.bucketsMap[bucket.Name()] = bucket
+				a.bucketsList = append(a.bucketsList, bucket)
 			}
 		}
-		// now re-invoke finish, this should trim to correct size again
-		// and recalculate other
-		a.Finish()
+		// trim to the shard size again, the errors were counted by the merged calculators
+		sort.Sort(a)
+		a.trim()
 	}
 }
 
 func (a *TermsCalculator) Finish() {
-	// sort the buckets
-	if a.desc {
-		a.sortFunc(sort.Reverse(a))
-	} else {
-		a.sortFunc(a)
+	// add the buckets of the terms not in the matched documents
+	for _, term := range a.emptyTerms {
+		if _, ok := a.bucketsMap[term]; ok || (a.include != nil && !a.include(term)) {
+			continue
+		}
+		bucket := search.NewBucket(term, a.aggregations)
+		a.bucketsMap[term] = bucket
+		a.bucketsList = append(a.bucketsList, bucket)
 	}
+	a.emptyTerms = nil
 
-	trimTopN := a.size
-	if trimTopN > len(a.bucketsList) {
-		trimTopN = len(a.bucketsList)
+	// sort the buckets
+	sort.Sort(a)
+
+	// a trimmed term may have at most the doc count of the last bucket, it is only counted once
+	if !a.finished && len(a.bucketsList) > a.shardSize && a.orders[0].Count && a.orders[0].Desc && a.shardSize > 0 {
+		a.docCountError += int(a.bucketsList[a.shardSize-1].Count())
 	}
-	a.bucketsList = a.bucketsList[:trimTopN]
+	a.finished = true
+	a.trim()
+}
 
-	var notOther int
-	for _, bucket := range a.bucketsList {
-		notOther += int(bucket.Aggregations()["count"].(search.MetricCalculator).Value())
+// trim keeps the top shard size buckets
+func (a *TermsCalculator) trim() {
+	if len(a.bucketsList) > a.shardSize {
+		for _, bucket := range a.bucketsList[a.shardSize:] {
+			delete(a.bucketsMap, bucket.Name())
+		}
+		a.bucketsList = a.bucketsList[:a.shardSize]
 	}
-	a.other = a.total - notOther
 }
 
+// Buckets returns the top size buckets which have at least min_doc_count documents
 func (a *TermsCalculator) Buckets() []*search.Bucket {
-	return a.bucketsList
+	rv := make([]*search.Bucket, 0, a.size)
+	for _, bucket := range a.bucketsList {
+		if len(rv) >= a.size {
+			break
+		}
+		if bucket.Count() >= uint64(a.minDocCount) {
+			rv = append(rv, bucket)
+		}
+	}
+	return rv
 }
 
+// Other returns the number of the documents not in the returned buckets
 func (a *TermsCalculator) Other() int {
-	return a.other
+	other := a.total
+	for _, bucket := range a.Buckets() {
+		other -= int(bucket.Count())
+	}
+	if other < 0 {
+		other = 0
+	}
+	return other
+}
+
+// DocCountError returns the upper bound of the error of the doc counts of the returned buckets
+func (a *TermsCalculator) DocCountError() int {
+	return a.docCountError
+}
+
+// Sources returns the fields of the aggregation, there are more than one fields for multi_terms aggregation
+func (a *TermsCalculator) Sources() []*TermsSource {
+	return a.sources
 }
 
 func (a *TermsCalculator) Len() int {
@@ -280,9 +380,60 @@ func (a *TermsCalculator) Len() int {
 }
 
 func (a *TermsCalculator) Less(i, j int) bool {
-	return a.lessFunc(a.bucketsList[i], a.bucketsList[j])
+	x, y := a.bucketsList[i], a.bucketsList[j]
+	for _, order := range a.orders {
+		c := order.Compare(x, y)
+		if order.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return a.compareKey(x, y) < 0
 }
 
 func (a *TermsCalculator) Swap(i, j int) {
 	a.bucketsList[i], a.bucketsList[j] = a.bucketsList[j], a.bucketsList[i]
 }
+
+// CompareBucketCount compares the doc counts of the buckets
+func CompareBucketCount(a, b *search.Bucket) int {
+	x, y := a.Count(), b.Count()
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareBucketKey compares the keys of the buckets, the numeric keys are compared by value
+func CompareBucketKey(numeric bool) func(a, b *search.Bucket) int {
+	if numeric {
+		return compareBucketNumericKey
+	}
+	return compareBucketKey
+}
+
+func compareBucketKey(a, b *search.Bucket) int {
+	return strings.Compare(a.Name(), b.Name())
+}
+
+func compareBucketNumericKey(a, b *search.Bucket) int {
+	x, errX := strconv.ParseFloat(a.Name(), 64)
+	y, errY := strconv.ParseFloat(b.Name(), 64)
+	if errX != nil || errY != nil {
+		return compareBucketKey(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
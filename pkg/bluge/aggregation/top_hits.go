@@ -0,0 +1,157 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"sort"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// TopHitsAggregation keeps the top documents of the bucket by the sort order,
+// the stored fields of a document are copied when it is kept because the document matches are reused by the collector.
+type TopHitsAggregation struct {
+	size   int
+	sorts  search.SortOrder
+	fields []string
+}
+
+// NewTopHitsAggregation returns a TopHitsAggregation keeps size documents,
+// fields are the stored fields of the documents need to be returned.
+func NewTopHitsAggregation(size int, sorts search.SortOrder, fields []string) *TopHitsAggregation {
+	return &TopHitsAggregation{
+		size:   size,
+		sorts:  sorts,
+		fields: fields,
+	}
+}
+
+func (t *TopHitsAggregation) Fields() []string {
+	return t.sorts.Fields()
+}
+
+func (t *TopHitsAggregation) Calculator() search.Calculator {
+	fields := make(map[string]struct{}, len(t.fields))
+	for _, field := range t.fields {
+		fields[field] = struct{}{}
+	}
+	return &TopHitsCalculator{
+		size:   t.size,
+		sorts:  t.sorts,
+		fields: fields,
+		hits:   make([]*TopHit, 0, t.size),
+	}
+}
+
+// TopHit is a document kept by the TopHitsCalculator
+type TopHit struct {
+	match  *search.DocumentMatch // only the score, sort values and hit number
+	Fields map[string][]byte     // stored fields
+}
+
+func (h *TopHit) Score() float64 {
+	return h.match.Score
+}
+
+func (h *TopHit) SortValue() [][]byte {
+	return h.match.SortValue
+}
+
+type TopHitsCalculator struct {
+	size     int
+	sorts    search.SortOrder
+	fields   map[string]struct{}
+	total    int64
+	maxScore float64
+	hits     []*TopHit // sorted
+}
+
+func (a *TopHitsCalculator) Consume(d *search.DocumentMatch) {
+	a.total++
+	if d.Score > a.maxScore {
+		a.maxScore = d.Score
+	}
+	if a.size <= 0 {
+		return
+	}
+
+	match := &search.DocumentMatch{Score: d.Score, HitNumber: d.HitNumber}
+	for _, s := range a.sorts {
+		match.SortValue = append(match.SortValue, append([]byte(nil), s.Value(d)...))
+	}
+	if len(a.hits) >= a.size && a.sorts.Compare(match, a.hits[len(a.hits)-1].match) >= 0 {
+		return
+	}
+
+	hit := &TopHit{match: match, Fields: make(map[string][]byte, len(a.fields))}
+	err := d.VisitStoredFields(func(field string, value []byte) bool {
+		if _, ok := a.fields[field]; ok {
+			hit.Fields[field] = append([]byte(nil), value...)
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+	a.insert(hit)
+}
+
+func (a *TopHitsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*TopHitsCalculator); ok {
+		a.total += other.total
+		if other.maxScore > a.maxScore {
+			a.maxScore = other.maxScore
+		}
+		for _, hit := range other.hits {
+			a.insert(hit)
+		}
+	}
+}
+
+func (a *TopHitsCalculator) Finish() {}
+
+// insert adds the hit to the sorted hits and drops the hits out of size
+func (a *TopHitsCalculator) insert(hit *TopHit) {
+	i := sort.Search(len(a.hits), func(i int) bool {
+		return a.sorts.Compare(hit.match, a.hits[i].match) < 0
+	})
+	if i >= a.size {
+		return
+	}
+	a.hits = append(a.hits, nil)
+	copy(a.hits[i+1:], a.hits[i:])
+	a.hits[i] = hit
+	if len(a.hits) > a.size {
+		a.hits = a.hits[:a.size]
+	}
+}
+
+// Total returns the number of the documents in the bucket
+func (a *TopHitsCalculator) Total() int64 {
+	return a.total
+}
+
+func (a *TopHitsCalculator) MaxScore() float64 {
+	return a.maxScore
+}
+
+func (a *TopHitsCalculator) Sorts() search.SortOrder {
+	return a.sorts
+}
+
+func (a *TopHitsCalculator) Hits() []*TopHit {
+	return a.hits
+}
@@ -26,12 +26,21 @@ import (
 // rootPath: the root path of data
 // indexName: the name of the index to use.
 func GetDiskConfig(rootPath string, indexName string, timeRange ...int64) bluge.Config {
+	return bluge.DefaultConfigWithIndexConfig(GetDiskIndexConfig(rootPath, indexName, timeRange...))
+}
+
+// GetDiskIndexConfig returns the index config of GetDiskConfig
+func GetDiskIndexConfig(rootPath string, indexName string, timeRange ...int64) index.Config {
 	config := index.DefaultConfig(path.Join(rootPath, indexName))
 	config = config.WithPersisterNapTimeMSec(50)
+	return withTimeRange(config, timeRange...)
+}
+
+func withTimeRange(config index.Config, timeRange ...int64) index.Config {
 	if len(timeRange) == 2 {
 		if timeRange[0] <= timeRange[1] {
 			config = config.WithTimeRange(timeRange[0], timeRange[1])
 		}
 	}
-	return bluge.DefaultConfigWithIndexConfig(config)
+	return config
 }
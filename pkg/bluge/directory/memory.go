@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// memoryDirectories keeps the directories of memory indexes alive when the
+// writer is closed, so the index can be reopened in the same process.
+var memoryDirectories = struct {
+	sync.Mutex
+	dirs map[string]*MemoryDirectory
+}{dirs: make(map[string]*MemoryDirectory)}
+
+// GetMemoryConfig returns a bluge config that will store index data in memory
+// indexName: the name of the index to use.
+func GetMemoryConfig(indexName string, timeRange ...int64) bluge.Config {
+	return bluge.DefaultConfigWithIndexConfig(GetMemoryIndexConfig(indexName, timeRange...))
+}
+
+// GetMemoryIndexConfig returns the index config of GetMemoryConfig
+func GetMemoryIndexConfig(indexName string, timeRange ...int64) index.Config {
+	config := index.DefaultConfigWithDirectory(func() index.Directory {
+		memoryDirectories.Lock()
+		defer memoryDirectories.Unlock()
+		dir, ok := memoryDirectories.dirs[indexName]
+		if !ok {
+			dir = NewMemoryDirectory()
+			memoryDirectories.dirs[indexName] = dir
+		}
+		return dir
+	})
+	return withTimeRange(config, timeRange...)
+}
+
+// DeleteMemoryIndex releases all the directories of the index
+func DeleteMemoryIndex(indexName string) {
+	memoryDirectories.Lock()
+	defer memoryDirectories.Unlock()
+	for name := range memoryDirectories.dirs {
+		if name == indexName || strings.HasPrefix(name, indexName+"/") {
+			delete(memoryDirectories.dirs, name)
+		}
+	}
+}
+
+// MemoryDirectory implements the bluge index.Directory, all the items are kept in memory.
+// Unlike index.InMemoryDirectory it also keeps the snapshots, so a writer can be reopened.
+type MemoryDirectory struct {
+	lock  sync.RWMutex
+	items map[string]map[uint64][]byte // kind => id => data
+}
+
+func NewMemoryDirectory() *MemoryDirectory {
+	return &MemoryDirectory{
+		items: make(map[string]map[uint64][]byte),
+	}
+}
+
+func (d *MemoryDirectory) Setup(readOnly bool) error {
+	return nil
+}
+
+func (d *MemoryDirectory) List(kind string) ([]uint64, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	ids := make([]uint64, 0, len(d.items[kind]))
+	for id := range d.items[kind] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	return ids, nil
+}
+
+func (d *MemoryDirectory) Load(kind string, id uint64) (*segment.Data, io.Closer, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	data, ok := d.items[kind][id]
+	if !ok {
+		return nil, nil, fmt.Errorf("item %d%s not found", id, kind)
+	}
+	return segment.NewDataBytes(data), nil, nil
+}
+
+func (d *MemoryDirectory) Persist(kind string, id uint64, w index.WriterTo, closeCh chan struct{}) error {
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf, closeCh); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.items[kind] == nil {
+		d.items[kind] = make(map[uint64][]byte)
+	}
+	d.items[kind][id] = buf.Bytes()
+	return nil
+}
+
+func (d *MemoryDirectory) Remove(kind string, id uint64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.items[kind], id)
+	return nil
+}
+
+// Stats returns the number of items and the memory they use
+func (d *MemoryDirectory) Stats() (numItems uint64, numBytes uint64) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, items := range d.items {
+		for _, data := range items {
+			numItems++
+			numBytes += uint64(len(data))
+		}
+	}
+	return numItems, numBytes
+}
+
+func (d *MemoryDirectory) Sync() error {
+	return nil
+}
+
+func (d *MemoryDirectory) Lock() error {
+	return nil
+}
+
+func (d *MemoryDirectory) Unlock() error {
+	return nil
+}
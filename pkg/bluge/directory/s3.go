@@ -0,0 +1,353 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/index/lock"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+const s3PidFilename = "bluge.pid"
+
+// S3Config is the connection config of an S3 compatible object storage
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix of the object keys, the index data is stored in Prefix/indexName/
+	Prefix string
+	// CacheSize is the size limit in bytes of the local cache of all the indexes, 0 means no limit
+	CacheSize int64
+	// Timeout is the time limit of a request, including uploading or downloading the object
+	Timeout time.Duration
+}
+
+// GetS3Config returns a bluge config that will store index data in an S3 compatible object storage,
+// the segments are cached in the local disk
+// cfg: the connection config of the object storage
+// rootPath: the root path of the local cache
+// indexName: the name of the index to use.
+func GetS3Config(cfg S3Config, rootPath string, indexName string, timeRange ...int64) bluge.Config {
+	return bluge.DefaultConfigWithIndexConfig(GetS3IndexConfig(cfg, rootPath, indexName, timeRange...))
+}
+
+// GetS3IndexConfig returns the index config of GetS3Config
+func GetS3IndexConfig(cfg S3Config, rootPath string, indexName string, timeRange ...int64) index.Config {
+	config := index.DefaultConfigWithDirectory(func() index.Directory {
+		return NewS3Directory(cfg, path.Join(rootPath, indexName), path.Join(cfg.Prefix, indexName))
+	})
+	config = config.WithPersisterNapTimeMSec(50)
+	return withTimeRange(config, timeRange...)
+}
+
+// DeleteS3Index removes all the objects of the index from the object storage
+func DeleteS3Index(cfg S3Config, indexName string) error {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return err
+	}
+	objects, err := client.ListObjects(path.Join(cfg.Prefix, indexName) + "/")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := client.DeleteObject(obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// S3Directory implements the bluge index.Directory, the items are stored in
+// an S3 compatible object storage and cached in a local directory.
+// An item is written to the local cache first and then uploaded, an item
+// missing in the local cache is downloaded when loading. The items which are
+// not loaded are evicted from the local cache when it exceeds CacheSize.
+type S3Directory struct {
+	cfg       S3Config
+	client    *s3Client
+	cachePath string
+	prefix    string
+	pid       lock.LockedFile
+
+	lock   sync.Mutex
+	sizes  map[string]int64 // object key => size
+	listed bool             // sizes has all the objects of the prefix
+}
+
+func NewS3Directory(cfg S3Config, cachePath, prefix string) *S3Directory {
+	return &S3Directory{
+		cfg:       cfg,
+		cachePath: cachePath,
+		prefix:    strings.Trim(prefix, "/"),
+		sizes:     make(map[string]int64),
+	}
+}
+
+func (d *S3Directory) Setup(readOnly bool) error {
+	client, err := newS3Client(d.cfg)
+	if err != nil {
+		return err
+	}
+	d.client = client
+	if err := os.MkdirAll(d.cachePath, 0o700); err != nil {
+		return fmt.Errorf("error creating cache directory '%s': %w", d.cachePath, err)
+	}
+	return s3LocalCache.scan(d.cachePath, d.cfg.CacheSize)
+}
+
+// List lists the objects once, then the items are tracked by Persist and Remove
+func (d *S3Directory) List(kind string) ([]uint64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.listed {
+		objects, err := d.client.ListObjects(d.prefix + "/")
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			d.sizes[obj.Key] = obj.Size
+		}
+		d.listed = true
+	}
+
+	ids := make([]uint64, 0, len(d.sizes))
+	for key := range d.sizes {
+		name := path.Base(key)
+		if path.Ext(name) != kind {
+			continue
+		}
+		id, err := strconv.ParseUint(name[:len(name)-len(kind)], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing identifier '%s': %w", name, err)
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	return ids, nil
+}
+
+func (d *S3Directory) Load(kind string, id uint64) (*segment.Data, io.Closer, error) {
+	cacheFile := filepath.Join(d.cachePath, d.fileName(kind, id))
+	f, err := d.openCacheFile(d.key(kind, id), cacheFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := segment.NewDataFile(f.File())
+	if err != nil {
+		_ = f.Close()
+		s3LocalCache.release(cacheFile, d.cfg.CacheSize)
+		return nil, nil, fmt.Errorf("error creating data from file: %w", err)
+	}
+	return data, &s3LoadedFile{LockedFile: f, cacheFile: cacheFile, cacheSize: d.cfg.CacheSize}, nil
+}
+
+func (d *S3Directory) Persist(kind string, id uint64, w index.WriterTo, closeCh chan struct{}) error {
+	cacheFile := filepath.Join(d.cachePath, d.fileName(kind, id))
+	f, err := lock.OpenExclusive(cacheFile, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	cleanup := func() {
+		_ = f.Close()
+		_ = os.Remove(cacheFile)
+	}
+
+	hash := sha256.New()
+	if _, err = w.WriteTo(io.MultiWriter(f.File(), hash), closeCh); err != nil {
+		cleanup()
+		return err
+	}
+	if err = f.File().Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	// the size returned by WriteTo is not reliable for all the item kinds
+	size, err := f.File().Seek(0, io.SeekCurrent)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	if _, err = f.File().Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return err
+	}
+
+	key := d.key(kind, id)
+	if err = d.client.PutObject(key, f.File(), size, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		cleanup()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	s3LocalCache.put(cacheFile, size, d.cfg.CacheSize)
+
+	d.lock.Lock()
+	d.sizes[key] = size
+	d.lock.Unlock()
+	return nil
+}
+
+func (d *S3Directory) Remove(kind string, id uint64) error {
+	key := d.key(kind, id)
+	if err := d.client.DeleteObject(key); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	delete(d.sizes, key)
+	d.lock.Unlock()
+
+	cacheFile := filepath.Join(d.cachePath, d.fileName(kind, id))
+	s3LocalCache.remove(cacheFile)
+	err := os.Remove(cacheFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Stats returns the number of items and their size in the object storage
+func (d *S3Directory) Stats() (numItems uint64, numBytes uint64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, size := range d.sizes {
+		numItems++
+		numBytes += uint64(size)
+	}
+	return numItems, numBytes
+}
+
+// Sync is a no-op, objects are visible as soon as they are uploaded
+func (d *S3Directory) Sync() error {
+	return nil
+}
+
+// Lock ensures this process has exclusive access to the local cache,
+// the object storage itself cannot be locked.
+func (d *S3Directory) Lock() error {
+	pidPath := filepath.Join(d.cachePath, s3PidFilename)
+	var err error
+	d.pid, err = lock.OpenExclusive(pidPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to obtain exclusive access: %w", err)
+	}
+	if err = d.pid.File().Truncate(0); err != nil {
+		return fmt.Errorf("error truncating pid file: %w", err)
+	}
+	if _, err = d.pid.File().WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		return fmt.Errorf("error writing pid: %w", err)
+	}
+	return nil
+}
+
+func (d *S3Directory) Unlock() error {
+	if err := d.pid.Close(); err != nil {
+		return fmt.Errorf("error closing pid file: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(d.cachePath, s3PidFilename)); err != nil {
+		return fmt.Errorf("error removing pid file: %w", err)
+	}
+	return nil
+}
+
+// openCacheFile opens the item in the local cache, it is downloaded if it is missing
+func (d *S3Directory) openCacheFile(key, cacheFile string) (lock.LockedFile, error) {
+	if s3LocalCache.acquire(cacheFile) {
+		f, err := lock.OpenShared(cacheFile, os.O_RDONLY, 0)
+		if err == nil {
+			return f, nil
+		}
+		s3LocalCache.release(cacheFile, d.cfg.CacheSize)
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// the file was removed from the local cache out of the tracking
+		s3LocalCache.remove(cacheFile)
+	}
+
+	size, err := d.download(key, cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	s3LocalCache.acquireDownloaded(cacheFile, size, d.cfg.CacheSize)
+	f, err := lock.OpenShared(cacheFile, os.O_RDONLY, 0)
+	if err != nil {
+		s3LocalCache.release(cacheFile, d.cfg.CacheSize)
+		return nil, err
+	}
+	return f, nil
+}
+
+// download fetches the object into the local cache and returns its size, the file
+// is written to a temporary name and renamed so a partial download is never loaded.
+func (d *S3Directory) download(key, cacheFile string) (int64, error) {
+	f, err := os.CreateTemp(d.cachePath, filepath.Base(cacheFile)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpFile := f.Name()
+	size, err := d.client.GetObject(key, f)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpFile)
+		return 0, fmt.Errorf("error downloading '%s': %w", key, err)
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(tmpFile)
+		return 0, err
+	}
+	return size, os.Rename(tmpFile, cacheFile)
+}
+
+// s3LoadedFile releases the item in the local cache when the loaded item is closed
+type s3LoadedFile struct {
+	lock.LockedFile
+	cacheFile string
+	cacheSize int64
+}
+
+func (f *s3LoadedFile) Close() error {
+	err := f.LockedFile.Close()
+	s3LocalCache.release(f.cacheFile, f.cacheSize)
+	return err
+}
+
+func (d *S3Directory) key(kind string, id uint64) string {
+	return d.prefix + "/" + d.fileName(kind, id)
+}
+
+func (d *S3Directory) fileName(kind string, id uint64) string {
+	return fmt.Sprintf("%012x", id) + kind
+}
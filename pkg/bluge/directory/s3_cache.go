@@ -0,0 +1,167 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// s3LocalCache is the local cache shared by all the S3 directories of the process
+var s3LocalCache = newS3Cache()
+
+// s3Cache tracks the items of the S3 directories in the local disk,
+// the least recently used items which are not loaded are evicted when
+// the size of the cache exceeds the limit.
+type s3Cache struct {
+	lock  sync.Mutex
+	size  int64
+	items map[string]*list.Element // cache file => element of *s3CacheItem
+	lru   *list.List               // the front is the most recently used
+}
+
+type s3CacheItem struct {
+	file string
+	size int64
+	refs int // the number of times the item is loaded and not closed
+}
+
+func newS3Cache() *s3Cache {
+	return &s3Cache{
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// scan adds the items left in the cache path by a previous process, the oldest first
+func (c *s3Cache) scan(cachePath string, limit int64) error {
+	entries, err := os.ReadDir(cachePath)
+	if err != nil {
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == s3PidFilename || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, info := range files {
+		file := filepath.Join(cachePath, info.Name())
+		if _, ok := c.items[file]; !ok {
+			c.add(file, info.Size(), 0)
+		}
+	}
+	c.evict(limit)
+	return nil
+}
+
+// put adds an item written to the cache, it is not loaded yet
+func (c *s3Cache) put(file string, size int64, limit int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.delete(file)
+	c.add(file, size, 0)
+	c.evict(limit)
+}
+
+// acquire marks a cached item as loaded, it returns false if the item is not in the cache
+func (c *s3Cache) acquire(file string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.items[file]
+	if !ok {
+		return false
+	}
+	elem.Value.(*s3CacheItem).refs++
+	c.lru.MoveToFront(elem)
+	return true
+}
+
+// acquireDownloaded adds a downloaded item as loaded
+func (c *s3Cache) acquireDownloaded(file string, size int64, limit int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[file]; ok {
+		// downloaded by another load at the same time
+		elem.Value.(*s3CacheItem).refs++
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.add(file, size, 1)
+	c.evict(limit)
+}
+
+// release marks a loaded item as closed and evicts the items over the limit
+func (c *s3Cache) release(file string, limit int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[file]; ok {
+		elem.Value.(*s3CacheItem).refs--
+	}
+	c.evict(limit)
+}
+
+// remove forgets an item which is removed from the cache path
+func (c *s3Cache) remove(file string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.delete(file)
+}
+
+func (c *s3Cache) add(file string, size int64, refs int) {
+	c.items[file] = c.lru.PushFront(&s3CacheItem{file: file, size: size, refs: refs})
+	c.size += size
+}
+
+func (c *s3Cache) delete(file string) {
+	if elem, ok := c.items[file]; ok {
+		c.size -= elem.Value.(*s3CacheItem).size
+		c.lru.Remove(elem)
+		delete(c.items, file)
+	}
+}
+
+// evict removes the least recently used items which are not loaded until
+// the size of the cache is under the limit, a limit <= 0 means no limit.
+func (c *s3Cache) evict(limit int64) {
+	if limit <= 0 {
+		return
+	}
+	for elem := c.lru.Back(); elem != nil && c.size > limit; {
+		prev := elem.Prev()
+		item := elem.Value.(*s3CacheItem)
+		if item.refs <= 0 {
+			if err := os.Remove(item.file); err == nil || os.IsNotExist(err) {
+				c.delete(item.file)
+			}
+		}
+		elem = prev
+	}
+}
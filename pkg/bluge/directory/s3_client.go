@@ -0,0 +1,344 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3SignAlgorithm = "AWS4-HMAC-SHA256"
+	s3Service       = "s3"
+	s3EmptySHA256   = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// s3DefaultTimeout is the time limit of a request when S3Config.Timeout is not set
+	s3DefaultTimeout = 5 * time.Minute
+	// s3MaxRetries is the max number of retries of a request failed by a network error or a 5xx status
+	s3MaxRetries = 3
+)
+
+// s3RetryBackoff is the wait before the first retry, it is doubled for each retry
+var s3RetryBackoff = 200 * time.Millisecond
+
+// s3Client is a minimal S3 compatible client which signs the requests with
+// AWS Signature Version 4 and uses path-style addressing, so it works with
+// AWS S3, MinIO and other compatible object storages.
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("s3: status %d, code: %s, message: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func isS3NotFound(err error) bool {
+	e, ok := err.(*s3Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+func newS3Client(cfg S3Config) (*s3Client, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint [%s]: %w", cfg.Endpoint, err)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = s3DefaultTimeout
+	}
+	return &s3Client{
+		endpoint:  u,
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// PutObject uploads size bytes of body, payloadHash is the hex encoded sha256 of the body
+func (c *s3Client) PutObject(key string, body io.Reader, size int64, payloadHash string) error {
+	resp, err := c.do(http.MethodPut, key, nil, body, size, payloadHash)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// GetObject writes the content of the object to w
+func (c *s3Client) GetObject(key string, w io.Writer) (int64, error) {
+	resp, err := c.do(http.MethodGet, key, nil, nil, 0, s3EmptySHA256)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// DeleteObject removes the object, it is not an error if the object does not exist
+func (c *s3Client) DeleteObject(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, 0, s3EmptySHA256)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// ListObjects returns all the objects with the prefix
+func (c *s3Client) ListObjects(prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil, 0, s3EmptySHA256)
+		if err != nil {
+			return nil, err
+		}
+		result := new(s3ListResult)
+		err = xml.NewDecoder(resp.Body).Decode(result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: decode list objects response: %w", err)
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return objects, nil
+}
+
+// do sends the request, it is retried with backoff when failed by a network error or a 5xx status,
+// the body is only retried when it is an io.Seeker.
+func (c *s3Client) do(method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	retries := s3MaxRetries
+	var offset int64
+	if body != nil {
+		// rewind the body to the offset when retrying
+		seeker, ok := body.(io.Seeker)
+		if !ok {
+			retries = 0
+		} else if n, err := seeker.Seek(0, io.SeekCurrent); err != nil {
+			retries = 0
+		} else {
+			offset = n
+		}
+	}
+
+	backoff := s3RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if body != nil {
+				if _, err := body.(io.Seeker).Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+		}
+		resp, err := c.send(method, u.String(), body, size, payloadHash)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= retries || !isS3Retryable(err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *s3Client) send(method, u string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	if body != nil {
+		// the http client closes the body if it is an io.Closer, the caller owns it
+		body = io.NopCloser(body)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		e := &s3Error{StatusCode: resp.StatusCode}
+		_ = xml.NewDecoder(resp.Body).Decode(e)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// isS3Retryable reports whether the request failed by a network error or a server error
+func isS3Retryable(err error) bool {
+	if e, ok := err.(*s3Error); ok {
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// sign adds the AWS Signature Version 4 headers to the request
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.accessKey == "" {
+		// anonymous access
+		return
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := shortDate + "/" + c.region + "/" + s3Service + "/aws4_request"
+	stringToSign := s3SignAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + s3SHA256Hex([]byte(canonicalRequest))
+
+	key := s3HMAC([]byte("AWS4"+c.secretKey), shortDate)
+	key = s3HMAC(key, c.region)
+	key = s3HMAC(key, s3Service)
+	key = s3HMAC(key, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SignAlgorithm, c.accessKey, scope, signedHeaders, signature))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3SHA256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// s3Escape escapes the string as required by AWS Signature Version 4,
+// only the unreserved characters are kept.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func s3EscapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = s3Escape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,120 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Client_Retry(t *testing.T) {
+	backoff := s3RetryBackoff
+	s3RetryBackoff = time.Millisecond
+	defer func() { s3RetryBackoff = backoff }()
+
+	newClient := func(t *testing.T, handler http.HandlerFunc, timeout time.Duration) *s3Client {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		client, err := newS3Client(S3Config{Endpoint: server.URL, Bucket: "zinc", AccessKey: "test", SecretKey: "secret", Timeout: timeout})
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("retry server errors", func(t *testing.T) {
+		var requests int32
+		var received []byte
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			received, _ = io.ReadAll(r.Body)
+		}, 0)
+
+		data := []byte("segment data")
+		err := client.PutObject("seg", bytes.NewReader(data), int64(len(data)), s3SHA256Hex(data))
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+		assert.Equal(t, data, received)
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		var requests int32
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, 0)
+
+		_, err := client.GetObject("seg", io.Discard)
+		assert.Error(t, err)
+		assert.Equal(t, int32(s3MaxRetries+1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("do not retry client errors", func(t *testing.T) {
+		var requests int32
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}, 0)
+
+		_, err := client.GetObject("seg", io.Discard)
+		assert.True(t, isS3NotFound(err))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("do not retry a body that can not rewind", func(t *testing.T) {
+		var requests int32
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, 0)
+
+		data := []byte("segment data")
+		err := client.PutObject("seg", io.MultiReader(bytes.NewReader(data)), int64(len(data)), s3SHA256Hex(data))
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		var requests int32
+		done := make(chan struct{})
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= 1 {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			_, _ = w.Write([]byte("segment data"))
+		}, 100*time.Millisecond)
+		defer close(done)
+
+		var buf bytes.Buffer
+		_, err := client.GetObject("seg", &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "segment data", buf.String())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+}
@@ -0,0 +1,149 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// BoostingQuery returns documents matching the positive query,
+// the scores of documents also matching the negative query are multiplied by negative boost.
+type BoostingQuery struct {
+	positive      bluge.Query
+	negative      bluge.Query
+	negativeBoost float64
+	boost         float64
+}
+
+func NewBoostingQuery(positive, negative bluge.Query, negativeBoost float64) *BoostingQuery {
+	return &BoostingQuery{
+		positive:      positive,
+		negative:      negative,
+		negativeBoost: negativeBoost,
+		boost:         1.0,
+	}
+}
+
+func (q *BoostingQuery) SetBoost(b float64) *BoostingQuery {
+	q.boost = b
+	return q
+}
+
+func (q *BoostingQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *BoostingQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	positive, err := q.positive.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	negative, err := q.negative.Searcher(i, options)
+	if err != nil {
+		_ = positive.Close()
+		return nil, err
+	}
+	return &BoostingSearcher{
+		positive:      positive,
+		negative:      negative,
+		negativeBoost: q.negativeBoost,
+		boost:         q.boost,
+		options:       options,
+	}, nil
+}
+
+type BoostingSearcher struct {
+	positive      search.Searcher
+	negative      search.Searcher
+	negativeBoost float64
+	boost         float64
+	currNegative  *search.DocumentMatch
+	negativeDone  bool
+	options       search.SearcherOptions
+}
+
+func (s *BoostingSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	d, err := s.positive.Next(ctx)
+	if err != nil || d == nil {
+		return d, err
+	}
+	return s.score(ctx, d)
+}
+
+func (s *BoostingSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	d, err := s.positive.Advance(ctx, number)
+	if err != nil || d == nil {
+		return d, err
+	}
+	return s.score(ctx, d)
+}
+
+// score demotes the document if it matches the negative query
+func (s *BoostingSearcher) score(ctx *search.Context, d *search.DocumentMatch) (*search.DocumentMatch, error) {
+	// the negative searcher only moves forward, as the positive searcher returns documents in order
+	if !s.negativeDone && (s.currNegative == nil || s.currNegative.Number < d.Number) {
+		if s.currNegative != nil {
+			ctx.DocumentMatchPool.Put(s.currNegative)
+		}
+		var err error
+		s.currNegative, err = s.negative.Advance(ctx, d.Number)
+		if err != nil {
+			return nil, err
+		}
+		if s.currNegative == nil {
+			s.negativeDone = true
+		}
+	}
+
+	boost := s.boost
+	if s.currNegative != nil && s.currNegative.Number == d.Number {
+		boost *= s.negativeBoost
+	}
+	if boost != 1.0 {
+		d.Score *= boost
+		if s.options.Explain && d.Explanation != nil {
+			d.Explanation = search.NewExplanation(d.Score, "boosting, product of:",
+				d.Explanation, search.NewExplanation(boost, "boost"))
+		}
+	}
+	return d, nil
+}
+
+func (s *BoostingSearcher) Close() error {
+	err0 := s.positive.Close()
+	err1 := s.negative.Close()
+	if err0 != nil {
+		return err0
+	}
+	return err1
+}
+
+func (s *BoostingSearcher) Count() uint64 {
+	return s.positive.Count()
+}
+
+func (s *BoostingSearcher) Min() int {
+	return s.positive.Min()
+}
+
+func (s *BoostingSearcher) Size() int {
+	return s.positive.Size() + s.negative.Size()
+}
+
+func (s *BoostingSearcher) DocumentMatchPoolSize() int {
+	return s.positive.DocumentMatchPoolSize() + s.negative.DocumentMatchPoolSize() + 1
+}
@@ -0,0 +1,443 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"math"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+const (
+	ScoreModeMultiply = "multiply"
+	ScoreModeSum      = "sum"
+	ScoreModeAvg      = "avg"
+	ScoreModeFirst    = "first"
+	ScoreModeMax      = "max"
+	ScoreModeMin      = "min"
+
+	BoostModeMultiply = "multiply"
+	BoostModeReplace  = "replace"
+	BoostModeSum      = "sum"
+	BoostModeAvg      = "avg"
+	BoostModeMax      = "max"
+	BoostModeMin      = "min"
+)
+
+// ScoreFunction computes a score of the document for function_score query
+type ScoreFunction interface {
+	// Fields returns the fields need to be loaded from doc values
+	Fields() []string
+	Score(doc *FunctionScoreDoc) float64
+}
+
+// FunctionScoreDoc is the document passed to score functions
+type FunctionScoreDoc struct {
+	Number uint64
+	Score  float64 // score of the inner query
+	values map[string][][]byte
+}
+
+func (d *FunctionScoreDoc) reset(number uint64, score float64) {
+	d.Number = number
+	d.Score = score
+	for k := range d.values {
+		delete(d.values, k)
+	}
+}
+
+func (d *FunctionScoreDoc) addValue(field string, term []byte) {
+	d.values[field] = append(d.values[field], term)
+}
+
+// Values returns the raw doc values of the field
+func (d *FunctionScoreDoc) Values(field string) [][]byte {
+	return d.values[field]
+}
+
+// Numbers returns the numeric doc values of the field
+func (d *FunctionScoreDoc) Numbers(field string) []float64 {
+	var rv []float64
+	for _, i64 := range d.int64s(field) {
+		rv = append(rv, numeric.Int64ToFloat64(i64))
+	}
+	return rv
+}
+
+// Dates returns the date doc values of the field
+func (d *FunctionScoreDoc) Dates(field string) []time.Time {
+	var rv []time.Time
+	for _, i64 := range d.int64s(field) {
+		rv = append(rv, time.Unix(0, i64))
+	}
+	return rv
+}
+
+func (d *FunctionScoreDoc) int64s(field string) []int64 {
+	var rv []int64
+	for _, term := range d.values[field] {
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			continue
+		}
+		if i64, err := prefixCoded.Int64(); err == nil {
+			rv = append(rv, i64)
+		}
+	}
+	return rv
+}
+
+type filteredScoreFunction struct {
+	filter   bluge.Query
+	function ScoreFunction
+	weight   float64
+}
+
+// FunctionScoreQuery modifies the scores of documents matching the query by score functions
+type FunctionScoreQuery struct {
+	query       bluge.Query
+	functions   []*filteredScoreFunction
+	scoreMode   string
+	boostMode   string
+	maxBoost    float64
+	minScore    float64
+	hasMinScore bool
+	boost       float64
+}
+
+func NewFunctionScoreQuery(query bluge.Query) *FunctionScoreQuery {
+	return &FunctionScoreQuery{
+		query:     query,
+		scoreMode: ScoreModeMultiply,
+		boostMode: BoostModeMultiply,
+		maxBoost:  math.MaxFloat64,
+		boost:     1.0,
+	}
+}
+
+// AddFunction adds a score function, the function only applies to documents matching the filter if filter is not nil,
+// the score of function is multiplied by weight, the weight is used as score if function is nil.
+func (q *FunctionScoreQuery) AddFunction(filter bluge.Query, function ScoreFunction, weight float64) *FunctionScoreQuery {
+	q.functions = append(q.functions, &filteredScoreFunction{
+		filter:   filter,
+		function: function,
+		weight:   weight,
+	})
+	return q
+}
+
+func (q *FunctionScoreQuery) SetScoreMode(mode string) *FunctionScoreQuery {
+	q.scoreMode = mode
+	return q
+}
+
+func (q *FunctionScoreQuery) SetBoostMode(mode string) *FunctionScoreQuery {
+	q.boostMode = mode
+	return q
+}
+
+func (q *FunctionScoreQuery) SetMaxBoost(maxBoost float64) *FunctionScoreQuery {
+	q.maxBoost = maxBoost
+	return q
+}
+
+// SetMinScore excludes documents whose score is less than minScore
+func (q *FunctionScoreQuery) SetMinScore(minScore float64) *FunctionScoreQuery {
+	q.minScore = minScore
+	q.hasMinScore = true
+	return q
+}
+
+func (q *FunctionScoreQuery) SetBoost(b float64) *FunctionScoreQuery {
+	q.boost = b
+	return q
+}
+
+func (q *FunctionScoreQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *FunctionScoreQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	searcher, err := q.query.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	rv := &FunctionScoreSearcher{
+		searcher:  searcher,
+		functions: make([]*functionScoreState, 0, len(q.functions)),
+		scoreMode: q.scoreMode,
+		boostMode: q.boostMode,
+		maxBoost:  q.maxBoost,
+		minScore:  q.minScore,
+		hasMin:    q.hasMinScore,
+		boost:     q.boost,
+		doc:       &FunctionScoreDoc{values: make(map[string][][]byte)},
+		options:   options,
+	}
+
+	// filters only decide if the functions apply, the scores are useless
+	filterOptions := options
+	filterOptions.Explain = false
+	filterOptions.IncludeTermVectors = false
+	filterOptions.Score = "none"
+
+	var fields []string
+	for _, fn := range q.functions {
+		state := &functionScoreState{function: fn.function, weight: fn.weight}
+		if fn.filter != nil {
+			if state.filter, err = fn.filter.Searcher(i, filterOptions); err != nil {
+				_ = rv.Close()
+				return nil, err
+			}
+		}
+		if fn.function != nil {
+			fields = append(fields, fn.function.Fields()...)
+		}
+		rv.functions = append(rv.functions, state)
+	}
+	if len(fields) > 0 {
+		if rv.dvReader, err = i.DocumentValueReader(fields); err != nil {
+			_ = rv.Close()
+			return nil, err
+		}
+	}
+
+	return rv, nil
+}
+
+type functionScoreState struct {
+	function ScoreFunction
+	weight   float64
+	filter   search.Searcher
+	curr     *search.DocumentMatch
+	done     bool
+}
+
+// match checks if the document matches the filter, the filter searcher only moves forward
+func (f *functionScoreState) match(ctx *search.Context, number uint64) (bool, error) {
+	if f.filter == nil {
+		return true, nil
+	}
+	if !f.done && (f.curr == nil || f.curr.Number < number) {
+		if f.curr != nil {
+			ctx.DocumentMatchPool.Put(f.curr)
+		}
+		var err error
+		if f.curr, err = f.filter.Advance(ctx, number); err != nil {
+			return false, err
+		}
+		if f.curr == nil {
+			f.done = true
+		}
+	}
+	return f.curr != nil && f.curr.Number == number, nil
+}
+
+type FunctionScoreSearcher struct {
+	searcher  search.Searcher
+	functions []*functionScoreState
+	dvReader  segment.DocumentValueReader
+	scoreMode string
+	boostMode string
+	maxBoost  float64
+	minScore  float64
+	hasMin    bool
+	boost     float64
+	doc       *FunctionScoreDoc
+	options   search.SearcherOptions
+}
+
+func (s *FunctionScoreSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	for {
+		d, err := s.searcher.Next(ctx)
+		if err != nil || d == nil {
+			return d, err
+		}
+		ok, err := s.score(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return d, nil
+		}
+		ctx.DocumentMatchPool.Put(d)
+	}
+}
+
+func (s *FunctionScoreSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	d, err := s.searcher.Advance(ctx, number)
+	if err != nil || d == nil {
+		return d, err
+	}
+	ok, err := s.score(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return d, nil
+	}
+	ctx.DocumentMatchPool.Put(d)
+	return s.Next(ctx)
+}
+
+// score computes the score of document, it returns false if the score is less than min score
+func (s *FunctionScoreSearcher) score(ctx *search.Context, d *search.DocumentMatch) (bool, error) {
+	s.doc.reset(d.Number, d.Score)
+	if s.dvReader != nil {
+		if err := s.dvReader.VisitDocumentValues(d.Number, s.doc.addValue); err != nil {
+			return false, err
+		}
+	}
+
+	var scores, weights []float64
+	for _, fn := range s.functions {
+		ok, err := fn.match(ctx, d.Number)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		score := fn.weight
+		if fn.function != nil {
+			score *= fn.function.Score(s.doc)
+		}
+		scores = append(scores, score)
+		weights = append(weights, fn.weight)
+		if s.scoreMode == ScoreModeFirst {
+			break
+		}
+	}
+
+	funcScore := 1.0
+	if len(scores) > 0 {
+		funcScore = combineScores(s.scoreMode, scores, weights)
+	}
+	funcScore = math.Min(funcScore, s.maxBoost)
+
+	queryScore := d.Score
+	switch s.boostMode {
+	case BoostModeReplace:
+		d.Score = funcScore
+	case BoostModeSum:
+		d.Score = queryScore + funcScore
+	case BoostModeAvg:
+		d.Score = (queryScore + funcScore) / 2
+	case BoostModeMax:
+		d.Score = math.Max(queryScore, funcScore)
+	case BoostModeMin:
+		d.Score = math.Min(queryScore, funcScore)
+	default:
+		d.Score = queryScore * funcScore
+	}
+	d.Score *= s.boost
+
+	if s.options.Explain {
+		children := []*search.Explanation{search.NewExplanation(funcScore, "function score, score mode ["+s.scoreMode+"]")}
+		if d.Explanation != nil {
+			children = append([]*search.Explanation{d.Explanation}, children...)
+		}
+		if s.boost != 1.0 {
+			children = append(children, search.NewExplanation(s.boost, "boost"))
+		}
+		d.Explanation = search.NewExplanation(d.Score, "function score, boost mode ["+s.boostMode+"]", children...)
+	}
+
+	return !s.hasMin || d.Score >= s.minScore, nil
+}
+
+func combineScores(mode string, scores, weights []float64) float64 {
+	rv := scores[0]
+	switch mode {
+	case ScoreModeSum:
+		for _, v := range scores[1:] {
+			rv += v
+		}
+	case ScoreModeAvg:
+		// weighted average, the scores are already multiplied by weights
+		sumWeight := weights[0]
+		for i := 1; i < len(scores); i++ {
+			rv += scores[i]
+			sumWeight += weights[i]
+		}
+		if sumWeight != 0 {
+			rv /= sumWeight
+		}
+	case ScoreModeFirst:
+	case ScoreModeMax:
+		for _, v := range scores[1:] {
+			rv = math.Max(rv, v)
+		}
+	case ScoreModeMin:
+		for _, v := range scores[1:] {
+			rv = math.Min(rv, v)
+		}
+	default:
+		for _, v := range scores[1:] {
+			rv *= v
+		}
+	}
+	return rv
+}
+
+func (s *FunctionScoreSearcher) Close() error {
+	var err error
+	if s.searcher != nil {
+		err = s.searcher.Close()
+	}
+	for _, fn := range s.functions {
+		if fn.filter == nil {
+			continue
+		}
+		if e := fn.filter.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *FunctionScoreSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *FunctionScoreSearcher) Min() int {
+	return s.searcher.Min()
+}
+
+func (s *FunctionScoreSearcher) Size() int {
+	sum := s.searcher.Size()
+	for _, fn := range s.functions {
+		if fn.filter != nil {
+			sum += fn.filter.Size()
+		}
+	}
+	return sum
+}
+
+func (s *FunctionScoreSearcher) DocumentMatchPoolSize() int {
+	sum := s.searcher.DocumentMatchPoolSize()
+	for _, fn := range s.functions {
+		if fn.filter != nil {
+			// the filter state keeps its current match out of the pool
+			sum += fn.filter.DocumentMatchPoolSize() + 1
+		}
+	}
+	return sum
+}
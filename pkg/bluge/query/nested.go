@@ -0,0 +1,262 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"math"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+const (
+	NestedScoreModeAvg  = "avg"
+	NestedScoreModeMax  = "max"
+	NestedScoreModeMin  = "min"
+	NestedScoreModeSum  = "sum"
+	NestedScoreModeNone = "none"
+)
+
+// NestedQuery matches the documents which have hidden nested documents matching the query,
+// the score of a document is computed from the scores of its matching nested documents by score mode.
+type NestedQuery struct {
+	query       bluge.Query
+	parentField string
+	scoreMode   string
+	boost       float64
+}
+
+// NewNestedQuery returns a NestedQuery, query should only match the nested documents of one path,
+// parentField is the field of the nested documents which stores the _id of the parent document.
+func NewNestedQuery(query bluge.Query, parentField string) *NestedQuery {
+	return &NestedQuery{
+		query:       query,
+		parentField: parentField,
+		scoreMode:   NestedScoreModeAvg,
+		boost:       1.0,
+	}
+}
+
+func (q *NestedQuery) SetScoreMode(mode string) *NestedQuery {
+	q.scoreMode = mode
+	return q
+}
+
+func (q *NestedQuery) SetBoost(b float64) *NestedQuery {
+	q.boost = b
+	return q
+}
+
+func (q *NestedQuery) Boost() float64 {
+	return q.boost
+}
+
+// Searcher collects all the matching nested documents first,
+// because the nested documents are not ordered with their parent documents.
+func (q *NestedQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	childOptions := options
+	childOptions.Explain = false
+	childOptions.IncludeTermVectors = false
+	if q.scoreMode == NestedScoreModeNone {
+		childOptions.Score = "none"
+	}
+	searcher, err := q.query.Searcher(i, childOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer searcher.Close()
+
+	dvReader, err := i.DocumentValueReader([]string{q.parentField})
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make(map[string]*nestedMatch)
+	ctx := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	d, err := searcher.Next(ctx)
+	for err == nil && d != nil {
+		var parent string
+		err = dvReader.VisitDocumentValues(d.Number, func(field string, term []byte) {
+			parent = string(term)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if parent != "" {
+			m, ok := parents[parent]
+			if !ok {
+				m = &nestedMatch{min: math.Inf(1), max: math.Inf(-1)}
+				parents[parent] = m
+			}
+			m.add(d.Score)
+		}
+		ctx.DocumentMatchPool.Put(d)
+		d, err = searcher.Next(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// find the parent documents by _id
+	matches := make([]*nestedMatch, 0, len(parents))
+	for id, m := range parents {
+		postings, err := i.PostingsIterator([]byte(id), "_id", false, false, false)
+		if err != nil {
+			return nil, err
+		}
+		p, err := postings.Next()
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			continue // parent was deleted
+		}
+		m.number = p.Number()
+		m.score = q.score(m) * q.boost
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].number < matches[j].number
+	})
+
+	return &NestedSearcher{
+		reader:  i,
+		matches: matches,
+		options: options,
+	}, nil
+}
+
+func (q *NestedQuery) score(m *nestedMatch) float64 {
+	switch q.scoreMode {
+	case NestedScoreModeMax:
+		return m.max
+	case NestedScoreModeMin:
+		return m.min
+	case NestedScoreModeSum:
+		return m.sum
+	case NestedScoreModeNone:
+		return 0
+	default:
+		return m.sum / float64(m.count)
+	}
+}
+
+type nestedMatch struct {
+	number uint64
+	score  float64
+	count  int
+	sum    float64
+	min    float64
+	max    float64
+}
+
+func (m *nestedMatch) add(score float64) {
+	m.count++
+	m.sum += score
+	m.min = math.Min(m.min, score)
+	m.max = math.Max(m.max, score)
+}
+
+// NestedSearcher returns the parent documents found by NestedQuery in order
+type NestedSearcher struct {
+	reader  search.Reader
+	matches []*nestedMatch
+	pos     int
+	options search.SearcherOptions
+}
+
+func (s *NestedSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if s.pos >= len(s.matches) {
+		return nil, nil
+	}
+	m := s.matches[s.pos]
+	s.pos++
+
+	d := ctx.DocumentMatchPool.Get()
+	d.SetReader(s.reader)
+	d.Number = m.number
+	d.Score = m.score
+	if s.options.Explain {
+		d.Explanation = search.NewExplanation(m.score, "nested, score of matching nested documents",
+			search.NewExplanation(float64(m.count), "matching nested documents"))
+	}
+	return d, nil
+}
+
+func (s *NestedSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	for s.pos < len(s.matches) && s.matches[s.pos].number < number {
+		s.pos++
+	}
+	return s.Next(ctx)
+}
+
+func (s *NestedSearcher) Close() error {
+	return nil
+}
+
+func (s *NestedSearcher) Count() uint64 {
+	return uint64(len(s.matches))
+}
+
+func (s *NestedSearcher) Min() int {
+	return 0
+}
+
+func (s *NestedSearcher) Size() int {
+	return len(s.matches)
+}
+
+func (s *NestedSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
+
+// RootQuery wraps the query of a search request on an index which has nested documents,
+// it excludes the hidden nested documents from the results,
+// and provides the reader of the index to the aggregations which need to search the documents by themselves.
+type RootQuery struct {
+	query     bluge.Query
+	pathField string
+	hooks     []func(search.Reader)
+}
+
+// NewRootQuery returns a RootQuery, pathField is the field which only exists in the nested documents,
+// it is empty if the index has no nested documents.
+func NewRootQuery(query bluge.Query, pathField string) *RootQuery {
+	return &RootQuery{
+		query:     query,
+		pathField: pathField,
+	}
+}
+
+// AddReaderHook adds a function which is called with the reader before searching
+func (q *RootQuery) AddReaderHook(fn func(search.Reader)) *RootQuery {
+	q.hooks = append(q.hooks, fn)
+	return q
+}
+
+func (q *RootQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	for _, fn := range q.hooks {
+		fn(i)
+	}
+	if q.pathField == "" {
+		return q.query.Searcher(i, options)
+	}
+	return bluge.NewBooleanQuery().
+		AddMust(q.query).
+		AddMustNot(bluge.NewWildcardQuery("*").SetField(q.pathField)).
+		Searcher(i, options)
+}
@@ -0,0 +1,240 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils/hash/fnv64"
+)
+
+const (
+	FieldValueFactorModifierNone       = "none"
+	FieldValueFactorModifierLog        = "log"
+	FieldValueFactorModifierLog1p      = "log1p"
+	FieldValueFactorModifierLog2p      = "log2p"
+	FieldValueFactorModifierLn         = "ln"
+	FieldValueFactorModifierLn1p       = "ln1p"
+	FieldValueFactorModifierLn2p       = "ln2p"
+	FieldValueFactorModifierSquare     = "square"
+	FieldValueFactorModifierSqrt       = "sqrt"
+	FieldValueFactorModifierReciprocal = "reciprocal"
+
+	DecayGauss  = "gauss"
+	DecayExp    = "exp"
+	DecayLinear = "linear"
+
+	MultiValueModeMin = "min"
+	MultiValueModeMax = "max"
+	MultiValueModeAvg = "avg"
+	MultiValueModeSum = "sum"
+)
+
+// FieldValueFactorFunction uses the value of a numeric field as score
+type FieldValueFactorFunction struct {
+	field      string
+	factor     float64
+	modifier   string
+	missing    float64
+	hasMissing bool
+}
+
+func NewFieldValueFactorFunction(field string, factor float64, modifier string) *FieldValueFactorFunction {
+	return &FieldValueFactorFunction{
+		field:    field,
+		factor:   factor,
+		modifier: modifier,
+	}
+}
+
+// SetMissing sets the value used for documents without the field,
+// if it is not set the function returns 1 for these documents.
+func (f *FieldValueFactorFunction) SetMissing(missing float64) *FieldValueFactorFunction {
+	f.missing = missing
+	f.hasMissing = true
+	return f
+}
+
+func (f *FieldValueFactorFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *FieldValueFactorFunction) Score(doc *FunctionScoreDoc) float64 {
+	var v float64
+	if values := doc.Numbers(f.field); len(values) > 0 {
+		v = values[0]
+	} else if f.hasMissing {
+		v = f.missing
+	} else {
+		return 1
+	}
+
+	v *= f.factor
+	switch f.modifier {
+	case FieldValueFactorModifierLog:
+		return math.Log10(v)
+	case FieldValueFactorModifierLog1p:
+		return math.Log10(v + 1)
+	case FieldValueFactorModifierLog2p:
+		return math.Log10(v + 2)
+	case FieldValueFactorModifierLn:
+		return math.Log(v)
+	case FieldValueFactorModifierLn1p:
+		return math.Log1p(v)
+	case FieldValueFactorModifierLn2p:
+		return math.Log(v + 2)
+	case FieldValueFactorModifierSquare:
+		return v * v
+	case FieldValueFactorModifierSqrt:
+		return math.Sqrt(v)
+	case FieldValueFactorModifierReciprocal:
+		return 1 / v
+	default:
+		return v
+	}
+}
+
+// DecayFunction scores documents by the distance of a numeric or date field from the origin,
+// values of date fields are compared in nanoseconds.
+type DecayFunction struct {
+	field          string
+	kind           string
+	date           bool
+	origin         float64
+	offset         float64
+	multiValueMode string
+	// decay parameter computed from scale and decay
+	param float64
+}
+
+// NewDecayFunction returns a DecayFunction, kind is one of gauss, exp and linear,
+// the score is decay when the distance from origin is offset + scale.
+func NewDecayFunction(kind, field string, date bool, origin, scale, offset, decay float64) *DecayFunction {
+	rv := &DecayFunction{
+		field:          field,
+		kind:           kind,
+		date:           date,
+		origin:         origin,
+		offset:         offset,
+		multiValueMode: MultiValueModeMin,
+	}
+	switch kind {
+	case DecayExp:
+		rv.param = math.Log(decay) / scale
+	case DecayLinear:
+		rv.param = scale / (1.0 - decay)
+	default:
+		rv.param = -scale * scale / (2.0 * math.Log(decay))
+	}
+	return rv
+}
+
+func (f *DecayFunction) SetMultiValueMode(mode string) *DecayFunction {
+	f.multiValueMode = mode
+	return f
+}
+
+func (f *DecayFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *DecayFunction) Score(doc *FunctionScoreDoc) float64 {
+	var values []float64
+	if f.date {
+		for _, t := range doc.Dates(f.field) {
+			values = append(values, float64(t.UnixNano()))
+		}
+	} else {
+		values = doc.Numbers(f.field)
+	}
+	if len(values) == 0 {
+		return 1
+	}
+
+	var distance float64
+	for i, v := range values {
+		d := math.Max(0, math.Abs(v-f.origin)-f.offset)
+		switch {
+		case i == 0:
+			distance = d
+		case f.multiValueMode == MultiValueModeMax:
+			distance = math.Max(distance, d)
+		case f.multiValueMode == MultiValueModeAvg, f.multiValueMode == MultiValueModeSum:
+			distance += d
+		default:
+			distance = math.Min(distance, d)
+		}
+	}
+	if f.multiValueMode == MultiValueModeAvg {
+		distance /= float64(len(values))
+	}
+
+	switch f.kind {
+	case DecayExp:
+		return math.Exp(f.param * distance)
+	case DecayLinear:
+		return math.Max(0, (f.param-distance)/f.param)
+	default:
+		return math.Exp(-distance * distance / (2.0 * f.param))
+	}
+}
+
+// RandomScoreFunction generates scores uniformly distributed in [0, 1),
+// the scores are reproducible for the same seed and the same field values.
+type RandomScoreFunction struct {
+	seed  string
+	field string
+}
+
+func NewRandomScoreFunction(seed, field string) *RandomScoreFunction {
+	return &RandomScoreFunction{
+		seed:  seed,
+		field: field,
+	}
+}
+
+func (f *RandomScoreFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *RandomScoreFunction) Score(doc *FunctionScoreDoc) float64 {
+	key := strconv.FormatUint(doc.Number, 10)
+	if values := doc.Values(f.field); len(values) > 0 {
+		key = string(values[0])
+	}
+	h := fnv64.NewDefaultHasher().Sum64(f.seed + ":" + key)
+	return float64(h>>11) / (1 << 53)
+}
+
+// ScoreFunc adapts a function to ScoreFunction
+type ScoreFunc struct {
+	fields []string
+	fn     func(doc *FunctionScoreDoc) float64
+}
+
+// NewScoreFunc returns a ScoreFunc, fields are the fields need to be loaded from doc values
+func NewScoreFunc(fields []string, fn func(doc *FunctionScoreDoc) float64) *ScoreFunc {
+	return &ScoreFunc{fields: fields, fn: fn}
+}
+
+func (f *ScoreFunc) Fields() []string {
+	return f.fields
+}
+
+func (f *ScoreFunc) Score(doc *FunctionScoreDoc) float64 {
+	return f.fn(doc)
+}
@@ -0,0 +1,255 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// TermsSetMinimumFunc returns the number of terms a document must match,
+// values contains the first numeric doc value of the required fields,
+// it returns false if the number can't be computed for the document.
+type TermsSetMinimumFunc func(numTerms int, values map[string]float64) (float64, bool)
+
+// TermsSetQuery returns documents matching at least the required number of terms,
+// the required number is computed per document by the minimum function.
+type TermsSetQuery struct {
+	queries []bluge.Query
+	fields  []string
+	minimum TermsSetMinimumFunc
+	boost   float64
+}
+
+// NewTermsSetQuery returns a TermsSetQuery, queries are the term queries,
+// fields are the numeric fields read from doc values for the minimum function.
+func NewTermsSetQuery(queries []bluge.Query, fields []string, minimum TermsSetMinimumFunc) *TermsSetQuery {
+	return &TermsSetQuery{
+		queries: queries,
+		fields:  fields,
+		minimum: minimum,
+		boost:   1.0,
+	}
+}
+
+func (q *TermsSetQuery) SetBoost(b float64) *TermsSetQuery {
+	q.boost = b
+	return q
+}
+
+func (q *TermsSetQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *TermsSetQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	searchers := make([]search.Searcher, 0, len(q.queries))
+	closeAll := func() {
+		for _, s := range searchers {
+			_ = s.Close()
+		}
+	}
+	for _, subq := range q.queries {
+		s, err := subq.Searcher(i, options)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		searchers = append(searchers, s)
+	}
+	dvReader, err := i.DocumentValueReader(q.fields)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return &TermsSetSearcher{
+		searchers: searchers,
+		currs:     make([]*search.DocumentMatch, len(searchers)),
+		dvReader:  dvReader,
+		minimum:   q.minimum,
+		boost:     q.boost,
+		values:    make(map[string]float64, len(q.fields)),
+		options:   options,
+	}, nil
+}
+
+type TermsSetSearcher struct {
+	searchers   []search.Searcher
+	currs       []*search.DocumentMatch
+	dvReader    segment.DocumentValueReader
+	minimum     TermsSetMinimumFunc
+	boost       float64
+	values      map[string]float64
+	initialized bool
+	options     search.SearcherOptions
+}
+
+func (s *TermsSetSearcher) initSearchers(ctx *search.Context) error {
+	var err error
+	for i, searcher := range s.searchers {
+		if s.currs[i], err = searcher.Next(ctx); err != nil {
+			return err
+		}
+	}
+	s.initialized = true
+	return nil
+}
+
+func (s *TermsSetSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initSearchers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s.next(ctx)
+}
+
+func (s *TermsSetSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initSearchers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	for i, searcher := range s.searchers {
+		if s.currs[i] == nil || s.currs[i].Number >= number {
+			continue
+		}
+		ctx.DocumentMatchPool.Put(s.currs[i])
+		if s.currs[i], err = searcher.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+	}
+	return s.next(ctx)
+}
+
+// next returns the first document from the current position which matches enough terms
+func (s *TermsSetSearcher) next(ctx *search.Context) (*search.DocumentMatch, error) {
+	var err error
+	for {
+		var rv *search.DocumentMatch
+		for _, curr := range s.currs {
+			if curr != nil && (rv == nil || curr.Number < rv.Number) {
+				rv = curr
+			}
+		}
+		if rv == nil {
+			return nil, nil
+		}
+
+		number := rv.Number
+		matched := 0
+		score := 0.0
+		var explanations []*search.Explanation
+		for i, curr := range s.currs {
+			if curr == nil || curr.Number != number {
+				continue
+			}
+			matched++
+			score += curr.Score
+			if s.options.Explain && curr.Explanation != nil {
+				explanations = append(explanations, curr.Explanation)
+			}
+			if curr != rv {
+				ctx.DocumentMatchPool.Put(curr)
+			}
+			if s.currs[i], err = s.searchers[i].Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		ok, err := s.match(number, matched)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			ctx.DocumentMatchPool.Put(rv)
+			continue
+		}
+
+		rv.Score = score * s.boost
+		if s.options.Explain {
+			rv.Explanation = search.NewExplanation(rv.Score, "terms_set, sum of:", explanations...)
+		}
+		return rv, nil
+	}
+}
+
+// match checks if the number of matched terms reaches the minimum of the document
+func (s *TermsSetSearcher) match(number uint64, matched int) (bool, error) {
+	for k := range s.values {
+		delete(s.values, k)
+	}
+	err := s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		if _, ok := s.values[field]; ok {
+			return
+		}
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			return
+		}
+		if i64, err := prefixCoded.Int64(); err == nil {
+			s.values[field] = numeric.Int64ToFloat64(i64)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	minimum, ok := s.minimum(len(s.searchers), s.values)
+	if !ok {
+		return false, nil
+	}
+	return float64(matched) >= minimum, nil
+}
+
+func (s *TermsSetSearcher) Close() error {
+	var err error
+	for _, searcher := range s.searchers {
+		if e := searcher.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *TermsSetSearcher) Count() uint64 {
+	var sum uint64
+	for _, searcher := range s.searchers {
+		sum += searcher.Count()
+	}
+	return sum
+}
+
+func (s *TermsSetSearcher) Min() int {
+	return 0
+}
+
+func (s *TermsSetSearcher) Size() int {
+	var sum int
+	for _, searcher := range s.searchers {
+		sum += searcher.Size()
+	}
+	return sum
+}
+
+func (s *TermsSetSearcher) DocumentMatchPoolSize() int {
+	sum := 1
+	for _, searcher := range s.searchers {
+		sum += searcher.DocumentMatchPoolSize()
+	}
+	return sum
+}
@@ -54,14 +54,18 @@ type config struct {
 	BatchSize                 int           `env:"ZINC_BATCH_SIZE,default=1024"`
 	MaxResults                int           `env:"ZINC_MAX_RESULTS,default=10000"`
 	AggregationTermsSize      int           `env:"ZINC_AGGREGATION_TERMS_SIZE,default=1000"`
-	MaxDocumentSize           int           `env:"ZINC_MAX_DOCUMENT_SIZE,default=1m"`      // Max size for a single document . Default = 1 MB = 1024 * 1024
-	WalSyncInterval           time.Duration `env:"ZINC_WAL_SYNC_INTERVAL,default=1s"`      // sync wal to disk, 1s, 10ms
-	WalRedoLogNoSync          bool          `env:"ZINC_WAL_REDOLOG_NO_SYNC,default=false"` // control sync after every write
+	ScrollMaxOpenContexts     int           `env:"ZINC_SCROLL_MAX_OPEN_CONTEXTS,default=500"`
+	MaxDocumentSize           int           `env:"ZINC_MAX_DOCUMENT_SIZE,default=1m"`        // Max size for a single document . Default = 1 MB = 1024 * 1024
+	WalSyncInterval           time.Duration `env:"ZINC_WAL_SYNC_INTERVAL,default=1s"`        // sync wal to disk, 1s, 10ms
+	WalRedoLogNoSync          bool          `env:"ZINC_WAL_REDOLOG_NO_SYNC,default=false"`   // control sync after every write
+	ILMCheckInterval          time.Duration `env:"ZINC_ILM_CHECK_INTERVAL,default=10m"`      // check index lifecycle policies
+	RetentionCheckInterval    time.Duration `env:"ZINC_RETENTION_CHECK_INTERVAL,default=1h"` // check index retention
 	ZincSwaggerEnable         bool          `env:"ZINC_SWAGGER_ENABLE,default=true"`
 	LogLevel                  string        `env:"ZINC_LOG_LEVEL,default=debug"`
 	Cluster                   cluster
 	Shard                     shard
 	Etcd                      etcd
+	S3                        s3
 	Plugin                    plugin
 }
 
@@ -85,6 +89,20 @@ type etcd struct {
 	Password  string   `env:"ZINC_ETCD_PASSWORD"`
 }
 
+type s3 struct {
+	// Endpoint of the S3 compatible object storage, default is AWS S3 of the region
+	Endpoint  string `env:"ZINC_S3_ENDPOINT"`
+	Region    string `env:"ZINC_S3_REGION,default=us-east-1"`
+	Bucket    string `env:"ZINC_S3_BUCKET"`
+	AccessKey string `env:"ZINC_S3_ACCESS_KEY_ID"`
+	SecretKey string `env:"ZINC_S3_SECRET_ACCESS_KEY"`
+	Prefix    string `env:"ZINC_S3_PREFIX"`
+	// CacheSize is the size limit of the local segment cache, the least recently used closed segments are evicted
+	CacheSize int64 `env:"ZINC_S3_CACHE_SIZE,default=10g"`
+	// Timeout of a request to the object storage, the failed requests are retried
+	Timeout time.Duration `env:"ZINC_S3_TIMEOUT,default=5m"`
+}
+
 type plugin struct {
 	ES  elasticsearch
 	GSE gse
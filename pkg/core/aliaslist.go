@@ -63,16 +63,15 @@ func (al *AliasList) RemoveIndexesFromAlias(alias string, removeIndexes []string
 		removeIndexesMap[index] = true
 	}
 
-	lastIndex := len(indexes)
-	for i := 0; i < lastIndex; i++ {
-		if _, ok := removeIndexesMap[indexes[i]]; ok {
-			indexes[lastIndex-1], indexes[i] = indexes[i], indexes[lastIndex-1]
-			i--
-			lastIndex--
+	// keep the order of the remaining indexes, the last one is the write index
+	remaining := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		if _, ok := removeIndexesMap[index]; !ok {
+			remaining = append(remaining, index)
 		}
 	}
 
-	al.Aliases[alias] = indexes[:lastIndex]
+	al.Aliases[alias] = remaining
 
 	err := metadata.Alias.Set(al.Aliases)
 	if err != nil {
@@ -100,6 +99,17 @@ func (al *AliasList) GetIndexesForAlias(aliasName string) ([]string, bool) {
 	return v, ok
 }
 
+// GetWriteIndexForAlias returns the last added index of the alias, it accepts the writes to the alias
+func (al *AliasList) GetWriteIndexForAlias(aliasName string) (string, bool) {
+	al.lock.RLock()
+	defer al.lock.RUnlock()
+	idx := al.Aliases[aliasName]
+	if len(idx) == 0 {
+		return "", false
+	}
+	return idx[len(idx)-1], true
+}
+
 func (al *AliasList) GetAliasesForIndex(indexName string) []string {
 	al.lock.RLock()
 	var aliases []string
@@ -90,6 +90,18 @@ func TestAliasList_RemoveIndexesFromAlias(t *testing.T) {
 			wantErr:     false,
 			wantIndexes: []string{"index_0", "index_2"},
 		},
+		{
+			name: "should_keep_order_of_remaining_indexes",
+			nFn: func(al *AliasList) {
+				al.Aliases["alias_1"] = append(al.Aliases["alias_1"], "index_0", "index_1", "index_2", "index_3")
+			},
+			args: args{
+				alias:         "alias_1",
+				removeIndexes: []string{"index_0"},
+			},
+			wantErr:     false,
+			wantIndexes: []string{"index_1", "index_2", "index_3"},
+		},
 		{
 			name: "should_not_find_alias",
 			nFn:  nil,
@@ -170,6 +182,28 @@ func TestAliasList_GetIndexesForAlias(t *testing.T) {
 	}
 }
 
+func TestAliasList_GetWriteIndexForAlias(t *testing.T) {
+	al := NewAliasList()
+	_, ok := al.GetWriteIndexForAlias("alias_1")
+	require.False(t, ok)
+
+	al.Aliases["alias_1"] = append(al.Aliases["alias_1"], "index_0", "index_1", "index_2")
+	writeIndex, ok := al.GetWriteIndexForAlias("alias_1")
+	require.True(t, ok)
+	require.Equal(t, "index_2", writeIndex)
+
+	// removing an older index keeps the write index
+	require.NoError(t, al.RemoveIndexesFromAlias("alias_1", []string{"index_0"}))
+	writeIndex, ok = al.GetWriteIndexForAlias("alias_1")
+	require.True(t, ok)
+	require.Equal(t, "index_2", writeIndex)
+
+	require.NoError(t, al.AddIndexesToAlias("alias_1", []string{"index_3"}))
+	writeIndex, ok = al.GetWriteIndexForAlias("alias_1")
+	require.True(t, ok)
+	require.Equal(t, "index_3", writeIndex)
+}
+
 func TestAliasList_GetAliasesForIndex(t *testing.T) {
 	type args struct {
 		indexName string
@@ -21,6 +21,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/zincsearch/zincsearch/pkg/bluge/directory"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/metadata"
 )
@@ -36,12 +37,25 @@ func DeleteIndex(name string) error {
 	ZINC_INDEX_LIST.Delete(name)
 
 	// 3. Physically delete the index
+	deleteIndexStorage(index.GetName(), index.GetStorageType())
+
+	// 4. Delete form metadata
+	return metadata.Index.Delete(name)
+}
+
+// deleteIndexStorage removes the data of the index or the second layer shard from the storage
+func deleteIndexStorage(name, storageType string) {
 	dataPath := config.Global.DataPath
-	err := os.RemoveAll(dataPath + "/" + index.GetName())
+	err := os.RemoveAll(dataPath + "/" + name)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to delete index")
 	}
-
-	// 4. Delete form metadata
-	return metadata.Index.Delete(name)
+	switch storageType {
+	case "s3":
+		if err := directory.DeleteS3Index(getS3Config(), name); err != nil {
+			log.Error().Err(err).Msg("failed to delete index from s3")
+		}
+	case "memory":
+		directory.DeleteMemoryIndex(name)
+	}
 }
@@ -0,0 +1,354 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/metadata"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+const (
+	ILMPhaseHot    = "hot"
+	ILMPhaseWarm   = "warm"
+	ILMPhaseDelete = "delete"
+)
+
+var rolloverIndexNameRe = regexp.MustCompile(`^(.*-)(\d+)$`)
+
+// StartILM checks the ILM policies and the retention of all the indexes periodically
+func StartILM() {
+	go runILM(config.Global.ILMCheckInterval)
+	go runRetention(config.Global.RetentionCheckInterval)
+}
+
+func runILM(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	for range tick.C {
+		if err := CheckILM(); err != nil {
+			log.Error().Err(err).Msg("failed to check index lifecycle policies")
+		}
+	}
+}
+
+// ListILMPolicies returns all ILM policies
+func ListILMPolicies() ([]*meta.ILMPolicy, error) {
+	policies, err := metadata.ILM.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = make([]*meta.ILMPolicy, 0)
+	}
+	return policies, nil
+}
+
+// LoadILMPolicy load a specific ILM policy
+func LoadILMPolicy(name string) (*meta.ILMPolicy, bool, error) {
+	if name == "" {
+		return nil, false, nil
+	}
+
+	policy, err := metadata.ILM.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return policy, true, nil
+}
+
+// NewILMPolicy create or update an ILM policy
+func NewILMPolicy(name string, policy *meta.ILMPolicyBody) error {
+	if name == "" {
+		return fmt.Errorf("ilm: policy name should be not empty")
+	}
+	if policy == nil {
+		return fmt.Errorf("ilm: policy should be not empty")
+	}
+	if err := checkILMPolicy(policy); err != nil {
+		return err
+	}
+
+	oldPolicy, exists, err := LoadILMPolicy(name)
+	if err != nil {
+		return err
+	}
+	newPolicy := meta.ILMPolicy{
+		Name:      name,
+		Policy:    policy,
+		Version:   1,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if exists {
+		newPolicy.Version = oldPolicy.Version + 1
+		newPolicy.CreatedAt = oldPolicy.CreatedAt
+	}
+	if err := metadata.ILM.Set(name, newPolicy); err != nil {
+		return fmt.Errorf("ilm: error updating policy: %s", err.Error())
+	}
+	return nil
+}
+
+// DeleteILMPolicy delete an ILM policy, the policy can not be deleted when it is used by any index
+func DeleteILMPolicy(name string) error {
+	for _, index := range ZINC_INDEX_LIST.List() {
+		settings := index.GetSettings()
+		if settings != nil && settings.Lifecycle != nil && settings.Lifecycle.Name == name {
+			return fmt.Errorf("ilm: policy [%s] is in use by index [%s]", name, index.GetName())
+		}
+	}
+	return metadata.ILM.Delete(name)
+}
+
+func checkILMPolicy(policy *meta.ILMPolicyBody) error {
+	phases := map[string]*meta.ILMPhase{
+		ILMPhaseHot:    policy.Phases.Hot,
+		ILMPhaseWarm:   policy.Phases.Warm,
+		ILMPhaseDelete: policy.Phases.Delete,
+	}
+	allowed := map[string]string{
+		ILMPhaseHot:    "rollover",
+		ILMPhaseWarm:   "freeze",
+		ILMPhaseDelete: "delete",
+	}
+	for name, phase := range phases {
+		if phase == nil {
+			continue
+		}
+		if _, err := parseILMDuration(phase.MinAge); err != nil {
+			return fmt.Errorf("ilm: phase [%s] min_age [%s] is invalid", name, phase.MinAge)
+		}
+		actions := make([]string, 0, 3)
+		if phase.Actions.Rollover != nil {
+			actions = append(actions, "rollover")
+		}
+		if phase.Actions.Freeze != nil {
+			actions = append(actions, "freeze")
+		}
+		if phase.Actions.Delete != nil {
+			actions = append(actions, "delete")
+		}
+		for _, action := range actions {
+			if action != allowed[name] {
+				return fmt.Errorf("ilm: action [%s] is not allowed in phase [%s]", action, name)
+			}
+		}
+	}
+
+	if policy.Phases.Hot != nil && policy.Phases.Hot.Actions.Rollover != nil {
+		rollover := policy.Phases.Hot.Actions.Rollover
+		if rollover.MaxDocs <= 0 && rollover.MaxSize == "" && rollover.MaxAge == "" {
+			return fmt.Errorf("ilm: rollover action requires at least one of max_docs, max_size, max_age")
+		}
+		if _, err := parseILMSize(rollover.MaxSize); err != nil {
+			return fmt.Errorf("ilm: rollover max_size [%s] is invalid", rollover.MaxSize)
+		}
+		if _, err := parseILMDuration(rollover.MaxAge); err != nil {
+			return fmt.Errorf("ilm: rollover max_age [%s] is invalid", rollover.MaxAge)
+		}
+	}
+	return nil
+}
+
+func parseILMDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return zutils.ParseDuration(s)
+}
+
+func parseILMSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return units.RAMInBytes(s)
+}
+
+// CheckILM runs the actions of the ILM policies on the indexes which use them
+func CheckILM() error {
+	policies, err := ListILMPolicies()
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	policyMap := make(map[string]*meta.ILMPolicy, len(policies))
+	for _, policy := range policies {
+		policyMap[policy.Name] = policy
+	}
+
+	for _, index := range ZINC_INDEX_LIST.List() {
+		settings := index.GetSettings()
+		if settings == nil || settings.Lifecycle == nil {
+			continue
+		}
+		policy, ok := policyMap[settings.Lifecycle.Name]
+		if !ok || policy.Policy == nil {
+			continue
+		}
+		if err := index.runILMPolicy(*settings.Lifecycle, policy.Policy); err != nil {
+			log.Error().Err(err).
+				Str("index", index.GetName()).
+				Str("policy", policy.Name).
+				Msg("failed to run index lifecycle policy")
+		}
+	}
+	return nil
+}
+
+func (index *Index) runILMPolicy(lifecycle meta.IndexLifecycle, policy *meta.ILMPolicyBody) error {
+	// the write index of the rollover alias stays in the hot phase until it rolled over
+	if lifecycle.RolloverAlias != "" {
+		writeIndex, ok := ZINC_INDEX_ALIAS_LIST.GetWriteIndexForAlias(lifecycle.RolloverAlias)
+		if ok && writeIndex == index.GetName() {
+			if policy.Phases.Hot != nil && policy.Phases.Hot.Actions.Rollover != nil {
+				return index.Rollover(lifecycle, policy.Phases.Hot.Actions.Rollover)
+			}
+			return nil
+		}
+	}
+
+	// the phases after hot are counted from the rollover
+	age := time.Since(index.GetCreatedAt())
+	if lifecycle.RolloverDate != nil {
+		age = time.Since(*lifecycle.RolloverDate)
+	}
+	if phase := policy.Phases.Delete; phase != nil && phase.Actions.Delete != nil {
+		minAge, _ := parseILMDuration(phase.MinAge)
+		if age >= minAge {
+			log.Info().Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("ilm: delete index")
+			for _, alias := range ZINC_INDEX_ALIAS_LIST.GetAliasesForIndex(index.GetName()) {
+				if err := ZINC_INDEX_ALIAS_LIST.RemoveIndexesFromAlias(alias, []string{index.GetName()}); err != nil {
+					return err
+				}
+			}
+			return DeleteIndex(index.GetName())
+		}
+	}
+	if phase := policy.Phases.Warm; phase != nil && lifecycle.Phase != ILMPhaseWarm {
+		minAge, _ := parseILMDuration(phase.MinAge)
+		if age >= minAge {
+			if phase.Actions.Freeze != nil {
+				log.Info().Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("ilm: freeze index")
+				if err := index.Freeze(); err != nil {
+					return err
+				}
+			}
+			lifecycle.Phase = ILMPhaseWarm
+			_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &lifecycle})
+			return storeIndex(index)
+		}
+	}
+	return nil
+}
+
+// Rollover creates a new index for the rollover alias when any of the conditions is reached,
+// the new index is named by increasing the number suffix of the index name: logs-000001 => logs-000002
+func (index *Index) Rollover(lifecycle meta.IndexLifecycle, action *meta.ILMRolloverAction) error {
+	// the stats of the index are only refreshed periodically
+	for id := range index.shards {
+		index.UpdateMetadataByShard(id)
+	}
+	if err := index.UpdateMetadata(); err != nil {
+		return err
+	}
+	if !index.needRollover(action) {
+		return nil
+	}
+
+	matches := rolloverIndexNameRe.FindStringSubmatch(index.GetName())
+	if matches == nil {
+		return fmt.Errorf("ilm: index name [%s] does not match pattern '^.*-\\d+$'", index.GetName())
+	}
+	n, err := strconv.ParseInt(matches[2], 10, 64)
+	if err != nil {
+		return err
+	}
+	newName := fmt.Sprintf("%s%0*d", matches[1], len(matches[2]), n+1)
+	if _, exists := GetIndex(newName); exists {
+		return fmt.Errorf("ilm: rollover index [%s] already exists", newName)
+	}
+
+	newIndex, err := NewIndex(newName, index.GetStorageType(), index.GetShardNum())
+	if err != nil {
+		return err
+	}
+	// inherit the settings and mappings when there is no template for the new index
+	if newIndex.GetSettings() == nil {
+		_ = newIndex.SetSettings(index.GetSettings())
+		_ = newIndex.SetAnalyzers(index.GetAnalyzers())
+	}
+	if newIndex.GetMappings() == nil && index.GetMappings() != nil {
+		mappings := meta.NewMappings()
+		for field, prop := range index.GetMappings().ListProperty() {
+			mappings.SetProperty(field, prop)
+		}
+		_ = newIndex.SetMappings(mappings)
+	}
+	_ = newIndex.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{
+		Name:          lifecycle.Name,
+		RolloverAlias: lifecycle.RolloverAlias,
+	}})
+	if err := StoreIndex(newIndex); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("index", index.GetName()).
+		Str("new index", newName).
+		Str("alias", lifecycle.RolloverAlias).
+		Msg("ilm: rollover index")
+
+	if err := ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(lifecycle.RolloverAlias, []string{newName}); err != nil {
+		return err
+	}
+	rolloverDate := time.Now()
+	lifecycle.RolloverDate = &rolloverDate
+	_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &lifecycle})
+	return storeIndex(index)
+}
+
+func (index *Index) needRollover(action *meta.ILMRolloverAction) bool {
+	stats := index.GetStats()
+	// empty index no need rollover
+	if stats.DocNum == 0 {
+		return false
+	}
+	if action.MaxDocs > 0 && stats.DocNum >= uint64(action.MaxDocs) {
+		return true
+	}
+	if maxSize, _ := parseILMSize(action.MaxSize); maxSize > 0 && stats.StorageSize+stats.MemorySize >= uint64(maxSize) {
+		return true
+	}
+	if maxAge, _ := parseILMDuration(action.MaxAge); maxAge > 0 && time.Since(index.GetCreatedAt()) >= maxAge {
+		return true
+	}
+	return false
+}
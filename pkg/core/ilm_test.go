@@ -0,0 +1,163 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestCheckILM(t *testing.T) {
+	policyName := "TestCheckILM.policy_1"
+	aliasName := "TestCheckILM.alias_1"
+	indexName := "TestCheckILM.index-000001"
+	newIndexName := "TestCheckILM.index-000002"
+	policy := &meta.ILMPolicyBody{Phases: meta.ILMPhases{
+		Hot: &meta.ILMPhase{Actions: meta.ILMActions{
+			Rollover: &meta.ILMRolloverAction{MaxDocs: 1},
+		}},
+		Warm: &meta.ILMPhase{Actions: meta.ILMActions{
+			Freeze: &meta.ILMFreezeAction{},
+		}},
+		Delete: &meta.ILMPhase{MinAge: "1h", Actions: meta.ILMActions{
+			Delete: &meta.ILMDeleteAction{},
+		}},
+	}}
+
+	t.Run("prepare", func(t *testing.T) {
+		err := NewILMPolicy(policyName, policy)
+		assert.NoError(t, err)
+
+		index, err := NewIndex(indexName, "disk", 1)
+		assert.NoError(t, err)
+		err = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{
+			Name:          policyName,
+			RolloverAlias: aliasName,
+		}})
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		err = ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(aliasName, []string{indexName})
+		assert.NoError(t, err)
+	})
+
+	t.Run("write to alias", func(t *testing.T) {
+		index, exists, err := GetOrCreateIndex(aliasName, "", 0)
+		require.NoError(t, err)
+		require.True(t, exists)
+		assert.Equal(t, indexName, index.GetName())
+
+		err = index.CreateDocument("1", map[string]interface{}{"name": "Hello"}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		require.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}})
+			return err == nil && resp.Hits.Total.Value == 1
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		err := CheckILM()
+		assert.NoError(t, err)
+
+		writeIndex, ok := ZINC_INDEX_ALIAS_LIST.GetWriteIndexForAlias(aliasName)
+		assert.True(t, ok)
+		assert.Equal(t, newIndexName, writeIndex)
+
+		newIndex, exists := GetIndex(newIndexName)
+		require.True(t, exists)
+		require.NotNil(t, newIndex)
+		assert.Equal(t, policyName, newIndex.GetSettings().Lifecycle.Name)
+		assert.Equal(t, aliasName, newIndex.GetSettings().Lifecycle.RolloverAlias)
+		assert.Nil(t, newIndex.GetSettings().Lifecycle.RolloverDate)
+
+		index, exists := GetIndex(indexName)
+		require.True(t, exists)
+		require.NotNil(t, index.GetSettings().Lifecycle.RolloverDate)
+		assert.WithinDuration(t, time.Now(), *index.GetSettings().Lifecycle.RolloverDate, time.Minute)
+	})
+
+	t.Run("freeze", func(t *testing.T) {
+		err := CheckILM()
+		assert.NoError(t, err)
+
+		index, exists := GetIndex(indexName)
+		require.True(t, exists)
+		assert.Equal(t, ILMPhaseWarm, index.GetSettings().Lifecycle.Phase)
+		for _, shard := range index.shards {
+			assert.Equal(t, int64(1), shard.GetLatestShardID())
+			assert.True(t, shard.IsFrozen(0))
+		}
+
+		// the write index stays in the hot phase
+		newIndex, exists := GetIndex(newIndexName)
+		require.True(t, exists)
+		assert.Equal(t, "", newIndex.GetSettings().Lifecycle.Phase)
+
+		err = DeleteILMPolicy(policyName)
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		policy.Phases.Delete.MinAge = "2h"
+		err := NewILMPolicy(policyName, policy)
+		assert.NoError(t, err)
+		p, exists, err := LoadILMPolicy(policyName)
+		require.NoError(t, err)
+		require.True(t, exists)
+		assert.Equal(t, int64(2), p.Version)
+
+		// the min_age is counted from the rollover, not the index created
+		index, exists := GetIndex(indexName)
+		require.True(t, exists)
+		lifecycle := *index.GetSettings().Lifecycle
+		index.ref.CreatedAt = time.Now().Add(-3 * time.Hour)
+		err = CheckILM()
+		assert.NoError(t, err)
+		_, exists = GetIndex(indexName)
+		assert.True(t, exists)
+
+		rolloverDate := time.Now().Add(-3 * time.Hour)
+		lifecycle.RolloverDate = &rolloverDate
+		err = index.SetSettings(&meta.IndexSettings{Lifecycle: &lifecycle})
+		assert.NoError(t, err)
+		err = CheckILM()
+		assert.NoError(t, err)
+
+		_, exists = GetIndex(indexName)
+		assert.False(t, exists)
+		_, exists = GetIndex(newIndexName)
+		assert.True(t, exists)
+		indexes, _ := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(aliasName)
+		assert.Equal(t, []string{newIndexName}, indexes)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := ZINC_INDEX_ALIAS_LIST.RemoveIndexesFromAlias(aliasName, []string{newIndexName})
+		assert.NoError(t, err)
+		err = DeleteIndex(newIndexName)
+		assert.NoError(t, err)
+		err = DeleteILMPolicy(policyName)
+		assert.NoError(t, err)
+	})
+}
@@ -16,8 +16,10 @@
 package core
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 	"github.com/zincsearch/zincsearch/pkg/zutils/hash/rendezvous"
 	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 )
@@ -83,6 +86,13 @@ func (index *Index) GetSettings() *meta.IndexSettings {
 	return s
 }
 
+func (index *Index) GetCreatedAt() time.Time {
+	index.lock.RLock()
+	t := index.ref.CreatedAt
+	index.lock.RUnlock()
+	return t
+}
+
 func (index *Index) GetStats() meta.IndexStat {
 	index.lock.RLock()
 	s := index.ref.Stats
@@ -143,6 +153,18 @@ func (index *Index) SetSettings(settings *meta.IndexSettings) error {
 	if settings.NumberOfShards > 0 && index.ref.Settings.NumberOfShards == 0 {
 		index.ref.Settings.NumberOfShards = settings.NumberOfShards
 	}
+	if settings.Lifecycle != nil {
+		lifecycle := *settings.Lifecycle
+		index.ref.Settings.Lifecycle = &lifecycle
+	}
+	if settings.Retention != "" {
+		// an explicit 0 turns the retention off
+		if retention, err := zutils.ParseDuration(settings.Retention); err == nil && retention <= 0 {
+			index.ref.Settings.Retention = ""
+		} else {
+			index.ref.Settings.Retention = settings.Retention
+		}
+	}
 	if settings.Analysis != nil {
 		if index.ref.Settings.Analysis == nil {
 			index.ref.Settings.Analysis = new(meta.IndexAnalysis)
@@ -248,7 +270,7 @@ func (index *Index) GetWALSize() uint64 {
 	return size
 }
 
-// GetReaders return all shard readers
+// GetReaders return all shard readers, the readers must be closed by closeReader
 func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error) {
 	readers := make([]*bluge.Reader, 0)
 	for _, shard := range index.shards {
@@ -266,16 +288,18 @@ func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 // UpdateMetadata update index metadata, mainly docNum and storageSize
 // need merge from all first layer shards
 func (index *Index) UpdateMetadata() error {
-	var totalDocNum, totalSize uint64
+	var totalDocNum, totalSize, totalMemory uint64
 	for id := range index.shards {
 		totalDocNum += atomic.LoadUint64(&index.shards[id].ref.Stats.DocNum)
 		totalSize += atomic.LoadUint64(&index.shards[id].ref.Stats.StorageSize)
+		totalMemory += atomic.LoadUint64(&index.shards[id].ref.Stats.MemorySize)
 	}
 
-	if totalDocNum > 0 && totalSize > 0 {
+	if totalDocNum > 0 && (totalSize > 0 || totalMemory > 0) {
 		index.lock.Lock()
 		atomic.StoreUint64(&index.ref.Stats.DocNum, totalDocNum)
 		atomic.StoreUint64(&index.ref.Stats.StorageSize, totalSize)
+		atomic.StoreUint64(&index.ref.Stats.MemorySize, totalMemory)
 		index.lock.Unlock()
 	}
 
@@ -285,23 +309,25 @@ func (index *Index) UpdateMetadata() error {
 // UpdateMetadataByShard update first layer shard metadata, mainly docNum, storageSize and timeRange
 // need merge from all second layer shards
 func (index *Index) UpdateMetadataByShard(id string) {
-	var totalDocNum, totalSize uint64
+	var totalDocNum, totalSize, totalMemory uint64
 	// update docNum and storageSize
 	shard := index.shards[id]
-	for i := int64(0); i < shard.GetShardNum(); i++ {
-		index.UpdateStatsBySecondShard(id, i)
-		totalDocNum += atomic.LoadUint64(&shard.ref.Shards[i].Stats.DocNum)
-		totalSize += atomic.LoadUint64(&shard.ref.Shards[i].Stats.StorageSize)
+	for _, secondShard := range shard.listSecondShards() {
+		index.UpdateStatsBySecondShard(id, secondShard.ref.ID)
+		totalDocNum += atomic.LoadUint64(&secondShard.ref.Stats.DocNum)
+		totalSize += atomic.LoadUint64(&secondShard.ref.Stats.StorageSize)
+		totalMemory += atomic.LoadUint64(&secondShard.ref.Stats.MemorySize)
 	}
-	if totalDocNum > 0 && totalSize > 0 {
+	if totalDocNum > 0 && (totalSize > 0 || totalMemory > 0) {
 		index.lock.Lock()
 		atomic.StoreUint64(&shard.ref.Stats.DocNum, totalDocNum)
 		atomic.StoreUint64(&shard.ref.Stats.StorageSize, totalSize)
+		atomic.StoreUint64(&shard.ref.Stats.MemorySize, totalMemory)
 		index.lock.Unlock()
 	}
 
 	// update latest shard docTime
-	secondShard := shard.shards[shard.GetLatestShardID()]
+	secondShard := shard.getSecondShard(shard.GetLatestShardID())
 	index.lock.Lock()
 	atomic.StoreInt64(&secondShard.ref.Stats.DocTimeMin, atomic.LoadInt64(&shard.ref.Stats.DocTimeMin))
 	atomic.StoreInt64(&secondShard.ref.Stats.DocTimeMax, atomic.LoadInt64(&shard.ref.Stats.DocTimeMax))
@@ -310,10 +336,10 @@ func (index *Index) UpdateMetadataByShard(id string) {
 
 // UpdateStatsBySecondShard update second layer shard stats, mainly docNum and storageSize
 func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
-	shard := index.shards[id]
-	shard.lock.RLock()
-	secondShard := shard.shards[secondIndex]
-	shard.lock.RUnlock()
+	secondShard := index.shards[id].getSecondShard(secondIndex)
+	if secondShard == nil {
+		return
+	}
 
 	secondShard.lock.RLock()
 	w := secondShard.writer
@@ -321,13 +347,23 @@ func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
 	if w == nil {
 		return
 	}
+	index.updateSecondShardStats(secondShard, w)
+}
 
+// updateSecondShardStats update second layer shard stats by the writer
+func (index *Index) updateSecondShardStats(secondShard *IndexSecondShard, w *bluge.Writer) {
 	var docNum, storageSize uint64
 	_, storageSize = w.DirectoryStats()
 	if r, err := w.Reader(); err == nil {
 		if n, err := r.Count(); err == nil {
 			docNum = n
 		}
+		// the hidden nested documents are not counted
+		if paths := index.GetMappings().ListNestedPath(); docNum > 0 && len(paths) > 0 {
+			if n, err := countNestedDocuments(r, paths); err == nil && n <= docNum {
+				docNum -= n
+			}
+		}
 		_ = r.Close()
 	}
 
@@ -336,11 +372,29 @@ func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
 		atomic.StoreUint64(&secondShard.ref.Stats.DocNum, docNum)
 	}
 	if storageSize > 0 {
-		atomic.StoreUint64(&secondShard.ref.Stats.StorageSize, storageSize)
+		// the data of memory index is kept in memory
+		if index.GetStorageType() == "memory" {
+			atomic.StoreUint64(&secondShard.ref.Stats.MemorySize, storageSize)
+		} else {
+			atomic.StoreUint64(&secondShard.ref.Stats.StorageSize, storageSize)
+		}
 	}
 	index.lock.Unlock()
 }
 
+// countNestedDocuments returns the number of the nested documents of the paths
+func countNestedDocuments(r *bluge.Reader, paths []string) (uint64, error) {
+	query := bluge.NewBooleanQuery()
+	for _, path := range paths {
+		query.AddShould(bluge.NewTermQuery(path).SetField(meta.NestedPathFieldName))
+	}
+	dmi, err := r.Search(context.Background(), bluge.NewTopNSearch(0, query).WithStandardAggregations())
+	if err != nil {
+		return 0, err
+	}
+	return dmi.Aggregations().Count(), nil
+}
+
 // Reopen just close the index, it will open automatically by trigger
 // Deprecated: it will be removed in the future
 func (index *Index) Reopen() error {
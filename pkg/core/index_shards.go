@@ -47,14 +47,15 @@ const (
 // then we will can not found the old document, maybe cause duplicate documents.
 // First layer shard just used for distribute not really store documents.
 type IndexShard struct {
-	open   uint64
-	name   string // shard name: index/shardID
-	root   *Index
-	ref    *meta.IndexShard
-	shards []*IndexSecondShard
-	wal    *wal.Log
-	lock   sync.RWMutex
-	close  chan struct{}
+	open      uint64
+	name      string // shard name: index/shardID
+	root      *Index
+	ref       *meta.IndexShard
+	shards    []*IndexSecondShard
+	wal       *wal.Log
+	lock      sync.RWMutex
+	writeLock sync.Mutex // the writers are not closed when the WAL is writing to them
+	close     chan struct{}
 }
 
 // IndexSecondShard second layer shard by auto increate shards for index.
@@ -65,11 +66,15 @@ type IndexShard struct {
 // filter which shards need to find data. We keep one shard size wouldn't over limit,
 // we will fozen old shards, just write new documents to new shards and do merge in new shards
 // this will improve shard performance.
+// A frozen shard closes the writer and is force merged into one segment in background,
+// it is searched by a cached read-only reader and a writer is only opened when deleting documents from it.
 type IndexSecondShard struct {
-	root   *Index
-	ref    *meta.IndexSecondShard
-	writer *bluge.Writer
-	lock   sync.RWMutex
+	root    *Index
+	ref     *meta.IndexSecondShard
+	writer  *bluge.Writer
+	reader  *frozenReader // guarded by frozenReaders
+	deleted bool          // guarded by the index lock
+	lock    sync.RWMutex
 }
 
 // GetShardByDocID return the shard by hash docID
@@ -130,23 +135,35 @@ func (s *IndexShard) NewShard() error {
 
 	// update current shard
 	s.root.UpdateStatsBySecondShard(s.GetID(), s.GetLatestShardID())
+	secondShard := s.getSecondShard(s.GetLatestShardID())
 	s.root.lock.Lock()
-	secondShard := s.shards[s.GetLatestShardID()]
 	secondShard.ref.Stats.DocTimeMin = s.ref.Stats.DocTimeMin
 	secondShard.ref.Stats.DocTimeMax = s.ref.Stats.DocTimeMax
 	s.ref.Stats.DocTimeMin = 0
 	s.ref.Stats.DocTimeMax = 0
 	// create new shard
-	atomic.AddInt64(&s.ref.ShardNum, 1)
-	s.ref.Shards = append(s.ref.Shards, &meta.IndexSecondShard{ID: s.GetLatestShardID()})
-	s.shards = append(s.shards, &IndexSecondShard{root: s.root, ref: s.ref.Shards[s.GetLatestShardID()]})
+	s.lock.Lock()
+	newShard := &meta.IndexSecondShard{ID: atomic.AddInt64(&s.ref.ShardNum, 1) - 1}
+	s.ref.Shards = append(s.ref.Shards, newShard)
+	s.shards = append(s.shards, &IndexSecondShard{root: s.root, ref: newShard})
+	s.lock.Unlock()
 	s.root.lock.Unlock()
 
 	// store update
 	if err := storeIndex(s.root); err != nil {
 		return err
 	}
-	return s.openWriter(s.GetLatestShardID())
+	if err := s.openWriter(s.GetLatestShardID()); err != nil {
+		return err
+	}
+
+	// freeze the previous shard and merge it in background
+	frozenID := s.GetLatestShardID() - 1
+	if err := s.FreezeShard(frozenID); err != nil {
+		return err
+	}
+	go s.forceMergeInBackground(frozenID)
+	return nil
 }
 
 // GetWriter return the newest shard writer or special shard writer
@@ -160,9 +177,13 @@ func (s *IndexShard) GetWriter(shardID ...int64) (*bluge.Writer, error) {
 	if id >= s.GetShardNum() || id < 0 {
 		return nil, errors.New(errors.ErrorTypeRuntimeException, "second shard not found")
 	}
-	s.lock.RLock()
-	secondShard := s.shards[id]
-	s.lock.RUnlock()
+	secondShard := s.getSecondShard(id)
+	if secondShard == nil {
+		return nil, errors.New(errors.ErrorTypeRuntimeException, "second shard not found")
+	}
+	if secondShard.IsFrozen() {
+		return nil, errors.New(errors.ErrorTypeRuntimeException, "second shard is frozen")
+	}
 
 	secondShard.lock.RLock()
 	w := secondShard.writer
@@ -187,11 +208,14 @@ func (s *IndexShard) GetWriter(shardID ...int64) (*bluge.Writer, error) {
 	return w, nil
 }
 
-// GetWriters return all shard writers
+// GetWriters return all shard writers, frozen shards have no writer and are skipped
 func (s *IndexShard) GetWriters() ([]*bluge.Writer, error) {
-	ws := make([]*bluge.Writer, 0, s.GetShardNum())
-	for i := int64(0); i < s.GetShardNum(); i++ {
-		w, err := s.GetWriter(i)
+	ws := make([]*bluge.Writer, 0, 1)
+	for _, secondShard := range s.listSecondShards() {
+		if secondShard.IsFrozen() {
+			continue
+		}
+		w, err := s.GetWriter(secondShard.ref.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -203,14 +227,16 @@ func (s *IndexShard) GetWriters() ([]*bluge.Writer, error) {
 // GetReaders return all shard readers
 func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error) {
 	rs := make([]*bluge.Reader, 0, 1)
-	chs := make(chan *bluge.Reader, s.GetShardNum())
+	shards := s.listSecondShards()
+	chs := make(chan *bluge.Reader, len(shards))
 	eg := errgroup.Group{}
 	eg.SetLimit(config.Global.Shard.GoroutineNum)
-	for i := s.GetLatestShardID(); i >= 0; i-- {
-		i := i
-		s.lock.RLock()
-		secondShard := s.shards[i]
-		s.lock.RUnlock()
+	for i := len(shards) - 1; i >= 0; i-- {
+		secondShard := shards[i]
+		id := secondShard.ref.ID
+		if secondShard.IsDeleted() {
+			continue
+		}
 		sMin := atomic.LoadInt64(&secondShard.ref.Stats.DocTimeMin)
 		sMax := atomic.LoadInt64(&secondShard.ref.Stats.DocTimeMax)
 		if (timeMin > 0 && sMax > 0 && sMax < timeMin) ||
@@ -218,12 +244,11 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 			continue
 		}
 		eg.Go(func() error {
-			w, err := s.GetWriter(i)
-			if err != nil {
-				return err
-			}
-			r, err := w.Reader()
+			r, err := s.getReader(id)
 			if err != nil {
+				if s.IsDeleted(id) {
+					return nil // deleted by the index retention meanwhile
+				}
 				return err
 			}
 			chs <- r
@@ -233,36 +258,53 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 			break
 		}
 	}
-	if err := eg.Wait(); err != nil {
-		return nil, err
-	}
+	err := eg.Wait()
 	close(chs)
 	for r := range chs {
+		if err != nil {
+			_ = closeReader(r)
+			continue
+		}
 		rs = append(rs, r)
 	}
+	if err != nil {
+		return nil, err
+	}
 	return rs, nil
 }
 
 func (s *IndexShard) openWriter(shardID int64) error {
-	var defaultSearchAnalyzer *analysis.Analyzer
-	analyzers := s.root.GetAnalyzers()
-	if analyzers != nil {
-		defaultSearchAnalyzer = analyzers["default"]
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil {
+		return errors.New(errors.ErrorTypeRuntimeException, "second shard not found")
 	}
-	s.lock.RLock()
-	secondShard := s.shards[shardID]
-	s.lock.RUnlock()
 	secondShard.lock.Lock()
 	defer secondShard.lock.Unlock()
 	if secondShard.writer != nil {
 		return nil
 	}
+	if secondShard.IsFrozen() {
+		// frozen after the writer was requested
+		return errors.New(errors.ErrorTypeRuntimeException, "second shard is frozen")
+	}
 	var err error
-	indexName := fmt.Sprintf("%s/%s/%06x", s.GetIndexName(), s.GetID(), shardID)
-	secondShard.writer, err = OpenIndexWriter(indexName, s.root.GetStorageType(), defaultSearchAnalyzer, 0, 0)
+	secondShard.writer, err = OpenIndexWriter(s.secondShardName(shardID), s.root.GetStorageType(), s.defaultSearchAnalyzer(), 0, 0)
 	return err
 }
 
+// secondShardName returns the storage name of the second layer shard: index/shardID/secondShardID
+func (s *IndexShard) secondShardName(shardID int64) string {
+	return fmt.Sprintf("%s/%s/%06x", s.GetIndexName(), s.GetID(), shardID)
+}
+
+func (s *IndexShard) defaultSearchAnalyzer() *analysis.Analyzer {
+	analyzers := s.root.GetAnalyzers()
+	if analyzers != nil {
+		return analyzers["default"]
+	}
+	return nil
+}
+
 func (s *IndexShard) Close() error {
 	if atomic.LoadUint64(&s.open) == 0 {
 		return nil
@@ -274,6 +316,7 @@ func (s *IndexShard) Close() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	for _, secondShard := range s.shards {
+		secondShard.retireFrozenReader()
 		if secondShard.writer == nil {
 			continue
 		}
@@ -308,25 +351,19 @@ func (s *IndexShard) SetTimestamp(t int64) {
 
 // FindShardByDocID finds docID in which shard and returns the shard id
 func (s *IndexShard) FindShardByDocID(docID string) (int64, error) {
-	query := bluge.NewBooleanQuery()
-	query.AddMust(bluge.NewTermQuery(docID).SetField("_id"))
-	request := bluge.NewTopNSearch(1, query).WithStandardAggregations()
 	ctx := context.Background()
 
 	// check id store by which shard
 	shardID := int64(-1)
-	writers, err := s.GetWriters()
-	if err != nil {
-		return shardID, err
-	}
-
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.SetLimit(config.Global.Shard.GoroutineNum)
-	for id := int64(len(writers)) - 1; id >= 0; id-- {
+	for id := s.GetLatestShardID(); id >= 0; id-- {
 		id := id
-		w := writers[id]
+		if s.IsDeleted(id) {
+			continue
+		}
 		eg.Go(func() error {
-			r, err := w.Reader()
+			r, err := s.getReader(id)
 			if err != nil {
 				log.Error().Err(err).
 					Str("index", s.GetIndexName()).
@@ -335,7 +372,11 @@ func (s *IndexShard) FindShardByDocID(docID string) (int64, error) {
 					Msg("failed to get reader")
 				return nil // not check err, if returns err with cancel all goroutines.
 			}
-			defer r.Close()
+			defer func() { _ = closeReader(r) }()
+			// the query is not safe for concurrent use, build it for every shard
+			query := bluge.NewBooleanQuery()
+			query.AddMust(bluge.NewTermQuery(docID).SetField("_id"))
+			request := bluge.NewTopNSearch(1, query).WithStandardAggregations()
 			dmi, err := r.Search(ctx, request)
 			if err != nil {
 				log.Error().Err(err).
@@ -361,25 +402,19 @@ func (s *IndexShard) FindShardByDocID(docID string) (int64, error) {
 
 // FindDocumentByDocID finds docID and returns the document
 func (s *IndexShard) FindDocumentByDocID(docID string) (*meta.Hit, error) {
-	query := bluge.NewBooleanQuery()
-	query.AddMust(bluge.NewTermQuery(docID).SetField("_id"))
-	request := bluge.NewTopNSearch(1, query).WithStandardAggregations()
 	ctx := context.Background()
 
 	// check id store by which shard
 	var hit *meta.Hit
-	writers, err := s.GetWriters()
-	if err != nil {
-		return nil, err
-	}
-
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.SetLimit(config.Global.Shard.GoroutineNum)
-	for id := int64(len(writers)) - 1; id >= 0; id-- {
+	for id := s.GetLatestShardID(); id >= 0; id-- {
 		id := id
-		w := writers[id]
+		if s.IsDeleted(id) {
+			continue
+		}
 		eg.Go(func() error {
-			r, err := w.Reader()
+			r, err := s.getReader(id)
 			if err != nil {
 				log.Error().Err(err).
 					Str("index", s.GetIndexName()).
@@ -388,7 +423,11 @@ func (s *IndexShard) FindDocumentByDocID(docID string) (*meta.Hit, error) {
 					Msg("failed to get reader")
 				return nil // not check err, if returns err with cancel all goroutines.
 			}
-			defer r.Close()
+			defer func() { _ = closeReader(r) }()
+			// the query is not safe for concurrent use, build it for every shard
+			query := bluge.NewBooleanQuery()
+			query.AddMust(bluge.NewTermQuery(docID).SetField("_id"))
+			request := bluge.NewTopNSearch(1, query).WithStandardAggregations()
 			dmi, err := r.Search(ctx, request)
 			if err != nil {
 				log.Error().Err(err).
@@ -18,6 +18,7 @@ package core
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blugelabs/bluge"
@@ -43,29 +44,8 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 
 	// Create a new bluge document
 	bdoc := bluge.NewDocument(docID)
-	// Iterate through each field and add it to the bluge document
-	for key, value := range doc {
-		if value == nil || key == meta.TimeFieldName || key == meta.SourceFieldName {
-			continue
-		}
-
-		prop, ok := mappings.GetProperty(key)
-		if !ok || !prop.Index {
-			continue // not index, skip
-		}
-
-		switch v := value.(type) {
-		case []interface{}:
-			for _, v := range v {
-				if err := s.buildField(mappings, bdoc, key, v); err != nil {
-					return nil, err
-				}
-			}
-		default:
-			if err := s.buildField(mappings, bdoc, key, v); err != nil {
-				return nil, err
-			}
-		}
+	if err := s.buildFields(mappings, bdoc, doc); err != nil {
+		return nil, err
 	}
 
 	// set timestamp
@@ -97,6 +77,102 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 	return bdoc, nil
 }
 
+// BuildNestedDocumentsFromJSON returns the hidden bluge documents for the nested objects of the json document,
+// the nested objects are removed from the json document.
+func (s *IndexShard) BuildNestedDocumentsFromJSON(docID string, doc map[string]interface{}) ([]*bluge.Document, error) {
+	value, ok := doc[meta.NestedFieldName]
+	if !ok {
+		return nil, nil
+	}
+	delete(doc, meta.NestedFieldName)
+	nested, _ := value.(map[string]interface{})
+	if len(nested) == 0 {
+		return nil, nil
+	}
+
+	mappings := s.root.GetMappings()
+	timestamp := time.Now()
+	if value, ok := doc[meta.TimeFieldName]; ok {
+		timestamp = time.Unix(0, int64(value.(float64)))
+	}
+	source, _ := doc[meta.SourceFieldName].(map[string]interface{})
+
+	bdocs := make([]*bluge.Document, 0)
+	for path, children := range nested {
+		children, _ := children.([]interface{})
+		sources, ok := source[path]
+		if !ok {
+			sources, _ = getValueByPath(source, path)
+		}
+		if _, ok := sources.([]interface{}); !ok {
+			sources = []interface{}{sources}
+		}
+		for offset, child := range children {
+			child, ok := child.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			bdoc := bluge.NewDocument(nestedDocumentID(docID, path, offset))
+			if err := s.buildFields(mappings, bdoc, child); err != nil {
+				return nil, err
+			}
+			bdoc.AddField(bluge.NewKeywordField(meta.NestedPathFieldName, path).Sortable())
+			bdoc.AddField(bluge.NewKeywordField(meta.NestedParentFieldName, docID).StoreValue().Sortable())
+			bdoc.AddField(bluge.NewNumericField(meta.NestedOffsetFieldName, float64(offset)).StoreValue())
+			bdoc.AddField(bluge.NewDateTimeField(meta.TimeFieldName, timestamp).StoreValue().Sortable().Aggregatable())
+
+			var childSource interface{}
+			if values := sources.([]interface{}); offset < len(values) {
+				childSource = values[offset]
+			}
+			sourceByteVal, _ := json.Marshal(childSource)
+			bdoc.AddField(bluge.NewStoredOnlyField("_source", sourceByteVal))
+			bdoc.AddField(bluge.NewStoredOnlyField("_index", []byte(s.GetIndexName())))
+			bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{
+				"_id", "_index", "_source", meta.TimeFieldName,
+				meta.NestedPathFieldName, meta.NestedParentFieldName, meta.NestedOffsetFieldName,
+			}))
+			bdoc.SetTimestamp(timestamp.UnixNano())
+			bdocs = append(bdocs, bdoc)
+		}
+	}
+
+	return bdocs, nil
+}
+
+// nestedDocumentID returns the id of the hidden document for a nested object
+func nestedDocumentID(docID, path string, offset int) string {
+	return docID + "#" + path + "#" + strconv.Itoa(offset)
+}
+
+// buildFields adds the indexed fields of the flattened json document to the bluge document
+func (s *IndexShard) buildFields(mappings *meta.Mappings, bdoc *bluge.Document, doc map[string]interface{}) error {
+	for key, value := range doc {
+		if value == nil || key == meta.TimeFieldName || key == meta.SourceFieldName {
+			continue
+		}
+
+		prop, ok := mappings.GetProperty(key)
+		if !ok || !prop.Index {
+			continue // not index, skip
+		}
+
+		switch v := value.(type) {
+		case []interface{}:
+			for _, v := range v {
+				if err := s.buildField(mappings, bdoc, key, v); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := s.buildField(mappings, bdoc, key, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, key string, value interface{}) error {
 	var field *bluge.TermField
 	prop, _ := mappings.GetProperty(key)
@@ -127,6 +203,18 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		field = bluge.NewDateTimeField(key, v)
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewGeoPointField(key, lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewKeywordField(key, zutils.EncodeIP(ip))
 	}
 	if prop.Store || prop.Highlightable {
 		field.StoreValue()
@@ -159,36 +247,31 @@ func (s *IndexShard) CheckDocument(docID string, doc map[string]interface{}, upd
 	mappingsNeedsUpdate := false
 
 	flatDoc, _ := flatten.Flatten(doc, "")
-	// Iterate through each field and add it to the bluge document
-	for key, value := range flatDoc {
-		if value == nil {
-			continue
-		}
-
-		if update := s.checkProperty(mappings, key, value); update {
-			mappingsNeedsUpdate = true
-		}
-
-		prop, ok := mappings.GetProperty(key)
-		if !ok || !prop.Index {
-			continue // not index, skip
-		}
-
-		switch v := value.(type) {
-		case []interface{}:
-			for i, v := range v {
-				if err := s.checkField(mappings, flatDoc, key, v, i, true); err != nil {
-					return nil, err
-				}
+	nestedDocs, err := s.extractNestedDocuments(mappings, doc, flatDoc)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mergeGeoPoints(mappings, doc, flatDoc); err != nil {
+		return nil, err
+	}
+	if mappingsNeedsUpdate, err = s.checkFields(mappings, flatDoc); err != nil {
+		return nil, err
+	}
+	for _, children := range nestedDocs {
+		for _, child := range children {
+			if child == nil {
+				continue
 			}
-		default:
-			if err := s.checkField(mappings, flatDoc, key, v, 0, false); err != nil {
+			update, err := s.checkFields(mappings, child.(map[string]interface{}))
+			if err != nil {
 				return nil, err
 			}
+			if update {
+				mappingsNeedsUpdate = true
+			}
 		}
 	}
 
-	var err error
 	if mappingsNeedsUpdate {
 		if err = s.root.SetMappings(mappings); err != nil {
 			return nil, err
@@ -220,10 +303,99 @@ func (s *IndexShard) CheckDocument(docID string, doc map[string]interface{}, upd
 	flatDoc[meta.ShardFieldName] = shard
 	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
 	flatDoc[meta.SourceFieldName] = doc
+	if len(nestedDocs) > 0 {
+		flatDoc[meta.NestedFieldName] = nestedDocs
+	}
 
 	return json.Marshal(flatDoc)
 }
 
+// checkFields checks the fields of the flattened document and returns if need update mappings
+func (s *IndexShard) checkFields(mappings *meta.Mappings, flatDoc map[string]interface{}) (bool, error) {
+	mappingsNeedsUpdate := false
+	for key, value := range flatDoc {
+		if value == nil {
+			continue
+		}
+
+		if update := s.checkProperty(mappings, key, value); update {
+			mappingsNeedsUpdate = true
+		}
+
+		prop, ok := mappings.GetProperty(key)
+		if !ok || !prop.Index {
+			continue // not index, skip
+		}
+
+		switch v := value.(type) {
+		case []interface{}:
+			for i, v := range v {
+				if err := s.checkField(mappings, flatDoc, key, v, i, true); err != nil {
+					return false, err
+				}
+			}
+		default:
+			if err := s.checkField(mappings, flatDoc, key, v, 0, false); err != nil {
+				return false, err
+			}
+		}
+	}
+	return mappingsNeedsUpdate, nil
+}
+
+// extractNestedDocuments removes the flattened keys of nested fields from the document,
+// and returns the flattened nested objects by path, the nil objects are kept to preserve offsets.
+func (s *IndexShard) extractNestedDocuments(mappings *meta.Mappings, doc, flatDoc map[string]interface{}) (map[string][]interface{}, error) {
+	paths := mappings.ListNestedPath()
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	nestedDocs := make(map[string][]interface{})
+	for _, path := range paths {
+		value, ok := doc[path]
+		if !ok {
+			value, ok = getValueByPath(doc, path)
+		}
+		if !ok || value == nil {
+			continue
+		}
+		for key := range flatDoc {
+			if key == path || strings.HasPrefix(key, path+".") {
+				delete(flatDoc, key)
+			}
+		}
+
+		values, isArray := value.([]interface{})
+		if !isArray {
+			values = []interface{}{value}
+		}
+		children := make([]interface{}, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			if _, ok := v.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("field [%s] was set type to [nested] but the value [%v] is not an object", path, v)
+			}
+			// rebuild the object under its path to get the same keys as other fields
+			var child interface{} = v
+			parts := strings.Split(path, ".")
+			for j := len(parts) - 1; j >= 0; j-- {
+				child = map[string]interface{}{parts[j]: child}
+			}
+			flatChild, _ := flatten.Flatten(child.(map[string]interface{}), "")
+			if err := s.mergeGeoPoints(mappings, child.(map[string]interface{}), flatChild); err != nil {
+				return nil, err
+			}
+			children[i] = flatChild
+		}
+		nestedDocs[path] = children
+	}
+
+	return nestedDocs, nil
+}
+
 // checkProperty returns if need update mappings
 func (s *IndexShard) checkProperty(mappings *meta.Mappings, key string, value interface{}) bool {
 	prop, ok := mappings.GetProperty(key)
@@ -319,6 +491,18 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		v = value
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = zutils.FormatGeoPoint(lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = ip.String()
 	}
 	if array {
 		sub := data[key].([]interface{})
@@ -330,3 +514,90 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 
 	return nil
 }
+
+// mergeGeoPoints replaces the flattened keys of geo_point fields with "lat,lon" values,
+// because a point like {"lat": 1, "lon": 2} or [2, 1] was flattened to multiple keys or values.
+func (s *IndexShard) mergeGeoPoints(mappings *meta.Mappings, doc, flatDoc map[string]interface{}) error {
+	paths := mappings.ListGeoPointPath()
+	if len(paths) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(paths))
+	for _, path := range paths {
+		for key := range flatDoc {
+			if key == path || strings.HasPrefix(key, path+".") {
+				fields = append(fields, path)
+				break
+			}
+		}
+	}
+
+	for _, field := range fields {
+		value, ok := doc[field]
+		if !ok {
+			value, ok = getValueByPath(doc, field)
+		}
+		if !ok {
+			continue
+		}
+		for key := range flatDoc {
+			if key == field || strings.HasPrefix(key, field+".") {
+				delete(flatDoc, key)
+			}
+		}
+
+		points := make([]interface{}, 0, 1)
+		values, isArray := value.([]interface{})
+		if !isArray || isGeoPointArray(values) {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			if v == nil {
+				continue
+			}
+			lon, lat, err := zutils.ParseGeoPoint(v)
+			if err != nil {
+				return fmt.Errorf("field [%s] value [%v] parse err: %s", field, v, err.Error())
+			}
+			points = append(points, zutils.FormatGeoPoint(lon, lat))
+		}
+		switch len(points) {
+		case 0:
+		case 1:
+			flatDoc[field] = points[0]
+		default:
+			flatDoc[field] = points
+		}
+	}
+
+	return nil
+}
+
+// isGeoPointArray returns if the value is a single point in [lon, lat] format
+func isGeoPointArray(v []interface{}) bool {
+	if len(v) != 2 {
+		return false
+	}
+	for _, v := range v {
+		if _, ok := v.(float64); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// getValueByPath returns the value of a nested document by the flattened key
+func getValueByPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	var value interface{} = doc
+	for _, part := range parts {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,509 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	blugeindex "github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/index/mergeplan"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+)
+
+// forceMergeTimeout is the max time to wait for the merge of a frozen second layer shard
+var forceMergeTimeout = time.Hour
+
+// forceMergePlanOptions plans to merge all the segments into one segment
+var forceMergePlanOptions = mergeplan.Options{
+	MaxSegmentsPerTier:   1,
+	MaxSegmentSize:       mergeplan.MaxSegmentSizeLimit,
+	TierGrowth:           mergeplan.DefaultMergePlanOptions.TierGrowth,
+	SegmentsPerMergeTask: 1 << 20,
+	FloorSegmentSize:     mergeplan.DefaultMergePlanOptions.FloorSegmentSize,
+	ReclaimDeletesWeight: mergeplan.DefaultMergePlanOptions.ReclaimDeletesWeight,
+}
+
+// IsFrozen returns if the second layer shard is frozen
+func (s *IndexSecondShard) IsFrozen() bool {
+	s.root.lock.RLock()
+	defer s.root.lock.RUnlock()
+	return s.ref.Frozen
+}
+
+// IsFrozen returns if the second layer shard is frozen, a deleted shard was frozen before deleting
+func (s *IndexShard) IsFrozen(shardID int64) bool {
+	secondShard := s.getSecondShard(shardID)
+	return secondShard == nil || secondShard.IsFrozen()
+}
+
+// IsDeleted returns if the second layer shard is deleted by the index retention
+func (s *IndexSecondShard) IsDeleted() bool {
+	s.root.lock.RLock()
+	defer s.root.lock.RUnlock()
+	return s.deleted
+}
+
+// IsDeleted returns if the second layer shard is deleted by the index retention
+func (s *IndexShard) IsDeleted(shardID int64) bool {
+	secondShard := s.getSecondShard(shardID)
+	return secondShard == nil || secondShard.IsDeleted()
+}
+
+// getSecondShard returns the second layer shard by id, nil if it is deleted by the index retention.
+// The shards are sorted by id, the ids are kept when the old shards are deleted.
+func (s *IndexShard) getSecondShard(shardID int64) *IndexSecondShard {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	i := sort.Search(len(s.shards), func(i int) bool { return s.shards[i].ref.ID >= shardID })
+	if i < len(s.shards) && s.shards[i].ref.ID == shardID {
+		return s.shards[i]
+	}
+	return nil
+}
+
+// listSecondShards returns the second layer shards which are not deleted, sorted by id
+func (s *IndexShard) listSecondShards() []*IndexSecondShard {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	shards := make([]*IndexSecondShard, len(s.shards))
+	copy(shards, s.shards)
+	return shards
+}
+
+// ForceMerge freezes all the old second layer shards and merges them into one segment
+func (index *Index) ForceMerge() error {
+	for _, shard := range index.shards {
+		for id := int64(0); id < shard.GetLatestShardID(); id++ {
+			if err := shard.FreezeShard(id); err != nil {
+				return err
+			}
+			if err := shard.ForceMerge(id); err != nil {
+				return err
+			}
+		}
+	}
+	return index.UpdateMetadata()
+}
+
+// Freeze freezes all the second layer shards of the index which stopped accepting writes,
+// the latest shard is rolled over to an empty one if it has documents.
+func (index *Index) Freeze() error {
+	for _, shard := range index.shards {
+		for id := int64(0); id < shard.GetLatestShardID(); id++ {
+			if shard.IsFrozen(id) {
+				continue
+			}
+			if err := shard.FreezeShard(id); err != nil {
+				return err
+			}
+			go shard.forceMergeInBackground(id)
+		}
+
+		latestID := shard.GetLatestShardID()
+		index.UpdateStatsBySecondShard(shard.GetID(), latestID)
+		docNum := atomic.LoadUint64(&shard.getSecondShard(latestID).ref.Stats.DocNum)
+		if docNum == 0 {
+			continue
+		}
+		if err := shard.NewShard(); err != nil {
+			return err
+		}
+	}
+	return index.UpdateMetadata()
+}
+
+// FreezeShard marks the second layer shard read-only and closes the writer,
+// the latest shard can not be frozen because new documents are written into it.
+// It waits for the WAL writing to the shard and the documents in memory to be persisted.
+func (s *IndexShard) FreezeShard(shardID int64) error {
+	if shardID < 0 || shardID >= s.GetLatestShardID() {
+		return errors.New(errors.ErrorTypeRuntimeException, "only the old second shards can be frozen")
+	}
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil || secondShard.IsFrozen() {
+		return nil
+	}
+
+	log.Info().
+		Str("index", s.GetIndexName()).
+		Str("shard", s.GetID()).
+		Int64("second shard", shardID).
+		Msg("freeze second layer shard")
+
+	s.writeLock.Lock()
+	s.root.UpdateStatsBySecondShard(s.GetID(), shardID)
+	s.root.lock.Lock()
+	secondShard.ref.Frozen = true
+	s.root.lock.Unlock()
+
+	secondShard.lock.Lock()
+	w := secondShard.writer
+	secondShard.writer = nil
+	secondShard.lock.Unlock()
+	s.writeLock.Unlock()
+	if w != nil {
+		// the writer doesn't persist the documents in memory when closing
+		if err := persistWriter(w); err != nil {
+			_ = w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+
+	return storeIndex(s.root)
+}
+
+// persistWriterTimeout is the max time to wait for the writer to persist the documents in memory
+var persistWriterTimeout = time.Minute
+
+// persistWriter waits for the documents in memory of the writer to be persisted
+func persistWriter(w *bluge.Writer) error {
+	persisted := make(chan error, 1)
+	batch := blugeindex.NewBatch()
+	batch.SetPersistedCallback(func(err error) {
+		persisted <- err
+	})
+	if err := w.Batch(batch); err != nil {
+		return err
+	}
+	timer := time.NewTimer(persistWriterTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-persisted:
+		return err
+	case <-timer.C:
+		return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("persist second shard timeout after %s", persistWriterTimeout))
+	}
+}
+
+// ForceMerge merges the frozen second layer shard into one segment
+func (s *IndexShard) ForceMerge(shardID int64) error {
+	if shardID < 0 || shardID >= s.GetShardNum() {
+		return errors.New(errors.ErrorTypeRuntimeException, "second shard not found")
+	}
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil {
+		return nil // deleted by the index retention
+	}
+	if !secondShard.IsFrozen() {
+		return errors.New(errors.ErrorTypeRuntimeException, "only the frozen second shards can be force merged")
+	}
+
+	secondShard.lock.Lock()
+	defer secondShard.lock.Unlock()
+	if secondShard.IsDeleted() {
+		return nil
+	}
+
+	// the merger only runs after a snapshot persisted, nothing to merge in an empty shard
+	cfg := getOpenIndexConfig(s.secondShardName(shardID), s.root.GetStorageType())
+	dir := cfg.DirectoryFunc()
+	if err := dir.Setup(false); err != nil {
+		return err
+	}
+	snapshots, err := dir.List(blugeindex.ItemKindSnapshot)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	// the merger plans a merge after every persisted snapshot, it is done
+	// when there is only one segment left and nothing need to be planned.
+	var planned uint32
+	merged := make(chan struct{}, 1)
+	mergeErr := make(chan error, 1)
+	cfg.MergePlanOptions = forceMergePlanOptions
+	cfg.MergePlanOptions.CalcBudget = func(totalSize int64, firstTierSize int64, o *mergeplan.Options) int {
+		atomic.StoreUint32(&planned, 1)
+		return 1
+	}
+	cfg.EventCallback = func(event blugeindex.Event) {
+		if event.Kind != blugeindex.EventKindMergerProgress {
+			return
+		}
+		if atomic.SwapUint32(&planned, 0) == 0 {
+			select {
+			case merged <- struct{}{}:
+			default:
+			}
+		}
+	}
+	cfg.AsyncError = func(err error) {
+		select {
+		case mergeErr <- err:
+		default:
+		}
+	}
+
+	w, err := bluge.OpenWriter(bluge.DefaultConfigWithIndexConfig(cfg))
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(forceMergeTimeout)
+	defer timer.Stop()
+	select {
+	case <-merged:
+	case err = <-mergeErr:
+		_ = w.Close()
+		return err
+	case <-timer.C:
+		_ = w.Close()
+		return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("force merge second shard timeout after %s", forceMergeTimeout))
+	}
+	s.root.updateSecondShardStats(secondShard, w)
+
+	log.Info().
+		Str("index", s.GetIndexName()).
+		Str("shard", s.GetID()).
+		Int64("second shard", shardID).
+		Msg("force merge second layer shard")
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+	// the next search opens a reader of the merged segment
+	secondShard.retireFrozenReader()
+	return nil
+}
+
+func (s *IndexShard) forceMergeInBackground(shardID int64) {
+	if err := s.ForceMerge(shardID); err != nil {
+		log.Error().Err(err).
+			Str("index", s.GetIndexName()).
+			Str("shard", s.GetID()).
+			Int64("second shard", shardID).
+			Msg("failed to force merge second layer shard")
+	}
+}
+
+// frozenReaders tracks the cached readers of the frozen second layer shards
+var frozenReaders = struct {
+	sync.Mutex
+	readers map[*bluge.Reader]*frozenReader
+}{readers: make(map[*bluge.Reader]*frozenReader)}
+
+// frozenReader is the read-only reader shared by the searches of a frozen second layer shard,
+// it is retired when the shard is force merged or deleted and closed after the last search closes it.
+type frozenReader struct {
+	reader  *bluge.Reader
+	refs    int
+	retired bool
+	closed  chan struct{}
+}
+
+// openFrozenReader opens a read-only reader for the frozen second layer shard
+func (s *IndexShard) openFrozenReader(shardID int64) (*bluge.Reader, error) {
+	cfg := getOpenConfig(s.secondShardName(shardID), s.root.GetStorageType(), s.defaultSearchAnalyzer())
+	r, err := bluge.OpenReader(cfg)
+	if err != nil {
+		// the snapshot maybe removed by a running merge, try again
+		r, err = bluge.OpenReader(cfg)
+	}
+	return r, err
+}
+
+// acquireFrozenReader returns the cached reader of the frozen second layer shard, it is opened if not cached.
+// The reader must be closed by closeReader.
+func (s *IndexShard) acquireFrozenReader(shardID int64) (*bluge.Reader, error) {
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil {
+		return nil, errors.New(errors.ErrorTypeRuntimeException, "second shard not found")
+	}
+	frozenReaders.Lock()
+	if fr := secondShard.reader; fr != nil {
+		fr.refs++
+		frozenReaders.Unlock()
+		return fr.reader, nil
+	}
+	frozenReaders.Unlock()
+
+	r, err := s.openFrozenReader(shardID)
+	if err != nil {
+		return nil, err
+	}
+
+	frozenReaders.Lock()
+	defer frozenReaders.Unlock()
+	if fr := secondShard.reader; fr != nil {
+		// opened by another search meanwhile
+		fr.refs++
+		_ = r.Close()
+		return fr.reader, nil
+	}
+	fr := &frozenReader{reader: r, refs: 1, closed: make(chan struct{})}
+	frozenReaders.readers[r] = fr
+	if secondShard.IsDeleted() {
+		fr.retired = true // not cached, closed after the search
+	} else {
+		secondShard.reader = fr
+	}
+	return r, nil
+}
+
+// retireFrozenReader stops sharing the cached reader of the second layer shard,
+// it is closed now if no search is using it or else by the last closeReader.
+// The returned channel is closed after the reader is closed.
+func (s *IndexSecondShard) retireFrozenReader() <-chan struct{} {
+	frozenReaders.Lock()
+	fr := s.reader
+	s.reader = nil
+	if fr == nil {
+		frozenReaders.Unlock()
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+	fr.retired = true
+	if fr.refs > 0 {
+		frozenReaders.Unlock()
+		return fr.closed
+	}
+	delete(frozenReaders.readers, fr.reader)
+	frozenReaders.Unlock()
+	_ = fr.reader.Close()
+	close(fr.closed)
+	return fr.closed
+}
+
+// closeReader closes the reader returned by getReader, a cached frozen reader is
+// only released and closed when it is retired and no other search uses it.
+func closeReader(r *bluge.Reader) error {
+	frozenReaders.Lock()
+	fr, ok := frozenReaders.readers[r]
+	if !ok {
+		frozenReaders.Unlock()
+		return r.Close()
+	}
+	fr.refs--
+	if !fr.retired || fr.refs > 0 {
+		frozenReaders.Unlock()
+		return nil
+	}
+	delete(frozenReaders.readers, r)
+	frozenReaders.Unlock()
+	defer close(fr.closed)
+	return r.Close()
+}
+
+// getReader returns a reader of the second layer shard, it must be closed by closeReader
+func (s *IndexShard) getReader(shardID int64) (*bluge.Reader, error) {
+	if !s.IsFrozen(shardID) {
+		r, err := s.getWriterReader(shardID)
+		if err == nil || !s.IsFrozen(shardID) {
+			return r, err
+		}
+		// frozen meanwhile, the writer is closed
+	}
+	return s.acquireFrozenReader(shardID)
+}
+
+func (s *IndexShard) getWriterReader(shardID int64) (*bluge.Reader, error) {
+	w, err := s.GetWriter(shardID)
+	if err != nil {
+		return nil, err
+	}
+	return w.Reader()
+}
+
+// writeFrozenShard deletes the documents from the frozen second layer shard, the documents are looked up
+// by the cached reader and the writer is opened only to delete the documents found in the shard.
+func (s *IndexShard) writeFrozenShard(shardID int64, docIDs []string) error {
+	if len(docIDs) == 0 || s.IsDeleted(shardID) {
+		return nil
+	}
+
+	r, err := s.acquireFrozenReader(shardID)
+	if err != nil {
+		if s.IsDeleted(shardID) {
+			return nil // deleted by the index retention meanwhile
+		}
+		return err
+	}
+	query := bluge.NewBooleanQuery()
+	for _, docID := range docIDs {
+		query.AddShould(bluge.NewTermQuery(docID).SetField("_id"))
+	}
+	dmi, err := r.Search(context.Background(), bluge.NewTopNSearch(len(docIDs), query))
+	if err != nil {
+		_ = closeReader(r)
+		return err
+	}
+	hasNested := len(s.root.GetMappings().ListNestedPath()) > 0
+	batch := blugeindex.NewBatch()
+	found := 0
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			if field != "_id" {
+				return true
+			}
+			docID := string(value)
+			batch.Delete(bluge.Identifier(docID))
+			if hasNested {
+				batch.Delete(nestedParentTerm(docID))
+			}
+			found++
+			return false
+		})
+		if err == nil {
+			next, err = dmi.Next()
+		}
+	}
+	_ = closeReader(r)
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		return nil
+	}
+
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil {
+		return nil
+	}
+	secondShard.lock.Lock()
+	defer secondShard.lock.Unlock()
+	if secondShard.IsDeleted() {
+		return nil
+	}
+	w, err := bluge.OpenWriter(getOpenConfig(s.secondShardName(shardID), s.root.GetStorageType(), s.defaultSearchAnalyzer()))
+	if err != nil {
+		return err
+	}
+	if err = w.Batch(batch); err != nil {
+		_ = w.Close()
+		return err
+	}
+	s.root.updateSecondShardStats(secondShard, w)
+	if err = w.Close(); err != nil {
+		return err
+	}
+	// the cached reader doesn't see the deletes
+	secondShard.retireFrozenReader()
+	return nil
+}
@@ -0,0 +1,151 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+func runRetention(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	for range tick.C {
+		if err := CheckRetention(); err != nil {
+			log.Error().Err(err).Msg("failed to check index retention")
+		}
+	}
+}
+
+// CheckRetention deletes the expired second layer shards of all the indexes which set retention
+func CheckRetention() error {
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if err := index.DeleteExpiredShards(); err != nil {
+			log.Error().Err(err).Str("index", index.GetName()).Msg("failed to delete expired second layer shards")
+		}
+	}
+	return nil
+}
+
+// DeleteExpiredShards deletes the old second layer shards whose documents are all older than the retention,
+// it is much cheaper than deleting the documents by query. The latest shard is never deleted.
+func (index *Index) DeleteExpiredShards() error {
+	settings := index.GetSettings()
+	if settings == nil || settings.Retention == "" {
+		return nil
+	}
+	retention, err := zutils.ParseDuration(settings.Retention)
+	if err != nil {
+		return err
+	}
+	if retention <= 0 {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-retention).UnixNano()
+	deleted := false
+	for _, shard := range index.shards {
+		latestID := shard.GetLatestShardID()
+		for _, secondShard := range shard.listSecondShards() {
+			id := secondShard.ref.ID
+			if id >= latestID {
+				continue
+			}
+			docTimeMax := atomic.LoadInt64(&secondShard.ref.Stats.DocTimeMax)
+			if docTimeMax == 0 || docTimeMax >= cutoff {
+				continue
+			}
+			if err := shard.FreezeShard(id); err != nil {
+				return err
+			}
+			shard.deleteSecondShard(id)
+			deleted = true
+		}
+	}
+	if !deleted {
+		return nil
+	}
+	return storeIndex(index)
+}
+
+// deleteSecondShard removes the frozen second layer shard and its stats from the index, the ids of the other
+// shards are not changed. The data is removed from the storage after the searches using the shard finished.
+func (s *IndexShard) deleteSecondShard(shardID int64) {
+	secondShard := s.getSecondShard(shardID)
+	if secondShard == nil {
+		return
+	}
+	secondShard.lock.Lock()
+	defer secondShard.lock.Unlock()
+
+	log.Info().
+		Str("index", s.GetIndexName()).
+		Str("shard", s.GetID()).
+		Int64("second shard", shardID).
+		Msg("delete expired second layer shard")
+
+	index := s.root
+	index.lock.Lock()
+	s.lock.Lock()
+	secondShard.deleted = true
+	for i := range s.shards {
+		if s.shards[i] == secondShard {
+			// copy the slices, the removed shard may still be used by a snapshot of them
+			s.shards = append(s.shards[:i:i], s.shards[i+1:]...)
+			break
+		}
+	}
+	for i := range s.ref.Shards {
+		if s.ref.Shards[i] == secondShard.ref {
+			s.ref.Shards = append(s.ref.Shards[:i:i], s.ref.Shards[i+1:]...)
+			break
+		}
+	}
+	s.lock.Unlock()
+	docNum := atomic.LoadUint64(&secondShard.ref.Stats.DocNum)
+	storageSize := atomic.LoadUint64(&secondShard.ref.Stats.StorageSize)
+	memorySize := atomic.LoadUint64(&secondShard.ref.Stats.MemorySize)
+	subStats(&s.ref.Stats.DocNum, docNum)
+	subStats(&s.ref.Stats.StorageSize, storageSize)
+	subStats(&s.ref.Stats.MemorySize, memorySize)
+	subStats(&index.ref.Stats.DocNum, docNum)
+	subStats(&index.ref.Stats.StorageSize, storageSize)
+	subStats(&index.ref.Stats.MemorySize, memorySize)
+	index.lock.Unlock()
+
+	name, storageType := s.secondShardName(shardID), index.GetStorageType()
+	closed := secondShard.retireFrozenReader()
+	select {
+	case <-closed:
+		deleteIndexStorage(name, storageType)
+	default:
+		go func() {
+			<-closed
+			deleteIndexStorage(name, storageType)
+		}()
+	}
+}
+
+func subStats(addr *uint64, delta uint64) {
+	if v := atomic.LoadUint64(addr); v > delta {
+		atomic.StoreUint64(addr, v-delta)
+	} else {
+		atomic.StoreUint64(addr, 0)
+	}
+}
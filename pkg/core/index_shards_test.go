@@ -16,10 +16,18 @@
 package core
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
 )
 
 func TestIndex_Shards(t *testing.T) {
@@ -91,3 +99,253 @@ func TestIndex_Shards(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestIndex_ForceMerge(t *testing.T) {
+	var index *Index
+	var shard *IndexShard
+	var err error
+	indexName := "TestIndex_ForceMerge.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		shard = index.GetShardByDocID("1")
+		assert.NotNil(t, shard)
+	})
+
+	t.Run("freeze", func(t *testing.T) {
+		for _, id := range []string{"1", "2", "3"} {
+			err := index.CreateDocument(id, map[string]interface{}{"name": "Hello" + id}, false)
+			assert.NoError(t, err)
+
+			// wait for WAL write to index
+			time.Sleep(time.Second)
+
+			err = shard.NewShard()
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int64(3), shard.GetLatestShardID())
+		for id := int64(0); id < shard.GetLatestShardID(); id++ {
+			assert.True(t, shard.IsFrozen(id))
+			_, err := shard.GetWriter(id)
+			assert.Error(t, err)
+		}
+		assert.False(t, shard.IsFrozen(shard.GetLatestShardID()))
+
+		err := shard.FreezeShard(shard.GetLatestShardID())
+		assert.Error(t, err)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resp.Hits.Total.Value)
+
+		secondShardID, err := shard.FindShardByDocID("2")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), secondShardID)
+	})
+
+	t.Run("cached frozen reader", func(t *testing.T) {
+		r1, err := shard.getReader(0)
+		require.NoError(t, err)
+		r2, err := shard.getReader(0)
+		require.NoError(t, err)
+		assert.Same(t, r1, r2)
+		assert.NoError(t, closeReader(r2))
+
+		// a retired reader is kept open until the last search closes it
+		shard.getSecondShard(0).retireFrozenReader()
+		count, err := r1.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), count)
+		assert.NoError(t, closeReader(r1))
+
+		r3, err := shard.getReader(0)
+		require.NoError(t, err)
+		assert.NotSame(t, r1, r3)
+		assert.NoError(t, closeReader(r3))
+	})
+
+	t.Run("write frozen shard", func(t *testing.T) {
+		err := index.UpdateDocument("1", map[string]interface{}{"name": "World1"}, false)
+		assert.NoError(t, err)
+		err = index.DeleteDocument("2")
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+
+		hit, err := index.GetDocument("1")
+		assert.NoError(t, err)
+		assert.Equal(t, "World1", hit.Source.(map[string]interface{})["name"])
+		secondShardID, err := shard.FindShardByDocID("1")
+		assert.NoError(t, err)
+		assert.Equal(t, shard.GetLatestShardID(), secondShardID)
+
+		_, err = index.GetDocument("2")
+		assert.Error(t, err)
+
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+	})
+
+	t.Run("force merge", func(t *testing.T) {
+		err := index.ForceMerge()
+		assert.NoError(t, err)
+
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+	})
+
+	t.Run("rollover while writing", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				err := index.CreateDocument("new"+strconv.Itoa(i), map[string]interface{}{"name": "Hello"}, false)
+				assert.NoError(t, err)
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+		for i := 0; i < 5; i++ {
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, shard.NewShard())
+		}
+		<-done
+
+		// the documents written to the frozen shards are not lost
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0})
+			return err == nil && resp.Hits.Total.Value == 102
+		}, 10*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("freeze while writing", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				err := index.CreateDocument("freeze"+strconv.Itoa(i), map[string]interface{}{"name": "Hello"}, false)
+				assert.NoError(t, err)
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+		// the same as the warm phase of ILM
+		for i := 0; i < 5; i++ {
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, index.Freeze())
+		}
+		<-done
+
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0})
+			return err == nil && resp.Hits.Total.Value == 202
+		}, 10*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_DeleteExpiredShards(t *testing.T) {
+	var index *Index
+	var shard *IndexShard
+	var err error
+	indexName := "TestIndex_DeleteExpiredShards.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+
+		err = index.SetSettings(&meta.IndexSettings{Retention: "1h"})
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		shard = index.GetShardByDocID("1")
+		assert.NotNil(t, shard)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		docs := []map[string]interface{}{
+			{"name": "Hello1", meta.TimeFieldName: time.Now().Add(-2 * time.Hour).Format(time.RFC3339)},
+			{"name": "Hello2", meta.TimeFieldName: time.Now().Format(time.RFC3339)},
+		}
+		for i, doc := range docs {
+			err := index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+
+			// wait for WAL write to the latest second shard before rolling it over
+			latestID := shard.GetLatestShardID()
+			require.Eventually(t, func() bool {
+				index.UpdateStatsBySecondShard(shard.GetID(), latestID)
+				return atomic.LoadUint64(&shard.getSecondShard(latestID).ref.Stats.DocNum) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+
+			err = shard.NewShard()
+			assert.NoError(t, err)
+		}
+		err := index.UpdateMetadata()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), index.GetStats().DocNum)
+	})
+
+	t.Run("delete expired shards", func(t *testing.T) {
+		// a search is still using the expired shard
+		r, err := shard.getReader(0)
+		require.NoError(t, err)
+
+		err = index.DeleteExpiredShards()
+		assert.NoError(t, err)
+
+		assert.True(t, shard.IsDeleted(0))
+		assert.False(t, shard.IsDeleted(1))
+		assert.False(t, shard.IsDeleted(2))
+		assert.Nil(t, shard.getSecondShard(0))
+		ids := make([]int64, 0, len(shard.ref.Shards))
+		for _, secondShard := range shard.ref.Shards {
+			ids = append(ids, secondShard.ID)
+		}
+		assert.Equal(t, []int64{1, 2}, ids)
+		assert.Equal(t, int64(2), shard.GetLatestShardID())
+		assert.Equal(t, uint64(1), index.GetStats().DocNum)
+
+		path := filepath.Join(config.Global.DataPath, shard.secondShardName(0))
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+		assert.NoError(t, closeReader(r))
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(path)
+			return os.IsNotExist(err)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 1, resp.Hits.Total.Value)
+		require.Len(t, resp.Hits.Hits, 1)
+		assert.Equal(t, "2", resp.Hits.Hits[0].ID)
+
+		_, err = index.GetDocument("1")
+		assert.Error(t, err)
+	})
+
+	t.Run("turn retention off", func(t *testing.T) {
+		err := index.SetSettings(&meta.IndexSettings{Retention: "0"})
+		assert.NoError(t, err)
+		assert.Equal(t, "", index.GetSettings().Retention)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -48,9 +48,11 @@ func (s *IndexShard) OpenWAL() error {
 		s.lock.Unlock()
 		return nil
 	}
-	// do open wal
+	// do open wal, memory index doesn't need durability
 	var err error
-	if s.wal, err = wal.Open(s.GetShardName()); err != nil {
+	if s.root.GetStorageType() == "memory" {
+		s.wal = wal.OpenMemory(s.GetShardName())
+	} else if s.wal, err = wal.Open(s.GetShardName()); err != nil {
 		s.lock.Unlock()
 		return err
 	}
@@ -326,6 +328,8 @@ func (w *walMergeDocs) AddDocument(data map[string]interface{}) {
 // need split by shards
 // need merge actions by docID
 func (w *walMergeDocs) WriteTo(shard *IndexShard, batch *blugeindex.Batch, rollback bool) error {
+	shard.writeLock.Lock()
+	defer shard.writeLock.Unlock()
 	var err error
 	for shardID := range *w {
 		if !rollback {
@@ -348,11 +352,15 @@ func (w *walMergeDocs) WriteToShard(shard *IndexShard, shardID int64, batch *blu
 		return nil
 	}
 	var writer *bluge.Writer
-	otherWriters := make([]*bluge.Writer, 0)
+	otherShardIDs := make([]int64, 0)
 	otherBatch := blugeindex.NewBatch()
 	if shardID == ShardIDNeedLatest {
 		shardID = shard.GetLatestShardID()
 	}
+	if shardID >= 0 && shard.IsFrozen(shardID) {
+		// the shard was frozen after the documents were logged, write to the latest shard
+		shardID = ShardIDNeedUpdate
+	}
 	if shardID >= 0 {
 		w, err := shard.GetWriter(shardID)
 		if err != nil {
@@ -360,23 +368,37 @@ func (w *walMergeDocs) WriteToShard(shard *IndexShard, shardID int64, batch *blu
 		}
 		writer = w
 	} else {
-		ws, err := shard.GetWriters() // get all shard
+		latestID := shard.GetLatestShardID()
+		w, err := shard.GetWriter(latestID)
 		if err != nil {
 			return err
 		}
-		writer = ws[len(ws)-1]
-		otherWriters = append(otherWriters, ws...)
-		otherWriters = otherWriters[:len(ws)-1]
+		writer = w
+		for id := int64(0); id < latestID; id++ {
+			otherShardIDs = append(otherShardIDs, id)
+		}
 	}
+	hasNested := len(shard.root.GetMappings().ListNestedPath()) > 0
 	var firstAction, lastAction string
+	otherDocIDs := make([]string, 0)
 	for _, doc := range docs {
 		// str, err := json.Marshal(doc.data)
 		// fmt.Printf("%s, %v, %v\n", str, err, doc.actions)
+		if hasNested {
+			nestedDocs, err := shard.BuildNestedDocumentsFromJSON(doc.docID, doc.data)
+			if err != nil {
+				return err
+			}
+			writeNestedDocuments(doc, nestedDocs, batch, otherBatch)
+		}
 		bdoc, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
 		if err != nil {
 			return err
 		}
 		firstAction = doc.actions[0]
+		if firstAction != meta.ActionTypeInsert {
+			otherDocIDs = append(otherDocIDs, doc.docID)
+		}
 		switch firstAction {
 		case meta.ActionTypeInsert:
 			if len(doc.actions) == 1 {
@@ -436,30 +458,60 @@ func (w *walMergeDocs) WriteToShard(shard *IndexShard, shardID int64, batch *blu
 	if err := writer.Batch(batch); err != nil {
 		return err
 	}
-	for _, writer := range otherWriters {
-		if err := writer.Batch(otherBatch); err != nil {
+	for _, id := range otherShardIDs {
+		if shard.IsFrozen(id) {
+			if err := shard.writeFrozenShard(id, otherDocIDs); err != nil {
+				return err
+			}
+			continue
+		}
+		w, err := shard.GetWriter(id)
+		if err != nil {
+			return err
+		}
+		if err := w.Batch(otherBatch); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// writeNestedDocuments replaces the hidden documents of the nested objects of the document
+func writeNestedDocuments(doc *walDocument, nestedDocs []*bluge.Document, batch, otherBatch *blugeindex.Batch) {
+	if doc.actions[0] != meta.ActionTypeInsert {
+		parent := nestedParentTerm(doc.docID)
+		batch.Delete(parent)
+		otherBatch.Delete(parent)
+	}
+	if doc.actions[len(doc.actions)-1] == meta.ActionTypeDelete {
+		return
+	}
+	for _, nestedDoc := range nestedDocs {
+		batch.Insert(nestedDoc)
+	}
+}
+
+// nestedParentTerm matches the hidden nested documents of the document
+type nestedParentTerm string
+
+func (t nestedParentTerm) Field() string {
+	return meta.NestedParentFieldName
+}
+
+func (t nestedParentTerm) Term() []byte {
+	return []byte(t)
+}
+
 func (w *walMergeDocs) WriteToShardRollback(shard *IndexShard, shardID int64, batch *blugeindex.Batch) error {
 	docs, ok := (*w)[shardID]
 	if !ok {
 		return nil
 	}
-	var writer *bluge.Writer
-	var err error
-	if shardID >= 0 {
-		writer, err = shard.GetWriter(shardID)
-	} else {
+	if shardID < 0 {
 		return nil // no insert
 	}
-	if err != nil {
-		return err
-	}
 	var firstAction string
+	docIDs := make([]string, 0)
 	for _, doc := range docs {
 		bdoc, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
 		if err != nil {
@@ -469,6 +521,8 @@ func (w *walMergeDocs) WriteToShardRollback(shard *IndexShard, shardID int64, ba
 		switch firstAction {
 		case meta.ActionTypeInsert:
 			batch.Delete(bdoc.ID())
+			batch.Delete(nestedParentTerm(doc.docID))
+			docIDs = append(docIDs, doc.docID)
 		case meta.ActionTypeUpdate:
 			// skip
 		case meta.ActionTypeDelete:
@@ -476,5 +530,12 @@ func (w *walMergeDocs) WriteToShardRollback(shard *IndexShard, shardID int64, ba
 		}
 	}
 
+	if shard.IsFrozen(shardID) {
+		return shard.writeFrozenShard(shardID, docIDs)
+	}
+	writer, err := shard.GetWriter(shardID)
+	if err != nil {
+		return err
+	}
 	return writer.Batch(batch)
 }
@@ -120,6 +120,7 @@ func (t *IndexShardWALList) ConsumeWAL() {
 			stats := index.GetStats()
 			SetMetricStatsByIndex(name, "doc_num", float64(atomic.LoadUint64(&stats.DocNum)))
 			SetMetricStatsByIndex(name, "storage_size", float64(atomic.LoadUint64(&stats.StorageSize)/1024/1024)) // convert to MB
+			SetMetricStatsByIndex(name, "memory_size", float64(atomic.LoadUint64(&stats.MemorySize)/1024/1024))   // convert to MB
 
 			delete(indexes, name)
 		}
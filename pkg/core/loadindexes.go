@@ -16,6 +16,8 @@
 package core
 
 import (
+	"time"
+
 	"github.com/rs/zerolog/log"
 
 	"github.com/zincsearch/zincsearch/pkg/errors"
@@ -35,6 +37,14 @@ func LoadZincIndexesFromMetadata(version string) error {
 
 	for i := range indexes {
 		readIndex := indexes[i]
+		// memory index is lost when the process exits, just remove the metadata
+		if readIndex.StorageType == "memory" {
+			log.Info().Msgf("Removing memory index... [%s]", readIndex.Name)
+			if err := metadata.Index.Delete(readIndex.Name); err != nil {
+				log.Error().Err(err).Msgf("Error removing memory index[%s]", readIndex.Name)
+			}
+			continue
+		}
 		index := new(Index)
 		index.ref = new(meta.Index)
 		index.ref.Name = readIndex.Name
@@ -42,6 +52,11 @@ func LoadZincIndexesFromMetadata(version string) error {
 		index.ref.Settings = readIndex.Settings
 		index.ref.Mappings = readIndex.Mappings
 		index.ref.Stats = readIndex.Stats
+		index.ref.CreatedAt = readIndex.CreatedAt
+		if index.ref.CreatedAt.IsZero() {
+			// index created by old version, count the age from now on
+			index.ref.CreatedAt = time.Now()
+		}
 
 		// upgrade from old version
 		if readIndex.Version != "" {
@@ -72,11 +87,12 @@ func LoadZincIndexesFromMetadata(version string) error {
 				ShardNum: readIndex.Shards[id].ShardNum,
 				Stats:    readIndex.Shards[id].Stats,
 			}
-			index.ref.Shards[id].Shards = make([]*meta.IndexSecondShard, index.ref.Shards[id].ShardNum)
+			index.ref.Shards[id].Shards = make([]*meta.IndexSecondShard, len(readIndex.Shards[id].Shards))
 			for j := range readIndex.Shards[id].Shards {
 				index.ref.Shards[id].Shards[j] = &meta.IndexSecondShard{
-					ID:    readIndex.Shards[id].Shards[j].ID,
-					Stats: readIndex.Shards[id].Shards[j].Stats,
+					ID:     readIndex.Shards[id].Shards[j].ID,
+					Frozen: readIndex.Shards[id].Shards[j].Frozen,
+					Stats:  readIndex.Shards[id].Shards[j].Stats,
 				}
 			}
 		}
@@ -91,7 +107,7 @@ func LoadZincIndexesFromMetadata(version string) error {
 				ref:  index.ref.Shards[id],
 				name: index.ref.Name + "/" + index.ref.Shards[id].ID,
 			}
-			index.shards[id].shards = make([]*IndexSecondShard, index.ref.Shards[id].ShardNum)
+			index.shards[id].shards = make([]*IndexSecondShard, len(index.ref.Shards[id].Shards))
 			for j := range index.ref.Shards[id].Shards {
 				index.shards[id].shards[j] = &IndexSecondShard{
 					root: index,
@@ -16,28 +16,51 @@
 package core
 
 import (
-	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
-	"github.com/rs/zerolog/log"
 
-	zincsearch "github.com/zincsearch/zincsearch/pkg/bluge/search"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	"github.com/zincsearch/zincsearch/pkg/uquery"
 	"github.com/zincsearch/zincsearch/pkg/uquery/timerange"
 )
 
 func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	timeMin, timeMax := timerange.Query(query.Query)
+	readers, shardNum, mappings, analyzers, err := getReadersByIndexNames(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	if len(readers) == 0 {
+		return &meta.SearchResponse{}, nil
+	}
+
+	defer func() {
+		for _, reader := range readers {
+			_ = closeReader(reader)
+		}
+	}()
+
+	_, err = uquery.ParseQueryDSL(query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchReaders(shardNum, readers, query, mappings, analyzers)
+}
+
+// getReadersByIndexNames returns readers of all indexes matched the names,
+// and the mappings, analyzers of the first matched index.
+func getReadersByIndexNames(indexNames []string, timeMin, timeMax int64) (
+	[]*bluge.Reader, int64, *meta.Mappings, map[string]*analysis.Analyzer, error,
+) {
 	var mappings *meta.Mappings
 	var analyzers map[string]*analysis.Analyzer
 	var readers []*bluge.Reader
 	var shardNum int64
 
-	timeMin, timeMax := timerange.Query(query.Query)
 	isMatched := false
 	hasIndex := false
 	for _, index := range ZINC_INDEX_LIST.List() {
@@ -56,7 +79,10 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 
 		reader, err := index.GetReaders(timeMin, timeMax)
 		if err != nil {
-			return nil, err
+			for _, r := range readers {
+				_ = closeReader(r)
+			}
+			return nil, 0, nil, nil, err
 		}
 		readers = append(readers, reader...)
 		shardNum += index.GetShardNum()
@@ -64,49 +90,13 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 			mappings = index.GetMappings()
 			analyzers = index.GetAnalyzers()
 		}
-
-	}
-
-	if len(readers) == 0 {
-		if !hasIndex {
-			return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
-		}
-		return &meta.SearchResponse{}, nil
-	}
-
-	defer func() {
-		for _, reader := range readers {
-			reader.Close()
-		}
-	}()
-
-	_, err := uquery.ParseQueryDSL(query, mappings, analyzers)
-	if err != nil {
-		return nil, err
 	}
 
-	ctx := context.Background()
-	var cancel context.CancelFunc
-	if query.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(query.Timeout)*time.Second)
-		defer cancel()
-	}
-
-	// dmi, err := bluge.MultiSearch(ctx, searchRequest, readers...)
-	dmi, err := zincsearch.MultiSearch(ctx, query, mappings, analyzers, readers...)
-	if err != nil {
-		log.Printf("core.MultiSearchV2: error executing search: %s", err.Error())
-		if err == context.DeadlineExceeded {
-			return &meta.SearchResponse{
-				TimedOut: true,
-				Error:    err.Error(),
-				Hits:     meta.Hits{Hits: []meta.Hit{}},
-			}, nil
-		}
-		return nil, err
+	if len(readers) == 0 && !hasIndex {
+		return nil, 0, nil, nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
 	}
 
-	return searchV2(shardNum, int64(len(readers)), dmi, query, mappings)
+	return readers, shardNum, mappings, analyzers, nil
 }
 
 // isMatchIndex("abc", "a")  false
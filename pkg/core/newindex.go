@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
+	blugeindex "github.com/blugelabs/bluge/index"
 
 	"github.com/zincsearch/zincsearch/pkg/bluge/directory"
 	"github.com/zincsearch/zincsearch/pkg/config"
@@ -46,6 +48,21 @@ func CheckIndexName(name string) error {
 	return nil
 }
 
+// CheckStorageType checks the storage type is supported and configured
+func CheckStorageType(storageType string) error {
+	switch storageType {
+	case "disk", "memory":
+		return nil
+	case "s3":
+		if config.Global.S3.Bucket == "" {
+			return fmt.Errorf("storage_type [s3] requires ZINC_S3_BUCKET to be set")
+		}
+		return nil
+	default:
+		return fmt.Errorf("storage_type [%s] is not supported, just accept [disk, s3, memory]", storageType)
+	}
+}
+
 // NewIndex creates an instance of a physical zinc index that can be used to store and retrieve data.
 func NewIndex(name, storageType string, shardNum int64) (*Index, error) {
 	if err := CheckIndexName(name); err != nil {
@@ -55,6 +72,9 @@ func NewIndex(name, storageType string, shardNum int64) (*Index, error) {
 	if storageType == "" {
 		storageType = "disk"
 	}
+	if err := CheckStorageType(storageType); err != nil {
+		return nil, err
+	}
 
 	if shardNum <= 0 {
 		shardNum = config.Global.Shard.Num
@@ -65,6 +85,7 @@ func NewIndex(name, storageType string, shardNum int64) (*Index, error) {
 	index.ref.Name = name
 	index.ref.StorageType = storageType
 	index.ref.Version = meta.Version
+	index.ref.CreatedAt = time.Now()
 
 	// use template
 	if err := index.UseTemplate(); err != nil {
@@ -125,14 +146,38 @@ func OpenIndexWriter(name string, storageType string, defaultSearchAnalyzer *ana
 }
 
 func getOpenConfig(name string, storageType string, defaultSearchAnalyzer *analysis.Analyzer, timeRange ...int64) bluge.Config {
-	dataPath := config.Global.DataPath
-	cfg := directory.GetDiskConfig(dataPath, name, timeRange...)
+	cfg := bluge.DefaultConfigWithIndexConfig(getOpenIndexConfig(name, storageType, timeRange...))
 	if defaultSearchAnalyzer != nil {
 		cfg.DefaultSearchAnalyzer = defaultSearchAnalyzer
 	}
 	return cfg
 }
 
+func getOpenIndexConfig(name string, storageType string, timeRange ...int64) blugeindex.Config {
+	dataPath := config.Global.DataPath
+	switch storageType {
+	case "s3":
+		return directory.GetS3IndexConfig(getS3Config(), dataPath, name, timeRange...)
+	case "memory":
+		return directory.GetMemoryIndexConfig(name, timeRange...)
+	default:
+		return directory.GetDiskIndexConfig(dataPath, name, timeRange...)
+	}
+}
+
+func getS3Config() directory.S3Config {
+	return directory.S3Config{
+		Endpoint:  config.Global.S3.Endpoint,
+		Region:    config.Global.S3.Region,
+		Bucket:    config.Global.S3.Bucket,
+		AccessKey: config.Global.S3.AccessKey,
+		SecretKey: config.Global.S3.SecretKey,
+		Prefix:    config.Global.S3.Prefix,
+		CacheSize: config.Global.S3.CacheSize,
+		Timeout:   config.Global.S3.Timeout,
+	}
+}
+
 // storeIndex stores the index to metadata
 func StoreIndex(index *Index) error {
 	// check index
@@ -181,5 +226,11 @@ func GetIndex(name string) (*Index, bool) {
 }
 
 func GetOrCreateIndex(name, storageType string, shardNum int64) (*Index, bool, error) {
+	// write to the write index if the name is an alias
+	if _, ok := ZINC_INDEX_LIST.Get(name); !ok {
+		if writeIndex, ok := ZINC_INDEX_ALIAS_LIST.GetWriteIndexForAlias(name); ok {
+			name = writeIndex
+		}
+	}
 	return ZINC_INDEX_LIST.GetOrCreate(name, storageType, shardNum)
 }
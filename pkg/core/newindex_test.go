@@ -16,9 +16,26 @@
 package core
 
 import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/blugelabs/bluge"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/metadata"
 )
 
 func TestNewIndex(t *testing.T) {
@@ -63,6 +80,14 @@ func TestNewIndex(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "unsupported storage type",
+			args: args{
+				name:        "TestNewIndex.index_4",
+				storageType: "hdfs",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -176,3 +201,232 @@ func TestCheckIndexName(t *testing.T) {
 		})
 	}
 }
+
+// fakeS3 is an in-process S3 compatible object storage for testing
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	lists   int
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/zinc/")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		s.lists++
+		type object struct {
+			Key  string
+			Size int
+		}
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []object
+		}{}
+		for k, v := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, object{Key: k, Size: len(v)})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *fakeS3) listCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lists
+}
+
+func (s *fakeS3) keys(suffix string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keys []string
+	for k := range s.objects {
+		if strings.HasSuffix(k, suffix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestNewIndex_S3(t *testing.T) {
+	storage := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	s3Config := config.Global.S3
+	config.Global.S3.Endpoint = server.URL
+	config.Global.S3.Bucket = "zinc"
+	config.Global.S3.AccessKey = "test"
+	config.Global.S3.SecretKey = "secret"
+	config.Global.S3.Prefix = "indices"
+	defer func() { config.Global.S3 = s3Config }()
+
+	indexName := "TestNewIndex_S3.index_1"
+	index, err := NewIndex(indexName, "s3", 1)
+	assert.NoError(t, err)
+	err = StoreIndex(index)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"name": "doc " + strconv.Itoa(i)}, false)
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}})
+		return err == nil && resp.Hits.Total.Value == 10 && len(storage.keys(".snp")) > 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	segments := storage.keys(".seg")
+	assert.NotEmpty(t, segments)
+	for _, key := range segments {
+		assert.True(t, strings.HasPrefix(key, "indices/"+indexName+"/"))
+	}
+
+	t.Run("load from object storage", func(t *testing.T) {
+		// drop the local cache, the segments should be downloaded
+		shardName := strings.TrimPrefix(path.Dir(segments[0]), "indices/")
+		cachePath := path.Join(config.Global.DataPath, shardName)
+		entries, err := os.ReadDir(cachePath)
+		assert.NoError(t, err)
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".seg") {
+				assert.NoError(t, os.Remove(path.Join(cachePath, entry.Name())))
+			}
+		}
+
+		reader, err := bluge.OpenReader(getOpenConfig(shardName, "s3", nil))
+		assert.NoError(t, err)
+		defer reader.Close()
+		count, err := reader.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), count)
+	})
+
+	t.Run("list objects once", func(t *testing.T) {
+		shardName := strings.TrimPrefix(path.Dir(segments[0]), "indices/")
+		lists := storage.listCount()
+		reader, err := bluge.OpenReader(getOpenConfig(shardName, "s3", nil))
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, lists+1, storage.listCount())
+	})
+
+	t.Run("evict closed segments", func(t *testing.T) {
+		shardName := strings.TrimPrefix(path.Dir(segments[0]), "indices/")
+		cachePath := path.Join(config.Global.DataPath, shardName)
+		cachedSegments := func() int {
+			entries, err := os.ReadDir(cachePath)
+			assert.NoError(t, err)
+			n := 0
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".seg") {
+					n++
+				}
+			}
+			return n
+		}
+
+		config.Global.S3.CacheSize = 1
+		reader, err := bluge.OpenReader(getOpenConfig(shardName, "s3", nil))
+		require.NoError(t, err)
+		count, err := reader.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), count)
+		// the loaded segments are kept until the reader is closed
+		assert.NotZero(t, cachedSegments())
+		assert.NoError(t, reader.Close())
+		assert.Zero(t, cachedSegments())
+
+		// the evicted segments are downloaded again
+		reader, err = bluge.OpenReader(getOpenConfig(shardName, "s3", nil))
+		require.NoError(t, err)
+		count, err = reader.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), count)
+		assert.NoError(t, reader.Close())
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+		assert.Empty(t, storage.keys(""))
+	})
+}
+
+func TestNewIndex_Memory(t *testing.T) {
+	indexName := "TestNewIndex_Memory.index_1"
+	index, err := NewIndex(indexName, "memory", 2)
+	assert.NoError(t, err)
+	err = StoreIndex(index)
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"name": "doc " + strconv.Itoa(i)}, false)
+		assert.NoError(t, err)
+	}
+	count := func() int {
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}})
+		if err != nil {
+			return -1
+		}
+		return resp.Hits.Total.Value
+	}
+	assert.Eventually(t, func() bool { return count() == 10 }, 5*time.Second, 100*time.Millisecond)
+
+	t.Run("nothing is written to disk", func(t *testing.T) {
+		_, err := os.Stat(path.Join(config.Global.DataPath, indexName))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("report memory use", func(t *testing.T) {
+		err := index.UpdateMetadata()
+		assert.NoError(t, err)
+		stats := index.GetStats()
+		assert.Equal(t, uint64(10), stats.DocNum)
+		assert.Greater(t, stats.MemorySize, uint64(0))
+		assert.Equal(t, uint64(0), stats.StorageSize)
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		err := index.Reopen()
+		assert.NoError(t, err)
+		assert.Equal(t, 10, count())
+	})
+
+	t.Run("excluded from loading", func(t *testing.T) {
+		_, err := metadata.Index.Get(indexName)
+		assert.NoError(t, err)
+		ZINC_INDEX_LIST.Close()
+		err = LoadZincIndexesFromMetadata(meta.Version)
+		assert.NoError(t, err)
+		// the metadata of memory index is removed
+		_, err = metadata.Index.Get(indexName)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,225 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/ider"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// pitGCInterval is the interval to release expired point in time
+const pitGCInterval = time.Second
+
+var ZINC_PIT_LIST PointInTimeList
+
+// PointInTime pins the readers of indexes when it opened,
+// all searches with it will see the same documents even new documents are writing.
+// The readers will be released when it closed or keep_alive expired.
+type PointInTime struct {
+	id        string
+	shardNum  int64
+	readers   []*bluge.Reader
+	mappings  *meta.Mappings
+	analyzers map[string]*analysis.Analyzer
+	expire    int64 // unix nano
+	closed    bool
+	lock      sync.RWMutex
+}
+
+type PointInTimeList struct {
+	Items map[string]*PointInTime
+	lock  sync.RWMutex
+}
+
+func init() {
+	ZINC_PIT_LIST.Items = make(map[string]*PointInTime)
+	go ZINC_PIT_LIST.GC()
+}
+
+// OpenPointInTime pins the current readers of indexes matched the names
+func OpenPointInTime(indexNames []string, keepAlive string) (*PointInTime, error) {
+	ttl, err := parseKeepAlive(keepAlive)
+	if err != nil {
+		return nil, err
+	}
+	pit, err := newPointInTime(indexNames, ttl)
+	if err != nil {
+		return nil, err
+	}
+	ZINC_PIT_LIST.Add(pit)
+	return pit, nil
+}
+
+func newPointInTime(indexNames []string, ttl time.Duration) (*PointInTime, error) {
+	readers, shardNum, mappings, analyzers, err := getReadersByIndexNames(indexNames, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pit := &PointInTime{
+		id:        ider.Generate(),
+		shardNum:  shardNum,
+		readers:   readers,
+		mappings:  mappings,
+		analyzers: analyzers,
+	}
+	pit.touch(ttl)
+	return pit, nil
+}
+
+// GetPointInTime returns an opened point in time by id
+func GetPointInTime(id string) (*PointInTime, bool) {
+	return ZINC_PIT_LIST.Get(id)
+}
+
+// ClosePointInTime releases the point in time, returns if it exists
+func ClosePointInTime(id string) bool {
+	pit, ok := ZINC_PIT_LIST.Get(id)
+	if !ok {
+		return false
+	}
+	ZINC_PIT_LIST.Delete(id)
+	pit.Close()
+	return true
+}
+
+func (p *PointInTime) GetID() string {
+	return p.id
+}
+
+// Expired returns if the keep_alive of point in time is over
+func (p *PointInTime) Expired() bool {
+	return time.Now().UnixNano() > atomic.LoadInt64(&p.expire)
+}
+
+// Search executes the query on the pinned readers, and extends keep_alive if it was set.
+func (p *PointInTime) Search(query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	if query.PIT != nil && query.PIT.KeepAlive != "" {
+		ttl, err := parseKeepAlive(query.PIT.KeepAlive)
+		if err != nil {
+			return nil, err
+		}
+		p.touch(ttl)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[pit] point in time [%s] already closed", p.id))
+	}
+
+	if _, err := uquery.ParseQueryDSL(query, p.mappings, p.analyzers); err != nil {
+		return nil, err
+	}
+
+	resp, err := searchReaders(p.shardNum, p.readers, query, p.mappings, p.analyzers)
+	if err != nil {
+		return nil, err
+	}
+	resp.PitID = p.id
+	return resp, nil
+}
+
+// Close releases the pinned readers, it will wait for the running searches
+func (p *PointInTime) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	for _, r := range p.readers {
+		if err := closeReader(r); err != nil {
+			log.Error().Err(err).Str("pit", p.id).Msg("failed to close reader")
+		}
+	}
+	p.readers = nil
+	p.closed = true
+}
+
+func (p *PointInTime) touch(ttl time.Duration) {
+	atomic.StoreInt64(&p.expire, time.Now().Add(ttl).UnixNano())
+}
+
+func (t *PointInTimeList) Add(pit *PointInTime) {
+	t.lock.Lock()
+	t.Items[pit.id] = pit
+	t.lock.Unlock()
+}
+
+func (t *PointInTimeList) Get(id string) (*PointInTime, bool) {
+	t.lock.RLock()
+	pit, ok := t.Items[id]
+	t.lock.RUnlock()
+	if ok && pit.Expired() {
+		return nil, false
+	}
+	return pit, ok
+}
+
+func (t *PointInTimeList) Delete(id string) {
+	t.lock.Lock()
+	delete(t.Items, id)
+	t.lock.Unlock()
+}
+
+func (t *PointInTimeList) Len() int {
+	t.lock.RLock()
+	n := len(t.Items)
+	t.lock.RUnlock()
+	return n
+}
+
+// GC releases the expired point in time
+func (t *PointInTimeList) GC() {
+	tick := time.NewTicker(pitGCInterval)
+	for range tick.C {
+		expired := make([]*PointInTime, 0)
+		t.lock.Lock()
+		for id, pit := range t.Items {
+			if pit.Expired() {
+				expired = append(expired, pit)
+				delete(t.Items, id)
+			}
+		}
+		t.lock.Unlock()
+		for _, pit := range expired {
+			pit.Close()
+		}
+	}
+}
+
+func parseKeepAlive(keepAlive string) (time.Duration, error) {
+	if keepAlive == "" {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, "[keep_alive] is required")
+	}
+	ttl, err := zutils.ParseDuration(keepAlive)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[keep_alive] failed to parse value [%s]", keepAlive))
+	}
+	return ttl, nil
+}
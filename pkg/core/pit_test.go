@@ -0,0 +1,130 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestPointInTime(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "TestPointInTime.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i % 3)}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	var pit *PointInTime
+	t.Run("open", func(t *testing.T) {
+		_, err = OpenPointInTime([]string{indexName}, "")
+		assert.Error(t, err)
+		_, err = OpenPointInTime([]string{indexName}, "abc")
+		assert.Error(t, err)
+
+		pit, err = OpenPointInTime([]string{indexName}, "1m")
+		assert.NoError(t, err)
+		assert.NotNil(t, pit)
+
+		got, ok := GetPointInTime(pit.GetID())
+		assert.True(t, ok)
+		assert.Equal(t, pit, got)
+	})
+
+	t.Run("search after", func(t *testing.T) {
+		// new documents are invisible for the point in time
+		err := index.CreateDocument("10", map[string]interface{}{"num": float64(1)}, false)
+		assert.NoError(t, err)
+		time.Sleep(time.Second)
+
+		ids := make(map[string]bool)
+		var after []interface{}
+		for page := 0; page < 10; page++ {
+			resp, err := pit.Search(&meta.ZincQuery{
+				Query:       &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+				Sort:        []interface{}{"-num"},
+				Size:        3,
+				SearchAfter: after,
+				PIT:         &meta.PointInTime{ID: pit.GetID(), KeepAlive: "1m"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, pit.GetID(), resp.PitID)
+			assert.Equal(t, 10, resp.Hits.Total.Value)
+			if len(resp.Hits.Hits) == 0 {
+				break
+			}
+			for _, hit := range resp.Hits.Hits {
+				assert.False(t, ids[hit.ID], "duplicate hit %s", hit.ID)
+				ids[hit.ID] = true
+				assert.Len(t, hit.Sort, 2)
+			}
+			after = resp.Hits.Hits[len(resp.Hits.Hits)-1].Sort
+		}
+		assert.Len(t, ids, 10)
+		assert.False(t, ids["10"])
+	})
+
+	t.Run("search after with wrong values", func(t *testing.T) {
+		_, err := pit.Search(&meta.ZincQuery{
+			Query:       &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Sort:        []interface{}{"-num"},
+			Size:        3,
+			SearchAfter: []interface{}{1},
+			PIT:         &meta.PointInTime{ID: pit.GetID()},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		assert.True(t, ClosePointInTime(pit.GetID()))
+		assert.False(t, ClosePointInTime(pit.GetID()))
+		_, ok := GetPointInTime(pit.GetID())
+		assert.False(t, ok)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		pit, err := OpenPointInTime([]string{indexName}, "1ms")
+		assert.NoError(t, err)
+		time.Sleep(pitGCInterval * 2)
+		_, ok := GetPointInTime(pit.GetID())
+		assert.False(t, ok)
+		pit.lock.RLock()
+		assert.True(t, pit.closed)
+		pit.lock.RUnlock()
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,208 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+var ZINC_SCROLL_LIST ScrollList
+
+// Scroll is a search context used to retrieve large numbers of results.
+// It pins the readers like point in time, and keeps the query and
+// the sort values of the last hit, every scroll continues with search_after.
+type Scroll struct {
+	pit   *PointInTime
+	query *meta.ZincQuery
+	size  int
+	after []interface{}
+	lock  sync.Mutex
+}
+
+type ScrollList struct {
+	Items map[string]*Scroll
+	lock  sync.RWMutex
+}
+
+func init() {
+	ZINC_SCROLL_LIST.Items = make(map[string]*Scroll)
+	go ZINC_SCROLL_LIST.GC()
+}
+
+// OpenScroll creates a scroll context for the query and returns the first page
+func OpenScroll(indexNames []string, query *meta.ZincQuery, keepAlive string) (*meta.SearchResponse, error) {
+	ttl, err := parseKeepAlive(keepAlive)
+	if err != nil {
+		return nil, err
+	}
+	if len(query.SearchAfter) > 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[search_after] cannot be used in a scroll context")
+	}
+	if query.PIT != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[pit] cannot be used in a scroll context")
+	}
+	if ZINC_SCROLL_LIST.Len() >= config.Global.ScrollMaxOpenContexts {
+		return nil, errors.New(
+			errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf(
+				"Trying to create too many scroll contexts. Must be less than or equal to: [%d]. "+
+					"This limit can be set by changing the [ZINC_SCROLL_MAX_OPEN_CONTEXTS] setting.",
+				config.Global.ScrollMaxOpenContexts,
+			),
+		)
+	}
+
+	pit, err := newPointInTime(indexNames, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	scroll := &Scroll{
+		pit:   pit,
+		query: query,
+		size:  query.Size,
+	}
+	// scroll uses the internal point in time to get a stable sort order
+	query.PIT = &meta.PointInTime{ID: pit.id}
+
+	resp, err := scroll.next()
+	if err != nil {
+		pit.Close()
+		return nil, err
+	}
+	ZINC_SCROLL_LIST.Add(scroll)
+	return resp, nil
+}
+
+// GetScroll returns an opened scroll context by id
+func GetScroll(id string) (*Scroll, bool) {
+	return ZINC_SCROLL_LIST.Get(id)
+}
+
+// CloseScroll releases the scroll context, returns if it exists
+func CloseScroll(id string) bool {
+	scroll, ok := ZINC_SCROLL_LIST.Get(id)
+	if !ok {
+		return false
+	}
+	ZINC_SCROLL_LIST.Delete(id)
+	scroll.pit.Close()
+	return true
+}
+
+// CloseAllScrolls releases all scroll contexts, returns the number of released
+func CloseAllScrolls() int {
+	ZINC_SCROLL_LIST.lock.Lock()
+	scrolls := ZINC_SCROLL_LIST.Items
+	ZINC_SCROLL_LIST.Items = make(map[string]*Scroll)
+	ZINC_SCROLL_LIST.lock.Unlock()
+	for _, scroll := range scrolls {
+		scroll.pit.Close()
+	}
+	return len(scrolls)
+}
+
+func (s *Scroll) GetID() string {
+	return s.pit.id
+}
+
+// Next returns the next page of the scroll, and extends keep_alive if it was set.
+func (s *Scroll) Next(keepAlive string) (*meta.SearchResponse, error) {
+	if keepAlive != "" {
+		ttl, err := parseKeepAlive(keepAlive)
+		if err != nil {
+			return nil, err
+		}
+		s.pit.touch(ttl)
+	}
+	return s.next()
+}
+
+func (s *Scroll) next() (*meta.SearchResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.query.Size = s.size
+	s.query.SearchAfter = s.after
+	resp, err := s.pit.Search(s.query)
+	if err != nil {
+		return nil, err
+	}
+	// from and aggregations only used by the first page
+	s.query.From = 0
+	s.query.Aggregations = nil
+	if n := len(resp.Hits.Hits); n > 0 {
+		s.after = resp.Hits.Hits[n-1].Sort
+	}
+
+	resp.PitID = ""
+	resp.ScrollID = s.pit.id
+	return resp, nil
+}
+
+func (t *ScrollList) Add(scroll *Scroll) {
+	t.lock.Lock()
+	t.Items[scroll.GetID()] = scroll
+	t.lock.Unlock()
+}
+
+func (t *ScrollList) Get(id string) (*Scroll, bool) {
+	t.lock.RLock()
+	scroll, ok := t.Items[id]
+	t.lock.RUnlock()
+	if ok && scroll.pit.Expired() {
+		return nil, false
+	}
+	return scroll, ok
+}
+
+func (t *ScrollList) Delete(id string) {
+	t.lock.Lock()
+	delete(t.Items, id)
+	t.lock.Unlock()
+}
+
+func (t *ScrollList) Len() int {
+	t.lock.RLock()
+	n := len(t.Items)
+	t.lock.RUnlock()
+	return n
+}
+
+// GC releases the expired scroll contexts
+func (t *ScrollList) GC() {
+	tick := time.NewTicker(pitGCInterval)
+	for range tick.C {
+		expired := make([]*Scroll, 0)
+		t.lock.Lock()
+		for id, scroll := range t.Items {
+			if scroll.pit.Expired() {
+				expired = append(expired, scroll)
+				delete(t.Items, id)
+			}
+		}
+		t.lock.Unlock()
+		for _, scroll := range expired {
+			scroll.pit.Close()
+		}
+	}
+}
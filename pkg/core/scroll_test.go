@@ -0,0 +1,110 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestScroll(t *testing.T) {
+	indexName := "TestScroll.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err := NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"name": "doc" + strconv.Itoa(i)}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	t.Run("scroll", func(t *testing.T) {
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:  4,
+		}, "1m")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ScrollID)
+		assert.Empty(t, resp.PitID)
+		assert.Equal(t, 10, resp.Hits.Total.Value)
+
+		ids := make(map[string]bool)
+		for _, hit := range resp.Hits.Hits {
+			ids[hit.ID] = true
+		}
+		scroll, ok := GetScroll(resp.ScrollID)
+		assert.True(t, ok)
+		for {
+			resp, err = scroll.Next("1m")
+			assert.NoError(t, err)
+			if len(resp.Hits.Hits) == 0 {
+				break
+			}
+			for _, hit := range resp.Hits.Hits {
+				assert.False(t, ids[hit.ID], "duplicate hit %s", hit.ID)
+				ids[hit.ID] = true
+			}
+		}
+		assert.Len(t, ids, 10)
+
+		assert.True(t, CloseScroll(scroll.GetID()))
+		assert.False(t, CloseScroll(scroll.GetID()))
+	})
+
+	t.Run("max open contexts", func(t *testing.T) {
+		old := config.Global.ScrollMaxOpenContexts
+		config.Global.ScrollMaxOpenContexts = 1
+		defer func() { config.Global.ScrollMaxOpenContexts = old }()
+
+		query := func() *meta.ZincQuery {
+			return &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 1}
+		}
+		_, err := OpenScroll([]string{indexName}, query(), "1m")
+		assert.NoError(t, err)
+		_, err = OpenScroll([]string{indexName}, query(), "1m")
+		assert.Error(t, err)
+		assert.Equal(t, 1, CloseAllScrolls())
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:  1,
+		}, "1ms")
+		assert.NoError(t, err)
+		time.Sleep(pitGCInterval * 2)
+		_, ok := GetScroll(resp.ScrollID)
+		assert.False(t, ok)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/highlight"
 	"github.com/rs/zerolog/log"
@@ -28,8 +29,10 @@ import (
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	"github.com/zincsearch/zincsearch/pkg/uquery"
 	"github.com/zincsearch/zincsearch/pkg/uquery/fields"
+	"github.com/zincsearch/zincsearch/pkg/uquery/sort"
 	"github.com/zincsearch/zincsearch/pkg/uquery/source"
 	"github.com/zincsearch/zincsearch/pkg/uquery/timerange"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 )
 
 func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error) {
@@ -48,10 +51,21 @@ func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error)
 	}
 	defer func() {
 		for _, reader := range readers {
-			reader.Close()
+			_ = closeReader(reader)
 		}
 	}()
 
+	return searchReaders(index.GetAllShardNum(), readers, query, mappings, analyzers)
+}
+
+// searchReaders executes the query on the given readers and formats the response
+func searchReaders(
+	shardNum int64,
+	readers []*bluge.Reader,
+	query *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+) (*meta.SearchResponse, error) {
 	ctx := context.Background()
 	var cancel context.CancelFunc
 	if query.Timeout > 0 {
@@ -62,7 +76,7 @@ func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error)
 	// dmi, err := bluge.MultiSearch(ctx, searchRequest, readers...)
 	dmi, err := zincsearch.MultiSearch(ctx, query, mappings, analyzers, readers...)
 	if err != nil {
-		log.Printf("index.SearchV2: error executing search: %s", err.Error())
+		log.Printf("core.searchReaders: error executing search: %s", err.Error())
 		if err == context.DeadlineExceeded {
 			return &meta.SearchResponse{
 				TimedOut: true,
@@ -73,7 +87,84 @@ func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error)
 		return nil, err
 	}
 
-	return searchV2(index.GetAllShardNum(), int64(len(readers)), dmi, query, mappings)
+	resp, err := searchV2(shardNum, int64(len(readers)), dmi, query, mappings)
+	if err != nil || len(resp.Hits.Hits) == 0 {
+		return resp, err
+	}
+
+	innerHits, err := uquery.ParseInnerHits(query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	if len(innerHits) > 0 {
+		if err := searchInnerHits(ctx, readers, innerHits, resp.Hits.Hits); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// searchInnerHits finds the matching nested objects of the hits for the nested queries with inner_hits
+func searchInnerHits(ctx context.Context, readers []*bluge.Reader, innerHits []*uquery.InnerHits, hits []meta.Hit) error {
+	for i := range hits {
+		hit := &hits[i]
+		hit.InnerHits = make(map[string]meta.InnerHitsResponse, len(innerHits))
+		for _, ih := range innerHits {
+			query := bluge.NewBooleanQuery().
+				AddMust(ih.Query).
+				AddMust(bluge.NewTermQuery(hit.ID).SetField(meta.NestedParentFieldName).SetBoost(0))
+			request := bluge.NewTopNSearch(ih.Size, query).SetFrom(ih.From).WithStandardAggregations()
+			dmi, err := bluge.MultiSearch(ctx, request, readers...)
+			if err != nil {
+				return err
+			}
+
+			nestedHits := make([]meta.Hit, 0)
+			next, err := dmi.Next()
+			for err == nil && next != nil {
+				nestedHit := meta.Hit{
+					Type:   "_doc",
+					ID:     hit.ID,
+					Score:  next.Score,
+					Nested: &meta.NestedIdentity{Field: ih.Path},
+				}
+				err = next.VisitStoredFields(func(field string, value []byte) bool {
+					switch field {
+					case "_index":
+						nestedHit.Index = string(value)
+					case "@timestamp":
+						nestedHit.Timestamp, _ = bluge.DecodeDateTime(value)
+					case meta.NestedOffsetFieldName:
+						offset, _ := bluge.DecodeNumericFloat64(value)
+						nestedHit.Nested.Offset = int(offset)
+					case "_source":
+						var source map[string]interface{}
+						_ = json.Unmarshal(value, &source)
+						nestedHit.Source = source
+					}
+					return true
+				})
+				if err != nil {
+					return err
+				}
+				nestedHits = append(nestedHits, nestedHit)
+				next, err = dmi.Next()
+			}
+			if err != nil {
+				return err
+			}
+
+			hit.InnerHits[ih.Name] = meta.InnerHitsResponse{
+				Hits: meta.Hits{
+					Total:    meta.Total{Value: int(dmi.Aggregations().Count())},
+					MaxScore: dmi.Aggregations().Metric("max_score"),
+					Hits:     nestedHits,
+				},
+			}
+		}
+	}
+	return nil
 }
 
 func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query *meta.ZincQuery, mappings *meta.Mappings) (*meta.SearchResponse, error) {
@@ -91,6 +182,12 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 		}
 	}
 
+	// sort values
+	var sorts search.SortOrder
+	if query.Sort != nil {
+		sorts, _ = query.Sort.(search.SortOrder)
+	}
+
 	Hits := make([]meta.Hit, 0)
 	next, err := dmi.Next()
 	for err == nil && next != nil {
@@ -153,6 +250,9 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 			Fields:    fieldsData,
 			Highlight: highlightData,
 		}
+		if sorts != nil {
+			hit.Sort = sort.Values(sorts, next.SortValue, mappings)
+		}
 		Hits = append(Hits, hit)
 
 		next, err = dmi.Next()
@@ -169,7 +269,7 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 		Hits:     Hits,
 	}
 
-	if err := uquery.FormatResponse(resp, query, dmi.Aggregations()); err != nil {
+	if err := uquery.FormatResponse(resp, query, mappings, dmi.Aggregations()); err != nil {
 		log.Printf("core.SearchV2: error format response: %s", err.Error())
 	}
 
@@ -0,0 +1,1299 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+func TestIndex_SearchMetricAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.metric_aggregations.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+
+		// latency is 1..100, service a has the odd and b has the even ones
+		for i := 1; i <= 100; i++ {
+			service := "a"
+			if i%2 == 0 {
+				service = "b"
+			}
+			doc := map[string]interface{}{"latency": i, "service": service}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		err := index.CreateDocument("101", map[string]interface{}{"service": "c"}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query:        &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:         0,
+			Aggregations: aggs,
+		})
+		assert.NoError(t, err)
+		return resp.Aggregations
+	}
+
+	t.Run("value_count", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"latency": {ValueCount: &meta.AggregationMetric{Field: "latency"}},
+			"service": {ValueCount: &meta.AggregationMetric{Field: "service"}},
+		})
+		assert.Equal(t, 100.0, aggs["latency"].Value)
+		assert.Equal(t, 101.0, aggs["service"].Value)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"stats": {Stats: &meta.AggregationMetric{Field: "latency"}},
+		})
+		stats := aggs["stats"].Metrics
+		assert.Equal(t, int64(100), stats["count"])
+		assert.Equal(t, 1.0, stats["min"])
+		assert.Equal(t, 100.0, stats["max"])
+		assert.Equal(t, 50.5, stats["avg"])
+		assert.Equal(t, 5050.0, stats["sum"])
+
+		data, err := json.Marshal(aggs["stats"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"count":100,"min":1,"max":100,"avg":50.5,"sum":5050}`, string(data))
+	})
+
+	t.Run("extended_stats", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"stats": {ExtendedStats: &meta.AggregationExtendedStats{Field: "latency", Sigma: 3}},
+		})
+		stats := aggs["stats"].Metrics
+		assert.Equal(t, int64(100), stats["count"])
+		assert.Equal(t, 338350.0, stats["sum_of_squares"])
+		assert.InDelta(t, 833.25, stats["variance"], 0.000001)
+		assert.InDelta(t, 833.25, stats["variance_population"], 0.000001)
+		assert.InDelta(t, 841.666667, stats["variance_sampling"], 0.000001)
+		assert.InDelta(t, 28.866070, stats["std_deviation"], 0.000001)
+		assert.InDelta(t, 29.011492, stats["std_deviation_sampling"], 0.000001)
+		bounds := stats["std_deviation_bounds"].(map[string]interface{})
+		assert.InDelta(t, 50.5+3*28.866070, bounds["upper"], 0.00001)
+		assert.InDelta(t, 50.5-3*28.866070, bounds["lower"], 0.00001)
+	})
+
+	t.Run("stats without values", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{Term: map[string]*meta.TermQuery{"service": {Value: "c"}}},
+			Aggregations: map[string]meta.Aggregations{
+				"stats":    {ExtendedStats: &meta.AggregationExtendedStats{Field: "latency"}},
+				"percents": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}}},
+			},
+		})
+		assert.NoError(t, err)
+		stats := resp.Aggregations["stats"].Metrics
+		assert.Equal(t, int64(0), stats["count"])
+		assert.Nil(t, stats["min"])
+		assert.Nil(t, stats["variance"])
+
+		data, err := json.Marshal(resp.Aggregations["percents"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"values":{"50.0":null}}`, string(data))
+	})
+
+	t.Run("percentiles", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"percents": {Percentiles: &meta.AggregationPercentiles{Field: "latency"}},
+		})
+		values := aggs["percents"].Values.(map[string]interface{})
+		assert.Len(t, values, 7)
+		assert.InDelta(t, 50.5, values["50.0"], 1)
+		assert.InDelta(t, 95.5, values["95.0"], 1)
+		assert.InDelta(t, 99.5, values["99.0"], 1)
+
+		keyed := false
+		aggs = search(t, map[string]meta.Aggregations{
+			"percents": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{99.9, 25}, Keyed: &keyed}},
+		})
+		list := aggs["percents"].Values.([]map[string]interface{})
+		assert.Len(t, list, 2)
+		assert.Equal(t, 99.9, list[0]["key"])
+		assert.InDelta(t, 100, list[0]["value"], 1)
+		assert.Equal(t, 25.0, list[1]["key"])
+		assert.InDelta(t, 25.5, list[1]["value"], 1)
+	})
+
+	t.Run("percentiles in buckets", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"services": {
+				Terms: &meta.AggregationsTerms{Field: "service"},
+				Aggregations: map[string]meta.Aggregations{
+					"p50": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}}},
+				},
+			},
+		})
+		buckets := aggs["services"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 3)
+		for _, bucket := range buckets {
+			values := bucket["p50"].(meta.AggregationResponse).Values.(map[string]interface{})
+			switch bucket["key"] {
+			case "a":
+				assert.InDelta(t, 50, values["50.0"], 1)
+			case "b":
+				assert.InDelta(t, 51, values["50.0"], 1)
+			case "c":
+				assert.Nil(t, values["50.0"])
+			}
+		}
+	})
+
+	t.Run("percentile_ranks", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"ranks": {PercentileRanks: &meta.AggregationPercentileRanks{Field: "latency", Values: []float64{10, 90, 200}}},
+		})
+		values := aggs["ranks"].Values.(map[string]interface{})
+		assert.InDelta(t, 10, values["10.0"], 1)
+		assert.InDelta(t, 90, values["90.0"], 1)
+		assert.Equal(t, 100.0, values["200.0"])
+	})
+
+	t.Run("metric aggregation errors", func(t *testing.T) {
+		for _, agg := range []meta.Aggregations{
+			{Stats: &meta.AggregationMetric{Field: "service"}},
+			{ExtendedStats: &meta.AggregationExtendedStats{Field: "latency", Sigma: -1}},
+			{Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{101}}},
+			{Percentiles: &meta.AggregationPercentiles{Field: "latency", TDigest: &meta.AggregationTDigest{Compression: 0.5}}},
+			{PercentileRanks: &meta.AggregationPercentileRanks{Field: "latency"}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{
+				Query:        &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+				Aggregations: map[string]meta.Aggregations{"agg": agg},
+			})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchTopHitsAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.top_hits_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("seq", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("host", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("time", meta.NewProperty("date"))
+
+		// seq is 1..30, host-N has seq%3 == N, one event per hour
+		start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 1; i <= 30; i++ {
+			doc := map[string]interface{}{
+				"seq":  i,
+				"host": "host-" + strconv.Itoa(i%3),
+				"time": start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query:        &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:         0,
+			Aggregations: aggs,
+		})
+		assert.NoError(t, err)
+		return resp.Aggregations
+	}
+
+	t.Run("terms with top_hits", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"hosts": {
+				Terms: &meta.AggregationsTerms{Field: "host"},
+				Aggregations: map[string]meta.Aggregations{
+					"latest": {TopHits: &meta.AggregationTopHits{
+						Size:   1,
+						Sort:   []interface{}{map[string]interface{}{"seq": "desc"}},
+						Source: []interface{}{"seq"},
+					}},
+				},
+			},
+		})
+		buckets := aggs["hosts"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 3)
+		latest := map[string]float64{"host-0": 30, "host-1": 28, "host-2": 29}
+		for _, bucket := range buckets {
+			hits := bucket["latest"].(meta.AggregationResponse).Hits
+			assert.Equal(t, 10, hits.Total.Value)
+			assert.Len(t, hits.Hits, 1)
+			seq := latest[bucket["key"].(string)]
+			assert.Equal(t, strconv.Itoa(int(seq)), hits.Hits[0].ID)
+			assert.Equal(t, indexName, hits.Hits[0].Index)
+			assert.Equal(t, map[string]interface{}{"seq": seq}, hits.Hits[0].Source)
+			assert.Equal(t, []interface{}{seq}, hits.Hits[0].Sort)
+		}
+	})
+
+	t.Run("histogram with top_hits", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"seq": {
+				Histogram: &meta.AggregationHistogram{Field: "seq", Interval: 10},
+				Aggregations: map[string]meta.Aggregations{
+					"first": {TopHits: &meta.AggregationTopHits{
+						Size:   2,
+						Sort:   []interface{}{"seq"},
+						Source: map[string]interface{}{"excludes": []interface{}{"host", "time"}},
+					}},
+				},
+			},
+		})
+		buckets := aggs["seq"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 4)
+		hits := buckets[1]["first"].(meta.AggregationResponse).Hits
+		assert.Equal(t, 10, hits.Total.Value)
+		assert.Len(t, hits.Hits, 2)
+		assert.Equal(t, "10", hits.Hits[0].ID)
+		assert.Equal(t, "11", hits.Hits[1].ID)
+		source := hits.Hits[0].Source.(map[string]interface{})
+		assert.Equal(t, 10.0, source["seq"])
+		assert.NotContains(t, source, "host")
+		assert.NotContains(t, source, "time")
+	})
+
+	t.Run("date_histogram with top_hits", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"days": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: "time", CalendarInterval: "day"},
+				Aggregations: map[string]meta.Aggregations{
+					"top": {TopHits: &meta.AggregationTopHits{}},
+				},
+			},
+		})
+		buckets := aggs["days"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		hits := buckets[0]["top"].(meta.AggregationResponse).Hits
+		assert.Equal(t, 23, hits.Total.Value)
+		assert.Len(t, hits.Hits, 3)
+		assert.Nil(t, hits.Hits[0].Sort)
+		assert.Contains(t, hits.Hits[0].Source, "@timestamp")
+
+		data, err := json.Marshal(buckets[1]["top"])
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"hits":{"total":{"value":7}`)
+	})
+
+	t.Run("top_hits with wrong size", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Aggregations: map[string]meta.Aggregations{
+				"top": {TopHits: &meta.AggregationTopHits{Size: -1}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchIPRangeAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.ip_range_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("ip", meta.NewProperty("ip"))
+		index.GetMappings().SetProperty("bytes", meta.NewProperty("numeric"))
+
+		// 10.0.0.0 .. 10.0.0.255 and one IPv6 address
+		for i := 0; i < 256; i++ {
+			doc := map[string]interface{}{"ip": "10.0.0." + strconv.Itoa(i), "bytes": i}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		err := index.CreateDocument("ipv6", map[string]interface{}{"ip": "2001:db8::1", "bytes": 1}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query:        &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:         0,
+			Aggregations: aggs,
+		})
+		assert.NoError(t, err)
+		return resp.Aggregations
+	}
+
+	t.Run("from and to", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"ips": {
+				IPRange: &meta.AggregationIPRange{Field: "ip", Ranges: []meta.IPRange{
+					{To: "10.0.0.5"},
+					{From: "10.0.0.5"},
+				}},
+				Aggregations: map[string]meta.Aggregations{
+					"bytes": {Sum: &meta.AggregationMetric{Field: "bytes"}},
+				},
+			},
+		})
+		data, err := json.Marshal(aggs["ips"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":[
+			{"key":"*-10.0.0.5","to":"10.0.0.5","doc_count":5,"bytes":{"value":10}},
+			{"key":"10.0.0.5-*","from":"10.0.0.5","doc_count":252,"bytes":{"value":32631}}
+		]}`, string(data))
+	})
+
+	t.Run("mask and keyed", func(t *testing.T) {
+		aggs := search(t, map[string]meta.Aggregations{
+			"ips": {IPRange: &meta.AggregationIPRange{Field: "ip", Keyed: true, Ranges: []meta.IPRange{
+				{Mask: "10.0.0.0/25"},
+				{Mask: "10.0.0.127/25"},
+				{Key: "v6", Mask: "2001:db8::/32"},
+			}}},
+		})
+		data, err := json.Marshal(aggs["ips"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":{
+			"10.0.0.0/25":{"from":"10.0.0.0","to":"10.0.0.128","doc_count":128},
+			"10.0.0.127/25":{"from":"10.0.0.0","to":"10.0.0.128","doc_count":128},
+			"v6":{"from":"2001:db8::","to":"2001:db9::","doc_count":1}
+		}}`, string(data))
+	})
+
+	t.Run("wrong field type", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Aggregations: map[string]meta.Aggregations{
+				"ips": {IPRange: &meta.AggregationIPRange{Field: "bytes", Ranges: []meta.IPRange{{Mask: "10.0.0.0/8"}}}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchPipelineAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.pipeline_aggregations.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("month", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("sales", meta.NewProperty("numeric"))
+
+		for i, sales := range []int{10, 20, 5, 30, 15} {
+			doc := map[string]interface{}{"month": i, "sales": sales}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) (map[string]meta.AggregationResponse, error) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query:        &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:         0,
+			Aggregations: aggs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Aggregations, nil
+	}
+	months := func(subaggs map[string]meta.Aggregations) map[string]meta.Aggregations {
+		subaggs["sales"] = meta.Aggregations{Sum: &meta.AggregationMetric{Field: "sales"}}
+		return map[string]meta.Aggregations{
+			"months": {
+				Histogram:    &meta.AggregationHistogram{Field: "month", Interval: 1},
+				Aggregations: subaggs,
+			},
+		}
+	}
+
+	t.Run("parent pipelines", func(t *testing.T) {
+		aggs, err := search(t, months(map[string]meta.Aggregations{
+			"diff":  {Derivative: &meta.AggregationPipeline{BucketsPath: "sales"}},
+			"total": {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "sales"}},
+			"avg": {MovingFn: &meta.AggregationMovingFn{
+				BucketsPath: "sales", Window: 2, Script: "MovingFunctions.unweightedAvg(values)",
+			}},
+			"double": {BucketScript: &meta.AggregationBucketScript{
+				BucketsPath: map[string]string{"s": "sales", "n": "_count"}, Script: "params.s * 2 / params.n",
+			}},
+		}))
+		assert.NoError(t, err)
+		data, err := json.Marshal(aggs["months"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":[
+			{"key":0,"key_as_string":"0","doc_count":1,"sales":{"value":10},"total":{"value":10},"avg":{"value":null},"double":{"value":20}},
+			{"key":1,"key_as_string":"1","doc_count":1,"sales":{"value":20},"diff":{"value":10},"total":{"value":30},"avg":{"value":10},"double":{"value":40}},
+			{"key":2,"key_as_string":"2","doc_count":1,"sales":{"value":5},"diff":{"value":-15},"total":{"value":35},"avg":{"value":15},"double":{"value":10}},
+			{"key":3,"key_as_string":"3","doc_count":1,"sales":{"value":30},"diff":{"value":25},"total":{"value":65},"avg":{"value":12.5},"double":{"value":60}},
+			{"key":4,"key_as_string":"4","doc_count":1,"sales":{"value":15},"diff":{"value":-15},"total":{"value":80},"avg":{"value":17.5},"double":{"value":30}}
+		]}`, string(data))
+	})
+
+	t.Run("bucket selector and sort", func(t *testing.T) {
+		aggs, err := search(t, months(map[string]meta.Aggregations{
+			"big": {BucketSelector: &meta.AggregationBucketScript{
+				BucketsPath: map[string]string{"s": "sales"}, Script: "params.s >= 10",
+			}},
+			"top": {BucketSort: &meta.AggregationBucketSort{
+				Sort: []interface{}{map[string]interface{}{"sales": map[string]interface{}{"order": "desc"}}}, Size: 2,
+			}},
+		}))
+		assert.NoError(t, err)
+		data, err := json.Marshal(aggs["months"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":[
+			{"key":3,"key_as_string":"3","doc_count":1,"sales":{"value":30}},
+			{"key":1,"key_as_string":"1","doc_count":1,"sales":{"value":20}}
+		]}`, string(data))
+	})
+
+	t.Run("sibling pipelines", func(t *testing.T) {
+		aggs, err := search(t, map[string]meta.Aggregations{
+			"months": months(map[string]meta.Aggregations{})["months"],
+			"avg":    {AvgBucket: &meta.AggregationPipeline{BucketsPath: "months>sales"}},
+			"max":    {MaxBucket: &meta.AggregationPipeline{BucketsPath: "months>sales"}},
+			"sum":    {SumBucket: &meta.AggregationPipeline{BucketsPath: "months>sales"}},
+		})
+		assert.NoError(t, err)
+		data, err := json.Marshal(map[string]interface{}{"avg": aggs["avg"], "max": aggs["max"], "sum": aggs["sum"]})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"avg":{"value":16},"max":{"value":30,"keys":["3"]},"sum":{"value":80}}`, string(data))
+	})
+
+	t.Run("invalid buckets_path", func(t *testing.T) {
+		_, err := search(t, months(map[string]meta.Aggregations{
+			"diff": {Derivative: &meta.AggregationPipeline{}},
+		}))
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{
+			"max": {MaxBucket: &meta.AggregationPipeline{BucketsPath: "sales"}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchFilterAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.filter_aggregations.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("level", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("bytes", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"level": "error", "service": "api", "bytes": 10},
+			{"level": "error", "service": "web", "bytes": 20},
+			{"level": "warning", "service": "api", "bytes": 30},
+			{"level": "info", "service": "api", "bytes": 40},
+			{"level": "info", "service": "web", "bytes": 50},
+			{"service": "web", "bytes": 60},
+		}
+		for i, doc := range docs {
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query *meta.Query, aggs string) (string, error) {
+		if query == nil {
+			query = &meta.Query{MatchAll: &meta.MatchAllQuery{}}
+		}
+		q := &meta.ZincQuery{Query: query, Size: 0}
+		if err := json.Unmarshal([]byte(aggs), &q.Aggregations); err != nil {
+			return "", err
+		}
+		resp, err := index.Search(q)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(resp.Aggregations)
+		return string(data), err
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		data, err := search(t, nil, `{"errors":{
+			"filter":{"term":{"level":"error"}},
+			"aggs":{"bytes":{"sum":{"field":"bytes"}},"services":{"cardinality":{"field":"service"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"errors":{"doc_count":2,"bytes":{"value":30},"services":{"value":2}}}`, data)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		data, err := search(t, nil, `{"levels":{
+			"filters":{"filters":{
+				"errors":{"term":{"level":"error"}},
+				"warnings":{"term":{"level":"warning"}},
+				"info":{"term":{"level":"info"}}
+			},"other_bucket_key":"other"},
+			"aggs":{"bytes":{"sum":{"field":"bytes"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"levels":{"buckets":{
+			"errors":{"doc_count":2,"bytes":{"value":30}},
+			"info":{"doc_count":2,"bytes":{"value":90}},
+			"warnings":{"doc_count":1,"bytes":{"value":30}},
+			"other":{"doc_count":1,"bytes":{"value":60}}
+		}}}`, data)
+
+		data, err = search(t, nil, `{"levels":{"filters":{"filters":[
+			{"term":{"service":"api"}},
+			{"range":{"bytes":{"gte":30}}}
+		],"other_bucket":true}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"levels":{"buckets":[{"doc_count":3},{"doc_count":4},{"doc_count":1}]}}`, data)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		data, err := search(t, nil, `{"no_level":{"missing":{"field":"level"},"aggs":{"bytes":{"max":{"field":"bytes"}}}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"no_level":{"doc_count":1,"bytes":{"value":60}}}`, data)
+	})
+
+	t.Run("global", func(t *testing.T) {
+		query := &meta.Query{Term: map[string]*meta.TermQuery{"service": {Value: "api"}}}
+		data, err := search(t, query, `{
+			"api":{"sum":{"field":"bytes"}},
+			"all":{"global":{},"aggs":{"bytes":{"sum":{"field":"bytes"}}}}
+		}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"api":{"value":80},"all":{"doc_count":6,"bytes":{"value":210}}}`, data)
+
+		_, err = search(t, nil, `{"errors":{"filter":{"term":{"level":"error"}},"aggs":{"all":{"global":{}}}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchTermsAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.terms_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("status", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"service": "api", "status": 200, "latency": 10},
+			{"service": "api", "status": 200, "latency": 20},
+			{"service": "api", "status": 500, "latency": 30},
+			{"service": "web", "status": 200, "latency": 100},
+			{"service": "web", "status": 500, "latency": 200},
+			{"service": "db", "status": 200, "latency": 5},
+			{"status": 200, "latency": 1},
+		}
+		for i, doc := range docs {
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs string) (string, error) {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		if err := json.Unmarshal([]byte(aggs), &q.Aggregations); err != nil {
+			return "", err
+		}
+		resp, err := index.Search(q)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(resp.Aggregations)
+		return string(data), err
+	}
+
+	t.Run("size and sum_other_doc_count", func(t *testing.T) {
+		data, err := search(t, `{"services":{"terms":{"field":"service","size":2}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":2,"buckets":[
+			{"key":"api","doc_count":3},{"key":"web","doc_count":2}
+		]}}`, data)
+	})
+
+	t.Run("order by key and sub aggregation", func(t *testing.T) {
+		data, err := search(t, `{"services":{"terms":{"field":"service","order":{"_key":"asc"}}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":1,"buckets":[
+			{"key":"api","doc_count":3},{"key":"db","doc_count":1},{"key":"web","doc_count":2}
+		]}}`, data)
+
+		data, err = search(t, `{"services":{
+			"terms":{"field":"service","order":[{"latency.max":"desc"}]},
+			"aggs":{"latency":{"stats":{"field":"latency"}},"avg_latency":{"avg":{"field":"latency"}}}
+		}}`)
+		assert.NoError(t, err)
+		var resp map[string]struct {
+			Buckets []struct {
+				Key string `json:"key"`
+			} `json:"buckets"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(data), &resp))
+		assert.Len(t, resp["services"].Buckets, 3)
+		assert.Equal(t, "web", resp["services"].Buckets[0].Key)
+		assert.Equal(t, "api", resp["services"].Buckets[1].Key)
+		assert.Equal(t, "db", resp["services"].Buckets[2].Key)
+
+		data, err = search(t, `{"services":{
+			"terms":{"field":"service","order":{"avg_latency":"asc"}},
+			"aggs":{"avg_latency":{"avg":{"field":"latency"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":1,"buckets":[
+			{"key":"db","doc_count":1,"avg_latency":{"value":5}},
+			{"key":"api","doc_count":3,"avg_latency":{"value":20}},
+			{"key":"web","doc_count":2,"avg_latency":{"value":150}}
+		]}}`, data)
+
+		_, err = search(t, `{"services":{"terms":{"field":"service","order":{"unknown":"asc"}}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		data, err := search(t, `{"services":{"terms":{"field":"service","include":"a.*|w.*","exclude":["web"]}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":4,"buckets":[
+			{"key":"api","doc_count":3}
+		]}}`, data)
+	})
+
+	t.Run("min_doc_count and missing", func(t *testing.T) {
+		data, err := search(t, `{"services":{"terms":{"field":"service","min_doc_count":2,"missing":"none"}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":2,"buckets":[
+			{"key":"api","doc_count":3},{"key":"web","doc_count":2}
+		]}}`, data)
+
+		data, err = search(t, `{"services":{"terms":{"field":"service","missing":"none","order":{"_count":"asc"}}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":0,"buckets":[
+			{"key":"db","doc_count":1},{"key":"none","doc_count":1},{"key":"web","doc_count":2},{"key":"api","doc_count":3}
+		]}}`, data)
+	})
+
+	t.Run("min_doc_count 0", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{Term: map[string]*meta.TermQuery{"service": {Value: "api"}}},
+			Aggregations: map[string]meta.Aggregations{
+				"services": {Terms: &meta.AggregationsTerms{Field: "service", MinDocCount: new(int)}},
+			},
+		})
+		assert.NoError(t, err)
+		data, err := json.Marshal(resp.Aggregations)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":0,"buckets":[
+			{"key":"api","doc_count":3},{"key":"db","doc_count":0},{"key":"web","doc_count":0}
+		]}}`, string(data))
+	})
+
+	t.Run("multi_terms", func(t *testing.T) {
+		data, err := search(t, `{"pairs":{"multi_terms":{"terms":[{"field":"service"},{"field":"status"}],"size":3}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"pairs":{"doc_count_error_upper_bound":0,"sum_other_doc_count":3,"buckets":[
+			{"key":["api",200],"key_as_string":"api|200","doc_count":2},
+			{"key":["api",500],"key_as_string":"api|500","doc_count":1},
+			{"key":["db",200],"key_as_string":"db|200","doc_count":1}
+		]}}`, data)
+
+		_, err = search(t, `{"pairs":{"multi_terms":{"terms":[{"field":"service"}]}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchTermsAggregationDocCountError(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.terms_aggregation_doc_count_error.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+
+		// every second layer shard returns its top term only
+		shard := index.GetShardByDocID("1")
+		segments := [][]string{{"x", "x", "y"}, {"y", "y", "z"}, {"z", "z", "x"}}
+		total := 0
+		for i, services := range segments {
+			for j, service := range services {
+				err := index.CreateDocument(strconv.Itoa(i*10+j), map[string]interface{}{"service": service}, false)
+				assert.NoError(t, err)
+			}
+			total += len(services)
+			assert.Eventually(t, func() bool {
+				resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0})
+				return err == nil && resp.Hits.Total.Value == total
+			}, 5*time.Second, 10*time.Millisecond)
+			if i < len(segments)-1 {
+				assert.NoError(t, shard.NewShard())
+			}
+		}
+	})
+
+	t.Run("doc_count_error_upper_bound", func(t *testing.T) {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		err := json.Unmarshal([]byte(`{"services":{"terms":{"field":"service","size":1,"shard_size":1}}}`), &q.Aggregations)
+		assert.NoError(t, err)
+		resp, err := index.Search(q)
+		assert.NoError(t, err)
+		if assert.NotNil(t, resp) {
+			data, err := json.Marshal(resp.Aggregations)
+			assert.NoError(t, err)
+			assert.JSONEq(t, `{"services":{"doc_count_error_upper_bound":6,"sum_other_doc_count":7,"buckets":[
+				{"key":"x","doc_count":2}
+			]}}`, string(data))
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchCompositeAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.composite_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("status", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("time", meta.NewProperty("date"))
+		index.GetMappings().SetProperty("bytes", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"service": "api", "status": 200, "time": "2022-01-01T10:00:00Z", "bytes": 10},
+			{"service": "api", "status": 200, "time": "2022-01-02T10:00:00Z", "bytes": 20},
+			{"service": "api", "status": 500, "time": "2022-01-01T11:00:00Z", "bytes": 30},
+			{"service": "web", "status": 200, "time": "2022-01-01T12:00:00Z", "bytes": 40},
+			{"service": "web", "status": 500, "time": "2022-01-02T13:00:00Z", "bytes": 50},
+			{"service": "db", "status": 200, "time": "2022-01-01T14:00:00Z", "bytes": 60},
+			{"status": 200, "time": "2022-01-01T15:00:00Z", "bytes": 70},
+		}
+		for i, doc := range docs {
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs string) (string, error) {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		if err := json.Unmarshal([]byte(aggs), &q.Aggregations); err != nil {
+			return "", err
+		}
+		resp, err := index.Search(q)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(resp.Aggregations)
+		return string(data), err
+	}
+
+	t.Run("paginate with after", func(t *testing.T) {
+		sources := `"sources":[
+			{"service":{"terms":{"field":"service"}}},
+			{"status":{"terms":{"field":"status"}}},
+			{"day":{"date_histogram":{"field":"time","calendar_interval":"day"}}}
+		]`
+		data, err := search(t, `{"billing":{"composite":{"size":4,`+sources+`}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"billing":{"after_key":{"service":"db","status":200,"day":1640995200000},"buckets":[
+			{"key":{"service":"api","status":200,"day":1640995200000},"doc_count":1},
+			{"key":{"service":"api","status":200,"day":1641081600000},"doc_count":1},
+			{"key":{"service":"api","status":500,"day":1640995200000},"doc_count":1},
+			{"key":{"service":"db","status":200,"day":1640995200000},"doc_count":1}
+		]}}`, data)
+
+		data, err = search(t, `{"billing":{"composite":{"size":4,`+sources+`,
+			"after":{"service":"db","status":200,"day":1640995200000}}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"billing":{"after_key":{"service":"web","status":500,"day":1641081600000},"buckets":[
+			{"key":{"service":"web","status":200,"day":1640995200000},"doc_count":1},
+			{"key":{"service":"web","status":500,"day":1641081600000},"doc_count":1}
+		]}}`, data)
+
+		data, err = search(t, `{"billing":{"composite":{"size":4,`+sources+`,
+			"after":{"service":"web","status":500,"day":1641081600000}}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"billing":{"buckets":[]}}`, data)
+	})
+
+	t.Run("missing_bucket, histogram and sub aggregations", func(t *testing.T) {
+		data, err := search(t, `{"pairs":{
+			"composite":{"sources":[
+				{"status":{"histogram":{"field":"status","interval":100,"order":"desc"}}},
+				{"service":{"terms":{"field":"service","missing_bucket":true}}}
+			]},
+			"aggs":{"bytes":{"sum":{"field":"bytes"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"pairs":{"after_key":{"status":200,"service":"web"},"buckets":[
+			{"key":{"status":500,"service":"api"},"doc_count":1,"bytes":{"value":30}},
+			{"key":{"status":500,"service":"web"},"doc_count":1,"bytes":{"value":50}},
+			{"key":{"status":200,"service":null},"doc_count":1,"bytes":{"value":70}},
+			{"key":{"status":200,"service":"api"},"doc_count":2,"bytes":{"value":30}},
+			{"key":{"status":200,"service":"db"},"doc_count":1,"bytes":{"value":60}},
+			{"key":{"status":200,"service":"web"},"doc_count":1,"bytes":{"value":40}}
+		]}}`, data)
+	})
+
+	t.Run("invalid after", func(t *testing.T) {
+		_, err := search(t, `{"pairs":{"composite":{
+			"sources":[{"service":{"terms":{"field":"service"}}}],
+			"after":{"status":200}
+		}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchSignificantAndRareTermsAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.significant_terms_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("level", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("code", meta.NewProperty("numeric"))
+
+		// 21 documents, 7 errors: db has 4 of its 5 documents in the errors
+		var docs []map[string]interface{}
+		for i := 0; i < 5; i++ {
+			doc := map[string]interface{}{"service": "db", "level": "info", "code": 200}
+			if i < 4 {
+				doc["level"], doc["code"] = "error", 503
+			}
+			docs = append(docs, doc)
+		}
+		for i := 0; i < 10; i++ {
+			doc := map[string]interface{}{"service": "api", "level": "info", "code": 200}
+			if i < 2 {
+				doc["level"] = "error"
+			}
+			docs = append(docs, doc)
+		}
+		for i := 0; i < 5; i++ {
+			doc := map[string]interface{}{"service": "web", "level": "info", "code": 200}
+			if i < 1 {
+				doc["level"] = "error"
+			}
+			docs = append(docs, doc)
+		}
+		docs = append(docs, map[string]interface{}{"service": "cache", "level": "info", "code": 200})
+		for i, doc := range docs {
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	type significantTerms struct {
+		DocCount int `json:"doc_count"`
+		BgCount  int `json:"bg_count"`
+		Buckets  []struct {
+			Key      interface{} `json:"key"`
+			DocCount int         `json:"doc_count"`
+			BgCount  int         `json:"bg_count"`
+			Score    float64     `json:"score"`
+		} `json:"buckets"`
+	}
+	search := func(t *testing.T, query *meta.Query, aggs string) (map[string][]byte, error) {
+		q := &meta.ZincQuery{Query: query, Size: 0}
+		if err := json.Unmarshal([]byte(aggs), &q.Aggregations); err != nil {
+			return nil, err
+		}
+		resp, err := index.Search(q)
+		if err != nil {
+			return nil, err
+		}
+		rv := make(map[string][]byte)
+		for name, agg := range resp.Aggregations {
+			if rv[name], err = json.Marshal(agg); err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	}
+	errorLogs := &meta.Query{Term: map[string]*meta.TermQuery{"level": {Value: "error"}}}
+
+	t.Run("significant_terms with jlh", func(t *testing.T) {
+		resp, err := search(t, errorLogs, `{"services":{"significant_terms":{"field":"service","min_doc_count":1}}}`)
+		assert.NoError(t, err)
+		var result significantTerms
+		assert.NoError(t, json.Unmarshal(resp["services"], &result))
+		assert.Equal(t, 7, result.DocCount)
+		assert.Equal(t, 21, result.BgCount)
+		assert.Len(t, result.Buckets, 1)
+		assert.Equal(t, "db", result.Buckets[0].Key)
+		assert.Equal(t, 4, result.Buckets[0].DocCount)
+		assert.Equal(t, 5, result.Buckets[0].BgCount)
+		assert.InDelta(t, (4.0/7-5.0/21)*((4.0/7)/(5.0/21)), result.Buckets[0].Score, 1e-9)
+
+		// db has only 4 errors
+		resp, err = search(t, errorLogs, `{"services":{"significant_terms":{"field":"service","min_doc_count":5}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"doc_count":7,"bg_count":21,"buckets":[]}`, string(resp["services"]))
+	})
+
+	t.Run("significant_terms with chi_square and mutual_information", func(t *testing.T) {
+		for _, heuristic := range []string{"chi_square", "mutual_information"} {
+			resp, err := search(t, errorLogs, `{"codes":{"significant_terms":{"field":"code","`+heuristic+`":{}}}}`)
+			assert.NoError(t, err)
+			var result significantTerms
+			assert.NoError(t, json.Unmarshal(resp["codes"], &result))
+			assert.Len(t, result.Buckets, 1, heuristic)
+			assert.Equal(t, float64(503), result.Buckets[0].Key, heuristic)
+			assert.Equal(t, 4, result.Buckets[0].BgCount, heuristic)
+			assert.Greater(t, result.Buckets[0].Score, 0.0, heuristic)
+		}
+
+		_, err := search(t, errorLogs, `{"codes":{"significant_terms":{"field":"code","chi_square":{},"mutual_information":{}}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("rare_terms", func(t *testing.T) {
+		all := &meta.Query{MatchAll: &meta.MatchAllQuery{}}
+		resp, err := search(t, all, `{"services":{"rare_terms":{"field":"service"}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":[{"key":"cache","doc_count":1}]}`, string(resp["services"]))
+
+		resp, err = search(t, all, `{"services":{"rare_terms":{"field":"service","max_doc_count":5,"exclude":"cache"}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"buckets":[{"key":"db","doc_count":5},{"key":"web","doc_count":5}]}`, string(resp["services"]))
+
+		_, err = search(t, all, `{"services":{"rare_terms":{"field":"service","max_doc_count":101}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchCardinalityAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.cardinality_aggregation.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("user", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("time", meta.NewProperty("date"))
+
+		// 400 events of 300 users, service a has the even and b has the odd ones
+		start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 400; i++ {
+			service := "a"
+			if i%2 == 1 {
+				service = "b"
+			}
+			doc := map[string]interface{}{
+				"user":    "user-" + strconv.Itoa(i%300),
+				"service": service,
+				"latency": i % 100,
+				"time":    start.Add(time.Duration(i%10) * time.Hour).Format(time.RFC3339),
+			}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0})
+			return err == nil && resp.Hits.Total.Value == 400
+		}, time.Second*10, time.Millisecond*100)
+	})
+
+	search := func(t *testing.T, aggs string) map[string]meta.AggregationResponse {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		assert.NoError(t, json.Unmarshal([]byte(aggs), &q.Aggregations))
+		resp, err := index.Search(q)
+		assert.NoError(t, err)
+		return resp.Aggregations
+	}
+
+	t.Run("exact below precision_threshold", func(t *testing.T) {
+		aggs := search(t, `{
+			"users":{"cardinality":{"field":"user"}},
+			"latencies":{"cardinality":{"field":"latency"}},
+			"hours":{"cardinality":{"field":"time"}}
+		}`)
+		assert.Equal(t, 300.0, aggs["users"].Value)
+		assert.Equal(t, 100.0, aggs["latencies"].Value)
+		assert.Equal(t, 10.0, aggs["hours"].Value)
+	})
+
+	t.Run("estimated above precision_threshold", func(t *testing.T) {
+		aggs := search(t, `{"users":{"cardinality":{"field":"user","precision_threshold":100}}}`)
+		assert.InEpsilon(t, 300.0, aggs["users"].Value, 0.1)
+	})
+
+	t.Run("under terms and histogram buckets", func(t *testing.T) {
+		aggs := search(t, `{
+			"services":{"terms":{"field":"service"},"aggs":{"users":{"cardinality":{"field":"user"}}}},
+			"latency":{"histogram":{"field":"latency","interval":50},"aggs":{"users":{"cardinality":{"field":"user"}}}}
+		}`)
+		data, err := json.Marshal(aggs)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"services":{"doc_count_error_upper_bound":0,"sum_other_doc_count":0,"buckets":[
+				{"key":"a","doc_count":200,"users":{"value":150}},
+				{"key":"b","doc_count":200,"users":{"value":150}}
+			]},
+			"latency":{"buckets":[
+				{"key":0,"key_as_string":"0","doc_count":200,"users":{"value":150}},
+				{"key":50,"key_as_string":"50","doc_count":200,"users":{"value":150}}
+			]}
+		}`, string(data))
+	})
+
+	t.Run("invalid precision_threshold", func(t *testing.T) {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		assert.NoError(t, json.Unmarshal([]byte(`{"users":{"cardinality":{"field":"user","precision_threshold":-1}}}`), &q.Aggregations))
+		_, err := index.Search(q)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchRangeAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.range_aggregations.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("time", meta.NewProperty("date"))
+		index.GetMappings().SetProperty("bytes", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+
+		// one event at noon of each day from 2022-01-01 to 2022-01-10, bytes is the day
+		for i := 1; i <= 10; i++ {
+			doc := map[string]interface{}{
+				"time":    time.Date(2022, 1, i, 12, 0, 0, 0, time.UTC).Format(time.RFC3339),
+				"bytes":   i,
+				"service": "api",
+			}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		err := index.CreateDocument("11", map[string]interface{}{"service": "web"}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, aggs string) (string, error) {
+		q := &meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 0}
+		if err := json.Unmarshal([]byte(aggs), &q.Aggregations); err != nil {
+			return "", err
+		}
+		resp, err := index.Search(q)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(resp.Aggregations)
+		return string(data), err
+	}
+
+	t.Run("range with missing and sub aggregations", func(t *testing.T) {
+		data, err := search(t, `{"bytes":{
+			"range":{"field":"bytes","missing":0,"ranges":[{"to":3},{"from":3,"to":6},{"from":6}]},
+			"aggs":{"max_bytes":{"max":{"field":"bytes"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"bytes":{"buckets":[
+			{"key":"*-3.0","to":3,"doc_count":3,"max_bytes":{"value":2}},
+			{"key":"3.0-6.0","from":3,"to":6,"doc_count":3,"max_bytes":{"value":5}},
+			{"key":"6.0-*","from":6,"doc_count":5,"max_bytes":{"value":10}}
+		]}}`, data)
+
+		data, err = search(t, `{"bytes":{"range":{"field":"bytes","keyed":true,"ranges":[{"key":"small","from":0,"to":3}]}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"bytes":{"buckets":{"small":{"from":0,"to":3,"doc_count":2}}}}`, data)
+	})
+
+	t.Run("date_range with date math and epoch values", func(t *testing.T) {
+		data, err := search(t, `{"days":{
+			"date_range":{"field":"time","ranges":[
+				{"to":"2022-01-05T00:00:00Z"},
+				{"from":"2022-01-05T00:00:00Z||+1d","to":1641772800000}
+			]},
+			"aggs":{"bytes":{"sum":{"field":"bytes"}}}
+		}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"days":{"buckets":[
+			{"key":"*-2022-01-05T00:00:00Z","to":1641340800000,"to_as_string":"2022-01-05T00:00:00Z","doc_count":4,"bytes":{"value":10}},
+			{"key":"2022-01-06T00:00:00Z-2022-01-10T00:00:00Z","from":1641427200000,"from_as_string":"2022-01-06T00:00:00Z",
+			 "to":1641772800000,"to_as_string":"2022-01-10T00:00:00Z","doc_count":4,"bytes":{"value":30}}
+		]}}`, data)
+
+		data, err = search(t, `{"days":{"date_range":{"field":"time","ranges":[{"key":"recent","from":"now-7d/d"},{"key":"past","to":"now/M"}],"keyed":true}}}`)
+		assert.NoError(t, err)
+		var resp map[string]struct {
+			Buckets map[string]struct {
+				DocCount int `json:"doc_count"`
+			} `json:"buckets"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(data), &resp))
+		assert.Equal(t, 0, resp["days"].Buckets["recent"].DocCount)
+		assert.Equal(t, 10, resp["days"].Buckets["past"].DocCount)
+	})
+
+	t.Run("date_range keyed with format and missing", func(t *testing.T) {
+		data, err := search(t, `{"days":{"date_range":{
+			"field":"time","format":"2006-01-02","keyed":true,"missing":"2022-01-01",
+			"ranges":[{"key":"early","to":"2022-01-03"}]
+		}}}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"days":{"buckets":{"early":{"to":1641168000000,"to_as_string":"2022-01-03","doc_count":3}}}}`, data)
+
+		_, err = search(t, `{"days":{"date_range":{"field":"time","ranges":[{"to":"now-1q"}]}}}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
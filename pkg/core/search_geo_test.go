@@ -0,0 +1,231 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_SearchGeo(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.geo.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("location", meta.NewProperty("geo_point"))
+
+		docs := map[string]map[string]interface{}{
+			// object
+			"new_york": {"name": "New York", "location": map[string]interface{}{"lat": 40.7128, "lon": -74.0060}},
+			// "lat,lon"
+			"boston": {"name": "Boston", "location": "42.3601,-71.0589"},
+			// [lon, lat]
+			"philadelphia": {"name": "Philadelphia", "location": []interface{}{-75.1652, 39.9526}},
+			// geohash
+			"london": {"name": "London", "location": "gcpvj0duq"},
+			// array of points
+			"chain": {"name": "Chain", "location": []interface{}{"51.5,-0.12", map[string]interface{}{"lat": 41.8781, "lon": -87.6298}}},
+			// missing
+			"unknown": {"name": "Unknown"},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		err = index.CreateDocument("invalid", map[string]interface{}{"location": "abc,def"}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query *meta.ZincQuery) []string {
+		resp, err := index.Search(query)
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	t.Run("geo_distance", func(t *testing.T) {
+		ids := search(t, &meta.ZincQuery{
+			Query: &meta.Query{GeoDistance: map[string]interface{}{
+				"distance": "200km",
+				"location": map[string]interface{}{"lat": 40.7128, "lon": -74.0060},
+			}},
+			Size: 10,
+		})
+		assert.ElementsMatch(t, []string{"new_york", "philadelphia"}, ids)
+	})
+
+	t.Run("geo_bounding_box", func(t *testing.T) {
+		ids := search(t, &meta.ZincQuery{
+			Query: &meta.Query{GeoBoundingBox: map[string]interface{}{
+				"location": map[string]interface{}{
+					"top_left":     map[string]interface{}{"lat": 43, "lon": -76},
+					"bottom_right": "40,-71",
+				},
+			}},
+			Size: 10,
+		})
+		assert.ElementsMatch(t, []string{"new_york", "boston"}, ids)
+	})
+
+	t.Run("geo_polygon", func(t *testing.T) {
+		ids := search(t, &meta.ZincQuery{
+			Query: &meta.Query{GeoPolygon: map[string]interface{}{
+				"location": map[string]interface{}{
+					"points": []interface{}{"52,-1", "52,1", "51,1", "51,-1"},
+				},
+			}},
+			Size: 10,
+		})
+		assert.ElementsMatch(t, []string{"london", "chain"}, ids)
+	})
+
+	t.Run("geo query errors", func(t *testing.T) {
+		for _, query := range []*meta.Query{
+			{GeoDistance: map[string]interface{}{"location": "40,-74"}},
+			{GeoDistance: map[string]interface{}{"distance": "abc", "location": "40,-74"}},
+			{GeoBoundingBox: map[string]interface{}{"location": map[string]interface{}{"top_left": "40,-74"}}},
+			{GeoPolygon: map[string]interface{}{"location": map[string]interface{}{"points": []interface{}{"52,-1", "52,1"}}}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Query: query, Size: 10})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("sort by _geo_distance", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Sort: []interface{}{
+				map[string]interface{}{
+					"_geo_distance": map[string]interface{}{
+						"location": []interface{}{-74.0060, 40.7128},
+						"order":    "asc",
+						"unit":     "km",
+					},
+				},
+			},
+			Size: 10,
+		})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.Equal(t, []string{"new_york", "philadelphia", "boston", "chain", "london", "unknown"}, ids)
+
+		assert.InDelta(t, 0, resp.Hits.Hits[0].Sort[0], 0.1)
+		assert.InDelta(t, 130, resp.Hits.Hits[1].Sort[0], 5)
+		assert.Nil(t, resp.Hits.Hits[5].Sort[0])
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Size:  0,
+			Aggregations: map[string]meta.Aggregations{
+				"hash": {
+					GeoHashGrid: &meta.AggregationGeoGrid{Field: "location", Precision: 1},
+					Aggregations: map[string]meta.Aggregations{
+						"center": {GeoCentroid: &meta.AggregationMetric{Field: "location"}},
+					},
+				},
+				"tile":     {GeoTileGrid: &meta.AggregationGeoGrid{Field: "location", Precision: 2}},
+				"bounds":   {GeoBounds: &meta.AggregationGeoBounds{Field: "location"}},
+				"centroid": {GeoCentroid: &meta.AggregationMetric{Field: "location"}},
+				"rings": {GeoDistance: &meta.AggregationGeoDistance{
+					Field:  "location",
+					Origin: "40.7128,-74.0060",
+					Unit:   "km",
+					Ranges: []meta.GeoDistanceRange{
+						{To: floatPtr(200)},
+						{From: floatPtr(200), To: floatPtr(1000)},
+						{From: floatPtr(1000)},
+					},
+				}},
+			},
+		})
+		assert.NoError(t, err)
+
+		// d: new_york, philadelphia, boston, chain; g: london, chain
+		hash := resp.Aggregations["hash"].Buckets.([]map[string]interface{})
+		assert.Len(t, hash, 2)
+		assert.Equal(t, "d", hash[0]["key"])
+		assert.Equal(t, uint64(4), hash[0]["doc_count"])
+		assert.Equal(t, "g", hash[1]["key"])
+		assert.Equal(t, uint64(2), hash[1]["doc_count"])
+		// all points of the documents in bucket
+		center := hash[0]["center"].(meta.AggregationResponse)
+		assert.Equal(t, int64(5), *center.Count)
+
+		tile := resp.Aggregations["tile"].Buckets.([]map[string]interface{})
+		assert.Len(t, tile, 1)
+		assert.Equal(t, "2/1/1", tile[0]["key"])
+		assert.Equal(t, uint64(5), tile[0]["doc_count"])
+
+		bounds := resp.Aggregations["bounds"].Bounds.(map[string]interface{})
+		assert.InDelta(t, 51.5, bounds["top_left"].(map[string]float64)["lat"], 0.1)
+		assert.InDelta(t, -87.6298, bounds["top_left"].(map[string]float64)["lon"], 0.0001)
+		assert.InDelta(t, 39.9526, bounds["bottom_right"].(map[string]float64)["lat"], 0.0001)
+		assert.InDelta(t, -0.12, bounds["bottom_right"].(map[string]float64)["lon"], 0.1)
+
+		assert.Equal(t, int64(6), *resp.Aggregations["centroid"].Count)
+
+		rings := resp.Aggregations["rings"].Buckets.([]map[string]interface{})
+		assert.Len(t, rings, 3)
+		assert.Equal(t, "*-200.0", rings[0]["key"])
+		assert.Equal(t, uint64(2), rings[0]["doc_count"])
+		assert.Equal(t, "200.0-1000.0", rings[1]["key"])
+		assert.Equal(t, uint64(1), rings[1]["doc_count"])
+		assert.Equal(t, "1000.0-*", rings[2]["key"])
+		assert.Equal(t, uint64(2), rings[2]["doc_count"])
+		assert.Equal(t, float64(1000), rings[2]["from"])
+	})
+
+	t.Run("aggregation on non geo_point field", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}},
+			Aggregations: map[string]meta.Aggregations{
+				"hash": {GeoHashGrid: &meta.AggregationGeoGrid{Field: "name"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
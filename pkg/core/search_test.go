@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 )
 
 func TestIndex_Search(t *testing.T) {
@@ -286,3 +287,851 @@ func TestIndex_Search(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestIndex_SearchBoosting(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.boosting.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		docs := map[string]map[string]interface{}{
+			"1": {"name": "red apple", "stock": "in"},
+			"2": {"name": "green apple", "stock": "out"},
+			"3": {"name": "yellow apple", "stock": "in"},
+			"4": {"name": "orange", "stock": "in"},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	t.Run("boosting", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{Boosting: &meta.BoostingQuery{
+				Positive:      map[string]interface{}{"match": map[string]interface{}{"name": "apple"}},
+				Negative:      map[string]interface{}{"term": map[string]interface{}{"stock": "out"}},
+				NegativeBoost: 0.1,
+			}},
+			Size: 10,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resp.Hits.Total.Value)
+		assert.Len(t, resp.Hits.Hits, 3)
+		// out of stock product is demoted but not excluded
+		assert.Equal(t, "2", resp.Hits.Hits[2].ID)
+		assert.Less(t, resp.Hits.Hits[2].Score, resp.Hits.Hits[1].Score)
+
+		resp2, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{Match: map[string]*meta.MatchQuery{"name": {Query: "apple"}}},
+			Size:  10,
+		})
+		assert.NoError(t, err)
+		for _, hit := range resp2.Hits.Hits {
+			if hit.ID == "2" {
+				assert.InDelta(t, hit.Score*0.1, resp.Hits.Hits[2].Score, 0.000001)
+			}
+		}
+	})
+
+	t.Run("boosting errors", func(t *testing.T) {
+		for _, query := range []*meta.BoostingQuery{
+			{Negative: map[string]interface{}{"match_all": map[string]interface{}{}}, NegativeBoost: 0.5},
+			{Positive: map[string]interface{}{"match_all": map[string]interface{}{}}, NegativeBoost: 0.5},
+			{Positive: "abc", Negative: map[string]interface{}{"match_all": map[string]interface{}{}}, NegativeBoost: 0.5},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Query: &meta.Query{Boosting: query}, Size: 10})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchTermsSet(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.terms_set.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("skills", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("required", meta.NewProperty("numeric"))
+
+		docs := map[string]map[string]interface{}{
+			"1": {"skills": []interface{}{"go", "rust"}, "required": 1},
+			"2": {"skills": []interface{}{"go", "python", "java"}, "required": 2},
+			"3": {"skills": []interface{}{"go", "python", "java"}, "required": 3},
+			"4": {"skills": []interface{}{"java"}, "required": 1},
+			"5": {"skills": []interface{}{"go", "python"}},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query *meta.TermsSetQuery) []string {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{TermsSet: map[string]*meta.TermsSetQuery{"skills": query}},
+			Size:  10,
+		})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	t.Run("minimum_should_match_field", func(t *testing.T) {
+		ids := search(t, &meta.TermsSetQuery{
+			Terms:                   []interface{}{"go", "python"},
+			MinimumShouldMatchField: "required",
+		})
+		// 3 requires 3 matches, 4 matches nothing, 5 has no required field
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("minimum_should_match_script", func(t *testing.T) {
+		ids := search(t, &meta.TermsSetQuery{
+			Terms: []interface{}{"go", "python"},
+			MinimumShouldMatchScript: &meta.Script{
+				Source: "Math.min(params.num_terms, doc['required'].value)",
+			},
+		})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+
+		ids = search(t, &meta.TermsSetQuery{
+			Terms: []interface{}{"go", "python", "java"},
+			MinimumShouldMatchScript: &meta.Script{
+				Source: "params.num_terms - params.slack",
+				Params: map[string]interface{}{"slack": 1},
+			},
+		})
+		assert.ElementsMatch(t, []string{"2", "3", "5"}, ids)
+	})
+
+	t.Run("terms_set errors", func(t *testing.T) {
+		for _, query := range []*meta.TermsSetQuery{
+			{Terms: []interface{}{"go"}},
+			{Terms: []interface{}{"go"}, MinimumShouldMatchField: "skills"},
+			{Terms: []interface{}{"go"}, MinimumShouldMatchScript: &meta.Script{Source: "doc['required'].value +"}},
+			{Terms: []interface{}{"go"}, MinimumShouldMatchScript: &meta.Script{Source: "Math.unknown(1)"}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{
+				Query: &meta.Query{TermsSet: map[string]*meta.TermsSetQuery{"skills": query}},
+				Size:  10,
+			})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchFunctionScore(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.function_score.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("likes", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("price", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("published", meta.NewProperty("date"))
+
+		docs := map[string]map[string]interface{}{
+			"1": {"name": "coffee beans", "tag": "hot", "likes": 10, "price": 0, "published": "2022-01-10T00:00:00Z"},
+			"2": {"name": "coffee cup", "tag": "new", "likes": 100, "price": 10, "published": "2022-01-20T00:00:00Z"},
+			"3": {"name": "coffee machine", "tag": "hot", "likes": 1, "price": 20, "published": "2021-12-31T00:00:00Z"},
+			"4": {"name": "tea", "tag": "hot", "likes": 1000, "price": 5, "published": "2022-01-10T00:00:00Z"},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query *meta.Query) []meta.Hit {
+		resp, err := index.Search(&meta.ZincQuery{Query: query, Size: 10})
+		assert.NoError(t, err)
+		return resp.Hits.Hits
+	}
+	coffee := map[string]interface{}{"match": map[string]interface{}{"name": "coffee"}}
+
+	t.Run("field_value_factor", func(t *testing.T) {
+		hits := search(t, &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			Query: coffee,
+			ScoreFunction: &meta.ScoreFunction{
+				FieldValueFactor: &meta.FieldValueFactorFunction{Field: "likes", Factor: 2},
+			},
+			BoostMode: "replace",
+		}})
+		assert.Len(t, hits, 3)
+		assert.Equal(t, "2", hits[0].ID)
+		assert.InDelta(t, 200, hits[0].Score, 0.000001)
+		assert.Equal(t, "3", hits[2].ID)
+		assert.InDelta(t, 2, hits[2].Score, 0.000001)
+	})
+
+	t.Run("decay", func(t *testing.T) {
+		// the score is decay at offset + scale from origin
+		hits := search(t, &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			Query: coffee,
+			ScoreFunction: &meta.ScoreFunction{
+				Gauss: map[string]interface{}{
+					"published": map[string]interface{}{"origin": "2022-01-10T00:00:00Z", "scale": "10d"},
+				},
+			},
+			BoostMode: "replace",
+		}})
+		scores := make(map[string]float64)
+		for _, hit := range hits {
+			scores[hit.ID] = hit.Score
+		}
+		assert.InDelta(t, 1, scores["1"], 0.000001)
+		assert.InDelta(t, 0.5, scores["2"], 0.000001)
+		assert.InDelta(t, 0.5, scores["3"], 0.000001)
+
+		hits = search(t, &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			Query: coffee,
+			Functions: []*meta.ScoreFunction{
+				{Linear: map[string]interface{}{"price": map[string]interface{}{"origin": 0, "scale": 5, "offset": 5}}},
+				{Exp: map[string]interface{}{"price": map[string]interface{}{"origin": 0, "scale": 10, "decay": 0.25}}},
+			},
+			ScoreMode: "first",
+			BoostMode: "replace",
+		}})
+		scores = make(map[string]float64)
+		for _, hit := range hits {
+			scores[hit.ID] = hit.Score
+		}
+		assert.InDelta(t, 1, scores["1"], 0.000001)
+		assert.InDelta(t, 0.5, scores["2"], 0.000001)
+		assert.InDelta(t, 0, scores["3"], 0.000001)
+	})
+
+	t.Run("weight and score_mode", func(t *testing.T) {
+		hits := search(t, &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			Query: coffee,
+			Functions: []*meta.ScoreFunction{
+				{Filter: map[string]interface{}{"term": map[string]interface{}{"tag": "hot"}}, Weight: 3},
+				{Filter: map[string]interface{}{"term": map[string]interface{}{"tag": "new"}}, Weight: 2},
+				{Filter: map[string]interface{}{"range": map[string]interface{}{"price": map[string]interface{}{"gte": 10}}}, Weight: 4},
+			},
+			ScoreMode: "sum",
+			BoostMode: "replace",
+		}})
+		scores := make(map[string]float64)
+		for _, hit := range hits {
+			scores[hit.ID] = hit.Score
+		}
+		assert.InDelta(t, 3, scores["1"], 0.000001)
+		assert.InDelta(t, 6, scores["2"], 0.000001)
+		assert.InDelta(t, 7, scores["3"], 0.000001)
+
+		// max_boost and min_score
+		minScore := 3.5
+		hits = search(t, &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			Query: coffee,
+			Functions: []*meta.ScoreFunction{
+				{Filter: map[string]interface{}{"term": map[string]interface{}{"tag": "hot"}}, Weight: 3},
+				{Filter: map[string]interface{}{"term": map[string]interface{}{"tag": "new"}}, Weight: 5},
+			},
+			BoostMode: "replace",
+			MaxBoost:  4,
+			MinScore:  &minScore,
+		}})
+		assert.Len(t, hits, 1)
+		assert.Equal(t, "2", hits[0].ID)
+		assert.InDelta(t, 4, hits[0].Score, 0.000001)
+	})
+
+	t.Run("random_score", func(t *testing.T) {
+		query := &meta.Query{FunctionScore: &meta.FunctionScoreQuery{
+			ScoreFunction: &meta.ScoreFunction{RandomScore: &meta.RandomScoreFunction{Seed: 42}},
+			BoostMode:     "replace",
+		}}
+		hits1 := search(t, query)
+		hits2 := search(t, query)
+		assert.Len(t, hits1, 4)
+		for i := range hits1 {
+			assert.Equal(t, hits1[i].ID, hits2[i].ID)
+			assert.Equal(t, hits1[i].Score, hits2[i].Score)
+			assert.GreaterOrEqual(t, hits1[i].Score, 0.0)
+			assert.Less(t, hits1[i].Score, 1.0)
+		}
+	})
+
+	t.Run("nested in bool", func(t *testing.T) {
+		hits := search(t, &meta.Query{Bool: &meta.BoolQuery{
+			Must: map[string]interface{}{
+				"function_score": map[string]interface{}{
+					"query":              coffee,
+					"field_value_factor": map[string]interface{}{"field": "likes"},
+				},
+			},
+			Filter: map[string]interface{}{"term": map[string]interface{}{"tag": "hot"}},
+		}})
+		assert.Len(t, hits, 2)
+		assert.Equal(t, "1", hits[0].ID)
+		assert.Equal(t, "3", hits[1].ID)
+	})
+
+	t.Run("script_score", func(t *testing.T) {
+		hits := search(t, &meta.Query{ScriptScore: &meta.ScriptScoreQuery{
+			Query:  coffee,
+			Script: &meta.Script{Source: "doc['likes'].value * params.factor + _score * 0", Params: map[string]interface{}{"factor": 3}},
+		}})
+		assert.Len(t, hits, 3)
+		assert.Equal(t, "2", hits[0].ID)
+		assert.InDelta(t, 300, hits[0].Score, 0.000001)
+	})
+
+	t.Run("function_score errors", func(t *testing.T) {
+		for _, query := range []*meta.Query{
+			{FunctionScore: &meta.FunctionScoreQuery{ScoreFunction: &meta.ScoreFunction{FieldValueFactor: &meta.FieldValueFactorFunction{Field: "name"}}}},
+			{FunctionScore: &meta.FunctionScoreQuery{ScoreFunction: &meta.ScoreFunction{FieldValueFactor: &meta.FieldValueFactorFunction{Field: "likes", Modifier: "abc"}}}},
+			{FunctionScore: &meta.FunctionScoreQuery{ScoreFunction: &meta.ScoreFunction{Gauss: map[string]interface{}{"price": map[string]interface{}{"origin": 0}}}}},
+			{FunctionScore: &meta.FunctionScoreQuery{ScoreFunction: &meta.ScoreFunction{Gauss: map[string]interface{}{"published": map[string]interface{}{"scale": "abc"}}}}},
+			{FunctionScore: &meta.FunctionScoreQuery{ScoreFunction: &meta.ScoreFunction{Weight: 2}, ScoreMode: "abc"}},
+			{FunctionScore: &meta.FunctionScoreQuery{Functions: []*meta.ScoreFunction{{Filter: map[string]interface{}{"match_all": map[string]interface{}{}}}}}},
+			{ScriptScore: &meta.ScriptScoreQuery{Query: coffee}},
+			{ScriptScore: &meta.ScriptScoreQuery{Script: &meta.Script{Source: "_score"}}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Query: query, Size: 10})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchNested(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.nested.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("comments", meta.NewProperty("nested"))
+		index.GetMappings().SetProperty("comments.author", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("comments.rating", meta.NewProperty("numeric"))
+
+		docs := map[string]map[string]interface{}{
+			"1": {"title": "post one", "comments": []interface{}{
+				map[string]interface{}{"author": "alice", "rating": 5},
+				map[string]interface{}{"author": "bob", "rating": 1},
+			}},
+			"2": {"title": "post two", "comments": []interface{}{
+				map[string]interface{}{"author": "alice", "rating": 1},
+				map[string]interface{}{"author": "bob", "rating": 5},
+			}},
+			"3": {"title": "post three", "comments": map[string]interface{}{"author": "carol", "rating": 3}},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	nested := func(query map[string]interface{}) *meta.ZincQuery {
+		return &meta.ZincQuery{
+			Query: map[string]interface{}{"nested": map[string]interface{}{
+				"path":       "comments",
+				"query":      query,
+				"inner_hits": map[string]interface{}{},
+			}},
+			Size: 10,
+		}
+	}
+	aliceRated5 := map[string]interface{}{"bool": map[string]interface{}{"must": []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"comments.author": "alice"}},
+		map[string]interface{}{"term": map[string]interface{}{"comments.rating": 5.0}},
+	}}}
+	commentsAgg := func(t *testing.T) meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"comments": {
+					Nested: &meta.AggregationNested{Path: "comments"},
+					Aggregations: map[string]meta.Aggregations{
+						"authors": {Terms: &meta.AggregationsTerms{Field: "comments.author", Size: 10}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		return resp.Aggregations["comments"]
+	}
+
+	t.Run("nested documents are hidden", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{Query: &meta.Query{MatchAll: &meta.MatchAllQuery{}}, Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resp.Hits.Total.Value)
+		for _, hit := range resp.Hits.Hits {
+			assert.Nil(t, hit.InnerHits)
+			assert.NotNil(t, hit.Source.(map[string]interface{})["comments"])
+		}
+
+		// the fields of nested objects are not indexed in the parent document
+		resp, err = index.Search(&meta.ZincQuery{Query: aliceRated5, Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, resp.Hits.Total.Value)
+
+		// the nested documents are not counted in the stats
+		for id := range index.shards {
+			index.UpdateMetadataByShard(id)
+		}
+		assert.NoError(t, index.UpdateMetadata())
+		assert.Equal(t, uint64(3), index.GetStats().DocNum)
+	})
+
+	t.Run("nested query with inner_hits", func(t *testing.T) {
+		resp, err := index.Search(nested(aliceRated5))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Hits.Total.Value)
+		assert.Len(t, resp.Hits.Hits, 1)
+		hit := resp.Hits.Hits[0]
+		assert.Equal(t, "1", hit.ID)
+		assert.Greater(t, hit.Score, 0.0)
+
+		innerHits := hit.InnerHits["comments"].Hits
+		assert.Equal(t, 1, innerHits.Total.Value)
+		assert.Len(t, innerHits.Hits, 1)
+		assert.Equal(t, "1", innerHits.Hits[0].ID)
+		assert.Equal(t, &meta.NestedIdentity{Field: "comments", Offset: 0}, innerHits.Hits[0].Nested)
+		assert.Equal(t, "alice", innerHits.Hits[0].Source.(map[string]interface{})["author"])
+
+		resp, err = index.Search(nested(map[string]interface{}{"term": map[string]interface{}{"comments.author": "bob"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+		for _, hit := range resp.Hits.Hits {
+			innerHits := hit.InnerHits["comments"].Hits
+			assert.Len(t, innerHits.Hits, 1)
+			assert.Equal(t, 1, innerHits.Hits[0].Nested.Offset)
+		}
+	})
+
+	t.Run("nested aggregation", func(t *testing.T) {
+		agg := commentsAgg(t)
+		assert.Equal(t, int64(5), *agg.DocCount)
+		buckets := agg.Aggregations["authors"].Buckets.([]map[string]interface{})
+		counts := make(map[string]interface{})
+		for _, bucket := range buckets {
+			counts[bucket["key"].(string)] = bucket["doc_count"]
+		}
+		assert.Equal(t, map[string]interface{}{"alice": uint64(2), "bob": uint64(2), "carol": uint64(1)}, counts)
+
+		data, err := json.Marshal(agg)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"doc_count":5,"authors":{"buckets":`)
+	})
+
+	t.Run("update and delete nested documents", func(t *testing.T) {
+		err := index.UpdateDocument("2", map[string]interface{}{"title": "post two", "comments": []interface{}{
+			map[string]interface{}{"author": "carol", "rating": 5},
+		}}, false)
+		assert.NoError(t, err)
+		err = index.DeleteDocument("3")
+		assert.NoError(t, err)
+		time.Sleep(time.Second)
+
+		resp, err := index.Search(nested(map[string]interface{}{"term": map[string]interface{}{"comments.author": "carol"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Hits.Total.Value)
+		assert.Equal(t, "2", resp.Hits.Hits[0].ID)
+		assert.Equal(t, 0, resp.Hits.Hits[0].InnerHits["comments"].Hits.Hits[0].Nested.Offset)
+
+		assert.Equal(t, int64(3), *commentsAgg(t).DocCount)
+	})
+
+	t.Run("nested errors", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"nested": map[string]interface{}{
+				"path":  "title",
+				"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+			}},
+		})
+		assert.Error(t, err)
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{"title": {Nested: &meta.AggregationNested{Path: "title"}}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchQueryString(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.query_string.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("body", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("price", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("published", meta.NewProperty("date"))
+
+		docs := map[string]map[string]interface{}{
+			"1": {"title": "quick brown fox", "body": "jumps over the lazy dog", "tag": "animal", "price": 10, "published": "2022-01-01T10:00:00Z"},
+			"2": {"title": "lazy dog", "body": "sleeps all day", "tag": "animal", "price": 20, "published": "2022-01-01T20:00:00Z"},
+			"3": {"title": "brown bread", "body": "quick recipe", "tag": "food", "price": 30, "published": "2022-02-01T10:00:00Z"},
+			"4": {"title": "Elasticsearch guide", "body": "search engine", "tag": "book", "published": "2022-03-01T10:00:00Z"},
+		}
+		for id, doc := range docs {
+			err := index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query *meta.QueryStringQuery) []string {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: query},
+			Size:  10,
+		})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	t.Run("default_operator", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "quick lazy"})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick lazy", DefaultOperator: "AND"})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick lazy", DefaultOperator: "and"})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick OR lazy", DefaultOperator: "AND"})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+	})
+
+	t.Run("boolean syntax", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "(quick OR lazy) AND NOT dog"})
+		assert.ElementsMatch(t, []string{"3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "brown && !bread"})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "+brown -fox"})
+		assert.ElementsMatch(t, []string{"3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "-animal"})
+		assert.ElementsMatch(t, []string{"3", "4"}, ids)
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "quick", Fields: []string{"title"}})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick", DefaultField: "body"})
+		assert.ElementsMatch(t, []string{"3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick", Fields: []string{"t*", "body"}})
+		assert.ElementsMatch(t, []string{"1", "3"}, ids)
+
+		// the boost of the field decides the order
+		ids = search(t, &meta.QueryStringQuery{Query: "quick", Fields: []string{"title", "body^10"}})
+		assert.Equal(t, []string{"3", "1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "quick", Fields: []string{"title^10", "body"}})
+		assert.Equal(t, []string{"1", "3"}, ids)
+	})
+
+	t.Run("field syntax", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "title:(brown AND fox)"})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: `title:"lazy dog"`})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: `body:"quick dog"~5`})
+		assert.ElementsMatch(t, []string{}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: `body:"jumps lazy"~3`})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "tag:food OR tag:book"})
+		assert.ElementsMatch(t, []string{"3", "4"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "price:20"})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "price:[10 TO 20}"})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "price:>=20"})
+		assert.ElementsMatch(t, []string{"2", "3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "_exists_:price"})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "title:bro*"})
+		assert.ElementsMatch(t, []string{"1", "3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "title:/la.y/"})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "title:brwn~1"})
+		assert.ElementsMatch(t, []string{"1", "3"}, ids)
+	})
+
+	t.Run("time_zone", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "published:2022-01-01"})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "published:2022-01-02", TimeZone: "+08:00"})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "published:[2022-01-01 TO 2022-02-01]"})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "published:{2022-01-01 TO *]"})
+		assert.ElementsMatch(t, []string{"3", "4"}, ids)
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		ids := search(t, &meta.QueryStringQuery{Query: "title:Elastic*"})
+		assert.ElementsMatch(t, []string{}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "title:Elastic*", AnalyzeWildcard: true})
+		assert.ElementsMatch(t, []string{"4"}, ids)
+		ids = search(t, &meta.QueryStringQuery{Query: "title:*own"})
+		assert.ElementsMatch(t, []string{"1", "3"}, ids)
+
+		allow := false
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "title:*own", AllowLeadingWildcard: &allow}},
+			Size:  10,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "price:abc OR dog"}},
+			Size:  10,
+		})
+		assert.Error(t, err)
+		ids := search(t, &meta.QueryStringQuery{Query: "price:abc OR dog", Lenient: true})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		// a value which doesn't match the type of the field is an error unless lenient
+		_, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "price:abc"}},
+			Size:  10,
+		})
+		assert.EqualError(t, err, "type: x_content_parse_exception, reason: [query_string] failed to parse field, "+
+			`cause: type: illegal_argument_exception, reason: [query_string] field [price] convert value to numeric error: strconv.ParseFloat: parsing "abc": invalid syntax`)
+		_, err = index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "title:(quick"}},
+			Size:  10,
+		})
+		assert.EqualError(t, err, "type: x_content_parse_exception, reason: [query_string] failed to parse field, "+
+			"cause: type: parsing_exception, reason: [query_string] failed to parse query [title:(quick]: missing ')'")
+	})
+
+	t.Run("boost", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "guide"}},
+			Size:  10,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 1)
+		resp2, err := index.Search(&meta.ZincQuery{
+			Query: &meta.Query{QueryString: &meta.QueryStringQuery{Query: "guide", Boost: 3}},
+			Size:  10,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, resp2.Hits.Hits, 1)
+		assert.InDelta(t, resp.Hits.Hits[0].Score*3, resp2.Hits.Hits[0].Score, 0.000001)
+
+		ids := search(t, &meta.QueryStringQuery{Query: "title:quick^10 body:quick"})
+		assert.Equal(t, []string{"1", "3"}, ids)
+	})
+
+	t.Run("legacy syntax", func(t *testing.T) {
+		// the syntax supported by the previous query string parser keeps working
+		for query, expected := range map[string][]string{
+			`"lazy dog"`:                         {"1", "2"},
+			`"brown fox"^2`:                      {"1"},
+			`"quick \"brown\""`:                  {"1"},
+			`title:quick\ brown`:                 {"1", "3"},
+			`\-fox`:                              {"1"},
+			`fox\*`:                              {"1"},
+			`title:fox\?`:                        {"1"},
+			`+title:quick +body:jumps`:           {"1"},
+			`-tag:animal quick`:                  {"3"},
+			`quick -bread`:                       {"1"},
+			`price:>10`:                          {"2", "3"},
+			`price:<=20`:                         {"1", "2"},
+			`price:>-5`:                          {"1", "2", "3"},
+			`price:>=-10`:                        {"1", "2", "3"},
+			`price:-10`:                          {},
+			`published:>"2022-01-15T00:00:00Z"`:  {"3", "4"},
+			`published:<="2022-01-01T20:00:00Z"`: {"1", "2"},
+			`brwn~`:                              {"1", "3"},
+			`title:brwn~1^2`:                     {"1", "3"},
+			`/la.y/`:                             {"1", "2"},
+			`tag:/anim.*/`:                       {"1", "2"},
+			`title:/b.*/^2`:                      {"1", "3"},
+			`title:b?own`:                        {"1", "3"},
+			`tag:anim*`:                          {"1", "2"},
+		} {
+			ids := search(t, &meta.QueryStringQuery{Query: query})
+			assert.ElementsMatch(t, expected, ids, query)
+		}
+	})
+
+	t.Run("query_string errors", func(t *testing.T) {
+		for _, query := range []*meta.QueryStringQuery{
+			{Query: "(quick"},
+			{Query: `"quick`},
+			{Query: "quick AND"},
+			{Query: "price:[1 20]"},
+			{Query: "quick", DefaultOperator: "XOR"},
+			{Query: "quick", Fields: []string{"title^abc"}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Query: &meta.Query{QueryString: query}, Size: 10})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchIP(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.ip.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("ip", meta.NewProperty("ip"))
+
+		docs := map[string]string{
+			"1": "10.0.0.1",
+			"2": "10.0.0.200",
+			"3": "10.0.1.5",
+			"4": "192.168.1.1",
+			"5": "2001:db8::1",
+		}
+		for id, ip := range docs {
+			err := index.CreateDocument(id, map[string]interface{}{"ip": ip}, false)
+			assert.NoError(t, err)
+		}
+		err := index.CreateDocument("6", map[string]interface{}{"ip": "10.0.0"}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(t *testing.T, query map[string]interface{}) []string {
+		resp, err := index.Search(&meta.ZincQuery{Query: query, Size: 10})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	t.Run("term", func(t *testing.T) {
+		ids := search(t, map[string]interface{}{"term": map[string]interface{}{"ip": "10.0.0.200"}})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+		ids = search(t, map[string]interface{}{"term": map[string]interface{}{"ip": "2001:db8:0::1"}})
+		assert.ElementsMatch(t, []string{"5"}, ids)
+	})
+
+	t.Run("term with CIDR", func(t *testing.T) {
+		ids := search(t, map[string]interface{}{"term": map[string]interface{}{"ip": "10.0.0.0/24"}})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+		ids = search(t, map[string]interface{}{"term": map[string]interface{}{"ip": "10.0.0.0/8"}})
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, ids)
+		ids = search(t, map[string]interface{}{"term": map[string]interface{}{"ip": "2001:db8::/32"}})
+		assert.ElementsMatch(t, []string{"5"}, ids)
+	})
+
+	t.Run("terms", func(t *testing.T) {
+		ids := search(t, map[string]interface{}{"terms": map[string]interface{}{"ip": []interface{}{"10.0.0.1", "192.168.0.0/16"}}})
+		assert.ElementsMatch(t, []string{"1", "4"}, ids)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		ids := search(t, map[string]interface{}{"range": map[string]interface{}{"ip": map[string]interface{}{"gt": "10.0.0.1", "lte": "10.0.1.5"}}})
+		assert.ElementsMatch(t, []string{"2", "3"}, ids)
+		ids = search(t, map[string]interface{}{"range": map[string]interface{}{"ip": map[string]interface{}{"lt": "10.0.1.0"}}})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+		ids = search(t, map[string]interface{}{"range": map[string]interface{}{"ip": map[string]interface{}{"gte": "10.0.1.0/24"}}})
+		assert.ElementsMatch(t, []string{"3", "4", "5"}, ids)
+	})
+
+	t.Run("query_string", func(t *testing.T) {
+		ids := search(t, map[string]interface{}{"query_string": map[string]interface{}{"query": `ip:"10.0.0.0/24" OR ip:192.168.1.1`}})
+		assert.ElementsMatch(t, []string{"1", "2", "4"}, ids)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:  []interface{}{map[string]interface{}{"ip": "desc"}},
+			Size:  2,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 2)
+		assert.Equal(t, "5", resp.Hits.Hits[0].ID)
+		assert.Equal(t, []interface{}{"2001:db8::1"}, resp.Hits.Hits[0].Sort)
+		assert.Equal(t, []interface{}{"192.168.1.1"}, resp.Hits.Hits[1].Sort)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -28,6 +28,7 @@ const (
 	ErrorTypeRuntimeException         = "runtime_exception"
 	ErrorTypeNotImplemented           = "not_implemented"
 	ErrorTypeInvalidArgument          = "invalid_argument"
+	ErrorTypeIndexNotFoundException   = "index_not_found_exception"
 )
 
 var ErrorIDNotFound = errors.New("id not found")
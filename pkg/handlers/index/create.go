@@ -96,7 +96,9 @@ func CreateES(c *gin.Context) {
 }
 
 func CreateIndexWorker(newIndex *meta.IndexSimple, indexName string) error {
-	newIndex.StorageType = "disk"
+	if newIndex.StorageType == "" {
+		newIndex.StorageType = "disk"
+	}
 	if newIndex.Name == "" && indexName != "" {
 		newIndex.Name = indexName
 	}
@@ -112,6 +114,11 @@ func CreateIndexWorker(newIndex *meta.IndexSimple, indexName string) error {
 	if newIndex.Settings == nil {
 		newIndex.Settings = new(meta.IndexSettings)
 	}
+	if newIndex.Settings.Retention != "" {
+		if _, err := zutils.ParseDuration(newIndex.Settings.Retention); err != nil {
+			return errors.New("settings.retention [" + newIndex.Settings.Retention + "] is invalid")
+		}
+	}
 	analyzers, err := zincanalysis.RequestAnalyzer(newIndex.Settings.Analysis)
 	if err != nil {
 		return errors.New(err.Error())
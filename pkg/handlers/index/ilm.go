@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// @Id ListILMPolicies
+// @Summary List index lifecycle policies
+// @security BasicAuth
+// @Tags    Index
+// @Produce json
+// @Success 200 {object} []meta.ILMPolicy
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy [get]
+func ListILMPolicy(c *gin.Context) {
+	policies, err := core.ListILMPolicies()
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, policies)
+}
+
+// @Id GetILMPolicy
+// @Summary Get index lifecycle policy
+// @security BasicAuth
+// @Tags    Index
+// @Produce json
+// @Param   name path  string  true  "Policy"
+// @Success 200 {object} meta.ILMPolicy
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{name} [get]
+func GetILMPolicy(c *gin.Context) {
+	name := c.Param("target")
+	if name == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "policy.name should be not empty"})
+		return
+	}
+	policy, exists, err := core.LoadILMPolicy(name)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		zutils.GinRenderJSON(c, http.StatusNotFound, meta.HTTPResponseError{Error: "policy " + name + " does not exists"})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, policy)
+}
+
+// @Id CreateILMPolicy
+// @Summary Create update index lifecycle policy
+// @security BasicAuth
+// @Tags    Index
+// @Accept  json
+// @Produce json
+// @Param   name   path string         true  "Policy"
+// @Param   policy body meta.ILMPolicy true  "Policy data"
+// @Success 200 {object} meta.HTTPResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{name} [put]
+func CreateILMPolicy(c *gin.Context) {
+	var policy meta.ILMPolicy
+	if err := zutils.GinBindJSON(c, &policy); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	name := c.Param("target")
+	if name == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "policy.name should be not empty"})
+		return
+	}
+
+	if err := core.NewILMPolicy(name, policy.Policy); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponse{Message: "ok"})
+}
+
+// @Id DeleteILMPolicy
+// @Summary Delete index lifecycle policy
+// @security BasicAuth
+// @Tags    Index
+// @Produce json
+// @Param   name  path  string  true  "Policy"
+// @Success 200 {object} meta.HTTPResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{name} [delete]
+func DeleteILMPolicy(c *gin.Context) {
+	name := c.Param("target")
+	if err := core.DeleteILMPolicy(name); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponse{Message: "ok"})
+}
@@ -0,0 +1,191 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/test/utils"
+)
+
+func TestILMPolicy(t *testing.T) {
+	t.Run("create policy", func(t *testing.T) {
+		type args struct {
+			code    int
+			data    map[string]interface{}
+			rawData string
+			target  string
+			result  string
+		}
+		tests := []struct {
+			name    string
+			args    args
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				args: args{
+					code: http.StatusOK,
+					data: map[string]interface{}{
+						"policy": map[string]interface{}{
+							"phases": map[string]interface{}{
+								"hot": map[string]interface{}{
+									"actions": map[string]interface{}{
+										"rollover": map[string]interface{}{"max_size": "50gb", "max_age": "1d"},
+									},
+								},
+								"warm": map[string]interface{}{
+									"min_age": "7d",
+									"actions": map[string]interface{}{"freeze": map[string]interface{}{}},
+								},
+								"delete": map[string]interface{}{
+									"min_age": "30d",
+									"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+								},
+							},
+						},
+					},
+					target: "TestILMPolicy.policy_1",
+					result: `{"message":"ok"`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "empty",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"policy":{"phases":{}}}`,
+					target:  "",
+					result:  `should be not empty`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "with err json",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"x":x}`,
+					target:  "TestILMPolicy.policy_2",
+					result:  `"error":`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "with err action",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"policy":{"phases":{"hot":{"actions":{"delete":{}}}}}}`,
+					target:  "TestILMPolicy.policy_2",
+					result:  `action [delete] is not allowed in phase [hot]`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "with err rollover",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_size":"xx"}}}}}}`,
+					target:  "TestILMPolicy.policy_2",
+					result:  `max_size [xx] is invalid`,
+				},
+				wantErr: false,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				if tt.args.data != nil {
+					utils.SetGinRequestData(c, tt.args.data)
+				}
+				if tt.args.rawData != "" {
+					utils.SetGinRequestData(c, tt.args.rawData)
+				}
+				utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+				CreateILMPolicy(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("get policy", func(t *testing.T) {
+		type args struct {
+			code   int
+			target string
+			result string
+		}
+		tests := []struct {
+			name    string
+			args    args
+			wantErr bool
+		}{
+			{
+				name: "normal",
+				args: args{
+					code:   http.StatusOK,
+					target: "TestILMPolicy.policy_1",
+					result: `"max_size":"50gb"`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "empty",
+				args: args{
+					code:   http.StatusBadRequest,
+					target: "",
+					result: `should be not empty`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "not exists",
+				args: args{
+					code:   http.StatusNotFound,
+					target: "TestILMPolicy.policy_N",
+					result: `does not exists`,
+				},
+				wantErr: false,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+				GetILMPolicy(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("list policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		ListILMPolicy(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"TestILMPolicy.policy_1"`)
+	})
+
+	t.Run("delete policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestILMPolicy.policy_1"})
+		DeleteILMPolicy(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"message":"ok"`)
+	})
+}
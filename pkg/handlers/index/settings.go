@@ -83,6 +83,13 @@ func SetSettings(c *gin.Context) {
 		return
 	}
 
+	if settings.Retention != "" {
+		if _, err := zutils.ParseDuration(settings.Retention); err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "settings.retention [" + settings.Retention + "] is invalid"})
+			return
+		}
+	}
+
 	analyzers, err := zincanalysis.RequestAnalyzer(settings.Analysis)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
@@ -108,6 +115,9 @@ func SetSettings(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "can't update analyzer for existing index"})
 			return
 		}
+		if settings.Lifecycle != nil || settings.Retention != "" {
+			_ = index.SetSettings(&meta.IndexSettings{Lifecycle: settings.Lifecycle, Retention: settings.Retention})
+		}
 		// store index
 		if err := core.StoreIndex(index); err != nil {
 			c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
@@ -0,0 +1,81 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// OpenPointInTime pins the current state of indexes for searching
+//
+// @Id OpenPointInTime
+// @Summary Open a point in time for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Produce json
+// @Param   index       path   string  true  "Index"
+// @Param   keep_alive  query  string  true  "Keep alive, such as: 1m"
+// @Success 200 {object} meta.HTTPResponsePIT
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_pit [post]
+func OpenPointInTime(c *gin.Context) {
+	indexName := c.Param("target")
+	pit, err := core.OpenPointInTime(strings.Split(indexName, ","), c.Query("keep_alive"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponsePIT{ID: pit.GetID()})
+}
+
+// ClosePointInTime releases a point in time
+//
+// @Id ClosePointInTime
+// @Summary Close a point in time for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   pit  body  meta.PointInTime  true  "Point in time"
+// @Success 200 {object} meta.HTTPResponseClosePIT
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_pit [delete]
+func ClosePointInTime(c *gin.Context) {
+	pit := new(meta.PointInTime)
+	if err := zutils.GinBindJSON(c, pit); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if pit.ID == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[pit] id is required"})
+		return
+	}
+
+	if !core.ClosePointInTime(pit.ID) {
+		zutils.GinRenderJSON(c, http.StatusNotFound, meta.HTTPResponseClosePIT{Succeeded: true, NumFreed: 0})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseClosePIT{Succeeded: true, NumFreed: 1})
+}
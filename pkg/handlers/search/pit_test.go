@@ -0,0 +1,91 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+	"github.com/zincsearch/zincsearch/test/utils"
+)
+
+func TestPointInTime(t *testing.T) {
+	indexName := "TestPointInTime.index_1"
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+	})
+
+	var pitID string
+	t.Run("open without keep_alive", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		OpenPointInTime(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "keep_alive")
+	})
+
+	t.Run("open", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_pit", map[string]string{"keep_alive": "1m"})
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		OpenPointInTime(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.HTTPResponsePIT)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ID)
+		pitID = resp.ID
+	})
+
+	t.Run("search", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"size":10,"pit":{"id":"`+pitID+`","keep_alive":"1m"}}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"pit_id":"`+pitID+`"`)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"id":"`+pitID+`"}`)
+		ClosePointInTime(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"num_freed":1`)
+	})
+
+	t.Run("search closed", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"size":10,"pit":{"id":"`+pitID+`"}}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not exists")
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
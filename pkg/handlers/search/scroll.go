@@ -0,0 +1,133 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// Scroll returns the next page of a scroll search
+//
+// @Id Scroll
+// @Summary Scroll search for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  meta.ScrollRequest  true  "Scroll"
+// @Success 200 {object} meta.SearchResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [post]
+func Scroll(c *gin.Context) {
+	req := &meta.ScrollRequest{
+		Scroll:   c.Query("scroll"),
+		ScrollID: c.Query("scroll_id"),
+	}
+	if id := c.Param("scroll_id"); id != "" {
+		req.ScrollID = id
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			log.Printf("handlers.search.Scroll: %s", err.Error())
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+	if req.ScrollID == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll_id] is required"})
+		return
+	}
+
+	scroll, ok := core.GetScroll(req.ScrollID)
+	if !ok {
+		zutils.GinRenderJSON(c, http.StatusNotFound, meta.HTTPResponseError{Error: "No search context found for id [" + req.ScrollID + "]"})
+		return
+	}
+	resp, err := scroll.Next(req.Scroll)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// ClearScroll releases scroll contexts
+//
+// @Id ClearScroll
+// @Summary Clear scroll for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  meta.ClearScrollRequest  true  "Scroll IDs"
+// @Success 200 {object} meta.HTTPResponseClearScroll
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [delete]
+func ClearScroll(c *gin.Context) {
+	ids := make([]string, 0)
+	if id := c.Param("scroll_id"); id != "" {
+		if id == "_all" {
+			zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseClearScroll{Succeeded: true, NumFreed: core.CloseAllScrolls()})
+			return
+		}
+		ids = append(ids, strings.Split(id, ",")...)
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		req := new(meta.ClearScrollRequest)
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			log.Printf("handlers.search.ClearScroll: %s", err.Error())
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+		switch v := req.ScrollID.(type) {
+		case string:
+			ids = append(ids, v)
+		case []interface{}:
+			for _, id := range v {
+				if id, ok := id.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll_id] is required"})
+		return
+	}
+
+	freed := 0
+	for _, id := range ids {
+		if core.CloseScroll(id) {
+			freed++
+		}
+	}
+	code := http.StatusOK
+	if freed == 0 {
+		code = http.StatusNotFound
+	}
+	zutils.GinRenderJSON(c, code, meta.HTTPResponseClearScroll{Succeeded: true, NumFreed: freed})
+}
@@ -0,0 +1,91 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+	"github.com/zincsearch/zincsearch/test/utils"
+)
+
+func TestScroll(t *testing.T) {
+	indexName := "TestScroll.index_1"
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+	})
+
+	var scrollID string
+	t.Run("search with scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_search", map[string]string{"scroll": "1m"})
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"size":10}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.SearchResponse)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ScrollID)
+		scrollID = resp.ScrollID
+	})
+
+	t.Run("scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll":"1m","scroll_id":"`+scrollID+`"}`)
+		Scroll(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"_scroll_id":"`+scrollID+`"`)
+	})
+
+	t.Run("scroll without id", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll":"1m"}`)
+		Scroll(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll_id":["`+scrollID+`"]}`)
+		ClearScroll(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"num_freed":1`)
+	})
+
+	t.Run("scroll not found", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"scroll_id": scrollID})
+		Scroll(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "No search context found")
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
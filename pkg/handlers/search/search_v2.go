@@ -19,10 +19,13 @@ import (
 	"bufio"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/core"
@@ -40,8 +43,9 @@ import (
 // @Tags    Search
 // @Accept  json
 // @Produce json
-// @Param   index  path  string  true  "Index"
-// @Param   query  body  meta.ZincQueryForSDK true  "Query"
+// @Param   index   path   string  true   "Index"
+// @Param   scroll  query  string  false  "Keep alive of scroll context, such as: 1m"
+// @Param   query   body   meta.ZincQueryForSDK true  "Query"
 // @Success 200 {object} meta.SearchResponse
 // @Failure 400 {object} meta.HTTPResponseError
 // @Router /es/{index}/_search [post]
@@ -55,7 +59,13 @@ func SearchDSL(c *gin.Context) {
 		return
 	}
 
-	resp, err := searchIndex(strings.Split(indexName, ","), query)
+	var resp *meta.SearchResponse
+	var err error
+	if keepAlive := c.Query("scroll"); keepAlive != "" {
+		resp, err = core.OpenScroll(strings.Split(indexName, ","), query, keepAlive)
+	} else {
+		resp, err = searchIndex(strings.Split(indexName, ","), query)
+	}
 	if err != nil {
 		errors.HandleError(c, err)
 		return
@@ -79,7 +89,7 @@ func SearchDSL(c *gin.Context) {
 	zutils.GinRenderJSON(c, http.StatusOK, resp)
 }
 
-// MultipleSearch like bulk searches
+// MultipleSearch like bulk searches, the searches are executed concurrently
 //
 // @Id MSearch
 // @Summary Search V2 MultipleSearch for compatible ES
@@ -87,18 +97,28 @@ func SearchDSL(c *gin.Context) {
 // @Tags    Search
 // @Accept  plain
 // @Produce json
-// @Param   query  body  string  true  "Query"
+// @Param   max_concurrent_searches  query  int     false  "Maximum number of concurrent searches"
+// @Param   query                    body   string  true   "Query"
 // @Success 200 {object} meta.SearchResponse
 // @Failure 400 {object} meta.HTTPResponseError
 // @Router /es/_msearch [post]
 func MultipleSearch(c *gin.Context) {
+	startTime := time.Now()
 	indexName := c.Param("target")
 	defaultIndexNames := make([]string, 0)
 	if indexName != "" {
 		defaultIndexNames = strings.Split(indexName, ",")
 	}
 
-	responses := make([]interface{}, 0)
+	maxConcurrentSearches := config.Global.Shard.GoroutineNum
+	if v := c.Query("max_concurrent_searches"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "max_concurrent_searches must be a positive integer"})
+			return
+		}
+		maxConcurrentSearches = n
+	}
 
 	// Prepare to read the entire raw text of the body
 	scanner := bufio.NewScanner(c.Request.Body)
@@ -108,54 +128,116 @@ func MultipleSearch(c *gin.Context) {
 	buf := make([]byte, maxCapacityPerLine)
 	scanner.Buffer(buf, maxCapacityPerLine)
 
-	indexNames := make([]string, 0)
-	nextLineIsData := false
-
-	var doc map[string]interface{}
-	var err error
+	requests := make([]*multiSearchRequest, 0)
+	var req *multiSearchRequest
 	for scanner.Scan() { // Read each line
-		if nextLineIsData {
-			nextLineIsData = false
-			query := &meta.ZincQuery{Size: 10}
-			if err = json.Unmarshal(scanner.Bytes(), &query); err != nil {
-				log.Error().Msgf("handlers.search.MultipleSearch.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
-				responses = append(responses, &meta.SearchResponse{Error: err.Error()})
-				continue
+		if req != nil {
+			if req.err == nil {
+				req.query = &meta.ZincQuery{Size: 10}
+				if err := json.Unmarshal(scanner.Bytes(), req.query); err != nil {
+					log.Error().Msgf("handlers.search.MultipleSearch.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
+					req.err = errors.New(errors.ErrorTypeParsingException, err.Error())
+				}
 			}
-			// search query
-			resp, err := searchIndex(indexNames, query)
+			requests = append(requests, req)
+			req = nil
+			continue
+		}
+
+		req = &multiSearchRequest{indexNames: defaultIndexNames}
+		header := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+			log.Error().Msgf("handlers.search.MultipleSearch.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
+			req.err = errors.New(errors.ErrorTypeParsingException, err.Error())
+			continue
+		}
+		// preference and the other options of the header are accepted but ignored
+		if v, ok := header["index"]; ok {
+			indexNames := make([]string, 0)
+			switch v := v.(type) {
+			case string:
+				indexNames = append(indexNames, strings.Split(v, ",")...)
+			case []interface{}:
+				for _, v := range v {
+					name, _ := v.(string)
+					indexNames = append(indexNames, name)
+				}
+			}
+			req.indexNames = indexNames
+		}
+	}
+
+	responses := make([]interface{}, len(requests))
+	eg := &errgroup.Group{}
+	eg.SetLimit(maxConcurrentSearches)
+	for i, req := range requests {
+		i, req := i, req
+		if req.err != nil {
+			responses[i] = multiSearchErrorResponse(req.err)
+			continue
+		}
+		eg.Go(func() error {
+			resp, err := searchIndex(req.indexNames, req.query)
 			if err != nil {
 				log.Error().Msgf("handlers.search.MultipleSearch.searchIndex: err %s", err.Error())
-				responses = append(responses, &meta.SearchResponse{Error: err.Error()})
-			} else {
-				responses = append(responses, resp)
+				responses[i] = multiSearchErrorResponse(err)
+				return nil
 			}
+			resp.Status = http.StatusOK
+			responses[i] = resp
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	zutils.GinRenderJSON(c, http.StatusOK, gin.H{
+		"took":      time.Since(startTime).Milliseconds(),
+		"responses": responses,
+	})
+}
+
+type multiSearchRequest struct {
+	indexNames []string
+	query      *meta.ZincQuery
+	err        error
+}
+
+// multiSearchErrorResponse returns the response of a failed search in the same format as ES
+func multiSearchErrorResponse(err error) gin.H {
+	status := http.StatusBadRequest
+	e, ok := err.(*errors.Error)
+	if !ok {
+		var notFound indexNotFoundError
+		if errors.As(err, &notFound) {
+			e = errors.New(errors.ErrorTypeIndexNotFoundException, err.Error())
+			status = http.StatusNotFound
 		} else {
-			nextLineIsData = true
-			indexNames = indexNames[:0]
-			if err = json.Unmarshal(scanner.Bytes(), &doc); err != nil {
-				log.Error().Msgf("handlers.search.MultipleSearch.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
-				continue
-			}
-			if v, ok := doc["index"]; ok {
-				switch v := v.(type) {
-				case string:
-					indexNames = append(indexNames, v)
-				case []interface{}:
-					for _, v := range v {
-						indexNames = append(indexNames, v.(string))
-					}
-				}
-			} else {
-				indexNames = append(indexNames, defaultIndexNames...)
-			}
+			e = errors.New(errors.ErrorTypeIllegalArgumentException, err.Error())
 		}
 	}
+	cause := gin.H{"type": e.Type, "reason": e.Reason}
+	resp := gin.H{"root_cause": []gin.H{cause}, "type": e.Type, "reason": e.Reason}
+	if e.CausedBy != nil {
+		resp["caused_by"] = gin.H{"type": e.Type, "reason": e.CausedBy.Error()}
+	}
+	return gin.H{"error": resp, "status": status}
+}
+
+type indexNotFoundError string
 
-	zutils.GinRenderJSON(c, http.StatusOK, gin.H{"responses": responses})
+func (e indexNotFoundError) Error() string {
+	return fmt.Sprintf("index %s does not exists", string(e))
 }
 
 func searchIndex(indexNames []string, query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	if query.PIT != nil {
+		pit, exists := core.GetPointInTime(query.PIT.ID)
+		if !exists {
+			return nil, fmt.Errorf("point in time %s does not exists or expired", query.PIT.ID)
+		}
+		return pit.Search(query)
+	}
+
 	indexName := ""
 	if len(indexNames) > 0 {
 		indexName = indexNames[0]
@@ -167,7 +249,7 @@ func searchIndex(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 	} else {
 		index, exists := core.GetIndex(indexName)
 		if !exists {
-			return nil, fmt.Errorf("index %s does not exists", indexName)
+			return nil, indexNotFoundError(indexName)
 		}
 		resp, err = index.Search(query)
 	}
@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 	"github.com/zincsearch/zincsearch/test/utils"
 )
 
@@ -160,6 +161,57 @@ func TestMultipleSearch(t *testing.T) {
 		})
 	}
 
+	t.Run("responses", func(t *testing.T) {
+		data := `{"index":"` + indexName + `","preference":"dashboard"}
+{"query":{"match_all":{}},"size":10}
+{"index":"TestMultipleSearch.notExists"}
+{"query":{"match_all":{}},"size":10}
+{"index":"` + indexName + `"}
+{"query":{"unknown":{}},"size":10}
+{"index":"` + indexName + `"}
+{"query":
+{"index":"` + indexName + `"}
+{"query":{"match_all":{}},"size":1}`
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, data)
+		utils.SetGinRequestURL(c, "/es/_msearch", map[string]string{"max_concurrent_searches": "2"})
+		MultipleSearch(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp := struct {
+			Responses []struct {
+				Status int `json:"status"`
+				Error  *struct {
+					RootCause []map[string]interface{} `json:"root_cause"`
+					Type      string                   `json:"type"`
+					Reason    string                   `json:"reason"`
+				} `json:"error"`
+			} `json:"responses"`
+		}{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Responses, 5)
+		assert.Equal(t, http.StatusOK, resp.Responses[0].Status)
+		assert.Nil(t, resp.Responses[0].Error)
+		assert.Equal(t, http.StatusNotFound, resp.Responses[1].Status)
+		assert.Equal(t, "index_not_found_exception", resp.Responses[1].Error.Type)
+		assert.Len(t, resp.Responses[1].Error.RootCause, 1)
+		assert.Equal(t, http.StatusBadRequest, resp.Responses[2].Status)
+		assert.NotEmpty(t, resp.Responses[2].Error.Reason)
+		assert.Equal(t, http.StatusBadRequest, resp.Responses[3].Status)
+		assert.Equal(t, "parsing_exception", resp.Responses[3].Error.Type)
+		assert.Equal(t, http.StatusOK, resp.Responses[4].Status)
+	})
+
+	t.Run("max_concurrent_searches", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"index":"`+indexName+`"}
+{"query":{"match_all":{}},"size":10}`)
+		utils.SetGinRequestURL(c, "/es/_msearch", map[string]string{"max_concurrent_searches": "0"})
+		MultipleSearch(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("cleanup", func(t *testing.T) {
 		err := core.DeleteIndex(indexName)
 		assert.NoError(t, err)
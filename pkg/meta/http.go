@@ -80,3 +80,17 @@ type HTTPResponseDeleteByQuery struct {
 	RequestsPerSecond    int                 `json:"requests_per_second"`
 	ThrottledUntilMillis int                 `json:"throttled_until_millis"`
 }
+
+type HTTPResponsePIT struct {
+	ID string `json:"id"`
+}
+
+type HTTPResponseClosePIT struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
+
+type HTTPResponseClearScroll struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
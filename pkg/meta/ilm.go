@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+// ILMPolicy is an index lifecycle management policy,
+// indexes use it by the setting `lifecycle.name`.
+type ILMPolicy struct {
+	Name      string         `json:"name"`
+	Policy    *ILMPolicyBody `json:"policy"`
+	Version   int64          `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type ILMPolicyBody struct {
+	Phases ILMPhases `json:"phases"`
+}
+
+type ILMPhases struct {
+	Hot    *ILMPhase `json:"hot,omitempty"`
+	Warm   *ILMPhase `json:"warm,omitempty"`
+	Delete *ILMPhase `json:"delete,omitempty"`
+}
+
+type ILMPhase struct {
+	MinAge  string     `json:"min_age,omitempty"` // 7d, 12h, counted from the index rolled over, or created if never rolled over
+	Actions ILMActions `json:"actions"`
+}
+
+type ILMActions struct {
+	Rollover *ILMRolloverAction `json:"rollover,omitempty"`
+	Freeze   *ILMFreezeAction   `json:"freeze,omitempty"`
+	Delete   *ILMDeleteAction   `json:"delete,omitempty"`
+}
+
+// ILMRolloverAction rolls over the alias to a new index when any of the conditions is reached
+type ILMRolloverAction struct {
+	MaxDocs int64  `json:"max_docs,omitempty"`
+	MaxSize string `json:"max_size,omitempty"` // 50gb
+	MaxAge  string `json:"max_age,omitempty"`  // 1d
+}
+
+type ILMFreezeAction struct{}
+
+type ILMDeleteAction struct{}
+
+// IndexLifecycle binds the index to an ILM policy
+type IndexLifecycle struct {
+	Name          string     `json:"name"`
+	RolloverAlias string     `json:"rollover_alias,omitempty"`
+	Phase         string     `json:"phase,omitempty"`         // the phase entered, updated by ILM
+	RolloverDate  *time.Time `json:"rollover_date,omitempty"` // the time the index rolled over, updated by ILM
+}
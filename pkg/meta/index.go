@@ -15,6 +15,8 @@
 
 package meta
 
+import "time"
+
 type Index struct {
 	ShardNum    int64                  `json:"shard_num"`
 	Name        string                 `json:"name"`
@@ -24,6 +26,7 @@ type Index struct {
 	Shards      map[string]*IndexShard `json:"shards"`
 	Stats       IndexStat              `json:"stats"`
 	Version     string                 `json:"version"`
+	CreatedAt   time.Time              `json:"created_at"`
 }
 
 type IndexShard struct {
@@ -35,8 +38,9 @@ type IndexShard struct {
 }
 
 type IndexSecondShard struct {
-	ID    int64     `json:"id"`
-	Stats IndexStat `json:"stats"`
+	ID     int64     `json:"id"`
+	Frozen bool      `json:"frozen,omitempty"` // read-only, no more documents will be written
+	Stats  IndexStat `json:"stats"`
 }
 
 type IndexStat struct {
@@ -44,6 +48,7 @@ type IndexStat struct {
 	DocTimeMax  int64  `json:"doc_time_max"`
 	DocNum      uint64 `json:"doc_num"`
 	StorageSize uint64 `json:"storage_size"`
+	MemorySize  uint64 `json:"memory_size,omitempty"` // used by memory index instead of StorageSize
 	WALSize     uint64 `json:"wal_size"`
 }
 
@@ -56,9 +61,11 @@ type IndexSimple struct {
 }
 
 type IndexSettings struct {
-	NumberOfShards   int64          `json:"number_of_shards,omitempty"`
-	NumberOfReplicas int64          `json:"number_of_replicas,omitempty"`
-	Analysis         *IndexAnalysis `json:"analysis,omitempty"`
+	NumberOfShards   int64           `json:"number_of_shards,omitempty"`
+	NumberOfReplicas int64           `json:"number_of_replicas,omitempty"`
+	Analysis         *IndexAnalysis  `json:"analysis,omitempty"`
+	Lifecycle        *IndexLifecycle `json:"lifecycle,omitempty"`
+	Retention        string          `json:"retention,omitempty"` // 30d, drops the second layer shards older than it, 0 turns it off
 }
 
 type IndexAnalysis struct {
@@ -17,6 +17,7 @@ package meta
 
 import (
 	"bytes"
+	"sort"
 	"sync"
 
 	"github.com/zincsearch/zincsearch/pkg/zutils/json"
@@ -28,7 +29,7 @@ type Mappings struct {
 }
 
 type Property struct {
-	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point
+	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point, ip, nested
 	Analyzer       string `json:"analyzer,omitempty"`
 	SearchAnalyzer string `json:"search_analyzer,omitempty"`
 	Format         string `json:"format,omitempty"`    // date format yyyy-MM-dd HH:mm:ss || yyyy-MM-dd || epoch_millis
@@ -67,7 +68,13 @@ func NewProperty(typ string) Property {
 		Highlightable:  false,
 		Fields:         make(map[string]Property),
 	}
-	if typ == "text" {
+	switch typ {
+	case "text":
+		p.Sortable = false
+		p.Aggregatable = false
+	case "nested":
+		// the nested objects are indexed as hidden documents
+		p.Index = false
 		p.Sortable = false
 		p.Aggregatable = false
 	}
@@ -139,6 +146,29 @@ func (t *Mappings) ListProperty() map[string]Property {
 	return m
 }
 
+// ListNestedPath returns the paths of the nested properties in order.
+func (t *Mappings) ListNestedPath() []string {
+	return t.listPathByType("nested")
+}
+
+// ListGeoPointPath returns the paths of the geo_point properties in order.
+func (t *Mappings) ListGeoPointPath() []string {
+	return t.listPathByType("geo_point")
+}
+
+func (t *Mappings) listPathByType(typ string) []string {
+	paths := make([]string, 0)
+	t.lock.RLock()
+	for k, v := range t.Properties {
+		if v.Type == typ {
+			paths = append(paths, k)
+		}
+	}
+	t.lock.RUnlock()
+	sort.Strings(paths)
+	return paths
+}
+
 // DeepClone returns a full copy of the mapping.
 func (t *Mappings) DeepClone() *Mappings {
 	m := NewMappings()
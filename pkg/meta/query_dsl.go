@@ -30,6 +30,8 @@ type ZincQuery struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	SearchAfter    []interface{}           `json:"search_after"` // sort values of the last hit from previous page
+	PIT            *PointInTime            `json:"pit"`
 }
 
 type ZincQueryForSDK struct {
@@ -44,11 +46,37 @@ type ZincQueryForSDK struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	SearchAfter    []interface{}           `json:"search_after"` // sort values of the last hit from previous page
+	PIT            *PointInTime            `json:"pit"`
+}
+
+// PointInTime search on a pinned set of index readers
+// {"id": "46ToAwMDaWR5BXV1aWQy", "keep_alive": "1m"}
+type PointInTime struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+// ScrollRequest continues a scroll search
+// {"scroll": "1m", "scroll_id": "46ToAwMDaWR5BXV1aWQy"}
+type ScrollRequest struct {
+	Scroll   string `json:"scroll"`
+	ScrollID string `json:"scroll_id"`
+}
+
+// ClearScrollRequest releases scroll contexts
+// {"scroll_id": "46ToAwMDaWR5BXV1aWQy"}
+// {"scroll_id": ["46ToAwMDaWR5BXV1aWQy", "46ToAwMDaWR5BXV1aWQz"]}
+type ClearScrollRequest struct {
+	ScrollID interface{} `json:"scroll_id"`
 }
 
 type Query struct {
 	Bool              *BoolQuery                         `json:"bool,omitempty"`                // .
-	Boosting          *BoostingQuery                     `json:"boosting,omitempty"`            // TODO: not implemented
+	Boosting          *BoostingQuery                     `json:"boosting,omitempty"`            // .
+	FunctionScore     *FunctionScoreQuery                `json:"function_score,omitempty"`      // .
+	ScriptScore       *ScriptScoreQuery                  `json:"script_score,omitempty"`        // .
+	Nested            *NestedQuery                       `json:"nested,omitempty"`              // .
 	Match             map[string]*MatchQuery             `json:"match,omitempty"`               // simple, MatchQuery
 	MatchBoolPrefix   map[string]*MatchBoolPrefixQuery   `json:"match_bool_prefix,omitempty"`   // simple, MatchBoolPrefixQuery
 	MatchPhrase       map[string]*MatchPhraseQuery       `json:"match_phrase,omitempty"`        // simple, MatchPhraseQuery
@@ -68,10 +96,10 @@ type Query struct {
 	Wildcard          map[string]*WildcardQuery          `json:"wildcard,omitempty"`            // simple, WildcardQuery
 	Term              map[string]*TermQuery              `json:"term,omitempty"`                // simple, TermQuery
 	Terms             map[string]*TermsQuery             `json:"terms,omitempty"`               // .
-	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // TODO: not implemented
-	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // TODO: not implemented
-	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // TODO: not implemented
-	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // TODO: not implemented
+	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // .
+	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // .
+	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // .
+	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // .
 	GeoShape          interface{}                        `json:"geo_shape,omitempty"`           // TODO: not implemented
 }
 
@@ -119,6 +147,80 @@ type BoostingQuery struct {
 	NegativeBoost float64     `json:"negative_boost,omitempty"`
 }
 
+// FunctionScoreQuery
+// {"function_score": {"query": {"match_all": {}}, "functions": [{"filter": {"term": {"tag": "hot"}}, "weight": 2}, {"field_value_factor": {"field": "likes", "modifier": "log1p"}}], "score_mode": "sum", "boost_mode": "multiply"}}
+// {"function_score": {"query": {"match_all": {}}, "gauss": {"date": {"origin": "now", "scale": "10d"}}}}
+type FunctionScoreQuery struct {
+	Query     interface{}      `json:"query,omitempty"`
+	Functions []*ScoreFunction `json:"functions,omitempty"`
+	*ScoreFunction
+	ScoreMode string   `json:"score_mode,omitempty"` // multiply, sum, avg, first, max, min
+	BoostMode string   `json:"boost_mode,omitempty"` // multiply, replace, sum, avg, max, min
+	MaxBoost  float64  `json:"max_boost,omitempty"`
+	MinScore  *float64 `json:"min_score,omitempty"`
+	Boost     float64  `json:"boost,omitempty"`
+}
+
+type ScoreFunction struct {
+	Filter           interface{}               `json:"filter,omitempty"`
+	Weight           float64                   `json:"weight,omitempty"`
+	FieldValueFactor *FieldValueFactorFunction `json:"field_value_factor,omitempty"`
+	Gauss            map[string]interface{}    `json:"gauss,omitempty"`  // {"field": DecayFunction, "multi_value_mode": "min"}
+	Exp              map[string]interface{}    `json:"exp,omitempty"`    // {"field": DecayFunction, "multi_value_mode": "min"}
+	Linear           map[string]interface{}    `json:"linear,omitempty"` // {"field": DecayFunction, "multi_value_mode": "min"}
+	RandomScore      *RandomScoreFunction      `json:"random_score,omitempty"`
+	ScriptScore      *ScriptScoreFunction      `json:"script_score,omitempty"`
+}
+
+type FieldValueFactorFunction struct {
+	Field    string   `json:"field"`
+	Factor   float64  `json:"factor,omitempty"`
+	Modifier string   `json:"modifier,omitempty"` // none, log, log1p, log2p, ln, ln1p, ln2p, square, sqrt, reciprocal
+	Missing  *float64 `json:"missing,omitempty"`
+}
+
+type DecayFunction struct {
+	Origin interface{} `json:"origin,omitempty"` // number or date, default is now for date
+	Scale  interface{} `json:"scale"`            // number or duration
+	Offset interface{} `json:"offset,omitempty"` // number or duration
+	Decay  float64     `json:"decay,omitempty"`  // default is 0.5
+}
+
+type RandomScoreFunction struct {
+	Seed  interface{} `json:"seed,omitempty"`
+	Field string      `json:"field,omitempty"` // default is _id
+}
+
+type ScriptScoreFunction struct {
+	Script *Script `json:"script"`
+}
+
+// ScriptScoreQuery
+// {"script_score": {"query": {"match_all": {}}, "script": {"source": "_score * doc['likes'].value"}}}
+type ScriptScoreQuery struct {
+	Query    interface{} `json:"query"`
+	Script   *Script     `json:"script"`
+	MinScore *float64    `json:"min_score,omitempty"`
+	Boost    float64     `json:"boost,omitempty"`
+}
+
+// NestedQuery
+// {"nested": {"path": "comments", "query": {"match": {"comments.author": "alice"}}, "score_mode": "avg", "inner_hits": {"size": 3}}}
+type NestedQuery struct {
+	Path           string      `json:"path"`
+	Query          interface{} `json:"query"`
+	ScoreMode      string      `json:"score_mode,omitempty"` // avg, max, min, sum, none, default is avg
+	InnerHits      *InnerHits  `json:"inner_hits,omitempty"`
+	IgnoreUnmapped bool        `json:"ignore_unmapped,omitempty"`
+	Boost          float64     `json:"boost,omitempty"`
+}
+
+type InnerHits struct {
+	Name string `json:"name,omitempty"` // default is the path of nested query
+	From int    `json:"from,omitempty"`
+	Size int    `json:"size,omitempty"` // default is 3
+}
+
 type MatchAllQuery struct{}
 
 type MatchNoneQuery struct{}
@@ -169,12 +271,16 @@ type CombinedFieldsQuery struct {
 }
 
 type QueryStringQuery struct {
-	Query           string   `json:"query,omitempty"`
-	Analyzer        string   `json:"analyzer,omitempty"`
-	Fields          []string `json:"fields,omitempty"`
-	DefaultField    string   `json:"default_field,omitempty"`
-	DefaultOperator string   `json:"default_operator,omitempty"` // or(default), and
-	Boost           float64  `json:"boost,omitempty"`
+	Query                string   `json:"query,omitempty"`
+	Analyzer             string   `json:"analyzer,omitempty"`
+	Fields               []string `json:"fields,omitempty"` // field^boost, field name supports wildcard *
+	DefaultField         string   `json:"default_field,omitempty"`
+	DefaultOperator      string   `json:"default_operator,omitempty"` // or(default), and
+	AnalyzeWildcard      bool     `json:"analyze_wildcard,omitempty"`
+	Lenient              bool     `json:"lenient,omitempty"`
+	AllowLeadingWildcard *bool    `json:"allow_leading_wildcard,omitempty"` // true(default), false
+	TimeZone             string   `json:"time_zone,omitempty"`
+	Boost                float64  `json:"boost,omitempty"`
 }
 
 type SimpleQueryStringQuery struct {
@@ -273,8 +379,23 @@ type TermQueryForSDK struct {
 // {"terms": {"field": ["value1", "value2"], "boost": 1.0}}
 type TermsQuery map[string]interface{}
 
-// TermsSetQuery ...
-type TermsSetQuery struct{}
+// TermsSetQuery
+// {"terms_set": {"field": {"terms": ["value1", "value2"], "minimum_should_match_field": "required_matches"}}}
+// {"terms_set": {"field": {"terms": ["value1", "value2"], "minimum_should_match_script": {"source": "Math.min(params.num_terms, doc['required_matches'].value)"}}}}
+type TermsSetQuery struct {
+	Terms                    []interface{} `json:"terms"`
+	MinimumShouldMatchField  string        `json:"minimum_should_match_field,omitempty"`
+	MinimumShouldMatchScript *Script       `json:"minimum_should_match_script,omitempty"`
+	Boost                    float64       `json:"boost,omitempty"`
+}
+
+// Script
+// {"source": "doc['field'].value * params.factor", "params": {"factor": 2}}
+type Script struct {
+	Source string                 `json:"source"`
+	Lang   string                 `json:"lang,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
 
 type Aggregations struct {
 	Avg               *AggregationMetric            `json:"avg"`
@@ -283,15 +404,45 @@ type Aggregations struct {
 	Min               *AggregationMetric            `json:"min"`
 	Sum               *AggregationMetric            `json:"sum"`
 	Count             *AggregationMetric            `json:"count"`
-	Cardinality       *AggregationMetric            `json:"cardinality"`
+	Cardinality       *AggregationCardinality       `json:"cardinality"`
+	ValueCount        *AggregationMetric            `json:"value_count"`
+	Stats             *AggregationMetric            `json:"stats"`
+	ExtendedStats     *AggregationExtendedStats     `json:"extended_stats"`
+	Percentiles       *AggregationPercentiles       `json:"percentiles"`
+	PercentileRanks   *AggregationPercentileRanks   `json:"percentile_ranks"`
+	TopHits           *AggregationTopHits           `json:"top_hits"`
 	Terms             *AggregationsTerms            `json:"terms"`
+	MultiTerms        *AggregationMultiTerms        `json:"multi_terms"`
+	SignificantTerms  *AggregationSignificantTerms  `json:"significant_terms"`
+	RareTerms         *AggregationRareTerms         `json:"rare_terms"`
 	Range             *AggregationRange             `json:"range"`
 	DateRange         *AggregationDateRange         `json:"date_range"`
 	Histogram         *AggregationHistogram         `json:"histogram"`
 	DateHistogram     *AggregationDateHistogram     `json:"date_histogram"`
 	AutoDateHistogram *AggregationAutoDateHistogram `json:"auto_date_histogram"`
-	IPRange           *AggregationIPRange           `json:"ip_range"` // TODO: not implemented
-	Aggregations      map[string]Aggregations       `json:"aggs"`     // nested aggregations
+	Composite         *AggregationComposite         `json:"composite"`
+	IPRange           *AggregationIPRange           `json:"ip_range"`
+	GeoHashGrid       *AggregationGeoGrid           `json:"geohash_grid"`
+	GeoTileGrid       *AggregationGeoGrid           `json:"geotile_grid"`
+	GeoBounds         *AggregationGeoBounds         `json:"geo_bounds"`
+	GeoCentroid       *AggregationMetric            `json:"geo_centroid"`
+	GeoDistance       *AggregationGeoDistance       `json:"geo_distance"`
+	Nested            *AggregationNested            `json:"nested"`
+	Filter            interface{}                   `json:"filter"` // query
+	Filters           *AggregationFilters           `json:"filters"`
+	Missing           *AggregationMissing           `json:"missing"`
+	Global            *AggregationGlobal            `json:"global"`
+	Derivative        *AggregationPipeline          `json:"derivative"`      // pipeline, parent
+	CumulativeSum     *AggregationPipeline          `json:"cumulative_sum"`  // pipeline, parent
+	MovingAvg         *AggregationMovingAvg         `json:"moving_avg"`      // pipeline, parent
+	MovingFn          *AggregationMovingFn          `json:"moving_fn"`       // pipeline, parent
+	BucketScript      *AggregationBucketScript      `json:"bucket_script"`   // pipeline, parent
+	BucketSelector    *AggregationBucketScript      `json:"bucket_selector"` // pipeline, parent
+	BucketSort        *AggregationBucketSort        `json:"bucket_sort"`     // pipeline, parent
+	AvgBucket         *AggregationPipeline          `json:"avg_bucket"`      // pipeline, sibling
+	MaxBucket         *AggregationPipeline          `json:"max_bucket"`      // pipeline, sibling
+	SumBucket         *AggregationPipeline          `json:"sum_bucket"`      // pipeline, sibling
+	Aggregations      map[string]Aggregations       `json:"aggs"`            // nested aggregations
 }
 
 type AggregationMetric struct {
@@ -299,21 +450,122 @@ type AggregationMetric struct {
 	WeightField string `json:"weight_field"` // Field name to be used for setting weight for primary field for weighted average aggregation
 }
 
+type AggregationCardinality struct {
+	Field              string `json:"field"`
+	PrecisionThreshold *int   `json:"precision_threshold"` // default 3000, at most 40000
+}
+
+type AggregationExtendedStats struct {
+	Field string  `json:"field"`
+	Sigma float64 `json:"sigma"` // number of standard deviations of std_deviation_bounds, default 2
+}
+
+type AggregationPercentiles struct {
+	Field    string              `json:"field"`
+	Percents []float64           `json:"percents"` // default [1, 5, 25, 50, 75, 95, 99]
+	Keyed    *bool               `json:"keyed"`    // default true
+	TDigest  *AggregationTDigest `json:"tdigest"`
+}
+
+type AggregationPercentileRanks struct {
+	Field   string              `json:"field"`
+	Values  []float64           `json:"values"`
+	Keyed   *bool               `json:"keyed"` // default true
+	TDigest *AggregationTDigest `json:"tdigest"`
+}
+
+type AggregationTDigest struct {
+	Compression float64 `json:"compression"` // default 100
+}
+
+type AggregationTopHits struct {
+	Size   int         `json:"size"`    // default 3
+	Sort   interface{} `json:"sort"`    // same as the sort of query, default is _score desc
+	Source interface{} `json:"_source"` // true, false, ["field1", "field2.*"], {"includes": [], "excludes": []}
+}
+
 type AggregationsTerms struct {
-	Field string            `json:"field"`
-	Size  int               `json:"size"`
-	Order map[string]string `json:"order"` // { "_count": "asc" }
+	Field       string      `json:"field"`
+	Size        int         `json:"size"`
+	ShardSize   int         `json:"shard_size"`
+	MinDocCount *int        `json:"min_doc_count"` // default 1
+	Order       interface{} `json:"order"`         // { "_count": "asc" } or [{ "agg.metric": "desc" }, { "_key": "asc" }]
+	Include     interface{} `json:"include"`       // regexp or list of terms
+	Exclude     interface{} `json:"exclude"`       // regexp or list of terms
+	Missing     interface{} `json:"missing"`
+}
+
+type AggregationMultiTerms struct {
+	Terms       []AggregationMultiTermsField `json:"terms"`
+	Size        int                          `json:"size"`
+	ShardSize   int                          `json:"shard_size"`
+	MinDocCount *int                         `json:"min_doc_count"` // default 1
+	Order       interface{}                  `json:"order"`
+}
+
+type AggregationMultiTermsField struct {
+	Field   string      `json:"field"`
+	Missing interface{} `json:"missing"`
+}
+
+type AggregationSignificantTerms struct {
+	Field             string                            `json:"field"`
+	Size              int                               `json:"size"`          // default 10
+	MinDocCount       *int                              `json:"min_doc_count"` // default 3
+	Include           interface{}                       `json:"include"`
+	Exclude           interface{}                       `json:"exclude"`
+	JLH               *struct{}                         `json:"jlh"` // default significance heuristic
+	ChiSquare         *AggregationSignificanceHeuristic `json:"chi_square"`
+	MutualInformation *AggregationSignificanceHeuristic `json:"mutual_information"`
+}
+
+type AggregationSignificanceHeuristic struct {
+	IncludeNegatives     bool  `json:"include_negatives"`
+	BackgroundIsSuperset *bool `json:"background_is_superset"` // default true
+}
+
+type AggregationRareTerms struct {
+	Field       string      `json:"field"`
+	MaxDocCount int         `json:"max_doc_count"` // default 1, at most 100
+	Include     interface{} `json:"include"`
+	Exclude     interface{} `json:"exclude"`
+	Missing     interface{} `json:"missing"`
+}
+
+type AggregationComposite struct {
+	Size    int                                     `json:"size"`    // default 10
+	Sources []map[string]AggregationCompositeSource `json:"sources"` // a name and a source in each item
+	After   map[string]interface{}                  `json:"after"`   // after_key of the previous page
+}
+
+// AggregationCompositeSource is one of terms, histogram and date_histogram
+type AggregationCompositeSource struct {
+	Terms         *AggregationCompositeValues `json:"terms"`
+	Histogram     *AggregationCompositeValues `json:"histogram"`
+	DateHistogram *AggregationCompositeValues `json:"date_histogram"`
+}
+
+type AggregationCompositeValues struct {
+	Field            string      `json:"field"`
+	Order            string      `json:"order"` // asc, desc
+	MissingBucket    bool        `json:"missing_bucket"`
+	Interval         interface{} `json:"interval"`          // number for histogram, ms,s,m,h,d for date_histogram
+	FixedInterval    string      `json:"fixed_interval"`    // ms,s,m,h,d
+	CalendarInterval string      `json:"calendar_interval"` // minute,hour,day,week,month,quarter,year
+	TimeZone         string      `json:"time_zone"`
 }
 
 type AggregationRange struct {
-	Field  string  `json:"field"`
-	Ranges []Range `json:"ranges"`
-	Keyed  bool    `json:"keyed"`
+	Field   string      `json:"field"`
+	Ranges  []Range     `json:"ranges"`
+	Keyed   bool        `json:"keyed"`
+	Missing interface{} `json:"missing"` // the value of the documents which have no value
 }
 
 type Range struct {
-	To   float64 `json:"to"`
-	From float64 `json:"from"`
+	Key  string   `json:"key"`
+	To   *float64 `json:"to"`   // exclusive
+	From *float64 `json:"from"` // inclusive
 }
 
 // AggregationDateRange struct
@@ -325,11 +577,14 @@ type AggregationDateRange struct {
 	TimeZone string      `json:"time_zone"` // refer
 	Ranges   []DateRange `json:"ranges"`    // refer
 	Keyed    bool        `json:"keyed"`
+	Missing  interface{} `json:"missing"` // the date of the documents which have no value, supports date math
 }
 
+// DateRange is a range of date_range aggregation, the dates support date math, such as: now-7d/d
 type DateRange struct {
-	To   string `json:"to"`
-	From string `json:"from"`
+	Key  string      `json:"key"`
+	To   interface{} `json:"to"`   // exclusive
+	From interface{} `json:"from"` // inclusive
 }
 
 type AggregationIPRange struct {
@@ -339,8 +594,10 @@ type AggregationIPRange struct {
 }
 
 type IPRange struct {
-	To   string `json:"to"`
-	From string `json:"from"`
+	Key  string `json:"key"`
+	To   string `json:"to"`   // exclusive
+	From string `json:"from"` // inclusive
+	Mask string `json:"mask"` // CIDR block, like 10.0.0.0/25
 }
 
 type AggregationHistogram struct {
@@ -377,6 +634,94 @@ type AggregationAutoDateHistogram struct {
 	Keyed           bool   `json:"keyed"`
 }
 
+type AggregationGeoGrid struct {
+	Field     string                  `json:"field"`
+	Precision int                     `json:"precision"` // geohash length for geohash_grid, zoom level for geotile_grid
+	Size      int                     `json:"size"`
+	Bounds    *AggregationGeoBoundBox `json:"bounds"`
+}
+
+type AggregationGeoBoundBox struct {
+	TopLeft     interface{} `json:"top_left"`     // geo_point
+	BottomRight interface{} `json:"bottom_right"` // geo_point
+}
+
+type AggregationGeoBounds struct {
+	Field         string `json:"field"`
+	WrapLongitude bool   `json:"wrap_longitude"` // not supported, always false
+}
+
+type AggregationGeoDistance struct {
+	Field        string             `json:"field"`
+	Origin       interface{}        `json:"origin"`        // geo_point
+	Unit         string             `json:"unit"`          // m, km, mi ..., default is m
+	DistanceType string             `json:"distance_type"` // only support arc
+	Ranges       []GeoDistanceRange `json:"ranges"`
+	Keyed        bool               `json:"keyed"`
+}
+
+type GeoDistanceRange struct {
+	Key  string   `json:"key"`
+	From *float64 `json:"from"`
+	To   *float64 `json:"to"`
+}
+
+type AggregationNested struct {
+	Path string `json:"path"`
+}
+
+// AggregationFilters is the request of filters aggregation,
+// filters is a map of named queries or a list of anonymous queries
+type AggregationFilters struct {
+	Filters        interface{} `json:"filters"`
+	OtherBucket    bool        `json:"other_bucket"`
+	OtherBucketKey string      `json:"other_bucket_key"` // default _other_, enables other_bucket if set
+}
+
+type AggregationMissing struct {
+	Field string `json:"field"`
+}
+
+type AggregationGlobal struct{}
+
+// AggregationPipeline is the request of the pipeline aggregations which use one buckets_path,
+// buckets_path is like "agg>sub_agg.metric", "_count" or "_key"
+type AggregationPipeline struct {
+	BucketsPath string `json:"buckets_path"`
+	GapPolicy   string `json:"gap_policy"` // skip, insert_zeros, keep_values, default skip
+}
+
+type AggregationMovingAvg struct {
+	BucketsPath string                 `json:"buckets_path"`
+	GapPolicy   string                 `json:"gap_policy"`
+	Window      int                    `json:"window"`   // default 5
+	Model       string                 `json:"model"`    // simple, linear, ewma, default simple
+	Settings    map[string]interface{} `json:"settings"` // {"alpha": 0.3} for ewma
+}
+
+type AggregationMovingFn struct {
+	BucketsPath string      `json:"buckets_path"`
+	GapPolicy   string      `json:"gap_policy"`
+	Window      int         `json:"window"`
+	Shift       int         `json:"shift"`
+	Script      interface{} `json:"script"` // "MovingFunctions.unweightedAvg(values)" or {"source": "..."}
+}
+
+// AggregationBucketScript is the request of bucket_script and bucket_selector,
+// the values of buckets_path are the params of the script
+type AggregationBucketScript struct {
+	BucketsPath map[string]string `json:"buckets_path"`
+	GapPolicy   string            `json:"gap_policy"`
+	Script      interface{}       `json:"script"` // "params.a / params.b" or {"source": "...", "params": {}}
+}
+
+type AggregationBucketSort struct {
+	Sort      interface{} `json:"sort"` // ["_key", {"sales": {"order": "desc"}}]
+	From      int         `json:"from"`
+	Size      int         `json:"size"`
+	GapPolicy string      `json:"gap_policy"`
+}
+
 type Highlight struct {
 	NumberOfFragments int                   `json:"number_of_fragments"`
 	FragmentSize      int                   `json:"fragment_size"`
@@ -391,6 +736,7 @@ type Field struct {
 }
 
 type Source struct {
-	Enable bool     // enable _source returns, default is true
-	Fields []string // what fields can returns
+	Enable   bool     // enable _source returns, default is true
+	Fields   []string // what fields can returns
+	Excludes []string // what fields can't returns
 }
@@ -15,7 +15,13 @@
 
 package meta
 
-import "time"
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
 
 // SearchResponse for a query
 type SearchResponse struct {
@@ -25,6 +31,9 @@ type SearchResponse struct {
 	Hits         Hits                           `json:"hits"`
 	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
 	Error        string                         `json:"error,omitempty"`
+	PitID        string                         `json:"pit_id,omitempty"`
+	ScrollID     string                         `json:"_scroll_id,omitempty"`
+	Status       int                            `json:"status,omitempty"` // only for the responses of multiple search
 }
 
 type Shards struct {
@@ -41,14 +50,27 @@ type Hits struct {
 }
 
 type Hit struct {
-	Index     string                 `json:"_index"`
-	Type      string                 `json:"_type"`
-	ID        string                 `json:"_id"`
-	Score     float64                `json:"_score"`
-	Timestamp time.Time              `json:"@timestamp"`
-	Source    interface{}            `json:"_source,omitempty"`
-	Fields    map[string]interface{} `json:"fields,omitempty"`
-	Highlight map[string]interface{} `json:"highlight,omitempty"`
+	Index     string                       `json:"_index"`
+	Type      string                       `json:"_type"`
+	ID        string                       `json:"_id"`
+	Score     float64                      `json:"_score"`
+	Timestamp time.Time                    `json:"@timestamp"`
+	Source    interface{}                  `json:"_source,omitempty"`
+	Fields    map[string]interface{}       `json:"fields,omitempty"`
+	Highlight map[string]interface{}       `json:"highlight,omitempty"`
+	Sort      []interface{}                `json:"sort,omitempty"`
+	Nested    *NestedIdentity              `json:"_nested,omitempty"`    // support for inner hits of nested query
+	InnerHits map[string]InnerHitsResponse `json:"inner_hits,omitempty"` // support for nested query
+}
+
+// NestedIdentity identifies the nested object of an inner hit
+type NestedIdentity struct {
+	Field  string `json:"field"`
+	Offset int    `json:"offset"`
+}
+
+type InnerHitsResponse struct {
+	Hits Hits `json:"hits"`
 }
 
 type Total struct {
@@ -57,6 +79,63 @@ type Total struct {
 
 type AggregationResponse struct {
 	Value    interface{} `json:"value,omitempty"`
-	Buckets  interface{} `json:"buckets,omitempty"`  // slice or map
-	Interval string      `json:"interval,omitempty"` // support for auto_date_histogram_aggregation
+	Buckets  interface{} `json:"buckets,omitempty"`   // slice or map
+	Interval string      `json:"interval,omitempty"`  // support for auto_date_histogram_aggregation
+	Bounds   interface{} `json:"bounds,omitempty"`    // support for geo_bounds_aggregation
+	Location interface{} `json:"location,omitempty"`  // support for geo_centroid_aggregation
+	Count    *int64      `json:"count,omitempty"`     // support for geo_centroid_aggregation
+	DocCount *int64      `json:"doc_count,omitempty"` // support for single bucket aggregations, like nested
+	Values   interface{} `json:"values,omitempty"`    // support for percentiles and percentile_ranks aggregations
+	Hits     *Hits       `json:"hits,omitempty"`      // support for top_hits aggregation
+	// support for terms aggregation
+	DocCountErrorUpperBound *int64 `json:"doc_count_error_upper_bound,omitempty"`
+	SumOtherDocCount        *int64 `json:"sum_other_doc_count,omitempty"`
+	// support for significant_terms aggregation
+	BgCount *int64 `json:"bg_count,omitempty"`
+	// support for composite aggregation
+	AfterKey map[string]interface{} `json:"after_key,omitempty"`
+	// Metrics are the values of multi-value metrics aggregations, like stats,
+	// they are marshaled as the fields of the response
+	Metrics map[string]interface{} `json:"-"`
+	// Aggregations are the sub aggregations of single bucket aggregations,
+	// they are marshaled as the fields of the response
+	Aggregations map[string]AggregationResponse `json:"-"`
+}
+
+func (r AggregationResponse) MarshalJSON() ([]byte, error) {
+	type response AggregationResponse
+	data, err := json.Marshal(response(r))
+	if err != nil || (len(r.Metrics) == 0 && len(r.Aggregations) == 0) {
+		return data, err
+	}
+
+	fields := make(map[string]interface{}, len(r.Metrics)+len(r.Aggregations))
+	for name, v := range r.Metrics {
+		fields[name] = v
+	}
+	for name, v := range r.Aggregations {
+		fields[name] = v
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := bytes.NewBuffer(data[:len(data)-1])
+	for _, name := range names {
+		value, err := json.Marshal(fields[name])
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(name)
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
 }
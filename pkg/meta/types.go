@@ -22,6 +22,14 @@ const (
 	ActionFieldName = "@_action"
 	ShardFieldName  = "@_shard"
 	SourceFieldName = "@_source"
+	NestedFieldName = "@_nested"
+)
+
+// Field names of the hidden documents of nested objects
+const (
+	NestedPathFieldName   = "_nested_path"
+	NestedParentFieldName = "_nested_parent"
+	NestedOffsetFieldName = "_nested_offset"
 )
 
 const (
@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+type ilm struct{}
+
+var ILM = new(ilm)
+
+func (t *ilm) List(offset, limit int) ([]*meta.ILMPolicy, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]*meta.ILMPolicy, 0, len(data))
+	for _, d := range data {
+		policy := new(meta.ILMPolicy)
+		err = json.Unmarshal(d, policy)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (t *ilm) Get(id string) (*meta.ILMPolicy, error) {
+	data, err := db.Get(t.key(id))
+	if err != nil {
+		return nil, err
+	}
+	policy := new(meta.ILMPolicy)
+	err = json.Unmarshal(data, policy)
+	return policy, err
+}
+
+func (t *ilm) Set(id string, val meta.ILMPolicy) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(id), data)
+}
+
+func (t *ilm) Delete(id string) error {
+	return db.Delete(t.key(id))
+}
+
+func (t *ilm) key(id string) string {
+	return "/ilm/policy/" + id
+}
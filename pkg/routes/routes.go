@@ -106,6 +106,7 @@ func SetRoutes(r *gin.Engine) {
 	r.GET("/api/index/:target", AuthMiddleware("index.Get"), index.Get)
 	r.HEAD("/api/index/:target", AuthMiddleware("index.Exists"), index.Exists)
 	r.POST("/api/index/:target/refresh", AuthMiddleware("index.Refresh"), index.Refresh)
+	r.POST("/api/index/:target/_forcemerge", AuthMiddleware("index.ForceMerge"), index.ForceMerge)
 	// index settings
 	r.GET("/api/:target/_mapping", AuthMiddleware("index.GetMapping"), index.GetMapping)
 	r.PUT("/api/:target/_mapping", AuthMiddleware("index.SetMapping"), index.SetMapping)
@@ -155,6 +156,14 @@ func SetRoutes(r *gin.Engine) {
 	r.POST("/es/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
 	r.POST("/es/:target/_search", AuthMiddleware("search.SearchDSL"), ESMiddleware, IndexAliasMiddleware, search.SearchDSL)
 	r.POST("/es/:target/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
+	r.GET("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.GET("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.DELETE("/es/_search/scroll", AuthMiddleware("search.ClearScroll"), ESMiddleware, search.ClearScroll)
+	r.DELETE("/es/_search/scroll/:scroll_id", AuthMiddleware("search.ClearScroll"), ESMiddleware, search.ClearScroll) // _all
+	r.POST("/es/:target/_pit", AuthMiddleware("search.OpenPointInTime"), ESMiddleware, IndexAliasMiddleware, search.OpenPointInTime)
+	r.DELETE("/es/_pit", AuthMiddleware("search.ClosePointInTime"), ESMiddleware, search.ClosePointInTime)
 	r.POST("/es/:target/_delete_by_query", AuthMiddleware("search.DeleteByQuery"), IndexAliasMiddleware, search.DeleteByQuery)
 
 	r.GET("/es/_index_template", AuthMiddleware("index.ListTemplate"), ESMiddleware, index.ListTemplate)
@@ -163,6 +172,11 @@ func SetRoutes(r *gin.Engine) {
 	r.GET("/es/_index_template/:target", AuthMiddleware("index.GetTemplate"), ESMiddleware, index.GetTemplate)
 	r.HEAD("/es/_index_template/:target", AuthMiddleware("index.GetTemplate"), ESMiddleware, index.GetTemplate)
 	r.DELETE("/es/_index_template/:target", AuthMiddleware("index.DeleteTemplate"), ESMiddleware, index.DeleteTemplate)
+	// ES Compatible index lifecycle management
+	r.GET("/es/_ilm/policy", AuthMiddleware("index.ListILMPolicy"), ESMiddleware, index.ListILMPolicy)
+	r.GET("/es/_ilm/policy/:target", AuthMiddleware("index.GetILMPolicy"), ESMiddleware, index.GetILMPolicy)
+	r.PUT("/es/_ilm/policy/:target", AuthMiddleware("index.CreateILMPolicy"), ESMiddleware, index.CreateILMPolicy)
+	r.DELETE("/es/_ilm/policy/:target", AuthMiddleware("index.DeleteILMPolicy"), ESMiddleware, index.DeleteILMPolicy)
 	// ES Compatible data stream
 	r.PUT("/es/_data_stream/:target", AuthMiddleware("elastic.PutDataStream"), ESMiddleware, elastic.PutDataStream)
 	r.GET("/es/_data_stream/:target", AuthMiddleware("elastic.GetDataStream"), ESMiddleware, elastic.GetDataStream)
@@ -191,6 +205,7 @@ func SetRoutes(r *gin.Engine) {
 	r.POST("/es/:target/_bulk", AuthMiddleware("document.ESBulk"), ESMiddleware, document.ESBulk)
 	r.PUT("/es/:target/_bulk", AuthMiddleware("document.ESBulk"), ESMiddleware, document.ESBulk)
 	r.POST("/es/:target/_refresh", AuthMiddleware("index.Refresh"), index.Refresh)
+	r.POST("/es/:target/_forcemerge", AuthMiddleware("index.ForceMerge"), index.ForceMerge)
 	// ES Document
 	r.POST("/es/:target/_doc", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate)        // create
 	r.PUT("/es/:target/_doc/:id", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate)     // create or update
@@ -19,19 +19,38 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/numeric/geo"
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 
 	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/uquery/sort"
+	"github.com/zincsearch/zincsearch/pkg/uquery/source"
 	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, mappings *meta.Mappings) error {
+// DefaultTopHitsSize is the default number of documents returned for each bucket by top_hits aggregation
+const DefaultTopHitsSize = 3
+
+// Request adds the aggregations to req, analyzers are used by the queries of filter aggregations,
+// root is the query of the search request which provides the reader for nested, filter and global aggregations.
+func Request(
+	req zincaggregation.SearchAggregation,
+	aggs map[string]meta.Aggregations,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	root *zincquery.RootQuery,
+) error {
 	if len(aggs) == 0 {
 		return nil // not need aggregation
 	}
@@ -56,94 +75,176 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 		case agg.Count != nil:
 			req.AddAggregation(name, aggregations.CountMatches())
 		case agg.Cardinality != nil:
-			req.AddAggregation(name, aggregations.Cardinality(search.Field(agg.Cardinality.Field)))
-		case agg.Terms != nil:
-			if agg.Terms.Size == 0 {
-				agg.Terms.Size = config.Global.AggregationTermsSize
+			prop, _ := mappings.GetProperty(agg.Cardinality.Field)
+			numeric := prop.Type == "numeric" || prop.Type == "date" || prop.Type == "time"
+			subreq := zincaggregation.NewCardinalityAggregation(search.Field(agg.Cardinality.Field), numeric)
+			if agg.Cardinality.PrecisionThreshold != nil {
+				if *agg.Cardinality.PrecisionThreshold < 0 {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, "[cardinality] aggregation precision_threshold must be greater than or equal to 0")
+				}
+				subreq.SetPrecisionThreshold(*agg.Cardinality.PrecisionThreshold)
 			}
-			var subreq *zincaggregation.TermsAggregation
-			prop, _ := mappings.GetProperty(agg.Terms.Field)
-			switch prop.Type {
-			case "text", "keyword":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.TextValueSource, agg.Terms.Size)
-			case "numeric":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.NumericValueSource, agg.Terms.Size)
-			case "bool", "boolean":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.BooleanValueSource, agg.Terms.Size)
-			default:
+			req.AddAggregation(name, subreq)
+		case agg.ValueCount != nil:
+			prop, _ := mappings.GetProperty(agg.ValueCount.Field)
+			numeric := prop.Type == "numeric" || prop.Type == "date" || prop.Type == "time"
+			req.AddAggregation(name, zincaggregation.NewValueCountAggregation(search.Field(agg.ValueCount.Field), numeric))
+		case agg.Stats != nil:
+			if err := checkNumericField("stats", agg.Stats.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewStatsAggregation(search.Field(agg.Stats.Field)))
+		case agg.ExtendedStats != nil:
+			if err := checkNumericField("extended_stats", agg.ExtendedStats.Field, mappings); err != nil {
+				return err
+			}
+			if agg.ExtendedStats.Sigma == 0 {
+				agg.ExtendedStats.Sigma = 2
+			}
+			if agg.ExtendedStats.Sigma < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[extended_stats] aggregation sigma must be a non-negative number")
+			}
+			req.AddAggregation(name, zincaggregation.NewExtendedStatsAggregation(search.Field(agg.ExtendedStats.Field), agg.ExtendedStats.Sigma))
+		case agg.Percentiles != nil:
+			if err := checkNumericField("percentiles", agg.Percentiles.Field, mappings); err != nil {
+				return err
+			}
+			if len(agg.Percentiles.Percents) == 0 {
+				agg.Percentiles.Percents = []float64{1, 5, 25, 50, 75, 95, 99}
+			}
+			for _, p := range agg.Percentiles.Percents {
+				if p < 0 || p > 100 {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, "[percentiles] aggregation percents must be between 0 and 100")
+				}
+			}
+			compression, err := percentilesCompression("percentiles", agg.Percentiles.TDigest)
+			if err != nil {
+				return err
+			}
+			keyed := agg.Percentiles.Keyed == nil || *agg.Percentiles.Keyed
+			req.AddAggregation(name, zincaggregation.NewPercentilesAggregation(
+				search.Field(agg.Percentiles.Field),
+				compression,
+				agg.Percentiles.Percents,
+				keyed,
+			))
+		case agg.PercentileRanks != nil:
+			if err := checkNumericField("percentile_ranks", agg.PercentileRanks.Field, mappings); err != nil {
+				return err
+			}
+			if len(agg.PercentileRanks.Values) == 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[percentile_ranks] aggregation needs values")
+			}
+			compression, err := percentilesCompression("percentile_ranks", agg.PercentileRanks.TDigest)
+			if err != nil {
+				return err
+			}
+			keyed := agg.PercentileRanks.Keyed == nil || *agg.PercentileRanks.Keyed
+			req.AddAggregation(name, zincaggregation.NewPercentileRanksAggregation(
+				search.Field(agg.PercentileRanks.Field),
+				compression,
+				agg.PercentileRanks.Values,
+				keyed,
+			))
+		case agg.TopHits != nil:
+			if agg.TopHits.Size == 0 {
+				agg.TopHits.Size = DefaultTopHitsSize
+			}
+			if agg.TopHits.Size < 0 || agg.TopHits.Size > config.Global.MaxResults {
 				return errors.New(
-					errors.ErrorTypeParsingException,
-					fmt.Sprintf("[terms] aggregation doesn't support values of type: [%s:[%s]]", agg.Terms.Field, prop.Type),
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[top_hits] aggregation size must be between 0 and %d", config.Global.MaxResults),
 				)
 			}
+			if agg.TopHits.Source, err = source.Request(agg.TopHits.Source); err != nil {
+				return err
+			}
+			if agg.TopHits.Sort != nil {
+				if agg.TopHits.Sort, err = sort.Request(agg.TopHits.Sort); err != nil {
+					return err
+				}
+			}
+			sorts, _ := agg.TopHits.Sort.(search.SortOrder)
+			if len(sorts) == 0 {
+				sorts = search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}
+			}
+			req.AddAggregation(name, zincaggregation.NewTopHitsAggregation(
+				agg.TopHits.Size,
+				sorts,
+				[]string{"_id", "_index", "@timestamp", "_source"},
+			))
+		case agg.Terms != nil:
+			subreq, err := termsRequest(agg.Terms, agg.Aggregations, mappings)
+			if err != nil {
+				return err
+			}
+			if agg.Terms.MinDocCount != nil && *agg.Terms.MinDocCount == 0 && root != nil {
+				root.AddReaderHook(subreq.SetReader)
+			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
 					return err
 				}
 			}
 			req.AddAggregation(name, subreq)
-		case agg.Range != nil:
-			if len(agg.Range.Ranges) == 0 {
-				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation needs ranges")
+		case agg.MultiTerms != nil:
+			subreq, err := multiTermsRequest(agg.MultiTerms, agg.Aggregations, mappings)
+			if err != nil {
+				return err
 			}
-			var subreq *aggregations.RangeAggregation
-			prop, _ := mappings.GetProperty(agg.Range.Field)
-			switch prop.Type {
-			case "numeric":
-				subreq = aggregations.Ranges(search.Field(agg.Range.Field))
-				for _, v := range agg.Range.Ranges {
-					subreq.AddRange(aggregations.Range(v.From, v.To))
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
 				}
-				req.AddAggregation(name, subreq)
-			default:
-				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation only support type numeric")
 			}
-		case agg.DateRange != nil:
-			if len(agg.DateRange.Ranges) == 0 {
-				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation needs ranges")
+			req.AddAggregation(name, subreq)
+		case agg.SignificantTerms != nil:
+			subreq, err := significantTermsRequest(agg.SignificantTerms, mappings)
+			if err != nil {
+				return err
 			}
-			var subreq *aggregations.DateRangeAggregation
-			format := time.RFC3339
-			prop, ok := mappings.GetProperty(agg.DateRange.Field)
-			if ok {
-				if prop.Format != "" {
-					format = prop.Format
+			if root != nil {
+				root.AddReaderHook(subreq.SetReader)
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
 				}
 			}
-			if agg.DateRange.Format != "" {
-				format = agg.DateRange.Format
+			req.AddAggregation(name, subreq)
+		case agg.RareTerms != nil:
+			subreq, err := rareTermsRequest(agg.RareTerms, mappings)
+			if err != nil {
+				return err
 			}
-			timeZone := time.UTC
-			if agg.DateRange.TimeZone != "" {
-				timeZone, err = zutils.ParseTimeZone(agg.DateRange.TimeZone)
-				if err != nil {
-					return errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[date_range] time_zone parse err %s", err.Error()))
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
 				}
 			}
-			switch prop.Type {
-			case "date", "time":
-				subreq = aggregations.DateRanges(search.Field(agg.DateRange.Field))
-				for _, v := range agg.DateRange.Ranges {
-					from := time.Time{}
-					to := time.Time{}
-					if v.From != "" {
-						from, err = time.ParseInLocation(format, v.From, timeZone)
-						if err != nil {
-							return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] range value from parse err %s", err.Error()))
-						}
-					}
-					if v.To != "" {
-						to, err = time.ParseInLocation(format, v.To, timeZone)
-						if err != nil {
-							return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] range value to parse err %s", err.Error()))
-						}
-					}
-					subreq.AddRange(aggregations.NewDateRange(from, to))
+			req.AddAggregation(name, subreq)
+		case agg.Range != nil:
+			subreq, err := rangeRequest(agg.Range, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.DateRange != nil:
+			subreq, err := dateRangeRequest(agg.DateRange, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
 				}
-				req.AddAggregation(name, subreq)
-			default:
-				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation only support type datetime")
 			}
+			req.AddAggregation(name, subreq)
 		case agg.Histogram != nil:
 			if agg.Histogram.Size == 0 {
 				agg.Histogram.Size = config.Global.AggregationTermsSize
@@ -174,7 +275,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
 					return err
 				}
 			}
@@ -190,37 +291,9 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				return errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation calendar_interval or fixed_interval must be set one")
 			}
 
-			// format interval
-			var interval int64
-			calendarInterval := agg.DateHistogram.CalendarInterval
-			if agg.DateHistogram.CalendarInterval != "" {
-				switch agg.DateHistogram.CalendarInterval {
-				case "second", "1s":
-					interval = int64(time.Second)
-					calendarInterval = ""
-				case "minute", "1m":
-					interval = int64(time.Minute)
-					calendarInterval = ""
-				case "hour", "1h":
-					interval = int64(time.Hour)
-					calendarInterval = ""
-				case "day", "1d":
-					interval = int64(time.Hour * 24)
-					calendarInterval = ""
-				case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
-					// calendar
-				default:
-					return errors.New(
-						errors.ErrorTypeParsingException,
-						"[date_histogram] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year",
-					)
-				}
-			} else if agg.DateHistogram.FixedInterval != "" {
-				if duration, err := zutils.ParseDuration(agg.DateHistogram.FixedInterval); err != nil {
-					return errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d")
-				} else {
-					interval = int64(duration)
-				}
+			calendarInterval, interval, err := dateHistogramInterval("date_histogram", agg.DateHistogram.CalendarInterval, agg.DateHistogram.FixedInterval)
+			if err != nil {
+				return err
 			}
 
 			timeZone := time.UTC
@@ -259,7 +332,18 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Composite != nil:
+			subreq, err := compositeRequest(agg.Composite, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
 					return err
 				}
 			}
@@ -315,13 +399,224 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
 					return err
 				}
 			}
 			req.AddAggregation(name, subreq)
 		case agg.IPRange != nil:
-			return errors.New(errors.ErrorTypeNotImplemented, "[ip_range] aggregation doesn't support")
+			prop, _ := mappings.GetProperty(agg.IPRange.Field)
+			if prop.Type != "ip" {
+				return errors.New(
+					errors.ErrorTypeParsingException,
+					fmt.Sprintf("[ip_range] aggregation doesn't support values of type: [%s:[%s]]", agg.IPRange.Field, prop.Type),
+				)
+			}
+			if len(agg.IPRange.Ranges) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation needs ranges")
+			}
+			ranges := make([]*zincaggregation.IPRange, 0, len(agg.IPRange.Ranges))
+			for _, v := range agg.IPRange.Ranges {
+				r, err := ipRange(v)
+				if err != nil {
+					return err
+				}
+				ranges = append(ranges, r)
+			}
+			subreq := zincaggregation.NewIPRangeAggregation(search.Field(agg.IPRange.Field), ranges)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.GeoHashGrid != nil, agg.GeoTileGrid != nil:
+			aggType := "geohash_grid"
+			gridType := zincaggregation.GeoHashGrid
+			grid := agg.GeoHashGrid
+			minPrecision, maxPrecision, defaultPrecision := 1, 12, 5
+			if agg.GeoTileGrid != nil {
+				aggType = "geotile_grid"
+				gridType = zincaggregation.GeoTileGrid
+				grid = agg.GeoTileGrid
+				minPrecision, maxPrecision, defaultPrecision = 0, 29, 7
+			}
+			if err := checkGeoPointField(aggType, grid.Field, mappings); err != nil {
+				return err
+			}
+			if grid.Size == 0 {
+				grid.Size = 10000
+			}
+			if grid.Precision == 0 {
+				grid.Precision = defaultPrecision
+			}
+			if grid.Precision < minPrecision || grid.Precision > maxPrecision {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[%s] aggregation precision must be between %d and %d", aggType, minPrecision, maxPrecision),
+				)
+			}
+			var bounds *zincaggregation.GeoBoundingBox
+			if grid.Bounds != nil {
+				left, top, err := zutils.ParseGeoPoint(grid.Bounds.TopLeft)
+				if err != nil {
+					return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation bounds.top_left parse err: %s", aggType, err.Error()))
+				}
+				right, bottom, err := zutils.ParseGeoPoint(grid.Bounds.BottomRight)
+				if err != nil {
+					return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation bounds.bottom_right parse err: %s", aggType, err.Error()))
+				}
+				bounds = &zincaggregation.GeoBoundingBox{Top: top, Left: left, Bottom: bottom, Right: right}
+			}
+			subreq := zincaggregation.NewGeoGridAggregation(search.Field(grid.Field), gridType, grid.Precision, grid.Size, bounds)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.GeoBounds != nil:
+			if err := checkGeoPointField("geo_bounds", agg.GeoBounds.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewGeoBoundsAggregation(search.Field(agg.GeoBounds.Field)))
+		case agg.GeoCentroid != nil:
+			if err := checkGeoPointField("geo_centroid", agg.GeoCentroid.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewGeoCentroidAggregation(search.Field(agg.GeoCentroid.Field)))
+		case agg.GeoDistance != nil:
+			if err := checkGeoPointField("geo_distance", agg.GeoDistance.Field, mappings); err != nil {
+				return err
+			}
+			if len(agg.GeoDistance.Ranges) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[geo_distance] aggregation needs ranges")
+			}
+			lon, lat, err := zutils.ParseGeoPoint(agg.GeoDistance.Origin)
+			if err != nil {
+				return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] aggregation origin parse err: %s", err.Error()))
+			}
+			unit := 1.0
+			if agg.GeoDistance.Unit != "" {
+				if unit, err = geo.ParseDistanceUnit(agg.GeoDistance.Unit); err != nil {
+					return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] aggregation unknown unit [%s]", agg.GeoDistance.Unit))
+				}
+			}
+			ranges := make([]*zincaggregation.GeoDistanceRange, 0, len(agg.GeoDistance.Ranges))
+			for _, v := range agg.GeoDistance.Ranges {
+				r := &zincaggregation.GeoDistanceRange{Key: v.Key, From: math.Inf(-1), To: math.Inf(1)}
+				from, to := "*", "*"
+				if v.From != nil {
+					r.From = *v.From
+					from = strconv.FormatFloat(r.From, 'f', 1, 64)
+				}
+				if v.To != nil {
+					r.To = *v.To
+					to = strconv.FormatFloat(r.To, 'f', 1, 64)
+				}
+				if r.Key == "" {
+					r.Key = from + "-" + to
+				}
+				ranges = append(ranges, r)
+			}
+			subreq := zincaggregation.NewGeoDistanceAggregation(
+				search.Field(agg.GeoDistance.Field),
+				geo.Point{Lon: lon, Lat: lat},
+				unit,
+				ranges,
+			)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Nested != nil:
+			prop, _ := mappings.GetProperty(agg.Nested.Path)
+			if prop.Type != "nested" || root == nil {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[nested] aggregation path [%s] is not nested", agg.Nested.Path),
+				)
+			}
+			subreq := zincaggregation.NewNestedAggregation(agg.Nested.Path, meta.NestedPathFieldName, meta.NestedParentFieldName)
+			root.AddReaderHook(subreq.SetReader)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filter != nil:
+			if root == nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[filter] aggregation requires the root query")
+			}
+			filter, err := query.Query(agg.Filter, mappings, analyzers)
+			if err != nil {
+				return errors.New(errors.ErrorTypeParsingException, "[filter] aggregation failed to parse query").Cause(err)
+			}
+			subreq := zincaggregation.NewFilterAggregation(filter)
+			root.AddReaderHook(subreq.SetReader)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filters != nil:
+			if root == nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[filters] aggregation requires the root query")
+			}
+			keys, filters, keyed, err := filtersRequest(agg.Filters, mappings, analyzers)
+			if err != nil {
+				return err
+			}
+			subreq := zincaggregation.NewFiltersAggregation(keys, filters, keyed)
+			if agg.Filters.OtherBucket || agg.Filters.OtherBucketKey != "" {
+				subreq.SetOtherBucket(agg.Filters.OtherBucketKey)
+			}
+			root.AddReaderHook(subreq.SetReader)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Missing != nil:
+			if agg.Missing.Field == "" {
+				return errors.New(errors.ErrorTypeParsingException, "[missing] aggregation requires [field]")
+			}
+			subreq := zincaggregation.NewMissingAggregation(agg.Missing.Field)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Global != nil:
+			if _, ok := req.(*bluge.TopNSearch); !ok || root == nil {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[global] aggregation [%s] can only be defined as a top level aggregation", name),
+				)
+			}
+			all := bluge.NewBooleanQuery().AddMust(bluge.NewMatchAllQuery())
+			if len(mappings.ListNestedPath()) > 0 {
+				all.AddMustNot(bluge.NewWildcardQuery("*").SetField(meta.NestedPathFieldName))
+			}
+			subreq := zincaggregation.NewGlobalAggregation(all)
+			root.AddReaderHook(subreq.SetReader)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, root); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case isPipeline(agg):
+			// pipeline aggregations run on the response, only check the request here
+			if _, err := newPipeline(name, agg); err != nil {
+				return err
+			}
 		default:
 			// nothing
 		}
@@ -330,11 +625,166 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 	return nil
 }
 
-func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error) {
+// Response formats the aggregations of bucket, aggs are the requests of the aggregations
+// which provide the options for formatting, like the _source of top_hits aggregation.
+func Response(bucket *search.Bucket, aggs map[string]meta.Aggregations, mappings *meta.Mappings) (map[string]meta.AggregationResponse, error) {
 	resp := make(map[string]meta.AggregationResponse)
-	aggs := bucket.Aggregations()
-	for name, v := range aggs {
+	calculators := bucket.Aggregations()
+	for name, v := range calculators {
 		switch v := v.(type) {
+		case *zincaggregation.GeoBoundsCalculator:
+			aggResp := meta.AggregationResponse{}
+			if bounds, ok := v.Bounds(); ok {
+				aggResp.Bounds = map[string]interface{}{
+					"top_left":     map[string]float64{"lat": bounds.Top, "lon": bounds.Left},
+					"bottom_right": map[string]float64{"lat": bounds.Bottom, "lon": bounds.Right},
+				}
+			}
+			resp[name] = aggResp
+		case *zincaggregation.GeoCentroidCalculator:
+			lon, lat, count := v.Centroid()
+			aggResp := meta.AggregationResponse{Count: &count}
+			if count > 0 {
+				aggResp.Location = map[string]float64{"lat": lat, "lon": lon}
+			}
+			resp[name] = aggResp
+		case *zincaggregation.StatsCalculator:
+			resp[name] = meta.AggregationResponse{Metrics: statsResponse(v)}
+		case *zincaggregation.PercentilesCalculator:
+			resp[name] = meta.AggregationResponse{Values: percentilesResponse(v)}
+		case *zincaggregation.IPRangeCalculator:
+			keyed := aggs[name].IPRange != nil && aggs[name].IPRange.Keyed
+			aggRespBuckets := make([]map[string]interface{}, 0, len(v.Buckets()))
+			aggRespKeyed := make(map[string]interface{}, len(v.Buckets()))
+			for i, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+				r := v.Ranges()[i]
+				if r.From != nil {
+					aggBucket["from"] = zutils.FormatIP(r.From)
+				}
+				if r.To != nil {
+					aggBucket["to"] = zutils.FormatIP(r.To)
+				}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				if keyed {
+					aggRespKeyed[r.Key] = aggBucket
+				} else {
+					aggBucket["key"] = r.Key
+					aggRespBuckets = append(aggRespBuckets, aggBucket)
+				}
+			}
+			if keyed {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespKeyed}
+			} else {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			}
+		case *zincaggregation.RangeCalculator:
+			aggResp, err := rangeResponse(v, aggs[name], mappings)
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.FiltersCalculator:
+			aggRespBuckets := make([]map[string]interface{}, 0, len(v.Buckets()))
+			aggRespKeyed := make(map[string]interface{}, len(v.Buckets()))
+			for _, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				if v.Keyed() {
+					aggRespKeyed[bucket.Name()] = aggBucket
+				} else {
+					aggRespBuckets = append(aggRespBuckets, aggBucket)
+				}
+			}
+			if v.Keyed() {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespKeyed}
+			} else {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			}
+		case *zincaggregation.CompositeCalculator:
+			keys := v.Keys()
+			aggRespBuckets := make([]map[string]interface{}, 0, len(keys))
+			for i, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"key": compositeKey(v.Sources(), keys[i]), "doc_count": bucket.Count()}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			aggResp := meta.AggregationResponse{Buckets: aggRespBuckets}
+			if afterKey := v.AfterKey(); afterKey != nil {
+				aggResp.AfterKey = compositeKey(v.Sources(), afterKey)
+			}
+			resp[name] = aggResp
+		case *zincaggregation.SignificantTermsCalculator:
+			docCount, bgCount := int64(v.DocCount()), int64(v.BgCount())
+			aggResp := meta.AggregationResponse{DocCount: &docCount, BgCount: &bgCount}
+			aggRespBuckets := make([]map[string]interface{}, 0)
+			numericKey := v.Source().ValueType == zincaggregation.NumericValueSource
+			for _, bucket := range v.SignificantBuckets() {
+				aggBucket := map[string]interface{}{
+					"key":       bucket.Name(),
+					"doc_count": bucket.Count(),
+					"score":     bucket.Score,
+					"bg_count":  bucket.BgCount,
+				}
+				if numericKey {
+					aggBucket["key"], _ = strconv.ParseFloat(bucket.Name(), 64)
+				}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket.Bucket, aggs[name].Aggregations, mappings)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			aggResp.Buckets = aggRespBuckets
+			resp[name] = aggResp
+		case *zincaggregation.TopHitsCalculator:
+			resp[name] = meta.AggregationResponse{Hits: topHitsResponse(v, aggs[name].TopHits, mappings)}
+		case zincaggregation.SingleBucketCalculator:
+			bucket := v.Bucket()
+			count := int64(bucket.Count())
+			aggResp := meta.AggregationResponse{DocCount: &count}
+			if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+				subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
+				if err != nil {
+					return nil, err
+				}
+				delete(subResp, "count")
+				aggResp.Aggregations = subResp
+			}
+			resp[name] = aggResp
 		case search.MetricCalculator:
 			f := v.Value()
 			if math.IsNaN(f) {
@@ -347,15 +797,30 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			buckets := v.Buckets()
 			aggResp := meta.AggregationResponse{Buckets: make([]map[string]interface{}, 0)}
 			aggRespBuckets := make([]map[string]interface{}, 0)
-			for _, bucket := range buckets {
+			// geohash maybe only contains digits, but it is a string
+			_, isGeoGrid := v.(*zincaggregation.GeoGridCalculator)
+			geoDistance, isGeoDistance := v.(*zincaggregation.GeoDistanceCalculator)
+			terms, isTerms := v.(*zincaggregation.TermsCalculator)
+			isMultiTerms := isTerms && len(terms.Sources()) > 1
+			for i, bucket := range buckets {
 				aggBucket := map[string]interface{}{"key": bucket.Name(), "doc_count": bucket.Count()}
-				if zutils.IsNumeric(bucket.Name()) {
+				if isMultiTerms {
+					aggBucket["key"], aggBucket["key_as_string"] = multiTermsKey(terms.Sources(), bucket.Name())
+				} else if isGeoDistance {
+					r := geoDistance.Ranges()[i]
+					if !math.IsInf(r.From, 0) {
+						aggBucket["from"] = r.From
+					}
+					if !math.IsInf(r.To, 0) {
+						aggBucket["to"] = r.To
+					}
+				} else if !isGeoGrid && zutils.IsNumeric(bucket.Name()) {
 					key, _ := strconv.ParseInt(bucket.Name(), 10, 64)
 					aggBucket["key"] = key
 					aggBucket["key_as_string"] = bucket.Name()
 				}
 				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
-					subResp, err := Response(bucket)
+					subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
 					if err != nil {
 						return nil, err
 					}
@@ -368,8 +833,14 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			}
 			aggResp.Buckets = aggRespBuckets
 
+			if isTerms {
+				docCountError, sumOther := int64(terms.DocCountError()), int64(terms.Other())
+				aggResp.DocCountErrorUpperBound = &docCountError
+				aggResp.SumOtherDocCount = &sumOther
+			}
+
 			// hack: auto_date_histogram aggregation
-			if v, ok := aggs[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
+			if v, ok := calculators[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
 				aggResp.Interval = v.Interval()
 			}
 
@@ -381,3 +852,242 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 
 	return resp, nil
 }
+
+func statsResponse(v *zincaggregation.StatsCalculator) map[string]interface{} {
+	count := v.Count()
+	metrics := map[string]interface{}{
+		"count": count,
+		"min":   nil,
+		"max":   nil,
+		"avg":   nil,
+		"sum":   v.Sum(),
+	}
+	if count > 0 {
+		metrics["min"] = v.Min()
+		metrics["max"] = v.Max()
+		metrics["avg"] = v.Avg()
+	}
+	if !v.Extended() {
+		return metrics
+	}
+
+	for _, k := range []string{
+		"sum_of_squares", "variance", "variance_population", "variance_sampling",
+		"std_deviation", "std_deviation_population", "std_deviation_sampling",
+	} {
+		metrics[k] = nil
+	}
+	bounds := map[string]interface{}{
+		"upper":            nil,
+		"lower":            nil,
+		"upper_population": nil,
+		"lower_population": nil,
+		"upper_sampling":   nil,
+		"lower_sampling":   nil,
+	}
+	metrics["std_deviation_bounds"] = bounds
+	if count == 0 {
+		return metrics
+	}
+
+	avg := v.Avg()
+	variance := v.Variance()
+	varianceSampling := v.VarianceSampling()
+	stdDeviation := math.Sqrt(variance)
+	stdDeviationSampling := math.Sqrt(varianceSampling)
+	metrics["sum_of_squares"] = v.SumOfSquares()
+	metrics["variance"] = variance
+	metrics["variance_population"] = variance
+	metrics["variance_sampling"] = metricValue(varianceSampling)
+	metrics["std_deviation"] = stdDeviation
+	metrics["std_deviation_population"] = stdDeviation
+	metrics["std_deviation_sampling"] = metricValue(stdDeviationSampling)
+	bounds["upper"] = avg + v.Sigma()*stdDeviation
+	bounds["lower"] = avg - v.Sigma()*stdDeviation
+	bounds["upper_population"] = avg + v.Sigma()*stdDeviation
+	bounds["lower_population"] = avg - v.Sigma()*stdDeviation
+	bounds["upper_sampling"] = metricValue(avg + v.Sigma()*stdDeviationSampling)
+	bounds["lower_sampling"] = metricValue(avg - v.Sigma()*stdDeviationSampling)
+	return metrics
+}
+
+func percentilesResponse(v *zincaggregation.PercentilesCalculator) interface{} {
+	keys := v.Keys()
+	results, ok := v.Results()
+	if v.Keyed() {
+		values := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			values[percentilesKey(key)] = nil
+			if ok {
+				values[percentilesKey(key)] = metricValue(results[i])
+			}
+		}
+		return values
+	}
+
+	values := make([]map[string]interface{}, 0, len(keys))
+	for i, key := range keys {
+		value := map[string]interface{}{"key": key, "value": nil}
+		if ok {
+			value["value"] = metricValue(results[i])
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// percentilesKey formats the key of percentiles in the same way as ES, such as: 1.0, 99.9
+func percentilesKey(key float64) string {
+	s := strconv.FormatFloat(key, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// metricValue returns nil for NaN which can't be marshaled to JSON
+func metricValue(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+func percentilesCompression(aggType string, tdigest *meta.AggregationTDigest) (float64, error) {
+	if tdigest == nil || tdigest.Compression == 0 {
+		return zincaggregation.DefaultPercentilesCompression, nil
+	}
+	if tdigest.Compression < 1 {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation tdigest.compression must be greater than 1", aggType))
+	}
+	return tdigest.Compression, nil
+}
+
+func topHitsResponse(v *zincaggregation.TopHitsCalculator, topHits *meta.AggregationTopHits, mappings *meta.Mappings) *meta.Hits {
+	src := &meta.Source{Enable: true}
+	var sorts search.SortOrder
+	if topHits != nil {
+		if v, ok := topHits.Source.(*meta.Source); ok {
+			src = v
+		}
+		sorts, _ = topHits.Sort.(search.SortOrder)
+	}
+
+	hits := make([]meta.Hit, 0, len(v.Hits()))
+	for _, h := range v.Hits() {
+		hit := meta.Hit{
+			Index: string(h.Fields["_index"]),
+			Type:  "_doc",
+			ID:    string(h.Fields["_id"]),
+			Score: h.Score(),
+		}
+		if value, ok := h.Fields["@timestamp"]; ok {
+			hit.Timestamp, _ = bluge.DecodeDateTime(value)
+		}
+		sourceData := source.Response(src, h.Fields["_source"])
+		if sourceData != nil && src.Enable && len(src.Fields) == 0 {
+			sourceData["@timestamp"] = hit.Timestamp
+		}
+		hit.Source = sourceData
+		if len(sorts) > 0 {
+			hit.Sort = sort.Values(v.Sorts(), h.SortValue(), mappings)
+		}
+		hits = append(hits, hit)
+	}
+
+	return &meta.Hits{
+		Total:    meta.Total{Value: int(v.Total())},
+		MaxScore: v.MaxScore(),
+		Hits:     hits,
+	}
+}
+
+// ipRange converts a range of ip_range aggregation to the range of addresses,
+// the range of mask is from the first address of the block to the address after the block.
+func ipRange(v meta.IPRange) (*zincaggregation.IPRange, error) {
+	r := &zincaggregation.IPRange{Key: v.Key}
+	if v.Mask != "" {
+		if v.From != "" || v.To != "" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[ip_range] aggregation range [mask] can't be used with [from] or [to]")
+		}
+		from, to, err := zutils.ParseCIDR(v.Mask)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[ip_range] aggregation mask parse err: %s", err.Error()))
+		}
+		r.From = from
+		r.To, _ = zutils.NextIP(to)
+		if r.Key == "" {
+			r.Key = v.Mask
+		}
+		return r, nil
+	}
+
+	var err error
+	from, to := "*", "*"
+	if v.From != "" {
+		if r.From, err = zutils.ParseIP(v.From); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[ip_range] aggregation from parse err: %s", err.Error()))
+		}
+		from = zutils.FormatIP(r.From)
+	}
+	if v.To != "" {
+		if r.To, err = zutils.ParseIP(v.To); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[ip_range] aggregation to parse err: %s", err.Error()))
+		}
+		to = zutils.FormatIP(r.To)
+	}
+	if r.Key == "" {
+		r.Key = from + "-" + to
+	}
+	return r, nil
+}
+
+// dateHistogramInterval returns the calendar interval, or the fixed interval in nanoseconds if the calendar interval is empty
+func dateHistogramInterval(aggType, calendarInterval, fixedInterval string) (string, int64, error) {
+	if calendarInterval != "" {
+		switch calendarInterval {
+		case "second", "1s":
+			return "", int64(time.Second), nil
+		case "minute", "1m":
+			return "", int64(time.Minute), nil
+		case "hour", "1h":
+			return "", int64(time.Hour), nil
+		case "day", "1d":
+			return "", int64(time.Hour * 24), nil
+		case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
+			return calendarInterval, 0, nil
+		default:
+			return "", 0, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[%s] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year", aggType),
+			)
+		}
+	}
+	duration, err := zutils.ParseDuration(fixedInterval)
+	if err != nil || duration <= 0 {
+		return "", 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d", aggType))
+	}
+	return "", int64(duration), nil
+}
+
+func checkNumericField(aggType, field string, mappings *meta.Mappings) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "numeric" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", aggType, field, prop.Type),
+		)
+	}
+	return nil
+}
+
+func checkGeoPointField(aggType, field string, mappings *meta.Mappings) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "geo_point" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", aggType, field, prop.Type),
+		)
+	}
+	return nil
+}
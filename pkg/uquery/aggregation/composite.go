@@ -0,0 +1,220 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// DefaultCompositeSize is the default number of buckets returned by composite aggregation
+const DefaultCompositeSize = 10
+
+// compositeRequest returns the composite aggregation of the request of composite aggregation
+func compositeRequest(agg *meta.AggregationComposite, mappings *meta.Mappings) (*zincaggregation.CompositeAggregation, error) {
+	if agg.Size == 0 {
+		agg.Size = DefaultCompositeSize
+	}
+	if agg.Size < 0 || agg.Size > config.Global.MaxResults {
+		return nil, errors.New(
+			errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[composite] aggregation size must be between 1 and %d", config.Global.MaxResults),
+		)
+	}
+	if len(agg.Sources) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[composite] aggregation requires at least one [sources]")
+	}
+
+	sources := make([]*zincaggregation.CompositeSource, 0, len(agg.Sources))
+	names := make(map[string]struct{}, len(agg.Sources))
+	for _, item := range agg.Sources {
+		if len(item) != 1 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation each item of [sources] must have exactly one source")
+		}
+		for name, v := range item {
+			if _, ok := names[name]; ok {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation duplicated source name [%s]", name))
+			}
+			names[name] = struct{}{}
+			src, err := compositeSource(name, v, mappings)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, src)
+		}
+	}
+
+	subreq := zincaggregation.NewCompositeAggregation(sources, agg.Size)
+	if agg.After != nil {
+		after, err := compositeAfter(sources, agg.After)
+		if err != nil {
+			return nil, err
+		}
+		subreq.SetAfter(after)
+	}
+	return subreq, nil
+}
+
+func compositeSource(name string, v meta.AggregationCompositeSource, mappings *meta.Mappings) (*zincaggregation.CompositeSource, error) {
+	var values *meta.AggregationCompositeValues
+	var sourceType string
+	switch {
+	case v.Terms != nil:
+		values, sourceType = v.Terms, "terms"
+	case v.Histogram != nil:
+		values, sourceType = v.Histogram, "histogram"
+	case v.DateHistogram != nil:
+		values, sourceType = v.DateHistogram, "date_histogram"
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation source [%s] must be one of terms, histogram and date_histogram", name))
+	}
+	if values.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation source [%s] requires [field]", name))
+	}
+
+	src := &zincaggregation.CompositeSource{
+		Name:          name,
+		Field:         search.Field(values.Field),
+		MissingBucket: values.MissingBucket,
+	}
+	switch values.Order {
+	case "", "asc":
+	case "desc":
+		src.Desc = true
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation source [%s] unknown order [%s]", name, values.Order))
+	}
+
+	prop, _ := mappings.GetProperty(values.Field)
+	switch sourceType {
+	case "terms":
+		switch prop.Type {
+		case "text", "keyword":
+			src.ValueType = zincaggregation.TextValuesSource
+		case "numeric":
+			src.ValueType = zincaggregation.NumericValuesSource
+		case "bool", "boolean":
+			src.ValueType = zincaggregation.BooleanValuesSource
+		default:
+			return nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] aggregation doesn't support values of type: [%s:[%s]]", values.Field, prop.Type),
+			)
+		}
+	case "histogram":
+		if err := checkNumericField("composite", values.Field, mappings); err != nil {
+			return nil, err
+		}
+		interval, err := zutils.ToFloat64(values.Interval)
+		if err != nil || interval <= 0 {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation histogram source [%s] interval must be greater than 0", name))
+		}
+		src.Interval = interval
+	case "date_histogram":
+		if prop.Type != "date" && prop.Type != "time" {
+			return nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] aggregation doesn't support values of type: [%s:[%s]]", values.Field, prop.Type),
+			)
+		}
+		fixedInterval := values.FixedInterval
+		if values.Interval != nil {
+			fixedInterval, _ = zutils.ToString(values.Interval)
+		}
+		if values.CalendarInterval == "" && fixedInterval == "" {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation calendar_interval or fixed_interval must be set one")
+		}
+		calendarInterval, interval, err := dateHistogramInterval("composite", values.CalendarInterval, fixedInterval)
+		if err != nil {
+			return nil, err
+		}
+		timeZone := time.UTC
+		if values.TimeZone != "" {
+			timeZone, err = zutils.ParseTimeZone(values.TimeZone)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[composite] time_zone parse err %s", err.Error()))
+			}
+		}
+		src.Date = &zincaggregation.CompositeDateInterval{
+			CalendarInterval: calendarInterval,
+			FixedInterval:    interval,
+			TimeZone:         timeZone,
+		}
+	}
+	return src, nil
+}
+
+// compositeAfter converts the values of after to the values of the sources
+func compositeAfter(sources []*zincaggregation.CompositeSource, after map[string]interface{}) ([]interface{}, error) {
+	if len(after) != len(sources) {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[composite] aggregation [after] has different number of values than the number of [sources]")
+	}
+	rv := make([]interface{}, 0, len(sources))
+	for _, src := range sources {
+		v, ok := after[src.Name]
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation [after] has no value of source [%s]", src.Name))
+		}
+		if v == nil {
+			if !src.MissingBucket {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation [after] value of source [%s] is null without missing_bucket", src.Name))
+			}
+			rv = append(rv, nil)
+			continue
+		}
+		var value interface{}
+		var err error
+		switch {
+		case src.Date != nil:
+			var f float64
+			f, err = zutils.ToFloat64(v)
+			value = int64(f)
+		case src.Interval > 0:
+			value, err = zutils.ToFloat64(v)
+		default:
+			switch src.ValueType {
+			case zincaggregation.NumericValuesSource:
+				value, err = zutils.ToFloat64(v)
+			case zincaggregation.BooleanValuesSource:
+				value, err = zutils.ToBool(v)
+			default:
+				value, err = zutils.ToString(v)
+			}
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation invalid [after] value [%v] of source [%s]", v, src.Name))
+		}
+		rv = append(rv, value)
+	}
+	return rv, nil
+}
+
+// compositeKey returns the key of a bucket as a map from the names of the sources to the values
+func compositeKey(sources []*zincaggregation.CompositeSource, key []interface{}) map[string]interface{} {
+	rv := make(map[string]interface{}, len(sources))
+	for i, src := range sources {
+		rv[src.Name] = key[i]
+	}
+	return rv
+}
@@ -0,0 +1,76 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+)
+
+// filtersRequest parses the queries of filters aggregation, keyed is true if the filters are named
+func filtersRequest(
+	agg *meta.AggregationFilters,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+) ([]string, []bluge.Query, bool, error) {
+	var keys []string
+	var values []interface{}
+	var keyed bool
+	switch v := agg.Filters.(type) {
+	case map[string]interface{}:
+		keyed = true
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			values = append(values, v[k])
+		}
+	case []interface{}:
+		keys = zincaggregation.FiltersKeys(len(v))
+		values = v
+	default:
+		return nil, nil, false, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[filters] aggregation doesn't support filters of type: %T", v))
+	}
+	if agg.OtherBucketKey != "" {
+		for _, k := range keys {
+			if k == agg.OtherBucketKey {
+				return nil, nil, false, errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[filters] aggregation other_bucket_key [%s] is the key of a filter", k),
+				)
+			}
+		}
+	}
+
+	queries := make([]bluge.Query, len(values))
+	for i, v := range values {
+		q, err := query.Query(v, mappings, analyzers)
+		if err != nil {
+			return nil, nil, false, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[filters] aggregation failed to parse filter [%s]", keys[i])).Cause(err)
+		}
+		queries[i] = q
+	}
+	return keys, queries, keyed, nil
+}
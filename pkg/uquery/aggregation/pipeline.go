@@ -0,0 +1,967 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// gap policies of pipeline aggregations, a gap is a bucket without the value of buckets_path
+const (
+	GapPolicySkip        = "skip"
+	GapPolicyInsertZeros = "insert_zeros"
+	GapPolicyKeepValues  = "keep_values"
+)
+
+// DefaultMovingAvgWindow is the default window size of moving_avg aggregation
+const DefaultMovingAvgWindow = 5
+
+// Pipeline runs the pipeline aggregations on the response of the other aggregations,
+// aggs are the requests of the aggregations at the same level of resp.
+// The parent pipelines (like derivative) add a value to or filter the buckets of their parent,
+// the sibling pipelines (like max_bucket) add a value next to the multi-bucket aggregation.
+func Pipeline(resp map[string]meta.AggregationResponse, aggs map[string]meta.Aggregations) error {
+	for name, agg := range aggs {
+		if isPipeline(agg) || len(agg.Aggregations) == 0 {
+			continue
+		}
+		r, ok := resp[name]
+		if !ok {
+			continue
+		}
+		switch buckets := r.Buckets.(type) {
+		case []map[string]interface{}:
+			for _, bucket := range buckets {
+				if err := bucketPipeline(bucket, agg.Aggregations); err != nil {
+					return err
+				}
+			}
+			buckets, err := parentPipelines(buckets, agg.Aggregations)
+			if err != nil {
+				return err
+			}
+			r.Buckets = buckets
+		case map[string]interface{}: // keyed buckets
+			for _, bucket := range buckets {
+				if bucket, ok := bucket.(map[string]interface{}); ok {
+					if err := bucketPipeline(bucket, agg.Aggregations); err != nil {
+						return err
+					}
+				}
+			}
+		default: // single bucket aggregations
+			if r.Aggregations == nil {
+				r.Aggregations = make(map[string]meta.AggregationResponse)
+			}
+			if err := Pipeline(r.Aggregations, agg.Aggregations); err != nil {
+				return err
+			}
+		}
+		resp[name] = r
+	}
+
+	for _, name := range pipelineOrder(aggs, isSiblingPipeline) {
+		p, err := newPipeline(name, aggs[name])
+		if err != nil {
+			return err
+		}
+		resp[name] = p.(siblingPipeline).run(resp)
+	}
+	return nil
+}
+
+// bucketPipeline runs the pipeline aggregations of the sub aggregations of a bucket
+func bucketPipeline(bucket map[string]interface{}, aggs map[string]meta.Aggregations) error {
+	resp := make(map[string]meta.AggregationResponse, len(aggs))
+	for name := range aggs {
+		if v, ok := bucket[name].(meta.AggregationResponse); ok {
+			resp[name] = v
+		}
+	}
+	if err := Pipeline(resp, aggs); err != nil {
+		return err
+	}
+	for name, v := range resp {
+		bucket[name] = v
+	}
+	return nil
+}
+
+func parentPipelines(buckets []map[string]interface{}, aggs map[string]meta.Aggregations) ([]map[string]interface{}, error) {
+	for _, name := range pipelineOrder(aggs, isParentPipeline) {
+		p, err := newPipeline(name, aggs[name])
+		if err != nil {
+			return nil, err
+		}
+		buckets = p.(parentPipeline).run(name, buckets)
+	}
+	return buckets, nil
+}
+
+func isPipeline(agg meta.Aggregations) bool {
+	return isParentPipeline(agg) || isSiblingPipeline(agg)
+}
+
+func isParentPipeline(agg meta.Aggregations) bool {
+	return agg.Derivative != nil || agg.CumulativeSum != nil || agg.MovingAvg != nil || agg.MovingFn != nil ||
+		agg.BucketScript != nil || agg.BucketSelector != nil || agg.BucketSort != nil
+}
+
+func isSiblingPipeline(agg meta.Aggregations) bool {
+	return agg.AvgBucket != nil || agg.MaxBucket != nil || agg.SumBucket != nil
+}
+
+// pipelineOrder returns the names of the pipelines selected by filter,
+// a pipeline is after the pipelines used by its buckets_path.
+func pipelineOrder(aggs map[string]meta.Aggregations, filter func(meta.Aggregations) bool) []string {
+	names := make([]string, 0)
+	for name, agg := range aggs {
+		if filter(agg) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	order := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		p, err := newPipeline(name, aggs[name])
+		if err == nil {
+			for _, dep := range p.dependencies() {
+				if agg, ok := aggs[dep]; ok && filter(agg) {
+					visit(dep)
+				}
+			}
+		}
+		order = append(order, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+type pipeline interface {
+	// dependencies returns the names of the aggregations used by buckets_path
+	dependencies() []string
+}
+
+type parentPipeline interface {
+	pipeline
+	run(name string, buckets []map[string]interface{}) []map[string]interface{}
+}
+
+type siblingPipeline interface {
+	pipeline
+	run(resp map[string]meta.AggregationResponse) meta.AggregationResponse
+}
+
+// newPipeline parses the request of a pipeline aggregation
+func newPipeline(name string, agg meta.Aggregations) (pipeline, error) {
+	switch {
+	case agg.Derivative != nil:
+		path, gap, err := parsePipeline("derivative", agg.Derivative)
+		if err != nil {
+			return nil, err
+		}
+		return &derivativePipeline{path: path, gap: gap}, nil
+	case agg.CumulativeSum != nil:
+		path, _, err := parsePipeline("cumulative_sum", agg.CumulativeSum)
+		if err != nil {
+			return nil, err
+		}
+		return &cumulativeSumPipeline{path: path}, nil
+	case agg.MovingAvg != nil:
+		return newMovingAvgPipeline(agg.MovingAvg)
+	case agg.MovingFn != nil:
+		return newMovingFnPipeline(agg.MovingFn)
+	case agg.BucketScript != nil:
+		return newBucketScriptPipeline("bucket_script", agg.BucketScript, false)
+	case agg.BucketSelector != nil:
+		return newBucketScriptPipeline("bucket_selector", agg.BucketSelector, true)
+	case agg.BucketSort != nil:
+		return newBucketSortPipeline(agg.BucketSort)
+	case agg.AvgBucket != nil:
+		return newBucketMetricPipeline("avg_bucket", agg.AvgBucket)
+	case agg.MaxBucket != nil:
+		return newBucketMetricPipeline("max_bucket", agg.MaxBucket)
+	case agg.SumBucket != nil:
+		return newBucketMetricPipeline("sum_bucket", agg.SumBucket)
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation is not a pipeline aggregation", name))
+	}
+}
+
+func parsePipeline(aggType string, agg *meta.AggregationPipeline) (*bucketsPath, string, error) {
+	path, err := parseBucketsPath(aggType, agg.BucketsPath)
+	if err != nil {
+		return nil, "", err
+	}
+	gap, err := parseGapPolicy(aggType, agg.GapPolicy)
+	if err != nil {
+		return nil, "", err
+	}
+	return path, gap, nil
+}
+
+func parseGapPolicy(aggType, policy string) (string, error) {
+	switch policy = strings.ToLower(policy); policy {
+	case "":
+		return GapPolicySkip, nil
+	case GapPolicySkip, GapPolicyInsertZeros, GapPolicyKeepValues:
+		return policy, nil
+	default:
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation unknown gap_policy [%s]", aggType, policy))
+	}
+}
+
+// bucketsPath is the parsed buckets_path: AGG_NAME[>AGG_NAME]*[.METRIC], the agg name can be _count or _key
+type bucketsPath struct {
+	elems  []string
+	metric string
+}
+
+func parseBucketsPath(aggType, s string) (*bucketsPath, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation requires [buckets_path]", aggType))
+	}
+	path := &bucketsPath{elems: strings.Split(s, ">")}
+	last := path.elems[len(path.elems)-1]
+	if i := strings.IndexByte(last, '['); i > 0 && strings.HasSuffix(last, "]") {
+		path.metric = strings.Trim(last[i+1:len(last)-1], `'"`)
+		last = last[:i]
+	} else if i := strings.IndexByte(last, '.'); i > 0 {
+		path.metric = last[i+1:]
+		last = last[:i]
+	}
+	path.elems[len(path.elems)-1] = last
+	for _, elem := range path.elems {
+		if strings.TrimSpace(elem) == "" {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation invalid buckets_path [%s]", aggType, s))
+		}
+	}
+	return path, nil
+}
+
+// value resolves the path in a bucket, ok is false if the value is missing
+func (p *bucketsPath) value(bucket map[string]interface{}) (float64, bool) {
+	return resolveBucketsPath(bucket, p.elems, p.metric)
+}
+
+// gapValue returns the value of the path in the bucket with the gap policy, ok is false if the bucket should be skipped
+func (p *bucketsPath) gapValue(bucket map[string]interface{}, policy string) (float64, bool) {
+	v, ok := p.value(bucket)
+	if ok && !math.IsNaN(v) {
+		return v, true
+	}
+	switch policy {
+	case GapPolicyInsertZeros:
+		return 0, true
+	case GapPolicyKeepValues:
+		return v, ok
+	default:
+		return 0, false
+	}
+}
+
+func resolveBucketsPath(item interface{}, elems []string, metric string) (float64, bool) {
+	if len(elems) == 0 {
+		r, ok := item.(meta.AggregationResponse)
+		if !ok {
+			return 0, false
+		}
+		return metricOf(r, metric)
+	}
+	elem := elems[0]
+	switch item := item.(type) {
+	case map[string]interface{}: // bucket
+		switch elem {
+		case "_count":
+			return pipelineInput(item["doc_count"])
+		case "_key":
+			return pipelineInput(item["key"])
+		}
+		return resolveBucketsPath(item[elem], elems[1:], metric)
+	case meta.AggregationResponse: // single bucket aggregation
+		if elem == "_count" {
+			if item.DocCount == nil {
+				return 0, false
+			}
+			return float64(*item.DocCount), true
+		}
+		sub, ok := item.Aggregations[elem]
+		if !ok {
+			return 0, false
+		}
+		return resolveBucketsPath(sub, elems[1:], metric)
+	default:
+		return 0, false
+	}
+}
+
+// metricOf returns the metric of a metrics aggregation, the empty metric is the value of single-value metrics
+func metricOf(r meta.AggregationResponse, metric string) (float64, bool) {
+	if metric == "" || metric == "value" {
+		if r.Value != nil {
+			return pipelineInput(r.Value)
+		}
+		if v, ok := r.Metrics["value"]; ok {
+			return pipelineInput(v)
+		}
+		return 0, false
+	}
+	if v, ok := r.Metrics[metric]; ok {
+		return pipelineInput(v)
+	}
+	if values, ok := r.Values.(map[string]interface{}); ok {
+		if v, ok := values[metric]; ok {
+			return pipelineInput(v)
+		}
+		if f, err := strconv.ParseFloat(metric, 64); err == nil {
+			if v, ok := values[percentilesKey(f)]; ok {
+				return pipelineInput(v)
+			}
+		}
+	}
+	return 0, false
+}
+
+func pipelineInput(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	f, err := zutils.ToFloat64(v)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// pipelineResponse returns the response of a single-value pipeline, NaN and Inf are returned as null
+func pipelineResponse(v float64) meta.AggregationResponse {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return meta.AggregationResponse{Metrics: map[string]interface{}{"value": nil}}
+	}
+	return meta.AggregationResponse{Value: v}
+}
+
+type derivativePipeline struct {
+	path *bucketsPath
+	gap  string
+}
+
+func (p *derivativePipeline) dependencies() []string {
+	return p.path.elems[:1]
+}
+
+// run adds the difference between the value of a bucket and the previous one, the first bucket has no derivative
+func (p *derivativePipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	var prev float64
+	hasPrev := false
+	for _, bucket := range buckets {
+		v, ok := p.path.gapValue(bucket, p.gap)
+		if !ok {
+			continue
+		}
+		if hasPrev {
+			bucket[name] = pipelineResponse(v - prev)
+		}
+		prev, hasPrev = v, true
+	}
+	return buckets
+}
+
+type cumulativeSumPipeline struct {
+	path *bucketsPath
+}
+
+func (p *cumulativeSumPipeline) dependencies() []string {
+	return p.path.elems[:1]
+}
+
+// run adds the sum of the values of the bucket and the previous buckets, the gaps are treated as zero
+func (p *cumulativeSumPipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	sum := 0.0
+	for _, bucket := range buckets {
+		if v, ok := p.path.gapValue(bucket, GapPolicyInsertZeros); ok && !math.IsNaN(v) {
+			sum += v
+		}
+		bucket[name] = pipelineResponse(sum)
+	}
+	return buckets
+}
+
+type movingAvgPipeline struct {
+	path   *bucketsPath
+	gap    string
+	window int
+	model  func(values []float64) float64
+}
+
+func newMovingAvgPipeline(agg *meta.AggregationMovingAvg) (*movingAvgPipeline, error) {
+	path, gap, err := parsePipeline("moving_avg", &meta.AggregationPipeline{BucketsPath: agg.BucketsPath, GapPolicy: agg.GapPolicy})
+	if err != nil {
+		return nil, err
+	}
+	p := &movingAvgPipeline{path: path, gap: gap, window: agg.Window}
+	if p.window == 0 {
+		p.window = DefaultMovingAvgWindow
+	}
+	if p.window < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_avg] aggregation window must be a positive integer")
+	}
+	switch strings.ToLower(agg.Model) {
+	case "", "simple":
+		p.model = movingUnweightedAvg
+	case "linear":
+		p.model = movingLinearWeightedAvg
+	case "ewma":
+		alpha := 0.3
+		if v, ok := agg.Settings["alpha"]; ok {
+			if alpha, err = zutils.ToFloat64(v); err != nil || alpha < 0 || alpha > 1 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_avg] aggregation alpha must be between 0 and 1")
+			}
+		}
+		p.model = func(values []float64) float64 { return movingEWMA(values, alpha) }
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[moving_avg] aggregation unknown model [%s]", agg.Model))
+	}
+	return p, nil
+}
+
+func (p *movingAvgPipeline) dependencies() []string {
+	return p.path.elems[:1]
+}
+
+// run adds the average of the values of the previous window buckets, the first bucket has no average
+func (p *movingAvgPipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	values := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		v, ok := p.path.gapValue(bucket, p.gap)
+		if !ok {
+			continue
+		}
+		if n := len(values); n > 0 {
+			from := n - p.window
+			if from < 0 {
+				from = 0
+			}
+			bucket[name] = pipelineResponse(p.model(values[from:]))
+		}
+		values = append(values, v)
+	}
+	return buckets
+}
+
+// movingFunctionPattern matches the supported scripts of moving_fn: MovingFunctions.name(values[, args])
+var movingFunctionPattern = regexp.MustCompile(`^(?:return\s+)?MovingFunctions\.(\w+)\(\s*values\s*(?:,\s*(.*?))?\s*\)\s*;?$`)
+
+type movingFnPipeline struct {
+	path   *bucketsPath
+	gap    string
+	window int
+	shift  int
+	fn     func(values []float64) float64
+}
+
+func newMovingFnPipeline(agg *meta.AggregationMovingFn) (*movingFnPipeline, error) {
+	path, gap, err := parsePipeline("moving_fn", &meta.AggregationPipeline{BucketsPath: agg.BucketsPath, GapPolicy: agg.GapPolicy})
+	if err != nil {
+		return nil, err
+	}
+	if agg.Window <= 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_fn] aggregation window must be a positive integer")
+	}
+	script, err := pipelineScript("moving_fn", agg.Script)
+	if err != nil {
+		return nil, err
+	}
+	matches := movingFunctionPattern.FindStringSubmatch(strings.TrimSpace(script.Source))
+	if matches == nil {
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[moving_fn] aggregation script [%s] is not supported, use MovingFunctions.xxx(values)", script.Source),
+		)
+	}
+	p := &movingFnPipeline{path: path, gap: gap, window: agg.Window, shift: agg.Shift}
+	switch matches[1] {
+	case "max":
+		p.fn = movingMax
+	case "min":
+		p.fn = movingMin
+	case "sum":
+		p.fn = movingSum
+	case "unweightedAvg":
+		p.fn = movingUnweightedAvg
+	case "linearWeightedAvg":
+		p.fn = movingLinearWeightedAvg
+	case "stdDev":
+		p.fn = movingStdDev
+	case "ewma":
+		alpha, err := strconv.ParseFloat(matches[2], 64)
+		if err != nil || alpha < 0 || alpha > 1 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_fn] aggregation MovingFunctions.ewma alpha must be between 0 and 1")
+		}
+		p.fn = func(values []float64) float64 { return movingEWMA(values, alpha) }
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[moving_fn] aggregation unknown function [MovingFunctions.%s]", matches[1]))
+	}
+	return p, nil
+}
+
+func (p *movingFnPipeline) dependencies() []string {
+	return p.path.elems[:1]
+}
+
+// run adds the result of the function on the values of the window buckets,
+// the window of a bucket ends before it and is moved forward by shift.
+func (p *movingFnPipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	kept := make([]map[string]interface{}, 0, len(buckets))
+	values := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		if v, ok := p.path.gapValue(bucket, p.gap); ok {
+			kept = append(kept, bucket)
+			values = append(values, v)
+		}
+	}
+	for i, bucket := range kept {
+		from, to := i-p.window+p.shift, i+p.shift
+		if from < 0 {
+			from = 0
+		}
+		if to > len(values) {
+			to = len(values)
+		}
+		if from > to {
+			from = to
+		}
+		bucket[name] = pipelineResponse(p.fn(values[from:to]))
+	}
+	return buckets
+}
+
+func movingMax(values []float64) float64 {
+	rv := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(rv) || v > rv {
+			rv = v
+		}
+	}
+	return rv
+}
+
+func movingMin(values []float64) float64 {
+	rv := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(rv) || v < rv {
+			rv = v
+		}
+	}
+	return rv
+}
+
+func movingSum(values []float64) float64 {
+	rv := 0.0
+	for _, v := range values {
+		rv += v
+	}
+	return rv
+}
+
+func movingUnweightedAvg(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	return movingSum(values) / float64(len(values))
+}
+
+// movingLinearWeightedAvg weights the i-th value by i+1, so the older values have less weight
+func movingLinearWeightedAvg(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum, weights := 0.0, 0.0
+	for i, v := range values {
+		sum += v * float64(i+1)
+		weights += float64(i + 1)
+	}
+	return sum / weights
+}
+
+func movingStdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	avg := movingUnweightedAvg(values)
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - avg) * (v - avg)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// movingEWMA is the exponentially weighted moving average, alpha is the weight of the newer value
+func movingEWMA(values []float64, alpha float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	rv := values[0]
+	for _, v := range values[1:] {
+		rv = alpha*v + (1-alpha)*rv
+	}
+	return rv
+}
+
+// pipelineScript returns the script of a pipeline, the script is a string or an object
+func pipelineScript(aggType string, v interface{}) (*meta.Script, error) {
+	switch v := v.(type) {
+	case string:
+		return &meta.Script{Source: v}, nil
+	case map[string]interface{}:
+		script := new(meta.Script)
+		script.Source, _ = v["source"].(string)
+		script.Lang, _ = v["lang"].(string)
+		script.Params, _ = v["params"].(map[string]interface{})
+		return script, nil
+	case *meta.Script:
+		return v, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation requires [script]", aggType))
+	}
+}
+
+type bucketScriptPipeline struct {
+	paths    map[string]*bucketsPath
+	gap      string
+	script   *query.Script
+	selector bool
+}
+
+func newBucketScriptPipeline(aggType string, agg *meta.AggregationBucketScript, selector bool) (*bucketScriptPipeline, error) {
+	if len(agg.BucketsPath) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation requires [buckets_path]", aggType))
+	}
+	p := &bucketScriptPipeline{paths: make(map[string]*bucketsPath, len(agg.BucketsPath)), selector: selector}
+	var err error
+	for name, v := range agg.BucketsPath {
+		if p.paths[name], err = parseBucketsPath(aggType, v); err != nil {
+			return nil, err
+		}
+	}
+	if p.gap, err = parseGapPolicy(aggType, agg.GapPolicy); err != nil {
+		return nil, err
+	}
+	script, err := pipelineScript(aggType, agg.Script)
+	if err != nil {
+		return nil, err
+	}
+	if p.script, err = query.CompileScript(script); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *bucketScriptPipeline) dependencies() []string {
+	rv := make([]string, 0, len(p.paths))
+	for _, path := range p.paths {
+		rv = append(rv, path.elems[0])
+	}
+	return rv
+}
+
+// run adds the result of the script to the buckets or removes the buckets the script returns false for bucket_selector,
+// the values of buckets_path are the params of the script, the buckets with gaps are skipped.
+func (p *bucketScriptPipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	rv := buckets[:0]
+	for _, bucket := range buckets {
+		params := make(map[string]float64, len(p.paths))
+		skip := false
+		for k, path := range p.paths {
+			v, ok := path.gapValue(bucket, p.gap)
+			if !ok {
+				skip = true
+				break
+			}
+			params[k] = v
+		}
+		if !skip {
+			v, ok := p.script.Eval(params, nil, 0)
+			switch {
+			case !ok:
+			case p.selector:
+				if v == 0 {
+					continue
+				}
+			default:
+				bucket[name] = pipelineResponse(v)
+			}
+		}
+		rv = append(rv, bucket)
+	}
+	return rv
+}
+
+type bucketSort struct {
+	path *bucketsPath
+	desc bool
+}
+
+type bucketSortPipeline struct {
+	sorts []*bucketSort
+	from  int
+	size  int
+	gap   string
+}
+
+func newBucketSortPipeline(agg *meta.AggregationBucketSort) (*bucketSortPipeline, error) {
+	gap, err := parseGapPolicy("bucket_sort", agg.GapPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if agg.From < 0 || agg.Size < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[bucket_sort] aggregation from and size must be non-negative")
+	}
+	p := &bucketSortPipeline{from: agg.From, size: agg.Size, gap: gap}
+
+	var sorts []interface{}
+	switch v := agg.Sort.(type) {
+	case nil:
+	case []interface{}:
+		sorts = v
+	default:
+		sorts = []interface{}{v}
+	}
+	for _, v := range sorts {
+		var field, order string
+		switch v := v.(type) {
+		case string:
+			field = v
+		case map[string]interface{}:
+			if len(v) != 1 {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[bucket_sort] aggregation sort doesn't support multiple fields")
+			}
+			for k, vv := range v {
+				field = k
+				switch vv := vv.(type) {
+				case string:
+					order = vv
+				case map[string]interface{}:
+					order, _ = vv["order"].(string)
+				}
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_sort] aggregation sort doesn't support values of type: %T", v))
+		}
+		path, err := parseBucketsPath("bucket_sort", field)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(order) {
+		case "", "asc":
+			p.sorts = append(p.sorts, &bucketSort{path: path})
+		case "desc":
+			p.sorts = append(p.sorts, &bucketSort{path: path, desc: true})
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[bucket_sort] aggregation unknown order [%s]", order))
+		}
+	}
+	return p, nil
+}
+
+func (p *bucketSortPipeline) dependencies() []string {
+	rv := make([]string, 0, len(p.sorts))
+	for _, s := range p.sorts {
+		rv = append(rv, s.path.elems[0])
+	}
+	return rv
+}
+
+// run sorts the buckets and truncates them by from and size, the buckets without the sort values are the last ones
+func (p *bucketSortPipeline) run(name string, buckets []map[string]interface{}) []map[string]interface{} {
+	if len(p.sorts) > 0 {
+		keys := make(map[*map[string]interface{}][]interface{}, len(buckets))
+		for i := range buckets {
+			values := make([]interface{}, 0, len(p.sorts))
+			for _, s := range p.sorts {
+				values = append(values, p.sortValue(buckets[i], s.path))
+			}
+			keys[&buckets[i]] = values
+		}
+		sortKeys := make([][]interface{}, len(buckets))
+		for i := range buckets {
+			sortKeys[i] = keys[&buckets[i]]
+		}
+		sort.Stable(&bucketSorter{buckets: buckets, keys: sortKeys, sorts: p.sorts})
+	}
+
+	if p.from >= len(buckets) {
+		return buckets[:0]
+	}
+	buckets = buckets[p.from:]
+	if p.size > 0 && p.size < len(buckets) {
+		buckets = buckets[:p.size]
+	}
+	return buckets
+}
+
+// sortValue returns the string key for _key of terms buckets, otherwise the number, nil if missing
+func (p *bucketSortPipeline) sortValue(bucket map[string]interface{}, path *bucketsPath) interface{} {
+	if len(path.elems) == 1 && path.elems[0] == "_key" {
+		if key, ok := bucket["key"].(string); ok {
+			return key
+		}
+	}
+	v, ok := path.gapValue(bucket, p.gap)
+	if !ok || math.IsNaN(v) {
+		return nil
+	}
+	return v
+}
+
+type bucketSorter struct {
+	buckets []map[string]interface{}
+	keys    [][]interface{}
+	sorts   []*bucketSort
+}
+
+func (s *bucketSorter) Len() int {
+	return len(s.buckets)
+}
+
+func (s *bucketSorter) Swap(i, j int) {
+	s.buckets[i], s.buckets[j] = s.buckets[j], s.buckets[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func (s *bucketSorter) Less(i, j int) bool {
+	for k, sort := range s.sorts {
+		a, b := s.keys[i][k], s.keys[j][k]
+		if a == nil || b == nil {
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+			continue
+		}
+		c := 0
+		switch a := a.(type) {
+		case string:
+			c = strings.Compare(a, b.(string))
+		case float64:
+			if bf := b.(float64); a < bf {
+				c = -1
+			} else if a > bf {
+				c = 1
+			}
+		}
+		if c == 0 {
+			continue
+		}
+		if sort.desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+type bucketMetricPipeline struct {
+	aggType string
+	agg     string // name of the multi-bucket aggregation
+	path    *bucketsPath
+	gap     string
+}
+
+func newBucketMetricPipeline(aggType string, agg *meta.AggregationPipeline) (*bucketMetricPipeline, error) {
+	path, gap, err := parsePipeline(aggType, agg)
+	if err != nil {
+		return nil, err
+	}
+	if len(path.elems) < 2 {
+		return nil, errors.New(
+			errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[%s] aggregation buckets_path [%s] must reference a multi-bucket aggregation, like agg>metric", aggType, agg.BucketsPath),
+		)
+	}
+	return &bucketMetricPipeline{
+		aggType: aggType,
+		agg:     path.elems[0],
+		path:    &bucketsPath{elems: path.elems[1:], metric: path.metric},
+		gap:     gap,
+	}, nil
+}
+
+func (p *bucketMetricPipeline) dependencies() []string {
+	return []string{p.agg}
+}
+
+// run computes the avg, max or sum of the values of the buckets of the sibling aggregation
+func (p *bucketMetricPipeline) run(resp map[string]meta.AggregationResponse) meta.AggregationResponse {
+	buckets, _ := resp[p.agg].Buckets.([]map[string]interface{})
+	count, sum, max := 0, 0.0, math.NaN()
+	keys := make([]string, 0)
+	for _, bucket := range buckets {
+		v, ok := p.path.gapValue(bucket, p.gap)
+		if !ok || math.IsNaN(v) {
+			continue
+		}
+		count++
+		sum += v
+		key := bucket["key_as_string"]
+		if key == nil {
+			key = bucket["key"]
+		}
+		keyStr, _ := zutils.ToString(key)
+		switch {
+		case math.IsNaN(max) || v > max:
+			max = v
+			keys = append(keys[:0], keyStr)
+		case v == max:
+			keys = append(keys, keyStr)
+		}
+	}
+
+	switch p.aggType {
+	case "avg_bucket":
+		if count == 0 {
+			return pipelineResponse(math.NaN())
+		}
+		return pipelineResponse(sum / float64(count))
+	case "max_bucket":
+		rv := pipelineResponse(max)
+		if rv.Metrics == nil {
+			rv.Metrics = make(map[string]interface{})
+		}
+		rv.Metrics["keys"] = keys
+		return rv
+	default:
+		return pipelineResponse(sum)
+	}
+}
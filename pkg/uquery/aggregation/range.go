@@ -0,0 +1,194 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// rangeRequest returns the range aggregation of the request of range aggregation
+func rangeRequest(agg *meta.AggregationRange, mappings *meta.Mappings) (*zincaggregation.RangeAggregation, error) {
+	if len(agg.Ranges) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[range] aggregation needs ranges")
+	}
+	prop, _ := mappings.GetProperty(agg.Field)
+	if prop.Type != "numeric" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[range] aggregation only support type numeric")
+	}
+
+	ranges := make([]*zincaggregation.NumericRange, 0, len(agg.Ranges))
+	for _, v := range agg.Ranges {
+		r := &zincaggregation.NumericRange{Key: v.Key, From: math.Inf(-1), To: math.Inf(1)}
+		from, to := "*", "*"
+		if v.From != nil {
+			r.From = *v.From
+			from = strconv.FormatFloat(r.From, 'f', 1, 64)
+		}
+		if v.To != nil {
+			r.To = *v.To
+			to = strconv.FormatFloat(r.To, 'f', 1, 64)
+		}
+		if r.Key == "" {
+			r.Key = from + "-" + to
+		}
+		ranges = append(ranges, r)
+	}
+
+	subreq := zincaggregation.NewRangeAggregation(search.Field(agg.Field), ranges)
+	if agg.Missing != nil {
+		missing, err := zutils.ToFloat64(agg.Missing)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[range] aggregation invalid missing [%v]", agg.Missing))
+		}
+		subreq.SetMissing(missing)
+	}
+	return subreq, nil
+}
+
+// dateRangeRequest returns the range aggregation of the request of date_range aggregation,
+// the format of the field is set to the request if the request has no format.
+func dateRangeRequest(agg *meta.AggregationDateRange, mappings *meta.Mappings) (*zincaggregation.RangeAggregation, error) {
+	if len(agg.Ranges) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation needs ranges")
+	}
+	prop, _ := mappings.GetProperty(agg.Field)
+	if prop.Type != "date" && prop.Type != "time" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation only support type datetime")
+	}
+	if agg.Format == "" {
+		agg.Format = prop.Format
+	}
+	if agg.Format == "" {
+		agg.Format = time.RFC3339
+	}
+	timeZone := time.UTC
+	if agg.TimeZone != "" {
+		var err error
+		if timeZone, err = zutils.ParseTimeZone(agg.TimeZone); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[date_range] time_zone parse err %s", err.Error()))
+		}
+	}
+
+	now := time.Now()
+	ranges := make([]*zincaggregation.NumericRange, 0, len(agg.Ranges))
+	for _, v := range agg.Ranges {
+		r := &zincaggregation.NumericRange{Key: v.Key, From: math.Inf(-1), To: math.Inf(1)}
+		from, to := "*", "*"
+		if v.From != nil && v.From != "" {
+			t, err := zutils.ParseDateMath(v.From, agg.Format, agg.TimeZone, now)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] range value from parse err %s", err.Error()))
+			}
+			r.From = float64(t.UnixMilli())
+			from = formatDate(t, agg.Format, timeZone)
+		}
+		if v.To != nil && v.To != "" {
+			t, err := zutils.ParseDateMath(v.To, agg.Format, agg.TimeZone, now)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] range value to parse err %s", err.Error()))
+			}
+			r.To = float64(t.UnixMilli())
+			to = formatDate(t, agg.Format, timeZone)
+		}
+		if r.Key == "" {
+			r.Key = from + "-" + to
+		}
+		ranges = append(ranges, r)
+	}
+
+	subreq := zincaggregation.NewDateRangeAggregation(search.Field(agg.Field), ranges)
+	if agg.Missing != nil {
+		t, err := zutils.ParseDateMath(agg.Missing, agg.Format, agg.TimeZone, now)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] missing parse err %s", err.Error()))
+		}
+		subreq.SetMissing(float64(t.UnixMilli()))
+	}
+	return subreq, nil
+}
+
+// formatDate formats the date in the time zone, the format can be epoch_millis
+func formatDate(t time.Time, format string, timeZone *time.Location) string {
+	if format == "epoch_millis" {
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.In(timeZone).Format(format)
+}
+
+// rangeResponse returns the response of range and date_range aggregations
+func rangeResponse(v *zincaggregation.RangeCalculator, agg meta.Aggregations, mappings *meta.Mappings) (meta.AggregationResponse, error) {
+	keyed := false
+	format, timeZone := "", time.UTC
+	switch {
+	case agg.Range != nil:
+		keyed = agg.Range.Keyed
+	case agg.DateRange != nil:
+		keyed = agg.DateRange.Keyed
+		format = agg.DateRange.Format
+		if agg.DateRange.TimeZone != "" {
+			timeZone, _ = zutils.ParseTimeZone(agg.DateRange.TimeZone)
+		}
+	}
+
+	aggRespBuckets := make([]map[string]interface{}, 0, len(v.Buckets()))
+	aggRespKeyed := make(map[string]interface{}, len(v.Buckets()))
+	for i, bucket := range v.Buckets() {
+		aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+		r := v.Ranges()[i]
+		if !math.IsInf(r.From, 0) {
+			aggBucket["from"] = r.From
+			if v.Date() {
+				aggBucket["from_as_string"] = formatDate(time.UnixMilli(int64(r.From)), format, timeZone)
+			}
+		}
+		if !math.IsInf(r.To, 0) {
+			aggBucket["to"] = r.To
+			if v.Date() {
+				aggBucket["to_as_string"] = formatDate(time.UnixMilli(int64(r.To)), format, timeZone)
+			}
+		}
+		if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+			subResp, err := Response(bucket, agg.Aggregations, mappings)
+			if err != nil {
+				return meta.AggregationResponse{}, err
+			}
+			delete(subResp, "count")
+			for k, v := range subResp {
+				aggBucket[k] = v
+			}
+		}
+		if keyed {
+			aggRespKeyed[r.Key] = aggBucket
+		} else {
+			aggBucket["key"] = r.Key
+			aggRespBuckets = append(aggRespBuckets, aggBucket)
+		}
+	}
+	if keyed {
+		return meta.AggregationResponse{Buckets: aggRespKeyed}, nil
+	}
+	return meta.AggregationResponse{Buckets: aggRespBuckets}, nil
+}
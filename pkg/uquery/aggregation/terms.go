@@ -0,0 +1,418 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/search"
+
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// termsRequest returns the terms aggregation of the request of terms aggregation
+func termsRequest(agg *meta.AggregationsTerms, subaggs map[string]meta.Aggregations, mappings *meta.Mappings) (*zincaggregation.TermsAggregation, error) {
+	if agg.Size == 0 {
+		agg.Size = config.Global.AggregationTermsSize
+	}
+	src, err := termsSource("terms", agg.Field, agg.Missing, mappings)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := termsOrder("terms", agg.Order, subaggs)
+	if err != nil {
+		return nil, err
+	}
+	include, err := termsInclude(agg.Include, agg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	subreq := zincaggregation.NewMultiTermsAggregation([]*zincaggregation.TermsSource{src}, agg.Size).
+		SetOrder(orders...).
+		SetInclude(include)
+	if agg.ShardSize > 0 {
+		subreq.SetShardSize(agg.ShardSize)
+	}
+	if agg.MinDocCount != nil {
+		if *agg.MinDocCount < 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[terms] aggregation min_doc_count must be greater than or equal to 0")
+		}
+		if *agg.MinDocCount == 0 && src.ValueType != zincaggregation.TextValueSource {
+			return nil, errors.New(
+				errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("[terms] aggregation min_doc_count 0 is only supported on keyword and text fields, got [%s]", agg.Field),
+			)
+		}
+		subreq.SetMinDocCount(*agg.MinDocCount)
+	}
+	return subreq, nil
+}
+
+// multiTermsRequest returns the terms aggregation of the request of multi_terms aggregation
+func multiTermsRequest(agg *meta.AggregationMultiTerms, subaggs map[string]meta.Aggregations, mappings *meta.Mappings) (*zincaggregation.TermsAggregation, error) {
+	if len(agg.Terms) < 2 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[multi_terms] aggregation requires at least two [terms]")
+	}
+	if agg.Size == 0 {
+		agg.Size = config.Global.AggregationTermsSize
+	}
+	sources := make([]*zincaggregation.TermsSource, 0, len(agg.Terms))
+	for _, term := range agg.Terms {
+		src, err := termsSource("multi_terms", term.Field, term.Missing, mappings)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	orders, err := termsOrder("multi_terms", agg.Order, subaggs)
+	if err != nil {
+		return nil, err
+	}
+
+	subreq := zincaggregation.NewMultiTermsAggregation(sources, agg.Size).SetOrder(orders...)
+	if agg.ShardSize > 0 {
+		subreq.SetShardSize(agg.ShardSize)
+	}
+	if agg.MinDocCount != nil {
+		if *agg.MinDocCount < 1 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[multi_terms] aggregation min_doc_count must be greater than 0")
+		}
+		subreq.SetMinDocCount(*agg.MinDocCount)
+	}
+	return subreq, nil
+}
+
+// DefaultSignificantTermsSize is the default number of buckets returned by significant_terms aggregation
+const DefaultSignificantTermsSize = 10
+
+// MaxRareTermsDocCount is the maximum max_doc_count of rare_terms aggregation
+const MaxRareTermsDocCount = 100
+
+// significantTermsRequest returns the significant_terms aggregation of the request of significant_terms aggregation
+func significantTermsRequest(agg *meta.AggregationSignificantTerms, mappings *meta.Mappings) (*zincaggregation.SignificantTermsAggregation, error) {
+	if agg.Size == 0 {
+		agg.Size = DefaultSignificantTermsSize
+	}
+	src, err := termsSource("significant_terms", agg.Field, nil, mappings)
+	if err != nil {
+		return nil, err
+	}
+	include, err := termsInclude(agg.Include, agg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var heuristic zincaggregation.SignificanceHeuristic
+	switch {
+	case agg.ChiSquare != nil && agg.MutualInformation != nil, agg.JLH != nil && (agg.ChiSquare != nil || agg.MutualInformation != nil):
+		return nil, errors.New(errors.ErrorTypeParsingException, "[significant_terms] aggregation supports only one significance heuristic")
+	case agg.ChiSquare != nil:
+		heuristic = zincaggregation.ChiSquare(agg.ChiSquare.IncludeNegatives, backgroundIsSuperset(agg.ChiSquare))
+	case agg.MutualInformation != nil:
+		heuristic = zincaggregation.MutualInformation(agg.MutualInformation.IncludeNegatives, backgroundIsSuperset(agg.MutualInformation))
+	default:
+		heuristic = zincaggregation.JLH()
+	}
+
+	subreq := zincaggregation.NewSignificantTermsAggregation(src, agg.Size).
+		SetInclude(include).
+		SetHeuristic(heuristic)
+	if agg.MinDocCount != nil {
+		if *agg.MinDocCount < 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[significant_terms] aggregation min_doc_count must be greater than or equal to 0")
+		}
+		subreq.SetMinDocCount(*agg.MinDocCount)
+	}
+	return subreq, nil
+}
+
+func backgroundIsSuperset(h *meta.AggregationSignificanceHeuristic) bool {
+	return h.BackgroundIsSuperset == nil || *h.BackgroundIsSuperset
+}
+
+// rareTermsRequest returns the rare_terms aggregation of the request of rare_terms aggregation
+func rareTermsRequest(agg *meta.AggregationRareTerms, mappings *meta.Mappings) (*zincaggregation.RareTermsAggregation, error) {
+	if agg.MaxDocCount == 0 {
+		agg.MaxDocCount = 1
+	}
+	if agg.MaxDocCount < 0 || agg.MaxDocCount > MaxRareTermsDocCount {
+		return nil, errors.New(
+			errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[rare_terms] aggregation max_doc_count must be between 1 and %d", MaxRareTermsDocCount),
+		)
+	}
+	src, err := termsSource("rare_terms", agg.Field, agg.Missing, mappings)
+	if err != nil {
+		return nil, err
+	}
+	include, err := termsInclude(agg.Include, agg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return zincaggregation.NewRareTermsAggregation(src, agg.MaxDocCount).SetInclude(include), nil
+}
+
+func termsSource(aggType, field string, missing interface{}, mappings *meta.Mappings) (*zincaggregation.TermsSource, error) {
+	src := &zincaggregation.TermsSource{Field: search.Field(field)}
+	prop, _ := mappings.GetProperty(field)
+	var err error
+	var term string
+	switch prop.Type {
+	case "text", "keyword":
+		src.ValueType = zincaggregation.TextValueSource
+		if missing != nil {
+			term, err = zutils.ToString(missing)
+		}
+	case "numeric":
+		src.ValueType = zincaggregation.NumericValueSource
+		if missing != nil {
+			var f float64
+			f, err = zutils.ToFloat64(missing)
+			term = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case "bool", "boolean":
+		src.ValueType = zincaggregation.BooleanValueSource
+		if missing != nil {
+			var b bool
+			b, err = zutils.ToBool(missing)
+			term = strconv.FormatBool(b)
+		}
+	default:
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", aggType, field, prop.Type),
+		)
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation invalid missing [%v] for field [%s]", aggType, missing, field))
+	}
+	if missing != nil {
+		src.Missing = &term
+	}
+	return src, nil
+}
+
+// termsOrder parses the order of terms aggregation, it is an object or an array of objects,
+// the key is _count, _key or the path of a metrics sub aggregation like agg.metric
+func termsOrder(aggType string, v interface{}, subaggs map[string]meta.Aggregations) ([]*zincaggregation.TermsOrder, error) {
+	var items []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		items = []interface{}{v}
+	case map[string]string:
+		for k, vv := range v {
+			items = append(items, map[string]interface{}{k: vv})
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation order doesn't support values of type: %T", aggType, v))
+	}
+
+	orders := make([]*zincaggregation.TermsOrder, 0, len(items))
+	for _, item := range items {
+		item, ok := item.(map[string]interface{})
+		if !ok || len(item) != 1 {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation order must be an object with one field", aggType))
+		}
+		for key, dir := range item {
+			order := new(zincaggregation.TermsOrder)
+			dirStr, _ := dir.(string)
+			switch strings.ToLower(dirStr) {
+			case "asc":
+			case "desc":
+				order.Desc = true
+			default:
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation unknown order direction [%v]", aggType, dir))
+			}
+			switch key {
+			case "_count":
+				order.Compare = zincaggregation.CompareBucketCount
+				order.Count = true
+			case "_key", "_term":
+				order.Compare = zincaggregation.CompareBucketKey(false)
+			default:
+				path, err := parseBucketsPath(aggType, key)
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := subaggs[path.elems[0]]; !ok {
+					return nil, errors.New(
+						errors.ErrorTypeIllegalArgumentException,
+						fmt.Sprintf("[%s] aggregation invalid order path [%s], sub aggregation [%s] not found", aggType, key, path.elems[0]),
+					)
+				}
+				order.Compare = func(a, b *search.Bucket) int {
+					return compareOrderValue(bucketOrderValue(a, path.elems, path.metric), bucketOrderValue(b, path.elems, path.metric))
+				}
+			}
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// bucketOrderValue returns the value of the metrics sub aggregation of the bucket, NaN if it has no value
+func bucketOrderValue(bucket *search.Bucket, elems []string, metric string) float64 {
+	if elems[0] == "_count" {
+		return float64(bucket.Count())
+	}
+	calc, ok := bucket.Aggregations()[elems[0]]
+	if !ok {
+		return math.NaN()
+	}
+	if len(elems) > 1 {
+		if v, ok := calc.(zincaggregation.SingleBucketCalculator); ok {
+			return bucketOrderValue(v.Bucket(), elems[1:], metric)
+		}
+		return math.NaN()
+	}
+
+	switch v := calc.(type) {
+	case *zincaggregation.StatsCalculator:
+		if f, err := zutils.ToFloat64(statsResponse(v)[metric]); err == nil {
+			return f
+		}
+	case *zincaggregation.PercentilesCalculator:
+		key, err := strconv.ParseFloat(metric, 64)
+		results, ok := v.Results()
+		if err != nil || !ok {
+			return math.NaN()
+		}
+		for i, k := range v.Keys() {
+			if k == key {
+				return results[i]
+			}
+		}
+	case zincaggregation.SingleBucketCalculator:
+		return float64(v.Bucket().Count())
+	case search.MetricCalculator:
+		if metric == "" || metric == "value" {
+			return v.Value()
+		}
+	}
+	return math.NaN()
+}
+
+// compareOrderValue compares the values of sub aggregations, NaN is less than any value
+func compareOrderValue(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a) || a < b:
+		return -1
+	case math.IsNaN(b) || a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// termsInclude returns the filter of terms by include and exclude,
+// they can be a regexp or a list of terms, include can also be a partition like {"partition": 0, "num_partitions": 10}
+func termsInclude(include, exclude interface{}) (func(string) bool, error) {
+	if include == nil && exclude == nil {
+		return nil, nil
+	}
+	includeFn, err := termsMatcher("include", include)
+	if err != nil {
+		return nil, err
+	}
+	excludeFn, err := termsMatcher("exclude", exclude)
+	if err != nil {
+		return nil, err
+	}
+	return func(term string) bool {
+		if includeFn != nil && !includeFn(term) {
+			return false
+		}
+		return excludeFn == nil || !excludeFn(term)
+	}, nil
+}
+
+func termsMatcher(name string, v interface{}) (func(string) bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] aggregation invalid %s regexp [%s]: %s", name, v, err.Error()))
+		}
+		return re.MatchString, nil
+	case []interface{}:
+		terms := make(map[string]struct{}, len(v))
+		for _, term := range v {
+			s, err := zutils.ToString(term)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] aggregation invalid %s term [%v]", name, term))
+			}
+			terms[s] = struct{}{}
+		}
+		return func(term string) bool {
+			_, ok := terms[term]
+			return ok
+		}, nil
+	case map[string]interface{}:
+		if name != "include" {
+			break
+		}
+		partition, err1 := zutils.ToInt(v["partition"])
+		numPartitions, err2 := zutils.ToInt(v["num_partitions"])
+		if err1 != nil || err2 != nil || numPartitions <= 0 || partition < 0 || partition >= numPartitions {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[terms] aggregation include partition requires [partition] in [0, num_partitions)")
+		}
+		return func(term string) bool {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(term))
+			return int(h.Sum32()%uint32(numPartitions)) == partition
+		}, nil
+	}
+	return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation %s doesn't support values of type: %T", name, v))
+}
+
+// multiTermsKey returns the key of a bucket of multi_terms aggregation as the list of terms and the string joined by |
+func multiTermsKey(sources []*zincaggregation.TermsSource, name string) ([]interface{}, string) {
+	terms := strings.Split(name, zincaggregation.MultiTermsSeparator)
+	keys := make([]interface{}, len(terms))
+	for i, term := range terms {
+		keys[i] = term
+		if i >= len(sources) {
+			continue
+		}
+		switch sources[i].ValueType {
+		case zincaggregation.NumericValueSource, zincaggregation.NumericValuesSource:
+			if f, err := strconv.ParseFloat(term, 64); err == nil {
+				keys[i] = f
+			}
+		case zincaggregation.BooleanValueSource, zincaggregation.BooleanValuesSource:
+			keys[i] = term == "true"
+		}
+	}
+	return keys, strings.Join(terms, "|")
+}
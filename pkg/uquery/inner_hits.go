@@ -0,0 +1,122 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package uquery
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// DefaultInnerHitsSize is the default number of inner hits returned for each hit
+const DefaultInnerHitsSize = 3
+
+// InnerHits is the request of inner hits of a nested query
+type InnerHits struct {
+	Name  string
+	Path  string
+	From  int
+	Size  int
+	Query bluge.Query // matches the hidden nested documents of the path
+}
+
+// ParseInnerHits returns the inner hits requests of the nested queries in the query DSL
+func ParseInnerHits(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) ([]*InnerHits, error) {
+	if q.Query == nil || mappings == nil || len(mappings.ListNestedPath()) == 0 {
+		return nil, nil
+	}
+
+	// convert the query to map
+	data, err := json.Marshal(q.Query)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+	}
+	var v interface{}
+	if err = json.Unmarshal(data, &v); err != nil {
+		return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+	}
+
+	innerHits := make([]*InnerHits, 0)
+	if err := parseInnerHits(v, mappings, analyzers, &innerHits); err != nil {
+		return nil, err
+	}
+	return innerHits, nil
+}
+
+func parseInnerHits(v interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, innerHits *[]*InnerHits) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			if nested, ok := vv.(map[string]interface{}); ok && k == "nested" && nested["inner_hits"] != nil {
+				ih, err := parseNestedInnerHits(nested, mappings, analyzers)
+				if err != nil {
+					return err
+				}
+				if ih != nil {
+					*innerHits = append(*innerHits, ih)
+				}
+			}
+			if err := parseInnerHits(vv, mappings, analyzers, innerHits); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, vv := range v {
+			if err := parseInnerHits(vv, mappings, analyzers, innerHits); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseNestedInnerHits(nested map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*InnerHits, error) {
+	path, _ := nested["path"].(string)
+	if prop, _ := mappings.GetProperty(path); prop.Type != "nested" {
+		return nil, nil // ignore_unmapped
+	}
+	subq, _ := nested["query"].(map[string]interface{})
+
+	data, _ := json.Marshal(nested["inner_hits"])
+	value := new(meta.InnerHits)
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[inner_hits] failed to parse field: %s", err.Error()))
+	}
+	if value.Name == "" {
+		value.Name = path
+	}
+	if value.Size == 0 {
+		value.Size = DefaultInnerHitsSize
+	}
+
+	childQuery, err := query.NestedDocumentsQuery(path, subq, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	return &InnerHits{
+		Name:  value.Name,
+		Path:  path,
+		From:  value.From,
+		Size:  value.Size,
+		Query: childQuery,
+	}, nil
+}
@@ -64,6 +64,9 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			} else {
 				return nil, err
 			}
+			if propType, ok := prop["type"].(string); ok && strings.ToLower(propType) == "nested" {
+				mappings.SetProperty(field, meta.NewProperty("nested"))
+			}
 
 			continue
 		}
@@ -107,7 +110,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				p := meta.NewProperty("keyword")
 				newProp.AddField("keyword", p)
 			}
-		case "keyword", "numeric", "bool", "date":
+		case "keyword", "numeric", "bool", "date", "geo_point", "ip", "nested":
 			newProp = meta.NewProperty(propTypeStr)
 		case "constant_keyword":
 			newProp = meta.NewProperty("keyword")
@@ -119,7 +122,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			newProp = meta.NewProperty("bool")
 		case "time", "datetime":
 			newProp = meta.NewProperty("date")
-		case "flattened", "object", "nested", "wildcard", "byte", "alias", "geo_point", "ip", "ip_range", "scaled_float":
+		case "flattened", "object", "wildcard", "byte", "alias", "ip_range", "scaled_float":
 			// ignore
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[mappings] properties [%s] doesn't support type [%s]", field, propTypeStr))
@@ -16,11 +16,92 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func BoostingQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[boosting] query doesn't support")
+func BoostingQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	var positive, negative bluge.Query
+	negativeBoost := -1.0
+	boost := -1.0
+	var err error
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "positive":
+			// multiple positive queries should all match
+			if positive, err = boostingSubQuery(k, v, mappings, analyzers, false); err != nil {
+				return nil, err
+			}
+		case "negative":
+			// any of multiple negative queries matched will demote the document
+			if negative, err = boostingSubQuery(k, v, mappings, analyzers, true); err != nil {
+				return nil, err
+			}
+		case "negative_boost":
+			if negativeBoost, err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] negative_boost doesn't support values of type: %T", v))
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[boosting] unknown field [%s]", k))
+		}
+	}
+
+	if positive == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [positive] query to be set")
+	}
+	if negative == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [negative] query to be set")
+	}
+	if negativeBoost < 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [negative_boost] to be set to a non-negative value")
+	}
+
+	subq := zincquery.NewBoostingQuery(positive, negative, negativeBoost)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
+}
+
+func boostingSubQuery(k string, v interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, anyMatch bool) (bluge.Query, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		subq, err := Query(v, mappings, analyzers)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] failed to parse field", k)).Cause(err)
+		}
+		return subq, nil
+	case []interface{}:
+		boolQuery := bluge.NewBooleanQuery()
+		for _, vv := range v {
+			q, ok := vv.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] %s doesn't support values of type: %T", k, vv))
+			}
+			subq, err := Query(q, mappings, analyzers)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] failed to parse field", k)).Cause(err)
+			}
+			if anyMatch {
+				boolQuery.AddShould(subq)
+			} else {
+				boolQuery.AddMust(subq)
+			}
+		}
+		return boolQuery, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] %s doesn't support values of type: %T", k, v))
+	}
 }
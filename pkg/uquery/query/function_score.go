@@ -0,0 +1,421 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+func FunctionScoreQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	var subq bluge.Query
+	var functions []interface{}
+	function := make(map[string]interface{})
+	scoreMode := zincquery.ScoreModeMultiply
+	boostMode := zincquery.BoostModeMultiply
+	maxBoost := -1.0
+	minScore := -1.0
+	hasMinScore := false
+	boost := -1.0
+	var err error
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "query":
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] query doesn't support values of type: %T", v))
+			}
+			if subq, err = Query(vv, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[query] failed to parse field").Cause(err)
+			}
+		case "functions":
+			var ok bool
+			if functions, ok = v.([]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] functions doesn't support values of type: %T", v))
+			}
+		case "weight", "field_value_factor", "gauss", "exp", "linear", "random_score", "script_score":
+			function[k] = v
+		case "score_mode":
+			scoreMode, _ = zutils.ToString(v)
+			switch scoreMode {
+			case zincquery.ScoreModeMultiply, zincquery.ScoreModeSum, zincquery.ScoreModeAvg,
+				zincquery.ScoreModeFirst, zincquery.ScoreModeMax, zincquery.ScoreModeMin:
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] illegal score_mode [%s]", scoreMode))
+			}
+		case "boost_mode":
+			boostMode, _ = zutils.ToString(v)
+			switch boostMode {
+			case zincquery.BoostModeMultiply, zincquery.BoostModeReplace, zincquery.BoostModeSum,
+				zincquery.BoostModeAvg, zincquery.BoostModeMax, zincquery.BoostModeMin:
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] illegal boost_mode [%s]", boostMode))
+			}
+		case "max_boost":
+			if maxBoost, err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] max_boost doesn't support values of type: %T", v))
+			}
+		case "min_score":
+			if minScore, err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] min_score doesn't support values of type: %T", v))
+			}
+			hasMinScore = true
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] unknown field [%s]", k))
+		}
+	}
+
+	if len(function) > 0 {
+		if len(functions) > 0 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[function_score] already found [functions] array, now encountering a single function")
+		}
+		functions = append(functions, function)
+	}
+
+	if subq == nil {
+		subq = bluge.NewMatchAllQuery()
+	}
+	fsq := zincquery.NewFunctionScoreQuery(subq)
+	for _, v := range functions {
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] function doesn't support values of type: %T", v))
+		}
+		filter, fn, weight, err := scoreFunction(vv, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		fsq.AddFunction(filter, fn, weight)
+	}
+	fsq.SetScoreMode(scoreMode)
+	fsq.SetBoostMode(boostMode)
+	if maxBoost >= 0 {
+		fsq.SetMaxBoost(maxBoost)
+	}
+	if hasMinScore {
+		fsq.SetMinScore(minScore)
+	}
+	if boost >= 0 {
+		fsq.SetBoost(boost)
+	}
+
+	return fsq, nil
+}
+
+// scoreFunction parses a function of function_score query
+func scoreFunction(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, zincquery.ScoreFunction, float64, error) {
+	var filter bluge.Query
+	var fn zincquery.ScoreFunction
+	weight := 1.0
+	hasWeight := false
+	var err error
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "filter":
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, nil, 0, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] filter doesn't support values of type: %T", v))
+			}
+			if filter, err = Query(vv, mappings, analyzers); err != nil {
+				return nil, nil, 0, errors.New(errors.ErrorTypeXContentParseException, "[filter] failed to parse field").Cause(err)
+			}
+			continue
+		case "weight":
+			if weight, err = zutils.ToFloat64(v); err != nil {
+				return nil, nil, 0, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] weight doesn't support values of type: %T", v))
+			}
+			hasWeight = true
+			continue
+		}
+
+		if fn != nil {
+			return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] failed to parse function [%s], only one function is allowed", k))
+		}
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil, 0, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[function_score] %s doesn't support values of type: %T", k, v))
+		}
+		switch k {
+		case "field_value_factor":
+			fn, err = fieldValueFactorFunction(vv, mappings)
+		case zincquery.DecayGauss, zincquery.DecayExp, zincquery.DecayLinear:
+			fn, err = decayFunction(k, vv, mappings)
+		case "random_score":
+			fn, err = randomScoreFunction(vv)
+		case "script_score":
+			fn, err = scriptScoreFunction(vv, mappings)
+		default:
+			return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] unknown function [%s]", k))
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	if fn == nil && !hasWeight {
+		return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, "[function_score] function requires a score function or [weight] to be set")
+	}
+	return filter, fn, weight, nil
+}
+
+func fieldValueFactorFunction(query map[string]interface{}, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	value := new(meta.FieldValueFactorFunction)
+	value.Factor = 1.0
+	value.Modifier = zincquery.FieldValueFactorModifierNone
+	var err error
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			value.Field, _ = zutils.ToString(v)
+		case "factor":
+			if value.Factor, err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[field_value_factor] factor doesn't support values of type: %T", v))
+			}
+		case "modifier":
+			value.Modifier, _ = zutils.ToString(v)
+			switch value.Modifier {
+			case zincquery.FieldValueFactorModifierNone, zincquery.FieldValueFactorModifierLog, zincquery.FieldValueFactorModifierLog1p,
+				zincquery.FieldValueFactorModifierLog2p, zincquery.FieldValueFactorModifierLn, zincquery.FieldValueFactorModifierLn1p,
+				zincquery.FieldValueFactorModifierLn2p, zincquery.FieldValueFactorModifierSquare, zincquery.FieldValueFactorModifierSqrt,
+				zincquery.FieldValueFactorModifierReciprocal:
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] illegal modifier [%s]", value.Modifier))
+			}
+		case "missing":
+			missing, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[field_value_factor] missing doesn't support values of type: %T", v))
+			}
+			value.Missing = &missing
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] unknown field [%s]", k))
+		}
+	}
+
+	if value.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[field_value_factor] requires [field] to be set")
+	}
+	if prop, ok := mappings.GetProperty(value.Field); !ok || prop.Type != "numeric" {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] field [%s] should be a numeric field", value.Field))
+	}
+
+	fn := zincquery.NewFieldValueFactorFunction(value.Field, value.Factor, value.Modifier)
+	if value.Missing != nil {
+		fn.SetMissing(*value.Missing)
+	}
+	return fn, nil
+}
+
+func decayFunction(kind string, query map[string]interface{}, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	field := ""
+	multiValueMode := zincquery.MultiValueModeMin
+	value := new(meta.DecayFunction)
+	value.Decay = 0.5
+	for k, v := range query {
+		if strings.ToLower(k) == "multi_value_mode" {
+			multiValueMode, _ = zutils.ToString(v)
+			switch multiValueMode {
+			case zincquery.MultiValueModeMin, zincquery.MultiValueModeMax, zincquery.MultiValueModeAvg, zincquery.MultiValueModeSum:
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] illegal multi_value_mode [%s]", kind, multiValueMode))
+			}
+			continue
+		}
+		if field != "" {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] query doesn't support multiple fields", kind))
+		}
+		field = k
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] %s doesn't support values of type: %T", kind, k, v))
+		}
+		for k, v := range vv {
+			k := strings.ToLower(k)
+			switch k {
+			case "origin":
+				value.Origin = v
+			case "scale":
+				value.Scale = v
+			case "offset":
+				value.Offset = v
+			case "decay":
+				decay, err := zutils.ToFloat64(v)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] decay doesn't support values of type: %T", kind, v))
+				}
+				value.Decay = decay
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] unknown field [%s]", kind, k))
+			}
+		}
+	}
+
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires a field to be set", kind))
+	}
+	if value.Scale == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires [scale] to be set", kind))
+	}
+	if value.Decay <= 0 || value.Decay >= 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] decay must be in the range (0, 1), got [%v]", kind, value.Decay))
+	}
+
+	var origin, scale, offset float64
+	var err error
+	prop, _ := mappings.GetProperty(field)
+	switch prop.Type {
+	case "numeric":
+		if value.Origin == nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires [origin] to be set for numeric field", kind))
+		}
+		if origin, err = zutils.ToFloat64(value.Origin); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] origin doesn't support values of type: %T", kind, value.Origin))
+		}
+		if scale, err = zutils.ToFloat64(value.Scale); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] scale doesn't support values of type: %T", kind, value.Scale))
+		}
+		if value.Offset != nil {
+			if offset, err = zutils.ToFloat64(value.Offset); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] offset doesn't support values of type: %T", kind, value.Offset))
+			}
+		}
+	case "date", "time":
+		t := time.Now()
+		if value.Origin != nil && value.Origin != "now" {
+			if t, err = zutils.ParseTime(value.Origin, prop.Format, prop.TimeZone); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] origin parse err %s", kind, err.Error()))
+			}
+		}
+		origin = float64(t.UnixNano())
+		if scale, err = decayDuration(value.Scale); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] scale parse err %s", kind, err.Error()))
+		}
+		if value.Offset != nil {
+			if offset, err = decayDuration(value.Offset); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] offset parse err %s", kind, err.Error()))
+			}
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] field [%s] should be a numeric or date field", kind, field))
+	}
+	if scale <= 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] scale must be greater than 0", kind))
+	}
+
+	fn := zincquery.NewDecayFunction(kind, field, prop.Type != "numeric", origin, scale, offset, value.Decay)
+	fn.SetMultiValueMode(multiValueMode)
+	return fn, nil
+}
+
+// decayDuration parses the duration of date decay function in nanoseconds
+func decayDuration(v interface{}) (float64, error) {
+	s, err := zutils.ToString(v)
+	if err != nil {
+		return 0, err
+	}
+	d, err := zutils.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d), nil
+}
+
+func randomScoreFunction(query map[string]interface{}) (zincquery.ScoreFunction, error) {
+	value := new(meta.RandomScoreFunction)
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "seed":
+			value.Seed = v
+		case "field":
+			value.Field, _ = zutils.ToString(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[random_score] unknown field [%s]", k))
+		}
+	}
+
+	seed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if value.Seed != nil {
+		var err error
+		if seed, err = zutils.ToString(value.Seed); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[random_score] seed doesn't support values of type: %T", value.Seed))
+		}
+	}
+	if value.Field == "" {
+		value.Field = "_id"
+	}
+	return zincquery.NewRandomScoreFunction(seed, value.Field), nil
+}
+
+func scriptScoreFunction(query map[string]interface{}, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	value := new(meta.ScriptScoreFunction)
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "script":
+			script, err := parseScript(v)
+			if err != nil {
+				return nil, err
+			}
+			value.Script = script
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script_score] unknown field [%s]", k))
+		}
+	}
+	if value.Script == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[script_score] requires [script] to be set")
+	}
+	return scriptScore(value.Script, mappings)
+}
+
+// scriptScore compiles the script to a score function, the score is 0 if a doc value used by the script is missing
+func scriptScore(value *meta.Script, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	script, err := CompileScript(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range script.Fields() {
+		if prop, ok := mappings.GetProperty(f); !ok || prop.Type != "numeric" {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script_score] script field [%s] should be a numeric field", f))
+		}
+	}
+	fields := script.Fields()
+	return zincquery.NewScoreFunc(fields, func(doc *zincquery.FunctionScoreDoc) float64 {
+		values := make(map[string]float64, len(fields))
+		for _, f := range fields {
+			if v := doc.Numbers(f); len(v) > 0 {
+				values[f] = v[0]
+			}
+		}
+		score, _ := script.Eval(nil, values, doc.Score)
+		return score
+	}), nil
+}
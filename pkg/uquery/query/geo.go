@@ -16,23 +16,217 @@
 package query
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric/geo"
 
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
 func GeoBoundingBoxQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_bounding_box] query doesn't support")
+	field := ""
+	boost := -1.0
+	var box map[string]interface{}
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "validation_method", "type", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query doesn't support multiple fields")
+			}
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_bounding_box] %s doesn't support values of type: %T", k, v))
+			}
+			field = k
+			box = vv
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query requires a field")
+	}
+
+	var top, left, bottom, right float64
+	var hasTop, hasLeft, hasBottom, hasRight bool
+	for k, v := range box {
+		var err error
+		switch strings.ToLower(k) {
+		case "top_left":
+			left, top, err = zutils.ParseGeoPoint(v)
+			hasTop, hasLeft = true, true
+		case "bottom_right":
+			right, bottom, err = zutils.ParseGeoPoint(v)
+			hasBottom, hasRight = true, true
+		case "top_right":
+			right, top, err = zutils.ParseGeoPoint(v)
+			hasTop, hasRight = true, true
+		case "bottom_left":
+			left, bottom, err = zutils.ParseGeoPoint(v)
+			hasBottom, hasLeft = true, true
+		case "top":
+			top, err = zutils.ToFloat64(v)
+			hasTop = true
+		case "left":
+			left, err = zutils.ToFloat64(v)
+			hasLeft = true
+		case "bottom":
+			bottom, err = zutils.ToFloat64(v)
+			hasBottom = true
+		case "right":
+			right, err = zutils.ToFloat64(v)
+			hasRight = true
+		case "wkt":
+			s, _ := v.(string)
+			left, right, top, bottom, err = parseWKTBoundingBox(s)
+			hasTop, hasLeft, hasBottom, hasRight = true, true, true, true
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s [%s] parse err: %s", field, k, err.Error()))
+		}
+	}
+	if !hasTop || !hasLeft || !hasBottom || !hasRight {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s requires top, left, bottom and right", field))
+	}
+	if top < bottom {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s top [%v] is below bottom [%v]", field, top, bottom))
+	}
+
+	subq := bluge.NewGeoBoundingBoxQuery(left, top, right, bottom).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
 }
 
 func GeoDistanceQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_distance] query doesn't support")
+	field := ""
+	boost := -1.0
+	distance := ""
+	var lon, lat float64
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "distance":
+			switch v := v.(type) {
+			case string:
+				distance = strings.ReplaceAll(v, " ", "")
+			case float64:
+				distance = strconv.FormatFloat(v, 'f', -1, 64) + "m"
+			default:
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_distance] distance doesn't support values of type: %T", v))
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "distance_type", "validation_method", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query doesn't support multiple fields")
+			}
+			field = k
+			var err error
+			if lon, lat, err = zutils.ParseGeoPoint(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] %s parse err: %s", field, err.Error()))
+			}
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query requires a field")
+	}
+	if distance == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query requires [distance]")
+	}
+	if d, err := geo.ParseDistance(distance); err != nil || d < 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] failed to parse distance [%s]", distance))
+	}
+
+	subq := bluge.NewGeoDistanceQuery(lon, lat, distance).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
 }
 
 func GeoPolygonQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_polygon] query doesn't support")
+	field := ""
+	boost := -1.0
+	var points []geo.Point
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "validation_method", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_polygon] %s doesn't support values of type: %T", k, v))
+			}
+			values, ok := vv["points"].([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] %s [points] should be an array", k))
+			}
+			for _, p := range values {
+				lon, lat, err := zutils.ParseGeoPoint(p)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] %s parse err: %s", k, err.Error()))
+				}
+				points = append(points, geo.Point{Lon: lon, Lat: lat})
+			}
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query requires a field")
+	}
+	if len(points) < 3 {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] %s too few points defined, at least 3 points are required", field))
+	}
+
+	subq := bluge.NewGeoBoundingPolygonQuery(points).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
 }
 
 func GeoShapeQuery(query map[string]interface{}) (bluge.Query, error) {
 	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_shape] query doesn't support")
 }
+
+// parseWKTBoundingBox parses `BBOX (minLon, maxLon, maxLat, minLat)`
+func parseWKTBoundingBox(s string) (left, right, top, bottom float64, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToUpper(s), "BBOX") {
+		return 0, 0, 0, 0, fmt.Errorf("wkt [%s] should be BBOX", s)
+	}
+	s = strings.TrimSpace(s[len("BBOX"):])
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return 0, 0, 0, 0, fmt.Errorf("wkt [%s] is not a valid BBOX", s)
+	}
+	parts := strings.Split(s[1:len(s)-1], ",")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, fmt.Errorf("wkt [%s] is not a valid BBOX", s)
+	}
+	values := make([]float64, 4)
+	for i, p := range parts {
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("wkt [%s] is not a valid BBOX", s)
+		}
+	}
+	return values[0], values[1], values[2], values[3], nil
+}
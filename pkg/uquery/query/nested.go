@@ -0,0 +1,103 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+func NestedQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	var subq map[string]interface{}
+	value := new(meta.NestedQuery)
+	value.ScoreMode = zincquery.NestedScoreModeAvg
+	value.Boost = -1.0
+	var ok bool
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "path":
+			if value.Path, ok = v.(string); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[nested] path doesn't support values of type: %T", v))
+			}
+		case "query":
+			if subq, ok = v.(map[string]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[nested] query doesn't support values of type: %T", v))
+			}
+		case "score_mode":
+			mode, _ := v.(string)
+			switch mode = strings.ToLower(mode); mode {
+			case zincquery.NestedScoreModeAvg, zincquery.NestedScoreModeMax, zincquery.NestedScoreModeMin,
+				zincquery.NestedScoreModeSum, zincquery.NestedScoreModeNone:
+				value.ScoreMode = mode
+			default:
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[nested] illegal score_mode [%v]", v))
+			}
+		case "inner_hits":
+			// handled after search
+		case "ignore_unmapped":
+			if value.IgnoreUnmapped, ok = v.(bool); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[nested] ignore_unmapped doesn't support values of type: %T", v))
+			}
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[nested] unknown field [%s]", k))
+		}
+	}
+
+	if value.Path == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires [path] field")
+	}
+	if subq == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires [query] field")
+	}
+	if prop, _ := mappings.GetProperty(value.Path); prop.Type != "nested" {
+		if value.IgnoreUnmapped {
+			return MatchNoneQuery()
+		}
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[nested] nested object under path [%s] is not of nested type", value.Path))
+	}
+
+	childQuery, err := NestedDocumentsQuery(value.Path, subq, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	nestedQuery := zincquery.NewNestedQuery(childQuery, meta.NestedParentFieldName).SetScoreMode(value.ScoreMode)
+	if value.Boost >= 0 {
+		nestedQuery.SetBoost(value.Boost)
+	}
+	return nestedQuery, nil
+}
+
+// NestedDocumentsQuery returns the query matches the hidden nested documents of the path
+func NestedDocumentsQuery(path string, query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	subq, err := Query(query, mappings, analyzers)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, "[query] failed to parse field").Cause(err)
+	}
+	return bluge.NewBooleanQuery().
+		AddMust(subq).
+		AddMust(bluge.NewTermQuery(path).SetField(meta.NestedPathFieldName).SetBoost(0)), nil
+}
@@ -17,84 +17,515 @@ package query
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/analysis/analyzer"
-	querystr "github.com/blugelabs/query_string"
 
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
 func QueryStringQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
 	value := new(meta.QueryStringQuery)
+	value.Boost = -1.0
+	var ok bool
 	for k, v := range query {
 		k := strings.ToLower(k)
 		switch k {
 		case "query":
-			value.Query = v.(string)
+			if value.Query, ok = v.(string); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] query doesn't support values of type: %T", v))
+			}
 		case "analyzer":
-			value.Analyzer = v.(string)
+			value.Analyzer, _ = zutils.ToString(v)
 		case "fields":
 			if vv, ok := v.([]interface{}); ok {
 				for _, vvv := range vv {
-					value.Fields = append(value.Fields, vvv.(string))
+					field, _ := zutils.ToString(vvv)
+					value.Fields = append(value.Fields, field)
 				}
 			}
 		case "default_field":
-			value.DefaultField = v.(string)
+			value.DefaultField, _ = zutils.ToString(v)
 		case "default_operator":
-			value.DefaultOperator = v.(string)
-		case "boost":
-			value.Boost = v.(float64)
+			value.DefaultOperator, _ = zutils.ToString(v)
 		case "analyze_wildcard":
-			// noop
+			value.AnalyzeWildcard, _ = zutils.ToBool(v)
+		case "lenient":
+			value.Lenient, _ = zutils.ToBool(v)
+		case "allow_leading_wildcard":
+			allow, _ := zutils.ToBool(v)
+			value.AllowLeadingWildcard = &allow
+		case "time_zone":
+			value.TimeZone, _ = zutils.ToString(v)
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
 		default:
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] unsupported children %s", k))
 		}
 	}
 
-	options := querystr.DefaultOptions()
+	b := &queryStringBuilder{
+		value:     value,
+		mappings:  mappings,
+		analyzers: analyzers,
+		operator:  bluge.MatchQueryOperatorOr,
+	}
+	switch op := strings.ToUpper(value.DefaultOperator); op {
+	case "", "OR":
+	case "AND":
+		b.operator = bluge.MatchQueryOperatorAnd
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[query_string] unknown default_operator %s", op))
+	}
+	if value.Analyzer != "" {
+		zer, err := zincanalysis.QueryAnalyzer(analyzers, value.Analyzer)
+		if err != nil {
+			return nil, err
+		}
+		b.analyzer = zer
+	}
+	if value.TimeZone != "" {
+		timeZone, err := zutils.ParseTimeZone(value.TimeZone)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] time_zone parse err %s", err.Error()))
+		}
+		b.timeZone = timeZone
+	}
+	if err := b.setDefaultFields(); err != nil {
+		return nil, err
+	}
+
+	node, err := parseQueryStringSyntax(value.Query, b.operator == bluge.MatchQueryOperatorAnd)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] failed to parse query [%s]: %s", value.Query, err.Error()))
+	}
+	subq, err := b.build(node)
+	if err != nil {
+		return nil, err
+	}
+	return queryStringBoost(subq, value.Boost), nil
+}
+
+// queryStringField is a field to search with the boost of the field, boost is -1 if not set
+type queryStringField struct {
+	name  string
+	boost float64
+}
 
-	// TODO fields
-	// TODO default_field
-	// TODO default_operator
-	// TODO boost
+type queryStringBuilder struct {
+	value         *meta.QueryStringQuery
+	mappings      *meta.Mappings
+	analyzers     map[string]*analysis.Analyzer
+	analyzer      *analysis.Analyzer // overrides the analyzers of the fields
+	operator      bluge.MatchQueryOperator
+	timeZone      *time.Location // overrides the time zones of the fields
+	defaultFields []queryStringField
+}
 
-	zer, _ := zincanalysis.QueryAnalyzer(analyzers, value.Analyzer)
-	if zer == nil {
-		zer = analyzer.NewStandardAnalyzer()
+// setDefaultFields sets the fields of the terms without field by fields or default_field,
+// it searches the _all field by default.
+func (b *queryStringBuilder) setDefaultFields() error {
+	if len(b.value.Fields) == 0 {
+		field := b.value.DefaultField
+		if field == "" || field == "*" {
+			field = "_all"
+		}
+		b.defaultFields = b.expandFields(field, -1)
+		return nil
 	}
-	options.WithDefaultAnalyzer(zer)
 
-	if len(value.Fields) == 0 {
-		for field, prop := range mappings.ListProperty() {
-			if prop.Type == "text" || prop.Type == "keyword" {
-				value.Fields = append(value.Fields, field)
+	for _, field := range b.value.Fields {
+		boost := -1.0
+		if i := strings.LastIndex(field, "^"); i > 0 {
+			v, err := strconv.ParseFloat(field[i+1:], 64)
+			if err != nil {
+				return errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] fields invalid boost of field [%s]", field))
 			}
+			field, boost = field[:i], v
+		}
+		b.defaultFields = append(b.defaultFields, b.expandFields(field, boost)...)
+	}
+	return nil
+}
+
+// expandFields expands the field name with wildcard * to the text and keyword fields in mappings
+func (b *queryStringBuilder) expandFields(field string, boost float64) []queryStringField {
+	if !strings.Contains(field, "*") {
+		return []queryStringField{{name: field, boost: boost}}
+	}
+	pattern := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(field), `\*`, ".*") + "$")
+	fields := make([]queryStringField, 0)
+	if b.mappings == nil {
+		return fields
+	}
+	for name, prop := range b.mappings.ListProperty() {
+		if (prop.Type == "text" || prop.Type == "keyword") && pattern.MatchString(name) {
+			fields = append(fields, queryStringField{name: name, boost: boost})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+	return fields
+}
+
+func (b *queryStringBuilder) build(node qsNode) (bluge.Query, error) {
+	switch node := node.(type) {
+	case *qsBoolNode:
+		return b.buildBool(node)
+	case *qsTermNode:
+		if node.field == qsFieldExists {
+			return b.buildFields(node.text, node.boost, b.buildExists)
+		}
+		if node.wildcard && node.kind == qsTermKindTerm && node.text == "*" {
+			return b.buildFields(node.field, node.boost, b.buildExists)
+		}
+		if node.wildcard && node.kind == qsTermKindTerm && !b.allowLeadingWildcard() &&
+			(strings.HasPrefix(node.text, "*") || strings.HasPrefix(node.text, "?")) {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] leading wildcard is not allowed: %s", node.text))
 		}
+		return b.buildFields(node.field, node.boost, func(field string) (bluge.Query, error) {
+			return b.buildTerm(field, node)
+		})
+	case *qsRangeNode:
+		return b.buildFields(node.field, node.boost, func(field string) (bluge.Query, error) {
+			return b.buildRange(field, node)
+		})
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] unknown clause %T", node))
 	}
-	for _, field := range value.Fields {
-		var zer *analysis.Analyzer
-		prop, _ := mappings.GetProperty(field)
-		if prop.Type == "keyword" {
-			zer = analyzer.NewKeywordAnalyzer()
-		} else {
-			indexZer, searchZer := zincanalysis.QueryAnalyzerForField(analyzers, mappings, field)
-			if zer == nil && searchZer != nil {
-				zer = searchZer
+}
+
+func (b *queryStringBuilder) buildBool(node *qsBoolNode) (bluge.Query, error) {
+	if len(node.clauses) == 0 {
+		return bluge.NewMatchNoneQuery(), nil
+	}
+	if len(node.clauses) == 1 && node.clauses[0].occur != qsOccurMustNot {
+		subq, err := b.build(node.clauses[0].node)
+		if err != nil {
+			return nil, err
+		}
+		return queryStringBoost(subq, node.boost), nil
+	}
+
+	query := bluge.NewBooleanQuery()
+	for _, clause := range node.clauses {
+		subq, err := b.build(clause.node)
+		if err != nil {
+			return nil, err
+		}
+		switch clause.occur {
+		case qsOccurMust:
+			query.AddMust(subq)
+		case qsOccurMustNot:
+			query.AddMustNot(subq)
+		default:
+			query.AddShould(subq)
+		}
+	}
+	if node.boost >= 0 {
+		query.SetBoost(node.boost)
+	}
+	return query, nil
+}
+
+// buildFields builds the query for every field which the clause searches and combines them
+func (b *queryStringBuilder) buildFields(field string, boost float64, fn func(field string) (bluge.Query, error)) (bluge.Query, error) {
+	fields := b.defaultFields
+	if field != "" {
+		fields = b.expandFields(field, -1)
+	}
+	queries := make([]bluge.Query, 0, len(fields))
+	for _, field := range fields {
+		subq, err := fn(field.name)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, queryStringBoost(subq, field.boost))
+	}
+
+	switch len(queries) {
+	case 0:
+		return bluge.NewMatchNoneQuery(), nil
+	case 1:
+		return queryStringBoost(queries[0], boost), nil
+	default:
+		query := bluge.NewBooleanQuery().AddShould(queries...)
+		if boost >= 0 {
+			query.SetBoost(boost)
+		}
+		return query, nil
+	}
+}
+
+func (b *queryStringBuilder) buildTerm(field string, node *qsTermNode) (bluge.Query, error) {
+	prop, typ := b.property(field)
+	switch typ {
+	case "text":
+		zer := b.fieldAnalyzer(field)
+		switch {
+		case node.kind == qsTermKindRegexp:
+			return bluge.NewRegexpQuery(node.text).SetField(field), nil
+		case node.kind == qsTermKindPhrase:
+			return bluge.NewMatchPhraseQuery(node.text).SetField(field).SetAnalyzer(zer).SetSlop(node.slop), nil
+		case node.wildcard:
+			wildcard := node.text
+			if b.value.AnalyzeWildcard {
+				wildcard = analyzeWildcard(zer, wildcard)
 			}
-			if zer == nil && indexZer != nil {
-				zer = indexZer
+			return bluge.NewWildcardQuery(wildcard).SetField(field), nil
+		case node.fuzziness >= 0:
+			return bluge.NewMatchQuery(node.text).SetField(field).SetAnalyzer(zer).
+				SetFuzziness(node.fuzziness).SetOperator(b.operator), nil
+		default:
+			return bluge.NewMatchQuery(node.text).SetField(field).SetAnalyzer(zer).SetOperator(b.operator), nil
+		}
+	case "keyword":
+		switch {
+		case node.kind == qsTermKindRegexp:
+			return bluge.NewRegexpQuery(node.text).SetField(field), nil
+		case node.kind == qsTermKindPhrase:
+			return bluge.NewTermQuery(node.text).SetField(field), nil
+		case node.wildcard:
+			return bluge.NewWildcardQuery(node.text).SetField(field), nil
+		case node.fuzziness >= 0:
+			return bluge.NewFuzzyQuery(node.text).SetField(field).SetFuzziness(node.fuzziness), nil
+		default:
+			return bluge.NewTermQuery(node.text).SetField(field), nil
+		}
+	}
+
+	if node.kind == qsTermKindRegexp || (node.kind == qsTermKindTerm && (node.wildcard || node.fuzziness >= 0)) {
+		return b.failed(fmt.Sprintf("[query_string] field [%s] of type [%s] only supports exact values: %s", field, typ, node.text))
+	}
+	switch typ {
+	case "numeric":
+		v, err := strconv.ParseFloat(node.text, 64)
+		if err != nil {
+			return b.failed(fmt.Sprintf("[query_string] field [%s] convert value to numeric error: %s", field, err.Error()))
+		}
+		return bluge.NewNumericRangeInclusiveQuery(v, v, true, true).SetField(field), nil
+	case "bool":
+		v, err := strconv.ParseBool(node.text)
+		if err != nil {
+			return b.failed(fmt.Sprintf("[query_string] field [%s] convert value to boolean error: %s", field, err.Error()))
+		}
+		return bluge.NewTermQuery(strconv.FormatBool(v)).SetField(field), nil
+	case "date", "time":
+		t, precision, err := b.parseTime(prop, node.text)
+		if err != nil {
+			return b.failed(fmt.Sprintf("[query_string] field [%s] parse date error: %s", field, err.Error()))
+		}
+		if precision > 0 {
+			return bluge.NewDateRangeInclusiveQuery(t, t.Add(precision), true, false).SetField(field), nil
+		}
+		return bluge.NewDateRangeInclusiveQuery(t, t, true, true).SetField(field), nil
+	case "ip":
+		q, err := TermQueryIP(field, &meta.TermQuery{Value: node.text, Boost: -1})
+		if err != nil {
+			return b.failed(fmt.Sprintf("[query_string] field [%s] parse ip error: %s", field, err.Error()))
+		}
+		return q, nil
+	default:
+		return b.failed(fmt.Sprintf("[query_string] field [%s] of type [%s] doesn't support query_string", field, typ))
+	}
+}
+
+func (b *queryStringBuilder) buildRange(field string, node *qsRangeNode) (bluge.Query, error) {
+	if node.min == "" && node.max == "" {
+		return b.buildExists(field)
+	}
+
+	prop, typ := b.property(field)
+	switch typ {
+	case "text", "keyword":
+		return bluge.NewTermRangeInclusiveQuery(node.min, node.max, node.minInclusive, node.maxInclusive).SetField(field), nil
+	case "numeric":
+		min, max := bluge.MinNumeric, bluge.MaxNumeric
+		var err error
+		if node.min != "" {
+			if min, err = strconv.ParseFloat(node.min, 64); err != nil {
+				return b.failed(fmt.Sprintf("[query_string] field [%s] convert value to numeric error: %s", field, err.Error()))
+			}
+		}
+		if node.max != "" {
+			if max, err = strconv.ParseFloat(node.max, 64); err != nil {
+				return b.failed(fmt.Sprintf("[query_string] field [%s] convert value to numeric error: %s", field, err.Error()))
+			}
+		}
+		return bluge.NewNumericRangeInclusiveQuery(min, max, node.minInclusive, node.maxInclusive).SetField(field), nil
+	case "date", "time":
+		// the bounds are rounded by the precision of the value, e.g. [* TO 2022-01-01] includes the whole day
+		var min, max time.Time
+		minInclusive, maxInclusive := node.minInclusive, node.maxInclusive
+		if node.min != "" {
+			t, precision, err := b.parseTime(prop, node.min)
+			if err != nil {
+				return b.failed(fmt.Sprintf("[query_string] field [%s] parse date error: %s", field, err.Error()))
+			}
+			min = t
+			if !minInclusive && precision > 0 {
+				min, minInclusive = t.Add(precision), true
+			}
+		}
+		if node.max != "" {
+			t, precision, err := b.parseTime(prop, node.max)
+			if err != nil {
+				return b.failed(fmt.Sprintf("[query_string] field [%s] parse date error: %s", field, err.Error()))
+			}
+			max = t
+			if maxInclusive && precision > 0 {
+				max, maxInclusive = t.Add(precision), false
+			}
+		}
+		return bluge.NewDateRangeInclusiveQuery(min, max, minInclusive, maxInclusive).SetField(field), nil
+	case "ip":
+		minOp, maxOp := "gt", "lt"
+		if node.minInclusive {
+			minOp = "gte"
+		}
+		if node.maxInclusive {
+			maxOp = "lte"
+		}
+		bounds := make(map[string]interface{}, 2)
+		if node.min != "" {
+			bounds[minOp] = node.min
+		}
+		if node.max != "" {
+			bounds[maxOp] = node.max
+		}
+		q, err := RangeQueryIP(field, bounds)
+		if err != nil {
+			return b.failed(fmt.Sprintf("[query_string] field [%s] parse ip error: %s", field, err.Error()))
+		}
+		return q, nil
+	default:
+		return b.failed(fmt.Sprintf("[query_string] field [%s] of type [%s] doesn't support range", field, typ))
+	}
+}
+
+func (b *queryStringBuilder) buildExists(field string) (bluge.Query, error) {
+	_, typ := b.property(field)
+	switch typ {
+	case "numeric":
+		return bluge.NewNumericRangeInclusiveQuery(-math.MaxFloat64, math.MaxFloat64, true, true).SetField(field), nil
+	case "date", "time":
+		return bluge.NewDateRangeInclusiveQuery(time.Time{}, time.Time{}, true, true).SetField(field), nil
+	default:
+		return bluge.NewWildcardQuery("*").SetField(field), nil
+	}
+}
+
+// property returns the property of the field and its type, the unmapped fields are searched as text
+func (b *queryStringBuilder) property(field string) (meta.Property, string) {
+	if field == "_all" || b.mappings == nil {
+		return meta.Property{}, "text"
+	}
+	prop, ok := b.mappings.GetProperty(field)
+	if !ok {
+		return prop, "text"
+	}
+	return prop, prop.Type
+}
+
+func (b *queryStringBuilder) fieldAnalyzer(field string) *analysis.Analyzer {
+	if b.analyzer != nil {
+		return b.analyzer
+	}
+	if field != "_all" {
+		indexZer, searchZer := zincanalysis.QueryAnalyzerForField(b.analyzers, b.mappings, field)
+		if searchZer != nil {
+			return searchZer
+		}
+		if indexZer != nil {
+			return indexZer
+		}
+	}
+	return analyzer.NewStandardAnalyzer()
+}
+
+// parseTime parses the date value by the format of the field,
+// a date without time is also accepted and its precision is one day.
+func (b *queryStringBuilder) parseTime(prop meta.Property, value string) (time.Time, time.Duration, error) {
+	format := time.RFC3339
+	if prop.Format != "" {
+		format = prop.Format
+	}
+	timeZone := time.UTC
+	if prop.TimeZone != "" {
+		timeZone, _ = zutils.ParseTimeZone(prop.TimeZone)
+	}
+	if b.timeZone != nil {
+		timeZone = b.timeZone
+	}
+
+	if format == "epoch_millis" {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		return time.UnixMilli(int64(v)), 0, nil
+	}
+	t, err := time.ParseInLocation(format, value, timeZone)
+	if err == nil {
+		return t.UTC(), 0, nil
+	}
+	if day, dayErr := time.ParseInLocation("2006-01-02", value, timeZone); dayErr == nil {
+		return day.UTC(), 24 * time.Hour, nil
+	}
+	return time.Time{}, 0, err
+}
+
+// failed returns the error of a clause which doesn't match the type of the field, or ignores it if lenient
+func (b *queryStringBuilder) failed(message string) (bluge.Query, error) {
+	if b.value.Lenient {
+		return bluge.NewMatchNoneQuery(), nil
+	}
+	return nil, errors.New(errors.ErrorTypeIllegalArgumentException, message)
+}
+
+func (b *queryStringBuilder) allowLeadingWildcard() bool {
+	return b.value.AllowLeadingWildcard == nil || *b.value.AllowLeadingWildcard
+}
+
+// analyzeWildcard analyzes the parts of the wildcard between * and ?,
+// the part is kept if the analyzer doesn't produce exactly one token for it.
+func analyzeWildcard(zer *analysis.Analyzer, wildcard string) string {
+	var sb strings.Builder
+	start := 0
+	flush := func(end int) {
+		if part := wildcard[start:end]; part != "" {
+			if tokens := zer.Analyze([]byte(part)); len(tokens) == 1 {
+				part = string(tokens[0].Term)
 			}
+			sb.WriteString(part)
 		}
-		if zer != nil {
-			options.WithAnalyzerForField(field, zer)
+	}
+	for i, c := range wildcard {
+		if c == '*' || c == '?' {
+			flush(i)
+			sb.WriteRune(c)
+			start = i + 1
 		}
 	}
+	flush(len(wildcard))
+	return sb.String()
+}
 
-	return querystr.ParseQueryString(value.Query, options)
+// queryStringBoost applies the boost to the query, boost is ignored if it is negative
+func queryStringBoost(query bluge.Query, boost float64) bluge.Query {
+	if boost < 0 {
+		return query
+	}
+	return bluge.NewBooleanQuery().AddMust(query).SetBoost(boost)
 }
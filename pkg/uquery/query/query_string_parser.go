@@ -0,0 +1,543 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The query string syntax follows the Lucene classic query parser:
+//
+//	term  "phrase"~slop  field:value  field:(group)  (a OR b) AND c
+//	+required  -prohibited  NOT  !  &&  ||  wild?ca*d  /regexp/  fuzzy~2
+//	value^boost  [min TO max]  {min TO max}  field:>=value  _exists_:field
+
+type qsOccur int
+
+const (
+	qsOccurShould qsOccur = iota
+	qsOccurMust
+	qsOccurMustNot
+)
+
+// qsFieldExists is the pseudo field of the exists clause _exists_:field
+const qsFieldExists = "_exists_"
+
+// qsDefaultFuzziness is the edit distance of a fuzzy term without value, e.g. term~
+const qsDefaultFuzziness = 2
+
+type qsNode interface{}
+
+type qsBoolNode struct {
+	clauses []*qsClause
+	boost   float64
+}
+
+type qsClause struct {
+	occur qsOccur
+	node  qsNode
+}
+
+type qsTermKind int
+
+const (
+	qsTermKindTerm qsTermKind = iota
+	qsTermKindPhrase
+	qsTermKindRegexp
+)
+
+type qsTermNode struct {
+	field     string
+	text      string
+	kind      qsTermKind
+	wildcard  bool
+	fuzziness int // -1 if the term is not fuzzy
+	slop      int
+	boost     float64
+}
+
+type qsRangeNode struct {
+	field        string
+	min          string // empty if unbounded
+	max          string // empty if unbounded
+	minInclusive bool
+	maxInclusive bool
+	boost        float64
+}
+
+type qsTokenKind int
+
+const (
+	qsTokenEOF qsTokenKind = iota
+	qsTokenTerm
+	qsTokenPhrase
+	qsTokenRegexp
+	qsTokenRange
+	qsTokenAnd
+	qsTokenOr
+	qsTokenNot
+	qsTokenPlus
+	qsTokenMinus
+	qsTokenLParen
+	qsTokenRParen
+	qsTokenColon
+	qsTokenBoost
+	qsTokenFuzzy
+)
+
+type qsToken struct {
+	kind     qsTokenKind
+	text     string
+	wildcard bool
+	rng      *qsRangeNode
+}
+
+// qsTermStop are the characters which end a term
+const qsTermStop = `():^~"[]{}`
+
+func qsLex(s string) ([]*qsToken, error) {
+	rs := []rune(s)
+	tokens := make([]*qsToken, 0)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, &qsToken{kind: qsTokenLParen})
+			i++
+		case c == ')':
+			tokens = append(tokens, &qsToken{kind: qsTokenRParen})
+			i++
+		case c == ':':
+			tokens = append(tokens, &qsToken{kind: qsTokenColon})
+			i++
+		case c == '+':
+			tokens = append(tokens, &qsToken{kind: qsTokenPlus})
+			i++
+		case c == '-' && !qsNegativeNumber(rs, i, tokens):
+			tokens = append(tokens, &qsToken{kind: qsTokenMinus})
+			i++
+		case c == '!':
+			tokens = append(tokens, &qsToken{kind: qsTokenNot})
+			i++
+		case c == '&' && i+1 < len(rs) && rs[i+1] == '&':
+			tokens = append(tokens, &qsToken{kind: qsTokenAnd})
+			i += 2
+		case c == '|' && i+1 < len(rs) && rs[i+1] == '|':
+			tokens = append(tokens, &qsToken{kind: qsTokenOr})
+			i += 2
+		case c == '^' || c == '~':
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			kind := qsTokenFuzzy
+			if c == '^' {
+				kind = qsTokenBoost
+				if j == i+1 {
+					return nil, fmt.Errorf("missing boost value at position %d", i)
+				}
+			}
+			tokens = append(tokens, &qsToken{kind: kind, text: string(rs[i+1 : j])})
+			i = j
+		case c == '"':
+			text, j, err := qsLexQuoted(rs, i, '"')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, &qsToken{kind: qsTokenPhrase, text: text})
+			i = j
+		case c == '/':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(rs) && rs[j] != '/'; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) && rs[j+1] == '/' {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated regular expression at position %d", i)
+			}
+			tokens = append(tokens, &qsToken{kind: qsTokenRegexp, text: sb.String()})
+			i = j + 1
+		case c == '[' || c == '{':
+			rng, j, err := qsLexRange(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, &qsToken{kind: qsTokenRange, rng: rng})
+			i = j
+		case c == '>' || c == '<':
+			rng, j, err := qsLexCompare(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, &qsToken{kind: qsTokenRange, rng: rng})
+			i = j
+		default:
+			token, j := qsLexTerm(rs, i)
+			if j == i {
+				return nil, fmt.Errorf("unexpected '%c' at position %d", c, i)
+			}
+			tokens = append(tokens, token)
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// qsNegativeNumber reports whether the '-' at i is the sign of a value like field:-5
+func qsNegativeNumber(rs []rune, i int, tokens []*qsToken) bool {
+	return i+1 < len(rs) && unicode.IsDigit(rs[i+1]) &&
+		len(tokens) > 0 && tokens[len(tokens)-1].kind == qsTokenColon
+}
+
+// qsLexTerm reads a term starting at i, a backslash escapes the next character
+func qsLexTerm(rs []rune, i int) (*qsToken, int) {
+	var sb strings.Builder
+	escaped := false
+	wildcard := false
+	j := i
+	for ; j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(qsTermStop, rs[j]); j++ {
+		if rs[j] == '\\' && j+1 < len(rs) {
+			j++
+			escaped = true
+		} else if rs[j] == '*' || rs[j] == '?' {
+			wildcard = true
+		}
+		sb.WriteRune(rs[j])
+	}
+
+	text := sb.String()
+	if !escaped {
+		switch text {
+		case "AND":
+			return &qsToken{kind: qsTokenAnd}, j
+		case "OR":
+			return &qsToken{kind: qsTokenOr}, j
+		case "NOT":
+			return &qsToken{kind: qsTokenNot}, j
+		}
+	}
+	return &qsToken{kind: qsTokenTerm, text: text, wildcard: wildcard}, j
+}
+
+// qsLexQuoted reads a quoted string starting at i and returns the unescaped content and the position after it
+func qsLexQuoted(rs []rune, i int, quote rune) (string, int, error) {
+	var sb strings.Builder
+	j := i + 1
+	for ; j < len(rs) && rs[j] != quote; j++ {
+		if rs[j] == '\\' && j+1 < len(rs) {
+			j++
+		}
+		sb.WriteRune(rs[j])
+	}
+	if j >= len(rs) {
+		return "", j, fmt.Errorf("unterminated quoted string at position %d", i)
+	}
+	return sb.String(), j + 1, nil
+}
+
+// qsLexBound reads a bound of a range, * means unbounded
+func qsLexBound(rs []rune, i int, stop string) (string, int, error) {
+	for i < len(rs) && unicode.IsSpace(rs[i]) {
+		i++
+	}
+	if i < len(rs) && rs[i] == '"' {
+		return qsLexQuoted(rs, i, '"')
+	}
+	var sb strings.Builder
+	for ; i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune(stop, rs[i]); i++ {
+		if rs[i] == '\\' && i+1 < len(rs) {
+			i++
+		}
+		sb.WriteRune(rs[i])
+	}
+	text := sb.String()
+	if text == "*" {
+		text = ""
+	}
+	return text, i, nil
+}
+
+// qsLexRange reads a range like [min TO max] or {min TO max}
+func qsLexRange(rs []rune, i int) (*qsRangeNode, int, error) {
+	rng := &qsRangeNode{minInclusive: rs[i] == '[', boost: -1}
+	min, j, err := qsLexBound(rs, i+1, "]}")
+	if err != nil {
+		return nil, j, err
+	}
+	to, j, err := qsLexBound(rs, j, "]}")
+	if err != nil {
+		return nil, j, err
+	}
+	if to != "TO" {
+		return nil, j, fmt.Errorf("range at position %d requires TO", i)
+	}
+	max, j, err := qsLexBound(rs, j, "]}")
+	if err != nil {
+		return nil, j, err
+	}
+	for j < len(rs) && unicode.IsSpace(rs[j]) {
+		j++
+	}
+	if j >= len(rs) || (rs[j] != ']' && rs[j] != '}') {
+		return nil, j, fmt.Errorf("unterminated range at position %d", i)
+	}
+	rng.min = min
+	rng.max = max
+	rng.maxInclusive = rs[j] == ']'
+	return rng, j + 1, nil
+}
+
+// qsLexCompare reads a one side range like >value, >=value, <value or <=value
+func qsLexCompare(rs []rune, i int) (*qsRangeNode, int, error) {
+	op := string(rs[i])
+	j := i + 1
+	if j < len(rs) && rs[j] == '=' {
+		op += "="
+		j++
+	}
+	value, j, err := qsLexBound(rs, j, qsTermStop)
+	if err != nil {
+		return nil, j, err
+	}
+	if value == "" {
+		return nil, j, fmt.Errorf("missing value of %s at position %d", op, i)
+	}
+	rng := &qsRangeNode{boost: -1}
+	switch op {
+	case ">":
+		rng.min = value
+	case ">=":
+		rng.min = value
+		rng.minInclusive = true
+	case "<":
+		rng.max = value
+	case "<=":
+		rng.max = value
+		rng.maxInclusive = true
+	}
+	return rng, j, nil
+}
+
+type qsParser struct {
+	tokens      []*qsToken
+	pos         int
+	andOperator bool
+}
+
+// parseQueryStringSyntax parses the query string into a tree of clauses,
+// andOperator is true if the default operator between clauses is AND.
+func parseQueryStringSyntax(s string, andOperator bool) (*qsBoolNode, error) {
+	tokens, err := qsLex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &qsParser{tokens: tokens, andOperator: andOperator}
+	node, err := p.parseQuery("")
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != qsTokenEOF {
+		return nil, fmt.Errorf("unexpected ')'")
+	}
+	return node, nil
+}
+
+func (p *qsParser) peek() *qsToken {
+	if p.pos >= len(p.tokens) {
+		return &qsToken{kind: qsTokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *qsParser) next() *qsToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *qsParser) parseQuery(field string) (*qsBoolNode, error) {
+	node := &qsBoolNode{boost: -1}
+	for {
+		t := p.peek()
+		if t.kind == qsTokenEOF || t.kind == qsTokenRParen {
+			return node, nil
+		}
+
+		conj := qsTokenEOF
+		if t.kind == qsTokenAnd || t.kind == qsTokenOr {
+			conj = t.kind
+			p.next()
+		}
+		mods := qsTokenEOF
+		switch p.peek().kind {
+		case qsTokenPlus:
+			mods = qsTokenPlus
+			p.next()
+		case qsTokenMinus, qsTokenNot:
+			mods = qsTokenNot
+			p.next()
+		}
+
+		clause, err := p.parseClause(field)
+		if err != nil {
+			return nil, err
+		}
+		p.addClause(node, conj, mods, clause)
+	}
+}
+
+// addClause decides the occurrence of the clause in the same way as Lucene
+func (p *qsParser) addClause(node *qsBoolNode, conj, mods qsTokenKind, clause qsNode) {
+	if n := len(node.clauses); n > 0 {
+		last := node.clauses[n-1]
+		if conj == qsTokenAnd && last.occur != qsOccurMustNot {
+			last.occur = qsOccurMust
+		}
+		if conj == qsTokenOr && p.andOperator && last.occur != qsOccurMustNot {
+			last.occur = qsOccurShould
+		}
+	}
+
+	occur := qsOccurShould
+	switch {
+	case mods == qsTokenNot:
+		occur = qsOccurMustNot
+	case mods == qsTokenPlus:
+		occur = qsOccurMust
+	case conj == qsTokenAnd:
+		occur = qsOccurMust
+	case p.andOperator && conj != qsTokenOr:
+		occur = qsOccurMust
+	}
+	node.clauses = append(node.clauses, &qsClause{occur: occur, node: clause})
+}
+
+func (p *qsParser) parseClause(field string) (qsNode, error) {
+	t := p.next()
+	if t.kind == qsTokenTerm && p.peek().kind == qsTokenColon {
+		p.next()
+		field = t.text
+		t = p.next()
+	}
+
+	switch t.kind {
+	case qsTokenLParen:
+		node, err := p.parseQuery(field)
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != qsTokenRParen {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		if p.peek().kind == qsTokenBoost {
+			if node.boost, err = strconv.ParseFloat(p.next().text, 64); err != nil {
+				return nil, fmt.Errorf("invalid boost: %s", err.Error())
+			}
+		}
+		return node, nil
+	case qsTokenTerm, qsTokenPhrase, qsTokenRegexp:
+		node := &qsTermNode{field: field, text: t.text, wildcard: t.wildcard, fuzziness: -1, boost: -1}
+		switch t.kind {
+		case qsTokenPhrase:
+			node.kind = qsTermKindPhrase
+		case qsTokenRegexp:
+			node.kind = qsTermKindRegexp
+		}
+		for {
+			var err error
+			switch p.peek().kind {
+			case qsTokenBoost:
+				if node.boost, err = strconv.ParseFloat(p.next().text, 64); err != nil {
+					return nil, fmt.Errorf("invalid boost: %s", err.Error())
+				}
+			case qsTokenFuzzy:
+				if node.kind == qsTermKindPhrase {
+					node.slop, err = qsParseInt(p.next().text, 0)
+				} else {
+					node.fuzziness, err = qsParseInt(p.next().text, qsDefaultFuzziness)
+					if node.fuzziness > qsDefaultFuzziness {
+						node.fuzziness = qsDefaultFuzziness
+					}
+				}
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return node, nil
+			}
+		}
+	case qsTokenRange:
+		node := *t.rng
+		node.field = field
+		if p.peek().kind == qsTokenBoost {
+			var err error
+			if node.boost, err = strconv.ParseFloat(p.next().text, 64); err != nil {
+				return nil, fmt.Errorf("invalid boost: %s", err.Error())
+			}
+		}
+		return &node, nil
+	case qsTokenEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected token %s", qsTokenString(t))
+	}
+}
+
+func qsParseInt(s string, defaultValue int) (int, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", s)
+	}
+	return int(v), nil
+}
+
+func qsTokenString(t *qsToken) string {
+	switch t.kind {
+	case qsTokenAnd:
+		return "AND"
+	case qsTokenOr:
+		return "OR"
+	case qsTokenNot:
+		return "NOT"
+	case qsTokenPlus:
+		return "'+'"
+	case qsTokenMinus:
+		return "'-'"
+	case qsTokenRParen:
+		return "')'"
+	case qsTokenColon:
+		return "':'"
+	case qsTokenBoost:
+		return "'^'"
+	case qsTokenFuzzy:
+		return "'~'"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
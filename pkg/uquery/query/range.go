@@ -18,6 +18,7 @@ package query
 import (
 	"fmt"
 	"math"
+	"net"
 	"strings"
 	"time"
 
@@ -44,9 +45,11 @@ func RangeQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 			return RangeQueryNumeric(field, vv, mappings)
 		case "date", "time":
 			return RangeQueryTime(field, vv, mappings)
+		case "ip":
+			return RangeQueryIP(field, vv)
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException,
-				fmt.Sprintf("[range] %s only support values of [numeric, time], got %q", field, prop.Type))
+				fmt.Sprintf("[range] %s only support values of [numeric, time, ip], got %q", field, prop.Type))
 		}
 	}
 
@@ -217,3 +220,52 @@ func RangeQueryTime(field string, query map[string]interface{}, mappings *meta.M
 
 	return subq, nil
 }
+
+// RangeQueryIP matches the ip addresses between the bounds,
+// a bound can be a CIDR block, the lower bound uses the first address of the block and the upper bound uses the last one.
+func RangeQueryIP(field string, query map[string]interface{}) (bluge.Query, error) {
+	var min, max string
+	minInclusive := false
+	maxInclusive := false
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "gt", "gte", "lt", "lte":
+			s, err := zutils.ToString(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.%s should be a string", field, k))
+			}
+			var from, to net.IP
+			if zutils.IsCIDR(s) {
+				from, to, err = zutils.ParseCIDR(s)
+			} else {
+				from, err = zutils.ParseIP(s)
+				to = from
+			}
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.%s format err %s", field, k, err.Error()))
+			}
+			switch k {
+			case "gt", "gte":
+				min, minInclusive = zutils.EncodeIP(from), k == "gte"
+			default:
+				max, maxInclusive = zutils.EncodeIP(to), k == "lte"
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			// return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[range] unknown field [%s]", k))
+		}
+	}
+
+	if min == "" {
+		min, minInclusive = zutils.EncodeIP(net.IPv6zero), true
+	}
+	subq := bluge.NewTermRangeInclusiveQuery(min, max, minInclusive, maxInclusive).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
+}
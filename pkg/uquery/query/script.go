@@ -0,0 +1,501 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// scriptEnv is the variables of a script evaluation
+type scriptEnv struct {
+	params map[string]float64
+	doc    map[string]float64
+	score  float64
+}
+
+// scriptExpr is a compiled numeric expression, it returns false if a doc value is missing
+type scriptExpr func(env *scriptEnv) (float64, bool)
+
+// Script is a compiled script, it supports a simple subset of painless expressions:
+// numbers, + - * / %, parentheses, params.name, doc['field'].value, _score
+// and the Math functions abs, ceil, floor, log, log10, max, min, pow, sqrt.
+// The comparisons == != < <= > >=, the logical operators && || ! and true, false
+// are also supported, a boolean is evaluated as 1 or 0.
+type Script struct {
+	expr   scriptExpr
+	params map[string]float64
+	fields []string
+}
+
+// CompileScript parses the script source, the params must be numeric
+func CompileScript(script *meta.Script) (*Script, error) {
+	if script == nil || strings.TrimSpace(script.Source) == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[script] requires [source] to be set")
+	}
+	if script.Lang != "" && script.Lang != "painless" && script.Lang != "expression" {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script] lang [%s] is not supported", script.Lang))
+	}
+
+	rv := &Script{params: make(map[string]float64, len(script.Params))}
+	for k, v := range script.Params {
+		f, err := zutils.ToFloat64(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script] param [%s] should be a number", k))
+		}
+		rv.params[k] = f
+	}
+
+	p := &scriptParser{src: strings.TrimSuffix(strings.TrimSpace(script.Source), ";"), fields: make(map[string]struct{})}
+	p.src = strings.TrimPrefix(p.src, "return ")
+	expr, err := p.parseExpr()
+	if err == nil {
+		p.skipSpace()
+		if p.pos < len(p.src) {
+			err = fmt.Errorf("unexpected [%s] at position %d", p.src[p.pos:], p.pos)
+		}
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script] compile error: %s", err.Error()))
+	}
+	rv.expr = expr
+	for field := range p.fields {
+		rv.fields = append(rv.fields, field)
+	}
+	return rv, nil
+}
+
+// Fields returns the doc fields used by the script
+func (s *Script) Fields() []string {
+	return s.fields
+}
+
+// Eval evaluates the script, params overrides the params of the script
+func (s *Script) Eval(params map[string]float64, doc map[string]float64, score float64) (float64, bool) {
+	env := &scriptEnv{params: s.params, doc: doc, score: score}
+	if len(params) > 0 {
+		env.params = make(map[string]float64, len(s.params)+len(params))
+		for k, v := range s.params {
+			env.params[k] = v
+		}
+		for k, v := range params {
+			env.params[k] = v
+		}
+	}
+	return s.expr(env)
+}
+
+type scriptParser struct {
+	src    string
+	pos    int
+	fields map[string]struct{}
+}
+
+func (p *scriptParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *scriptParser) consume(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *scriptParser) expect(s string) error {
+	if !p.consume(s) {
+		return fmt.Errorf("expected [%s] at position %d", s, p.pos)
+	}
+	return nil
+}
+
+// parseExpr parses: and ('||' and)*
+func (p *scriptParser) parseExpr() (scriptExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr(false, left, right)
+	}
+	return left, nil
+}
+
+// parseAnd parses: comparison ('&&' comparison)*
+func (p *scriptParser) parseAnd() (scriptExpr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr(true, left, right)
+	}
+	return left, nil
+}
+
+// parseComparison parses: sum (('==' | '!=' | '<=' | '>=' | '<' | '>') sum)?
+func (p *scriptParser) parseComparison() (scriptExpr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr(op, left, right), nil
+		}
+	}
+	return left, nil
+}
+
+// parseSum parses: term (('+' | '-') term)*
+func (p *scriptParser) parseSum() (scriptExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.consume("+"):
+			op = '+'
+		case p.consume("-"):
+			op = '-'
+		default:
+			return left, nil
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+// parseTerm parses: unary (('*' | '/' | '%') unary)*
+func (p *scriptParser) parseTerm() (scriptExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.consume("*"):
+			op = '*'
+		case p.consume("/"):
+			op = '/'
+		case p.consume("%"):
+			op = '%'
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+func (p *scriptParser) parseUnary() (scriptExpr, error) {
+	if p.consume("-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *scriptEnv) (float64, bool) {
+			v, ok := expr(env)
+			return -v, ok
+		}, nil
+	}
+	if p.consume("+") {
+		return p.parseUnary()
+	}
+	if p.consume("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *scriptEnv) (float64, bool) {
+			v, ok := expr(env)
+			return boolValue(v == 0), ok
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *scriptParser) parsePrimary() (scriptExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unexpected end of script")
+	}
+
+	if p.consume("(") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	c := p.src[p.pos]
+	if c >= '0' && c <= '9' || c == '.' {
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number [%s]", p.src[start:p.pos])
+		}
+		return func(env *scriptEnv) (float64, bool) { return v, true }, nil
+	}
+
+	name := p.parseIdent()
+	switch {
+	case name == "_score":
+		return func(env *scriptEnv) (float64, bool) { return env.score, true }, nil
+	case name == "true", name == "false":
+		v := boolValue(name == "true")
+		return func(env *scriptEnv) (float64, bool) { return v, true }, nil
+	case name == "params":
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+		param := p.parseIdent()
+		if param == "" {
+			return nil, fmt.Errorf("expected param name at position %d", p.pos)
+		}
+		return func(env *scriptEnv) (float64, bool) {
+			v, ok := env.params[param]
+			return v, ok
+		}, nil
+	case name == "doc":
+		return p.parseDocValue()
+	case strings.HasPrefix(name, "Math."):
+		return p.parseMathFunc(strings.TrimPrefix(name, "Math."))
+	case name == "":
+		return nil, fmt.Errorf("unexpected [%c] at position %d", c, p.pos)
+	default:
+		return nil, fmt.Errorf("unknown variable [%s]", name)
+	}
+}
+
+// parseIdent parses an identifier, Math.xxx is parsed as one identifier
+func (p *scriptParser) parseIdent() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := rune(p.src[p.pos])
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' && p.src[start:p.pos] == "Math" {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// parseDocValue parses: doc['field'].value
+func (p *scriptParser) parseDocValue() (scriptExpr, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) || (p.src[p.pos] != '\'' && p.src[p.pos] != '"') {
+		return nil, fmt.Errorf("expected quoted field name at position %d", p.pos)
+	}
+	quote := p.src[p.pos]
+	end := strings.IndexByte(p.src[p.pos+1:], quote)
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated field name at position %d", p.pos)
+	}
+	field := p.src[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(".value"); err != nil {
+		return nil, err
+	}
+	p.fields[field] = struct{}{}
+	return func(env *scriptEnv) (float64, bool) {
+		v, ok := env.doc[field]
+		return v, ok
+	}, nil
+}
+
+func (p *scriptParser) parseMathFunc(name string) (scriptExpr, error) {
+	var fn func(args []float64) float64
+	argc := 1
+	switch name {
+	case "abs":
+		fn = func(args []float64) float64 { return math.Abs(args[0]) }
+	case "ceil":
+		fn = func(args []float64) float64 { return math.Ceil(args[0]) }
+	case "floor":
+		fn = func(args []float64) float64 { return math.Floor(args[0]) }
+	case "log":
+		fn = func(args []float64) float64 { return math.Log(args[0]) }
+	case "log10":
+		fn = func(args []float64) float64 { return math.Log10(args[0]) }
+	case "sqrt":
+		fn = func(args []float64) float64 { return math.Sqrt(args[0]) }
+	case "max":
+		fn, argc = func(args []float64) float64 { return math.Max(args[0], args[1]) }, 2
+	case "min":
+		fn, argc = func(args []float64) float64 { return math.Min(args[0], args[1]) }, 2
+	case "pow":
+		fn, argc = func(args []float64) float64 { return math.Pow(args[0], args[1]) }, 2
+	default:
+		return nil, fmt.Errorf("unknown function [Math.%s]", name)
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make([]scriptExpr, 0, argc)
+	for i := 0; i < argc; i++ {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return func(env *scriptEnv) (float64, bool) {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			v, ok := arg(env)
+			if !ok {
+				return 0, false
+			}
+			values[i] = v
+		}
+		return fn(values), true
+	}, nil
+}
+
+func binaryExpr(op byte, left, right scriptExpr) scriptExpr {
+	return func(env *scriptEnv) (float64, bool) {
+		l, ok := left(env)
+		if !ok {
+			return 0, false
+		}
+		r, ok := right(env)
+		if !ok {
+			return 0, false
+		}
+		switch op {
+		case '+':
+			return l + r, true
+		case '-':
+			return l - r, true
+		case '*':
+			return l * r, true
+		case '/':
+			return l / r, true
+		default:
+			return math.Mod(l, r), true
+		}
+	}
+}
+
+func compareExpr(op string, left, right scriptExpr) scriptExpr {
+	return func(env *scriptEnv) (float64, bool) {
+		l, ok := left(env)
+		if !ok {
+			return 0, false
+		}
+		r, ok := right(env)
+		if !ok {
+			return 0, false
+		}
+		switch op {
+		case "==":
+			return boolValue(l == r), true
+		case "!=":
+			return boolValue(l != r), true
+		case "<=":
+			return boolValue(l <= r), true
+		case ">=":
+			return boolValue(l >= r), true
+		case "<":
+			return boolValue(l < r), true
+		default:
+			return boolValue(l > r), true
+		}
+	}
+}
+
+// logicalExpr returns the expression of && if and is true, otherwise ||
+func logicalExpr(and bool, left, right scriptExpr) scriptExpr {
+	return func(env *scriptEnv) (float64, bool) {
+		l, ok := left(env)
+		if !ok {
+			return 0, false
+		}
+		if and == (l == 0) {
+			return boolValue(!and), true // short-circuit
+		}
+		r, ok := right(env)
+		if !ok {
+			return 0, false
+		}
+		return boolValue(r != 0), true
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+func ScriptScoreQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	var subq bluge.Query
+	value := new(meta.ScriptScoreQuery)
+	value.Boost = -1.0
+	var err error
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "query":
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script_score] query doesn't support values of type: %T", v))
+			}
+			if subq, err = Query(vv, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[query] failed to parse field").Cause(err)
+			}
+		case "script":
+			if value.Script, err = parseScript(v); err != nil {
+				return nil, err
+			}
+		case "min_score":
+			minScore, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script_score] min_score doesn't support values of type: %T", v))
+			}
+			value.MinScore = &minScore
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script_score] unknown field [%s]", k))
+		}
+	}
+
+	if subq == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[script_score] requires [query] to be set")
+	}
+	if value.Script == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[script_score] requires [script] to be set")
+	}
+
+	fn, err := scriptScore(value.Script, mappings)
+	if err != nil {
+		return nil, err
+	}
+	fsq := zincquery.NewFunctionScoreQuery(subq).
+		AddFunction(nil, fn, 1.0).
+		SetBoostMode(zincquery.BoostModeReplace)
+	if value.MinScore != nil {
+		fsq.SetMinScore(*value.MinScore)
+	}
+	if value.Boost >= 0 {
+		fsq.SetBoost(value.Boost)
+	}
+	return fsq, nil
+}
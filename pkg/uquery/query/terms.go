@@ -72,9 +72,14 @@ func TermsQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 		}
 	}
 
+	termQuery := TermQueryText
+	if prop, _ := mappings.GetProperty(field); prop.Type == "ip" {
+		termQuery = TermQueryIP
+	}
+
 	subq := bluge.NewBooleanQuery()
 	for _, term := range values {
-		subqq, err := TermQueryText(field, &meta.TermQuery{Value: term})
+		subqq, err := termQuery(field, &meta.TermQuery{Value: term})
 		if err != nil {
 			return nil, err
 		}
@@ -16,11 +16,146 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func TermsSetQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[terms_set] query doesn't support")
+func TermsSetQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	if len(query) > 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] query doesn't support multiple fields")
+	}
+
+	field := ""
+	value := new(meta.TermsSetQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		field = k
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] %s doesn't support values of type: %T", k, v))
+		}
+		for k, v := range vv {
+			k := strings.ToLower(k)
+			switch k {
+			case "terms":
+				terms, ok := v.([]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] terms doesn't support values of type: %T", v))
+				}
+				value.Terms = terms
+			case "minimum_should_match_field":
+				value.MinimumShouldMatchField, ok = v.(string)
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] minimum_should_match_field doesn't support values of type: %T", v))
+				}
+			case "minimum_should_match_script":
+				script, err := parseScript(v)
+				if err != nil {
+					return nil, err
+				}
+				value.MinimumShouldMatchScript = script
+			case "boost":
+				value.Boost, _ = zutils.ToFloat64(v)
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] unknown field [%s]", k))
+			}
+		}
+	}
+
+	if value.MinimumShouldMatchField == "" && value.MinimumShouldMatchScript == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] query requires [minimum_should_match_field] or [minimum_should_match_script] to be set")
+	}
+	if value.MinimumShouldMatchField != "" && value.MinimumShouldMatchScript != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] query doesn't support both [minimum_should_match_field] and [minimum_should_match_script]")
+	}
+
+	prop, _ := mappings.GetProperty(field)
+	queries := make([]bluge.Query, 0, len(value.Terms))
+	for _, term := range value.Terms {
+		var subq bluge.Query
+		var err error
+		switch prop.Type {
+		case "numeric":
+			subq, err = TermQueryNumeric(field, &meta.TermQuery{Value: term, Boost: -1})
+		case "bool":
+			subq, err = TermQueryBool(field, &meta.TermQuery{Value: term, Boost: -1})
+		default:
+			subq, err = TermQueryText(field, &meta.TermQuery{Value: term, Boost: -1})
+		}
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, subq)
+	}
+
+	var fields []string
+	var minimum zincquery.TermsSetMinimumFunc
+	if value.MinimumShouldMatchField != "" {
+		msmField := value.MinimumShouldMatchField
+		if prop, ok := mappings.GetProperty(msmField); !ok || prop.Type != "numeric" {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] minimum_should_match_field [%s] should be a numeric field", msmField))
+		}
+		fields = []string{msmField}
+		minimum = func(numTerms int, values map[string]float64) (float64, bool) {
+			v, ok := values[msmField]
+			return v, ok
+		}
+	} else {
+		script, err := CompileScript(value.MinimumShouldMatchScript)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range script.Fields() {
+			if prop, ok := mappings.GetProperty(f); !ok || prop.Type != "numeric" {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] minimum_should_match_script field [%s] should be a numeric field", f))
+			}
+		}
+		fields = script.Fields()
+		minimum = func(numTerms int, values map[string]float64) (float64, bool) {
+			return script.Eval(map[string]float64{"num_terms": float64(numTerms)}, values, 0)
+		}
+	}
+
+	subq := zincquery.NewTermsSetQuery(queries, fields, minimum)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	return subq, nil
+}
+
+// parseScript parses a script in the form of string or object
+func parseScript(v interface{}) (*meta.Script, error) {
+	switch v := v.(type) {
+	case string:
+		return &meta.Script{Source: v}, nil
+	case map[string]interface{}:
+		script := new(meta.Script)
+		for k, v := range v {
+			k := strings.ToLower(k)
+			switch k {
+			case "source", "inline":
+				script.Source, _ = v.(string)
+			case "lang":
+				script.Lang, _ = v.(string)
+			case "params":
+				params, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script] params doesn't support values of type: %T", v))
+				}
+				script.Params = params
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script] unknown field [%s]", k))
+			}
+		}
+		return script, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script] doesn't support values of type: %T", v))
+	}
 }
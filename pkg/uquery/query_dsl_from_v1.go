@@ -169,9 +169,10 @@ func covertAggregationFromV1(agg v1.AggregationParams) (meta.Aggregations, error
 			Field: agg.Field,
 		}
 		for _, v := range agg.Ranges {
+			v := v
 			newagg.Range.Ranges = append(newagg.Range.Ranges, meta.Range{
-				To:   v.To,
-				From: v.From,
+				To:   &v.To,
+				From: &v.From,
 			})
 		}
 	case "date_range":
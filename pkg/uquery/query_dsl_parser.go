@@ -22,6 +22,7 @@ import (
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
@@ -49,6 +50,15 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 		return nil, errors.New(errors.ErrorTypeNotImplemented, fmt.Sprintf("[%s] query doesn't support", q.Query))
 	}
 
+	// exclude the hidden nested documents, and provide the reader to the aggregations
+	var root *zincquery.RootQuery
+	if mappings != nil && len(mappings.ListNestedPath()) > 0 {
+		root = zincquery.NewRootQuery(query, meta.NestedPathFieldName)
+	} else {
+		root = zincquery.NewRootQuery(query, "")
+	}
+	query = root
+
 	// create search request
 	request := bluge.NewTopNSearch(q.Size, query).WithStandardAggregations()
 
@@ -70,7 +80,7 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 
 	// parse aggregations
 	if q.Aggregations != nil {
-		if err := aggregation.Request(request, q.Aggregations, mappings); err != nil {
+		if err := aggregation.Request(request, q.Aggregations, mappings, analyzers, root); err != nil {
 			return nil, err
 		}
 	}
@@ -100,7 +110,22 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 	}
 
 	// pagenation
-	// TODO: search after PIT support
+	if q.PIT != nil {
+		// point in time needs a stable order to page through hits
+		sorts, _ := q.Sort.(search.SortOrder)
+		q.Sort = sort.WithTiebreaker(sorts)
+		request.SortByCustom(q.Sort.(search.SortOrder))
+	}
+	if len(q.SearchAfter) > 0 {
+		if q.From > 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[from] parameter must be set to 0 when [search_after] is used")
+		}
+		after, err := sort.SearchAfter(request.SortOrder(), q.SearchAfter, mappings)
+		if err != nil {
+			return nil, err
+		}
+		request.After(after)
+	}
 
 	return request, nil
 }
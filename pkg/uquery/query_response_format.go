@@ -23,11 +23,11 @@ import (
 	"github.com/zincsearch/zincsearch/pkg/uquery/aggregation"
 )
 
-func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *search.Bucket) error {
+func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, mappings *meta.Mappings, buckets *search.Bucket) error {
 	var err error
 	// format aggregations
 	if len(q.Aggregations) > 0 {
-		resp.Aggregations, err = aggregation.Response(buckets)
+		resp.Aggregations, err = aggregation.Response(buckets, q.Aggregations, mappings)
 		if err != nil {
 			return errors.New(errors.ErrorTypeParsingException, err.Error())
 		}
@@ -36,6 +36,9 @@ func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *searc
 			delete(resp.Aggregations, "duration")
 			delete(resp.Aggregations, "max_score")
 		}
+		if err = aggregation.Pipeline(resp.Aggregations, q.Aggregations); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,164 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// GeoDistanceField is the sort key to sort by the distance from geo points
+const GeoDistanceField = "_geo_distance"
+
+// GeoDistanceSource computes the distance between the geo_point field of
+// the document and the origin points, in the unit of the sort.
+type GeoDistanceSource struct {
+	field  search.FieldSource
+	points []geo.Point
+	unit   float64 // meters of unit
+	mode   string  // min, max, avg
+}
+
+func (s *GeoDistanceSource) Fields() []string {
+	return s.field.Fields()
+}
+
+// Value returns the distance as numeric prefix coded, or nil if the document has no point
+func (s *GeoDistanceSource) Value(match *search.DocumentMatch) []byte {
+	docPoints := s.field.GeoPoints(match)
+	if len(docPoints) == 0 {
+		return nil
+	}
+
+	var dist float64
+	var n int
+	for _, dp := range docPoints {
+		for _, p := range s.points {
+			d := geo.Haversin(dp.Lon, dp.Lat, p.Lon, p.Lat) * 1000 / s.unit
+			switch {
+			case n == 0:
+				dist = d
+			case s.mode == "max":
+				if d > dist {
+					dist = d
+				}
+			case s.mode == "avg":
+				dist += d
+			default:
+				if d < dist {
+					dist = d
+				}
+			}
+			n++
+		}
+	}
+	if s.mode == "avg" {
+		dist /= float64(n)
+	}
+	return numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(dist), 0)
+}
+
+// GeoDistanceSort parses the options of _geo_distance sort, e.g.
+//
+//	{"_geo_distance": {"location": [-70, 40], "order": "asc", "unit": "km", "mode": "min"}}
+func GeoDistanceSort(v map[string]interface{}) (*search.Sort, error) {
+	source := &GeoDistanceSource{unit: 1}
+	field := ""
+	desc := false
+	for k, v := range v {
+		switch strings.ToLower(k) {
+		case "order":
+			order, _ := v.(string)
+			desc = strings.ToLower(order) == "desc"
+		case "unit":
+			unit, _ := v.(string)
+			u, err := geo.ParseDistanceUnit(unit)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] unknown unit [%v]", v))
+			}
+			source.unit = u
+		case "mode":
+			mode, _ := v.(string)
+			source.mode = strings.ToLower(mode)
+			switch source.mode {
+			case "min", "max", "avg":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] unknown mode [%v]", v))
+			}
+		case "distance_type", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort doesn't support multiple fields")
+			}
+			field = k
+			points, err := parseGeoPoints(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] %s parse err: %s", k, err.Error()))
+			}
+			source.points = points
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort requires a field")
+	}
+	if source.mode == "" {
+		// the shortest distance for ascending and the longest for descending
+		source.mode = "min"
+		if desc {
+			source.mode = "max"
+		}
+	}
+	source.field = search.Field(field)
+
+	sort := search.SortBy(source)
+	if desc {
+		sort.Desc()
+	}
+	return sort, nil
+}
+
+// parseGeoPoints parses a point or an array of points
+func parseGeoPoints(v interface{}) ([]geo.Point, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) == 2 && isNumber(values[0]) && isNumber(values[1]) {
+		values = []interface{}{v}
+	}
+	points := make([]geo.Point, 0, len(values))
+	for _, v := range values {
+		lon, lat, err := zutils.ParseGeoPoint(v)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, geo.Point{Lon: lon, Lat: lat})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("at least one point is required")
+	}
+	return points, nil
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := v.(float64)
+	return ok
+}
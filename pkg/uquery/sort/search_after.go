@@ -0,0 +1,166 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// TiebreakerField is appended to the sort order of paginated searches,
+// so documents with the same sort values still have a stable order.
+const TiebreakerField = "_id"
+
+// WithTiebreaker returns the sort order with a trailing `_id` sort,
+// the default sort order is `_score` desc.
+func WithTiebreaker(sorts search.SortOrder) search.SortOrder {
+	if len(sorts) == 0 {
+		sorts = search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}
+	}
+	if fields := sorts[len(sorts)-1].Fields(); len(fields) > 0 && fields[0] == TiebreakerField {
+		return sorts
+	}
+	rv := sorts.Copy()
+	return append(rv, search.SortBy(search.Field(TiebreakerField)))
+}
+
+// Values converts the sort key of a document to json values
+// _score and numeric -> float64, date -> RFC3339Nano string, ip -> ip string, others -> string, missing -> null
+func Values(sorts search.SortOrder, values [][]byte, mappings *meta.Mappings) []interface{} {
+	rv := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		if i >= len(sorts) {
+			break
+		}
+		if bytes.Equal(value, missingValue(sorts[i])) {
+			rv = append(rv, nil)
+			continue
+		}
+		switch sortType(sorts[i], mappings) {
+		case "numeric":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rv = append(rv, nil)
+				continue
+			}
+			rv = append(rv, numeric.Int64ToFloat64(i64))
+		case "date":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rv = append(rv, nil)
+				continue
+			}
+			rv = append(rv, time.Unix(0, i64).UTC().Format(time.RFC3339Nano))
+		case "ip":
+			ip, err := zutils.DecodeIP(value)
+			if err != nil {
+				rv = append(rv, nil)
+				continue
+			}
+			rv = append(rv, zutils.FormatIP(ip))
+		default:
+			rv = append(rv, string(value))
+		}
+	}
+	return rv
+}
+
+// SearchAfter converts json values of search_after to the sort key used by bluge
+func SearchAfter(sorts search.SortOrder, values []interface{}, mappings *meta.Mappings) ([][]byte, error) {
+	if len(values) != len(sorts) {
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[search_after] has %d value(s) but sort has %d", len(values), len(sorts)),
+		)
+	}
+
+	rv := make([][]byte, 0, len(values))
+	for i, value := range values {
+		if value == nil {
+			rv = append(rv, missingValue(sorts[i]))
+			continue
+		}
+		switch sortType(sorts[i], mappings) {
+		case "numeric":
+			v, err := zutils.ToFloat64(value)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] should be a number", value))
+			}
+			rv = append(rv, numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(v), 0))
+		case "date":
+			t, err := zutils.ParseTime(value, time.RFC3339Nano, "")
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] should be a date: %s", value, err.Error()))
+			}
+			rv = append(rv, numeric.MustNewPrefixCodedInt64(t.UnixNano(), 0))
+		case "ip":
+			ip, err := zutils.ParseIP(value)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] should be an ip: %s", value, err.Error()))
+			}
+			rv = append(rv, []byte(zutils.EncodeIP(ip)))
+		default:
+			v, err := zutils.ToString(value)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] should be a string", value))
+			}
+			rv = append(rv, []byte(v))
+		}
+	}
+	return rv, nil
+}
+
+// sortType returns the value type of sort: numeric, date, ip or keyword
+func sortType(sort *search.Sort, mappings *meta.Mappings) string {
+	fields := sort.Fields()
+	if len(fields) == 0 {
+		return "numeric" // _score
+	}
+	if mappings == nil {
+		return "keyword"
+	}
+	prop, ok := mappings.GetProperty(fields[0])
+	if !ok {
+		return "keyword"
+	}
+	switch prop.Type {
+	case "numeric", "geo_point": // geo_point is sorted by distance
+		return "numeric"
+	case "date", "time":
+		return "date"
+	case "ip":
+		return "ip"
+	default:
+		return "keyword"
+	}
+}
+
+// missingValue returns the sort key of a document without value,
+// it depends on the direction of sort.
+func missingValue(sort *search.Sort) []byte {
+	if len(sort.Fields()) == 0 {
+		return nil // _score always has value
+	}
+	return sort.Value(&search.DocumentMatch{})
+}
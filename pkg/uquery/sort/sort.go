@@ -46,6 +46,18 @@ func Request(v interface{}) (search.SortOrder, error) {
 					return nil, errors.New(errors.ErrorTypeParsingException, "[sort] field doesn't support multiple values")
 				}
 				for field, v := range v {
+					if field == GeoDistanceField {
+						vv, ok := v.(map[string]interface{})
+						if !ok {
+							return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort value should be an object")
+						}
+						sort, err := GeoDistanceSort(vv)
+						if err != nil {
+							return nil, err
+						}
+						sorts = append(sorts, sort)
+						continue
+					}
 					sort := search.SortBy(search.Field(field))
 					switch v := v.(type) {
 					case string:
@@ -16,6 +16,7 @@
 package source
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/zincsearch/zincsearch/pkg/errors"
@@ -44,6 +45,21 @@ func Request(v interface{}) (*meta.Source, error) {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[_source] value should be boolean or []string")
 			}
 		}
+	case map[string]interface{}:
+		for k, v := range v {
+			fields, err := requestFields(k, v)
+			if err != nil {
+				return nil, err
+			}
+			switch strings.ToLower(k) {
+			case "includes", "include":
+				source.Fields = fields
+			case "excludes", "exclude":
+				source.Excludes = fields
+			default:
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[_source] unknown field [%s]", k))
+			}
+		}
 	default:
 		return nil, errors.New(errors.ErrorTypeXContentParseException, "[_source] value should be boolean or []string")
 	}
@@ -51,6 +67,26 @@ func Request(v interface{}) (*meta.Source, error) {
 	return source, nil
 }
 
+// requestFields parses the includes or excludes of _source, the value is a string or []string
+func requestFields(name string, v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		fields := make([]string, 0, len(v))
+		for _, field := range v {
+			if v, ok := field.(string); ok {
+				fields = append(fields, v)
+			} else {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[_source] %s should be string or []string", name))
+			}
+		}
+		return fields, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[_source] %s should be string or []string", name))
+	}
+}
+
 func Response(source *meta.Source, data []byte) map[string]interface{} {
 	ret := make(map[string]interface{})
 
@@ -65,25 +101,40 @@ func Response(source *meta.Source, data []byte) map[string]interface{} {
 	}
 
 	// return all fields
-	if len(source.Fields) == 0 {
+	if len(source.Fields) == 0 && len(source.Excludes) == 0 {
 		return ret
 	}
 
-	wildcard := false
-	rets := make(map[string]interface{})
-	for _, field := range source.Fields {
-		wildcard = false
-		if strings.HasSuffix(field, "*") {
-			wildcard = true
+	rets := ret
+	if len(source.Fields) > 0 {
+		wildcard := false
+		rets = make(map[string]interface{})
+		for _, field := range source.Fields {
+			wildcard = false
+			if strings.HasSuffix(field, "*") {
+				wildcard = true
+			}
+			if _, ok := ret[field]; ok {
+				rets[field] = ret[field]
+			} else if wildcard {
+				for k, v := range ret {
+					if strings.HasPrefix(k, field[:len(field)-1]) {
+						rets[k] = v
+					}
+				}
+			}
 		}
-		if _, ok := ret[field]; ok {
-			rets[field] = ret[field]
-		} else if wildcard {
-			for k, v := range ret {
+	}
+
+	for _, field := range source.Excludes {
+		if strings.HasSuffix(field, "*") {
+			for k := range rets {
 				if strings.HasPrefix(k, field[:len(field)-1]) {
-					rets[k] = v
+					delete(rets, k)
 				}
 			}
+		} else {
+			delete(rets, field)
 		}
 	}
 
@@ -0,0 +1,150 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package wal
+
+import (
+	"sync"
+
+	"github.com/zincsearch/wal"
+
+	"github.com/zincsearch/zincsearch/pkg/wal/redo"
+)
+
+// OpenMemory opens a write ahead log which is kept in memory, it is used by
+// the indexes which don't need durability and is lost when the process exits.
+func OpenMemory(indexName string) *Log {
+	return &Log{
+		name: indexName,
+		log:  newMemoryLog(),
+		Redo: newMemoryRedoLog(),
+	}
+}
+
+// memoryLog follows the behavior of wal.Log with FillID enabled
+type memoryLog struct {
+	lock       sync.RWMutex
+	firstIndex uint64
+	lastIndex  uint64
+	entries    [][]byte
+}
+
+func newMemoryLog() *memoryLog {
+	return &memoryLog{}
+}
+
+func (l *memoryLog) Len() (uint64, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if l.lastIndex == 0 {
+		return 0, nil
+	}
+	return l.lastIndex - l.firstIndex + 1, nil
+}
+
+func (l *memoryLog) FirstIndex() (uint64, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if l.lastIndex == 0 {
+		return 0, nil
+	}
+	return l.firstIndex, nil
+}
+
+func (l *memoryLog) LastIndex() (uint64, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.lastIndex, nil
+}
+
+// Write appends the data to the log, the index is always filled with the next index
+func (l *memoryLog) Write(_ uint64, data []byte) error {
+	entry := make([]byte, len(data))
+	copy(entry, data)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.lastIndex == 0 {
+		l.firstIndex = 1
+	}
+	l.lastIndex++
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryLog) Read(index uint64) ([]byte, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if index == 0 || index < l.firstIndex || index > l.lastIndex {
+		return nil, wal.ErrNotFound
+	}
+	return l.entries[index-l.firstIndex], nil
+}
+
+// TruncateFront removes all the entries before the index
+func (l *memoryLog) TruncateFront(index uint64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if index == 0 || l.lastIndex == 0 || index < l.firstIndex || index > l.lastIndex {
+		return wal.ErrOutOfRange
+	}
+	entries := make([][]byte, l.lastIndex-index+1)
+	copy(entries, l.entries[index-l.firstIndex:])
+	l.entries = entries
+	l.firstIndex = index
+	return nil
+}
+
+func (l *memoryLog) Sync() error {
+	return nil
+}
+
+func (l *memoryLog) Close() error {
+	return nil
+}
+
+type memoryRedoLog struct {
+	lock sync.RWMutex
+	data map[uint64][]byte
+}
+
+func newMemoryRedoLog() *memoryRedoLog {
+	return &memoryRedoLog{data: make(map[uint64][]byte)}
+}
+
+func (l *memoryRedoLog) Write(index uint64, data []byte) error {
+	if len(data) > redo.ValueFixedLength {
+		return redo.ErrValueTooLarge
+	}
+	value := make([]byte, len(data))
+	copy(value, data)
+	l.lock.Lock()
+	l.data[index] = value
+	l.lock.Unlock()
+	return nil
+}
+
+func (l *memoryRedoLog) Read(index uint64) ([]byte, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	value, ok := l.data[index]
+	if !ok {
+		return nil, redo.ErrNotFound
+	}
+	return value, nil
+}
+
+func (l *memoryRedoLog) Close() error {
+	return nil
+}
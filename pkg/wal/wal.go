@@ -26,8 +26,27 @@ import (
 
 type Log struct {
 	name string
-	log  *wal.Log
-	Redo *redo.Log
+	log  logger
+	Redo RedoLog
+}
+
+// logger is the write ahead log storage
+type logger interface {
+	Len() (uint64, error)
+	FirstIndex() (uint64, error)
+	LastIndex() (uint64, error)
+	Write(index uint64, data []byte) error
+	Read(index uint64) ([]byte, error)
+	TruncateFront(index uint64) error
+	Sync() error
+	Close() error
+}
+
+// RedoLog records the progress of consuming the write ahead log
+type RedoLog interface {
+	Write(index uint64, data []byte) error
+	Read(index uint64) ([]byte, error)
+	Close() error
 }
 
 func Open(indexName string) (*Log, error) {
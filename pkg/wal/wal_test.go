@@ -88,3 +88,49 @@ func BenchmarkWAL(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+func TestOpenMemory(t *testing.T) {
+	l := OpenMemory("walTestMemory")
+	assert.Equal(t, "walTestMemory", l.Name())
+
+	length, err := l.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), length)
+	_, err = l.Read(1)
+	assert.Error(t, err)
+
+	for _, entry := range []string{"test1", "test2", "test3"} {
+		err = l.Write([]byte(entry))
+		assert.NoError(t, err)
+	}
+	data, err := l.Read(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "test2", string(data))
+
+	err = l.TruncateFront(2)
+	assert.NoError(t, err)
+	firstIndex, err := l.FirstIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), firstIndex)
+	lastIndex, err := l.LastIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), lastIndex)
+	length, err = l.Len()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), length)
+	_, err = l.Read(1)
+	assert.Error(t, err)
+	err = l.TruncateFront(4)
+	assert.Error(t, err)
+
+	_, err = l.Redo.Read(1)
+	assert.Error(t, err)
+	err = l.Redo.Write(1, []byte("1:3"))
+	assert.NoError(t, err)
+	data, err = l.Redo.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "1:3", string(data))
+
+	assert.NoError(t, l.Sync())
+	assert.NoError(t, l.Close())
+}
@@ -0,0 +1,142 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ParseGeoPoint parses a geo_point value, supported formats:
+//
+//	{"lat": 41.12, "lon": -71.34}
+//	[-71.34, 41.12]      // [lon, lat]
+//	"41.12,-71.34"       // "lat,lon"
+//	"drm3btev3e86"       // geohash
+//	"POINT (-71.34 41.12)"
+func ParseGeoPoint(v interface{}) (lon, lat float64, err error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		latV, ok := v["lat"]
+		if !ok {
+			return 0, 0, fmt.Errorf("geo_point field [lat] is missing")
+		}
+		lonV, ok := v["lon"]
+		if !ok {
+			if lonV, ok = v["lng"]; !ok {
+				return 0, 0, fmt.Errorf("geo_point field [lon] is missing")
+			}
+		}
+		if lat, err = ToFloat64(latV); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lat] should be a number, got [%v]", latV)
+		}
+		if lon, err = ToFloat64(lonV); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lon] should be a number, got [%v]", lonV)
+		}
+	case []interface{}:
+		if len(v) != 2 {
+			return 0, 0, fmt.Errorf("geo_point array should be [lon, lat], got [%v]", v)
+		}
+		if lon, err = toGeoNumber(v[0]); err != nil {
+			return 0, 0, err
+		}
+		if lat, err = toGeoNumber(v[1]); err != nil {
+			return 0, 0, err
+		}
+	case string:
+		if lon, lat, err = parseGeoPointString(strings.TrimSpace(v)); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("geo_point doesn't support value of type %T", v)
+	}
+
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("geo_point latitude [%v] is out of bounds", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("geo_point longitude [%v] is out of bounds", lon)
+	}
+	return lon, lat, nil
+}
+
+// FormatGeoPoint formats the point as "lat,lon"
+func FormatGeoPoint(lon, lat float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+func parseGeoPointString(v string) (lon, lat float64, err error) {
+	if strings.HasPrefix(strings.ToUpper(v), "POINT") {
+		s := strings.TrimSpace(v[len("POINT"):])
+		if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+			return 0, 0, fmt.Errorf("geo_point [%s] is not a valid WKT point", v)
+		}
+		parts := strings.Fields(s[1 : len(s)-1])
+		if len(parts) != 2 {
+			return 0, 0, fmt.Errorf("geo_point [%s] is not a valid WKT point", v)
+		}
+		if lon, err = strconv.ParseFloat(parts[0], 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [%s] is not a valid WKT point", v)
+		}
+		if lat, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [%s] is not a valid WKT point", v)
+		}
+		return lon, lat, nil
+	}
+
+	if strings.Contains(v, ",") {
+		parts := strings.Split(v, ",")
+		if len(parts) != 2 {
+			return 0, 0, fmt.Errorf("geo_point [%s] should be \"lat,lon\"", v)
+		}
+		if lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [%s] latitude should be a number", v)
+		}
+		if lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [%s] longitude should be a number", v)
+		}
+		return lon, lat, nil
+	}
+
+	if v == "" || len(v) > 12 {
+		return 0, 0, fmt.Errorf("geo_point [%s] is not a valid geohash", v)
+	}
+	for _, c := range strings.ToLower(v) {
+		if !strings.ContainsRune(geohashAlphabet, c) {
+			return 0, 0, fmt.Errorf("geo_point [%s] is not a valid geohash", v)
+		}
+	}
+	lat, lon = geo.DecodeGeoHash(strings.ToLower(v))
+	return lon, lat, nil
+}
+
+func toGeoNumber(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("geo_point array value should be a number, got [%v]", v)
+	}
+}
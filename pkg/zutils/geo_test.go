@@ -0,0 +1,62 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeoPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		lon     float64
+		lat     float64
+		wantErr bool
+	}{
+		{name: "object", value: map[string]interface{}{"lat": 41.12, "lon": -71.34}, lon: -71.34, lat: 41.12},
+		{name: "object lng", value: map[string]interface{}{"lat": 41.12, "lng": -71.34}, lon: -71.34, lat: 41.12},
+		{name: "array", value: []interface{}{-71.34, 41.12}, lon: -71.34, lat: 41.12},
+		{name: "string", value: "41.12, -71.34", lon: -71.34, lat: 41.12},
+		{name: "wkt", value: "POINT (-71.34 41.12)", lon: -71.34, lat: 41.12},
+		{name: "geohash", value: "drm3btev3e86", lon: -71.34, lat: 41.12},
+		{name: "missing lon", value: map[string]interface{}{"lat": 41.12}, wantErr: true},
+		{name: "array length", value: []interface{}{-71.34}, wantErr: true},
+		{name: "invalid string", value: "abc,def", wantErr: true},
+		{name: "invalid geohash", value: "aaaa", wantErr: true},
+		{name: "latitude out of bounds", value: "91,0", wantErr: true},
+		{name: "longitude out of bounds", value: []interface{}{181.0, 0.0}, wantErr: true},
+		{name: "type", value: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon, lat, err := ParseGeoPoint(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.lon, lon, 0.0001)
+			assert.InDelta(t, tt.lat, lat, 0.0001)
+		})
+	}
+}
+
+func TestFormatGeoPoint(t *testing.T) {
+	assert.Equal(t, "41.12,-71.34", FormatGeoPoint(-71.34, 41.12))
+}
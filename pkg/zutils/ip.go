@@ -0,0 +1,91 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIP parses an IPv4 or IPv6 address, the address is returned in the 16-byte form,
+// so the IPv4 addresses are the IPv4-mapped IPv6 addresses and all the addresses are sortable by bytes.
+func ParseIP(v interface{}) (net.IP, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("ip doesn't support value of type %T", v)
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("'%s' is not an IP string literal", s)
+	}
+	return ip.To16(), nil
+}
+
+// ParseCIDR parses an address block in CIDR notation like "192.168.0.0/16",
+// from is the first address of the block and to is the last address, both in the 16-byte form.
+func ParseCIDR(s string) (from, to net.IP, err error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(s))
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' is not a valid CIDR notation", s)
+	}
+	from = ipNet.IP.To16()
+	to = make(net.IP, len(ipNet.IP))
+	for i := range ipNet.IP {
+		to[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+	}
+	return from, to.To16(), nil
+}
+
+// IsCIDR returns true if s is an address block in CIDR notation
+func IsCIDR(s string) bool {
+	return strings.Contains(s, "/")
+}
+
+// NextIP returns the address after ip, ok is false if ip is the last address
+func NextIP(ip net.IP) (next net.IP, ok bool) {
+	next = make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next, true
+		}
+	}
+	return nil, false
+}
+
+// FormatIP returns the string form of the address, IPv4 addresses are formatted in dotted decimal
+func FormatIP(ip net.IP) string {
+	return ip.String()
+}
+
+// EncodeIP returns the indexed term of the address, it is the hex of the 16-byte form
+// which keeps the order of the addresses, the raw bytes can't be used because the
+// doc values of bluge separate the terms by 0xff.
+func EncodeIP(ip net.IP) string {
+	return hex.EncodeToString(ip.To16())
+}
+
+// DecodeIP returns the address of the indexed term
+func DecodeIP(term []byte) (net.IP, error) {
+	ip := make(net.IP, net.IPv6len)
+	if n, err := hex.Decode(ip, term); err != nil || n != net.IPv6len {
+		return nil, fmt.Errorf("'%s' is not an encoded IP", term)
+	}
+	return ip, nil
+}
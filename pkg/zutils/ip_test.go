@@ -0,0 +1,91 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", value: "192.168.1.10", want: "192.168.1.10"},
+		{name: "ipv6", value: "2001:db8::1", want: "2001:db8::1"},
+		{name: "ipv4-mapped", value: "::ffff:10.0.0.1", want: "10.0.0.1"},
+		{name: "invalid", value: "192.168.1", wantErr: true},
+		{name: "type", value: 10.0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := ParseIP(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, ip, net.IPv6len)
+			assert.Equal(t, tt.want, FormatIP(ip))
+		})
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	from, to, err := ParseCIDR("10.1.2.3/16")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.1.0.0", FormatIP(from))
+	assert.Equal(t, "10.1.255.255", FormatIP(to))
+
+	from, to, err = ParseCIDR("2001:db8::/32")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::", FormatIP(from))
+	assert.Equal(t, "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", FormatIP(to))
+
+	_, _, err = ParseCIDR("10.1.2.3/33")
+	assert.Error(t, err)
+}
+
+func TestEncodeIP(t *testing.T) {
+	a, _ := ParseIP("10.0.0.255")
+	b, _ := ParseIP("10.0.1.0")
+	c, _ := ParseIP("2001:db8::1")
+	assert.Equal(t, "00000000000000000000ffff0a0000ff", EncodeIP(a))
+	assert.Less(t, EncodeIP(a), EncodeIP(b))
+	assert.Less(t, EncodeIP(b), EncodeIP(c))
+
+	ip, err := DecodeIP([]byte(EncodeIP(c)))
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", FormatIP(ip))
+	_, err = DecodeIP([]byte("0a000001"))
+	assert.Error(t, err)
+}
+
+func TestNextIP(t *testing.T) {
+	ip, _ := ParseIP("10.0.0.255")
+	next, ok := NextIP(ip)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.1.0", FormatIP(next))
+
+	ip, _ = ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	_, ok = NextIP(ip)
+	assert.False(t, ok)
+}
@@ -142,3 +142,128 @@ func ParseTime(value interface{}, format, timeZone string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+// ParseDateMath parses a date with the date math of Elasticsearch, such as: now-7d/d, now/M, 2022-01-01T00:00:00Z||+1M/d,
+// the anchor date is parsed by ParseTime, a number string is epoch time, the rounding uses the time zone.
+func ParseDateMath(value interface{}, format, timeZone string, now time.Time) (time.Time, error) {
+	s, ok := value.(string)
+	if !ok {
+		return ParseTime(value, format, timeZone)
+	}
+
+	var err error
+	loc := time.UTC
+	if timeZone != "" {
+		loc, err = ParseTimeZone(timeZone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time zone: %s", timeZone)
+		}
+	}
+
+	var t time.Time
+	var expr string
+	switch {
+	case strings.HasPrefix(s, "now"):
+		t, expr = now, s[len("now"):]
+	case strings.Contains(s, "||"):
+		i := strings.Index(s, "||")
+		if t, err = parseDateMathAnchor(s[:i], format, timeZone); err != nil {
+			return time.Time{}, err
+		}
+		expr = s[i+len("||"):]
+	default:
+		return parseDateMathAnchor(s, format, timeZone)
+	}
+
+	t = t.In(loc)
+	for len(expr) > 0 {
+		op := expr[0]
+		expr = expr[1:]
+		n := 1
+		switch op {
+		case '+', '-':
+			i := 0
+			for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+				i++
+			}
+			if i > 0 {
+				n, _ = strconv.Atoi(expr[:i])
+			}
+			if op == '-' {
+				n = -n
+			}
+			expr = expr[i:]
+		case '/':
+		default:
+			return time.Time{}, fmt.Errorf("date math [%s] has unsupported operator [%c]", s, op)
+		}
+		if len(expr) == 0 {
+			return time.Time{}, fmt.Errorf("date math [%s] is missing a time unit", s)
+		}
+		unit := expr[0]
+		expr = expr[1:]
+		if op == '/' {
+			t, err = roundDateMath(t, unit)
+		} else {
+			t, err = addDateMath(t, unit, n)
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date math [%s] %s", s, err.Error())
+		}
+	}
+	return t, nil
+}
+
+func parseDateMathAnchor(s, format, timeZone string) (time.Time, error) {
+	t, err := ParseTime(s, format, timeZone)
+	if err != nil {
+		if n, e := strconv.ParseInt(s, 10, 64); e == nil {
+			return Unix(n), nil
+		}
+	}
+	return t, err
+}
+
+func addDateMath(t time.Time, unit byte, n int) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	default:
+		return time.Time{}, fmt.Errorf("has unsupported time unit [%c]", unit)
+	}
+}
+
+// roundDateMath rounds down the time to the unit, the weeks start on Monday
+func roundDateMath(t time.Time, unit byte) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), nil
+	case 'M':
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	case 'w':
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location()), nil
+	case 'd':
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case 'h', 'H':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case 'm':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()), nil
+	case 's':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location()), nil
+	default:
+		return time.Time{}, fmt.Errorf("has unsupported time unit [%c]", unit)
+	}
+}
@@ -309,3 +309,101 @@ func TestParseTime(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDateMath(t *testing.T) {
+	now := time.Date(2022, 3, 17, 15, 30, 45, 0, time.UTC) // Thursday
+
+	type args struct {
+		value    interface{}
+		format   string
+		timeZone string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "now",
+			args: args{value: "now"},
+			want: now,
+		},
+		{
+			name: "now-7d/d",
+			args: args{value: "now-7d/d"},
+			want: time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now/M",
+			args: args{value: "now/M"},
+			want: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now+1M-1h/h",
+			args: args{value: "now+1M-1h/h"},
+			want: time.Date(2022, 4, 17, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now/w",
+			args: args{value: "now/w"},
+			want: time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now/d in time zone",
+			args: args{value: "now/d", timeZone: "+08:00"},
+			want: time.Date(2022, 3, 16, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "anchor date",
+			args: args{value: "2022-01-31T10:00:00Z||+1d/d"},
+			want: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "anchor date with format",
+			args: args{value: "2022-01-31||/y", format: "2006-01-02"},
+			want: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date",
+			args: args{value: "2022-01-31T10:00:00Z"},
+			want: time.Date(2022, 1, 31, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "epoch_millis number",
+			args: args{value: float64(1643623200000)},
+			want: time.Date(2022, 1, 31, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "epoch_millis string",
+			args: args{value: "1643623200000"},
+			want: time.Date(2022, 1, 31, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "unsupported unit",
+			args:    args{value: "now-1q"},
+			wantErr: true,
+		},
+		{
+			name:    "missing unit",
+			args:    args{value: "now-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			args:    args{value: "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateMath(tt.args.value, tt.args.format, tt.args.timeZone, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want.UnixNano(), got.UnixNano())
+		})
+	}
+}